@@ -0,0 +1,25 @@
+package json
+
+import (
+	"math"
+	"reflect"
+	"strconv"
+)
+
+// AppendString appends s to dst as a JSON string escaped like Marshal does
+// it (NeoCompat with HTML characters escaped). With AppendFloat it allows
+// MarshalJSON methods written by hand or generated by cmd/jsongen to
+// produce the same output as Marshal without reflection.
+func AppendString(dst []byte, s string) []byte {
+	return appendString(dst, s, encOpts{escapeHTML: true})
+}
+
+// AppendFloat appends f to dst formatted like Marshal formats values of
+// float types of the given size (32 or 64 bits). NaN and infinities make
+// it return an UnsupportedValueError.
+func AppendFloat(dst []byte, f float64, bits int) ([]byte, error) {
+	if math.IsInf(f, 0) || math.IsNaN(f) {
+		return dst, &UnsupportedValueError{reflect.ValueOf(f), strconv.FormatFloat(f, 'g', -1, bits)}
+	}
+	return appendFloatJS(dst, f, bits), nil
+}
@@ -0,0 +1,33 @@
+package json
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestAppendString(t *testing.T) {
+	for _, s := range []string{"", "plain", "<a&b>", "é \x01\"\\", "\xff"} {
+		want, _ := Marshal(s)
+		if got := AppendString([]byte("x"), s); string(got) != "x"+string(want) {
+			t.Errorf("%q: got %s, want x%s", s, got, want)
+		}
+	}
+}
+
+func TestAppendFloat(t *testing.T) {
+	for _, f := range []float64{0, -1.5, 1e21, 1e-7, 0.1} {
+		want, _ := Marshal(f)
+		if got, err := AppendFloat(nil, f, 64); err != nil || string(got) != string(want) {
+			t.Errorf("%v: got %s, %v, want %s", f, got, err, want)
+		}
+		want, _ = Marshal(float32(f))
+		if got, err := AppendFloat(nil, float64(float32(f)), 32); err != nil || string(got) != string(want) {
+			t.Errorf("float32 %v: got %s, %v, want %s", f, got, err, want)
+		}
+	}
+	var uve *UnsupportedValueError
+	if _, err := AppendFloat(nil, math.Inf(1), 64); !errors.As(err, &uve) {
+		t.Errorf("Inf: got %v", err)
+	}
+}
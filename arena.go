@@ -0,0 +1,219 @@
+package json
+
+import "unsafe"
+
+// An Arena is a memory region for the values a Decoder creates when
+// decoding into interfaces: OrderedObject and []any slices, object keys,
+// strings and numbers are taken from a few large blocks instead of being
+// allocated one by one. It makes decoding of big documents into any
+// cheaper, while Free makes the whole region available for the next
+// document without leaving work for the garbage collector.
+//
+// Values decoded with an Arena are only valid until Free is called, using
+// them afterwards gives unpredictable results. That includes the strings
+// stored in maps (objects decoded without UseOrderedObject), although the
+// maps themselves are allocated as usual, like big numbers and values
+// stored in typed destinations. An Arena must not be used by several
+// Decoders concurrently.
+type Arena struct {
+	bytes   slab[byte]
+	members slab[Member]
+	values  slab[any]
+	floats  slab[float64]
+	ints    slab[int64]
+	strings slab[string]
+	objects slab[OrderedObject]
+	arrays  slab[[]any]
+
+	// Buffers for members and elements of the objects and arrays being
+	// decoded, one per nesting level.
+	memberBufs [][]Member
+	valueBufs  [][]any
+}
+
+// NewArena returns an empty Arena.
+func NewArena() *Arena {
+	return new(Arena)
+}
+
+// Free releases all the values allocated from a to be reused by the values
+// decoded next. The memory is kept, so an Arena used for documents of
+// similar sizes stops allocating after the first few of them.
+func (a *Arena) Free() {
+	a.bytes.reset()
+	a.members.reset()
+	a.values.reset()
+	a.floats.reset()
+	a.ints.reset()
+	a.strings.reset()
+	a.objects.reset()
+	a.arrays.reset()
+}
+
+// slabMin is the minimal number of items in a block of a slab.
+const slabMin = 64
+
+// slab allocates items of type T from the unused capacity of its buffer,
+// a new buffer twice as large replaces it when it is exhausted. Buffers
+// that are replaced stay alive while there are items referring to them.
+type slab[T any] struct {
+	buf []T
+}
+
+// alloc returns n items with the capacity limited to n, so that appending
+// to them never overwrites the following items.
+func (s *slab[T]) alloc(n int) []T {
+	l := len(s.buf)
+	if cap(s.buf) == 0 || l+n > cap(s.buf) {
+		s.buf = make([]T, 0, max(2*cap(s.buf), n, slabMin))
+		l = 0
+	}
+	s.buf = s.buf[:l+n]
+	return s.buf[l : l+n : l+n]
+}
+
+// reset makes the current buffer available for reuse. It's cleared, so the
+// old items don't keep anything alive.
+func (s *slab[T]) reset() {
+	clear(s.buf)
+	s.buf = s.buf[:0]
+}
+
+// eface is the layout of an empty interface value.
+type eface struct {
+	typ, data unsafe.Pointer
+}
+
+// typeWord returns the type word of the interface value v.
+func typeWord(v any) unsafe.Pointer {
+	return (*eface)(unsafe.Pointer(&v)).typ
+}
+
+var (
+	float64Word       = typeWord(float64(0))
+	int64Word         = typeWord(int64(0))
+	stringWord        = typeWord("")
+	numberWord        = typeWord(Number(""))
+	orderedObjectWord = typeWord(OrderedObject(nil))
+	anySliceWord      = typeWord([]any(nil))
+)
+
+// box returns v as an interface value of the type typ, the value is stored
+// in s instead of being allocated like a conversion to any does.
+func box[T any](s *slab[T], v T, typ unsafe.Pointer) any {
+	p := &s.alloc(1)[0]
+	*p = v
+	var i any
+	e := (*eface)(unsafe.Pointer(&i))
+	e.typ, e.data = typ, unsafe.Pointer(p)
+	return i
+}
+
+// The methods below can be called on a nil Arena, then they allocate their
+// results as usual.
+
+// string returns b as a string.
+func (a *Arena) string(b []byte) string {
+	if a == nil || len(b) == 0 {
+		return string(b)
+	}
+	s := a.bytes.alloc(len(b))
+	copy(s, b)
+	return unsafe.String(&s[0], len(s))
+}
+
+// float returns f as an interface value.
+func (a *Arena) float(f float64) any {
+	if a == nil {
+		return f
+	}
+	return box(&a.floats, f, float64Word)
+}
+
+// int64 returns n as an interface value.
+func (a *Arena) int64(n int64) any {
+	if a == nil {
+		return n
+	}
+	return box(&a.ints, n, int64Word)
+}
+
+// stringValue returns s as an interface value.
+func (a *Arena) stringValue(s string) any {
+	if a == nil {
+		return s
+	}
+	return box(&a.strings, s, stringWord)
+}
+
+// number returns s as a Number interface value.
+func (a *Arena) number(s string) any {
+	if a == nil {
+		return Number(s)
+	}
+	return box(&a.strings, s, numberWord)
+}
+
+// object returns o as an interface value.
+func (a *Arena) object(o OrderedObject) any {
+	if a == nil {
+		return o
+	}
+	return box(&a.objects, o, orderedObjectWord)
+}
+
+// array returns v as an interface value.
+func (a *Arena) array(v []any) any {
+	if a == nil {
+		return v
+	}
+	return box(&a.arrays, v, anySliceWord)
+}
+
+// memberBuf returns an empty buffer for the members of an object at the
+// given nesting level.
+func (a *Arena) memberBuf(level int) []Member {
+	if a == nil {
+		return make([]Member, 0)
+	}
+	for len(a.memberBufs) <= level {
+		a.memberBufs = append(a.memberBufs, nil)
+	}
+	return a.memberBufs[level][:0]
+}
+
+// keepMembers returns v, a buffer of the given level, copied to the arena.
+// The buffer is retained for the next object of this level.
+func (a *Arena) keepMembers(level int, v []Member) OrderedObject {
+	if a == nil {
+		return v
+	}
+	o := a.members.alloc(len(v))
+	copy(o, v)
+	clear(v)
+	a.memberBufs[level] = v[:0]
+	return o
+}
+
+// valueBuf is like memberBuf, but for the elements of an array.
+func (a *Arena) valueBuf(level int) []any {
+	if a == nil {
+		return make([]any, 0)
+	}
+	for len(a.valueBufs) <= level {
+		a.valueBufs = append(a.valueBufs, nil)
+	}
+	return a.valueBufs[level][:0]
+}
+
+// keepValues is like keepMembers, but for the elements of an array.
+func (a *Arena) keepValues(level int, v []any) []any {
+	if a == nil {
+		return v
+	}
+	s := a.values.alloc(len(v))
+	copy(s, v)
+	clear(v)
+	a.valueBufs[level] = v[:0]
+	return s
+}
@@ -0,0 +1,86 @@
+package json
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestArena(t *testing.T) {
+	const in = `{"a": [1, 2.5, "x", true, null, {}, []], "b": {"c": "\u00e9", "d": [[{"e": -1}]]}, "": ""}`
+
+	for _, tc := range []struct {
+		name string
+		set  func(*Decoder)
+	}{
+		{"map", func(*Decoder) {}},
+		{"ordered", (*Decoder).UseOrderedObject},
+		{"number", (*Decoder).UseNumber},
+		{"int64", (*Decoder).UseInt64},
+		{"intern", func(dec *Decoder) { dec.InternStrings(8) }},
+	} {
+		var want any
+		dec := NewDecoder(bytes.NewReader([]byte(in)))
+		tc.set(dec)
+		if err := dec.Decode(&want); err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+
+		a := NewArena()
+		for i := range 3 {
+			var got any
+			dec := NewDecoder(bytes.NewReader([]byte(in)))
+			tc.set(dec)
+			dec.UseArena(a)
+			if err := dec.Decode(&got); err != nil {
+				t.Fatalf("%s: %v", tc.name, err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("%s #%d:\ngot  %#v\nwant %#v", tc.name, i, got, want)
+			}
+			a.Free()
+		}
+	}
+}
+
+func TestArenaOrderedObject(t *testing.T) {
+	a := NewArena()
+	var o OrderedObject
+	dec := NewDecoder(bytes.NewReader([]byte(`{"b": 1, "a": {"x": [2]}} {"c": 3}`)))
+	dec.UseArena(a)
+	if err := dec.Decode(&o); err != nil {
+		t.Fatal(err)
+	}
+	var next OrderedObject
+	if err := dec.Decode(&next); err != nil {
+		t.Fatal(err)
+	}
+	// Values of subsequent documents don't overwrite each other before Free.
+	want := OrderedObject{{"b", 1.0}, {"a", map[string]any{"x": []any{2.0}}}}
+	if !reflect.DeepEqual(o, want) {
+		t.Errorf("got %#v, want %#v", o, want)
+	}
+	if want := (OrderedObject{{"c", 3.0}}); !reflect.DeepEqual(next, want) {
+		t.Errorf("got %#v, want %#v", next, want)
+	}
+}
+
+func TestArenaAllocs(t *testing.T) {
+	data := []byte(`[{"id": 1, "name": "first", "tags": ["a", "b"]}, {"id": 2, "name": "second", "tags": []}]`)
+	a := NewArena()
+	decode := func(a *Arena) {
+		var v any
+		if err := (Options{UseOrderedObject: true, Arena: a}).NewDecoder(bytes.NewReader(data)).Decode(&v); err != nil {
+			t.Fatal(err)
+		}
+		if a != nil {
+			a.Free()
+		}
+	}
+	decode(a)
+	with := testing.AllocsPerRun(10, func() { decode(a) })
+	without := testing.AllocsPerRun(10, func() { decode(nil) })
+	if with >= without/2 {
+		t.Errorf("%v allocations with arena, %v without", with, without)
+	}
+}
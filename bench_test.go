@@ -15,6 +15,7 @@ import (
 	"compress/gzip"
 	"io"
 	"os"
+	"reflect"
 	"strings"
 	"testing"
 )
@@ -109,6 +110,26 @@ func BenchmarkCodeMarshal(b *testing.B) {
 	b.SetBytes(int64(len(codeJSON)))
 }
 
+func BenchmarkCodeMarshalCompiled(b *testing.B) {
+	if codeJSON == nil {
+		b.StopTimer()
+		codeInit()
+		b.StartTimer()
+	}
+	te, err := CompileEncoder(reflect.TypeOf(&codeStruct))
+	if err != nil {
+		b.Fatal("CompileEncoder:", err)
+	}
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if _, err := te.Marshal(&codeStruct); err != nil {
+				b.Fatal("Marshal:", err)
+			}
+		}
+	})
+	b.SetBytes(int64(len(codeJSON)))
+}
+
 func BenchmarkCodeDecoder(b *testing.B) {
 	if codeJSON == nil {
 		b.StopTimer()
@@ -250,3 +271,48 @@ func BenchmarkUnmapped(b *testing.B) {
 		}
 	})
 }
+
+func BenchmarkEncodeString(b *testing.B) {
+	s := strings.Repeat("The quick brown fox jumps over the lazy dog, 0123456789. ", 32)
+	for _, tc := range []struct {
+		name string
+		opts encOpts
+	}{
+		{"NeoCompat", encOpts{escapeHTML: true}},
+		{"GoStd", encOpts{extra: &extraOpts{escaping: GoStd}}},
+	} {
+		b.Run(tc.name, func(b *testing.B) {
+			buf := make([]byte, 0, 2*len(s))
+			b.SetBytes(int64(len(s)))
+			for b.Loop() {
+				buf = appendString(buf[:0], s, tc.opts)
+			}
+		})
+	}
+}
+
+func BenchmarkEncodeOrderedObject(b *testing.B) {
+	var v any = OrderedObject{{"x", 1.5}}
+	for range 20 {
+		v = OrderedObject{{"s", "str"}, {"a", []any{true, nil, 42.0}}, {"o", v}}
+	}
+	b.ReportAllocs()
+	var buf []byte
+	for b.Loop() {
+		buf, _ = AppendMarshal(buf[:0], v)
+	}
+	b.SetBytes(int64(len(buf)))
+}
+
+func BenchmarkEncodeInts(b *testing.B) {
+	v := make([]int64, 1000)
+	for i := range v {
+		v[i] = int64(i*i*7919) - 1e6
+	}
+	b.ReportAllocs()
+	var buf []byte
+	for b.Loop() {
+		buf, _ = AppendMarshal(buf[:0], v)
+	}
+	b.SetBytes(int64(len(buf)))
+}
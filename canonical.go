@@ -0,0 +1,131 @@
+package json
+
+import (
+	"hash"
+	"reflect"
+	"slices"
+)
+
+// CanonicalMode selects the deterministic encoding used by HashValue.
+type CanonicalMode int
+
+const (
+	// CanonicalOrdered is the encoding produced by Marshal, map keys are
+	// sorted and OrderedObject members retain their order.
+	CanonicalOrdered CanonicalMode = iota
+	// CanonicalJCS is the RFC 8785 encoding produced by MarshalCanonical.
+	CanonicalJCS
+)
+
+// MarshalCanonical returns the canonical JSON encoding of v as defined by
+// RFC 8785 (JSON Canonicalization Scheme). It's like Marshal, but object
+// members (including the ones of structs, maps and OrderedObject values)
+// are sorted by their names compared as UTF-16 code units, strings only
+// have the mandatory characters escaped and numbers are formatted as
+// ES6 does it. Integers that can't be represented exactly as IEEE 754
+// double values are rounded. The output of Marshaler values is converted
+// to the canonical form as well.
+//
+// Canonical output is meant for hashing and signing, so it ignores the
+// package escaping mode, but fields with the ",string" option are still
+// quoted.
+func MarshalCanonical(v any) ([]byte, error) {
+	e := &encodeState{}
+	err := e.marshal(v, canonicalOpts)
+	if err != nil {
+		return nil, err
+	}
+	return e.Bytes(), nil
+}
+
+// canonicalOpts are the options of MarshalCanonical.
+var canonicalOpts = encOpts{extra: &extraOpts{escaping: GoStd, canonical: true, keyCmp: CompareUTF16}}
+
+// HashValue writes the encoding of v selected by mode to h and returns
+// h.Sum(nil). The output is passed to h in chunks as it's produced, so the
+// whole encoding is never kept in memory, except for CanonicalJCS objects
+// that have to be complete to be sorted. h is not reset before writing.
+func HashValue(h hash.Hash, v any, mode CanonicalMode) ([]byte, error) {
+	opts := encOpts{escapeHTML: true}
+	if mode == CanonicalJCS {
+		opts = canonicalOpts
+	}
+	e := newEncodeState()
+	defer putEncodeState(e)
+	e.sink = h
+	e.streams = 1 // The whole value is streamed.
+	err := e.marshal(v, opts)
+	if err != nil {
+		return nil, err
+	}
+	h.Write(e.Bytes()) // Hash writes never fail.
+	return h.Sum(nil), nil
+}
+
+// canonicalJSON writes the canonical form of JSON b produced by a Marshaler.
+func (e *encodeState) canonicalJSON(b []byte, opts encOpts) error {
+	var d decodeState
+	if err := checkValid(b, &d.scan); err != nil {
+		return err
+	}
+	d.init(b)
+	d.useNumber = true
+	d.useOrderedObject = true
+	var v any
+	if err := d.unmarshal(&v); err != nil {
+		return err
+	}
+	e.reflectValue(reflect.ValueOf(v), opts)
+	return nil
+}
+
+// sortMembers sorts members of the compact object written to e starting at
+// the offset start by their names as RFC 8785 requires it.
+func (e *encodeState) sortMembers(start int) {
+	e.pinned--
+	data := e.Bytes()[start:]
+	members := objectMembers(data)
+	byKey := func(a, b rawMember) int { return CompareUTF16(a.key, b.key) }
+	if slices.IsSortedFunc(members, byKey) {
+		return
+	}
+	slices.SortStableFunc(members, byKey)
+	writeMembers(data, members)
+}
+
+// rawMember is a member of a compact object.
+type rawMember struct {
+	key   string
+	raw   []byte // the member text starting with the key
+	value int    // the offset of the value in raw
+}
+
+// objectMembers returns the members of the compact object data.
+func objectMembers(data []byte) []rawMember {
+	var members []rawMember
+	for off := 1; data[off] != '}'; {
+		end := skipString(data, off)
+		key, _ := unquote(data[off:end])
+		next := skipValue(data, end+1)
+		members = append(members, rawMember{key, data[off:next], end + 1 - off})
+		off = next
+		if data[off] == ',' {
+			off++
+		}
+	}
+	return members
+}
+
+// writeMembers replaces the members of the compact object data with
+// members (which are its members in a different order).
+func writeMembers(data []byte, members []rawMember) {
+	b := make([]byte, 1, len(data))
+	b[0] = '{'
+	for i, m := range members {
+		if i > 0 {
+			b = append(b, ',')
+		}
+		b = append(b, m.raw...)
+	}
+	copy(data, append(b, '}'))
+}
@@ -0,0 +1,114 @@
+package json
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"hash"
+	"math"
+	"strings"
+	"testing"
+)
+
+type canonicalMarshaler struct{}
+
+func (canonicalMarshaler) MarshalJSON() ([]byte, error) {
+	return []byte(`{"b": [1E3, 0.10] , "a": "\u00e9"}`), nil
+}
+
+func TestMarshalCanonical(t *testing.T) {
+	type inner struct {
+		Z string
+		A float64
+	}
+	type T struct {
+		Zeta  int
+		Inner inner `json:"inner"`
+		Raw   canonicalMarshaler
+		Big   int64
+		Num   Number
+		Rest  map[string]string `json:",inline"`
+	}
+	v := T{
+		Zeta:  1,
+		Inner: inner{"z", 1e21},
+		Big:   1<<60 + 1,
+		Num:   "1e-7",
+		Rest:  map[string]string{"a": "/", "\u20ac": "<&>\u2028", "\U0001F600": "\x01\n\"\\\xff"},
+	}
+	got, err := MarshalCanonical(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"Big":1152921504606847000,"Num":1e-7,"Raw":{"a":"é","b":[1000,0.1]},"Zeta":1,"a":"/",` +
+		`"inner":{"A":1e+21,"Z":"z"},"€":"<&>` + "\u2028" + `","😀":"\u0001\n\"\\` + "\ufffd" + `"}`
+	if string(got) != want {
+		t.Errorf("got  %s\nwant %s", got, want)
+	}
+
+	for _, tc := range []struct {
+		in   any
+		want string
+	}{
+		{OrderedObject{{"b", 1}, {"a", OrderedObject{{"d", -0.0}, {"c", 5e-324}}}}, `{"a":{"c":5e-324,"d":0},"b":1}`},
+		{map[string]float64{"\U00010000": 1, "\uff01": 2}, `{"𐀀":1,"！":2}`},
+		{[]any{uint64(math.MaxUint64), 333333333.33333329, "/"}, `[18446744073709552000,333333333.3333333,"/"]`},
+	} {
+		got, err := MarshalCanonical(tc.in)
+		if err != nil {
+			t.Errorf("%v: %v", tc.in, err)
+			continue
+		}
+		if string(got) != tc.want {
+			t.Errorf("%v: got %s, want %s", tc.in, got, tc.want)
+		}
+	}
+
+	if _, err := MarshalCanonical(math.NaN()); err == nil {
+		t.Error("NaN: no error")
+	}
+}
+
+type countingHash struct {
+	hash.Hash
+	writes int
+}
+
+func (h *countingHash) Write(p []byte) (int, error) {
+	h.writes++
+	return h.Hash.Write(p)
+}
+
+func TestHashValue(t *testing.T) {
+	big := make([]OrderedObject, 1000)
+	for i := range big {
+		big[i] = OrderedObject{{"b", strings.Repeat("x", 10)}, {"a", i}}
+	}
+	for _, tc := range []struct {
+		mode    CanonicalMode
+		marshal func(any) ([]byte, error)
+	}{
+		{CanonicalOrdered, Marshal},
+		{CanonicalJCS, MarshalCanonical},
+	} {
+		h := &countingHash{Hash: sha256.New()}
+		got, err := HashValue(h, big, tc.mode)
+		if err != nil {
+			t.Fatalf("mode %d: %v", tc.mode, err)
+		}
+		b, err := tc.marshal(big)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := sha256.Sum256(b)
+		if !bytes.Equal(got, want[:]) {
+			t.Errorf("mode %d: got hash %x, want %x", tc.mode, got, want)
+		}
+		if h.writes < 2 {
+			t.Errorf("mode %d: output written in %d chunks", tc.mode, h.writes)
+		}
+	}
+
+	if _, err := HashValue(sha256.New(), math.Inf(1), CanonicalJCS); err == nil {
+		t.Error("Inf: no error")
+	}
+}
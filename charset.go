@@ -0,0 +1,165 @@
+package json
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// Input encodings recognized by charsetReader.
+const (
+	charsetUTF8 = iota
+	charsetUTF16BE
+	charsetUTF16LE
+	charsetUTF32BE
+	charsetUTF32LE
+)
+
+// charsetReader detects the encoding of JSON text by its byte order mark or
+// using the RFC 4627 heuristics (the first two characters of the text are
+// ASCII, so the pattern of zero bytes tells the encoding) and converts it to
+// UTF-8 without the BOM.
+type charsetReader struct {
+	r        io.Reader
+	charset  int
+	detected bool
+	in       []byte // input not converted yet
+	out      []byte // converted data not returned yet
+	err      error
+}
+
+var boms = []struct {
+	bom     []byte
+	charset int
+}{ // UTF-32LE goes before UTF-16LE, it has the same prefix.
+	{[]byte{0x00, 0x00, 0xFE, 0xFF}, charsetUTF32BE},
+	{[]byte{0xFF, 0xFE, 0x00, 0x00}, charsetUTF32LE},
+	{[]byte{0xFE, 0xFF}, charsetUTF16BE},
+	{[]byte{0xFF, 0xFE}, charsetUTF16LE},
+	{[]byte{0xEF, 0xBB, 0xBF}, charsetUTF8},
+}
+
+func (c *charsetReader) detect() {
+	c.detected = true
+	var head [4]byte
+	n, err := io.ReadFull(c.r, head[:])
+	if err != nil {
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			err = io.EOF
+		}
+		c.err = err
+	}
+	charset, bom := detectCharset(head[:n])
+	c.charset = charset
+	c.in = append(c.in, head[bom:n]...)
+}
+
+// detectCharset returns the encoding of JSON text starting with head (its
+// first four bytes or less) and the length of its byte order mark.
+func detectCharset(head []byte) (charset, bomLen int) {
+	for _, bom := range boms {
+		if bytes.HasPrefix(head, bom.bom) {
+			return bom.charset, len(bom.bom)
+		}
+	}
+	b, n := head, len(head)
+	switch {
+	case n == 4 && b[0] == 0 && b[1] == 0 && b[2] == 0 && b[3] != 0:
+		return charsetUTF32BE, 0
+	case n == 4 && b[0] != 0 && b[1] == 0 && b[2] == 0 && b[3] == 0:
+		return charsetUTF32LE, 0
+	case n >= 2 && b[0] == 0 && b[1] != 0:
+		return charsetUTF16BE, 0
+	case n >= 2 && b[0] != 0 && b[1] == 0:
+		return charsetUTF16LE, 0
+	}
+	return charsetUTF8, 0
+}
+
+// convertCharset returns JSON text data converted to UTF-8 without the
+// BOM, converted is false if that's data itself or a part of it.
+func convertCharset(data []byte) (out []byte, converted bool) {
+	charset, bom := detectCharset(data[:min(len(data), 4)])
+	if charset == charsetUTF8 {
+		return data[bom:], false
+	}
+	out, _ = io.ReadAll(&charsetReader{r: bytes.NewReader(data)}) // Reading from bytes never fails.
+	return out, true
+}
+
+func (c *charsetReader) Read(p []byte) (int, error) {
+	if !c.detected {
+		c.detect()
+	}
+	for len(c.out) == 0 {
+		if c.charset == charsetUTF8 {
+			if len(c.in) > 0 {
+				c.out, c.in = c.in, nil
+				break
+			}
+			if c.err != nil {
+				return 0, c.err
+			}
+			return c.r.Read(p)
+		}
+		if c.err != nil {
+			if len(c.in) == 0 {
+				return 0, c.err
+			}
+			// Incomplete code unit at the end of input.
+			c.convert()
+			c.out = utf8.AppendRune(c.out, utf8.RuneError)
+			c.in = c.in[:0]
+			break
+		}
+		var buf [4096]byte
+		n, err := c.r.Read(buf[:])
+		c.in = append(c.in, buf[:n]...)
+		c.err = err
+		c.convert()
+	}
+	n := copy(p, c.out)
+	c.out = c.out[n:]
+	return n, nil
+}
+
+// convert converts all complete characters from c.in to c.out.
+func (c *charsetReader) convert() {
+	var order binary.ByteOrder = binary.BigEndian
+	if c.charset == charsetUTF16LE || c.charset == charsetUTF32LE {
+		order = binary.LittleEndian
+	}
+	in := c.in
+	if c.charset == charsetUTF32BE || c.charset == charsetUTF32LE {
+		for ; len(in) >= 4; in = in[4:] {
+			r := rune(order.Uint32(in))
+			if !utf8.ValidRune(r) {
+				r = utf8.RuneError
+			}
+			c.out = utf8.AppendRune(c.out, r)
+		}
+	} else {
+		for len(in) >= 2 {
+			r := rune(order.Uint16(in))
+			if utf16.IsSurrogate(r) {
+				if len(in) < 4 && c.err == nil {
+					break // Wait for the rest of the pair.
+				}
+				if len(in) >= 4 {
+					if dec := utf16.DecodeRune(r, rune(order.Uint16(in[2:]))); dec != utf8.RuneError {
+						c.out = utf8.AppendRune(c.out, dec)
+						in = in[4:]
+						continue
+					}
+				}
+				r = utf8.RuneError
+			}
+			c.out = utf8.AppendRune(c.out, r)
+			in = in[2:]
+		}
+	}
+	c.in = append(c.in[:0], in...)
+}
@@ -0,0 +1,80 @@
+package json
+
+import (
+	"bytes"
+	"encoding/binary"
+	"reflect"
+	"testing"
+	"testing/iotest"
+	"unicode/utf16"
+)
+
+func TestDetectEncoding(t *testing.T) {
+	const in = `{"name": "Ива😀"} 1`
+	encode := func(order binary.AppendByteOrder, wide bool) []byte {
+		var b []byte
+		if wide {
+			for _, r := range in {
+				b = order.AppendUint32(b, uint32(r))
+			}
+			return b
+		}
+		for _, u := range utf16.Encode([]rune(in)) {
+			b = order.AppendUint16(b, u)
+		}
+		return b
+	}
+	for name, data := range map[string][]byte{
+		"UTF-8":        []byte(in),
+		"UTF-8 BOM":    append([]byte{0xEF, 0xBB, 0xBF}, in...),
+		"UTF-16BE":     encode(binary.BigEndian, false),
+		"UTF-16LE":     encode(binary.LittleEndian, false),
+		"UTF-16BE BOM": append([]byte{0xFE, 0xFF}, encode(binary.BigEndian, false)...),
+		"UTF-16LE BOM": append([]byte{0xFF, 0xFE}, encode(binary.LittleEndian, false)...),
+		"UTF-32BE":     encode(binary.BigEndian, true),
+		"UTF-32LE":     encode(binary.LittleEndian, true),
+		"UTF-32BE BOM": append([]byte{0, 0, 0xFE, 0xFF}, encode(binary.BigEndian, true)...),
+		"UTF-32LE BOM": append([]byte{0xFF, 0xFE, 0, 0}, encode(binary.LittleEndian, true)...),
+	} {
+		for _, oneByte := range []bool{false, true} {
+			r := bytes.NewReader(data)
+			dec := NewDecoder(r)
+			if oneByte {
+				dec = NewDecoder(iotest.OneByteReader(r))
+			}
+			dec.DetectEncoding()
+			var v map[string]string
+			if err := dec.Decode(&v); err != nil || !reflect.DeepEqual(v, map[string]string{"name": "Ива😀"}) {
+				t.Errorf("%s: got %q, %v", name, v, err)
+				continue
+			}
+			var n int
+			if err := dec.Decode(&n); err != nil || n != 1 {
+				t.Errorf("%s: got %d, %v", name, n, err)
+			}
+		}
+		orig := bytes.Clone(data)
+		dec := Options{DetectEncoding: true}.NewBytesDecoder(data)
+		var v map[string]string
+		var n int
+		if err := dec.Decode(&v); err != nil || !reflect.DeepEqual(v, map[string]string{"name": "Ива😀"}) {
+			t.Errorf("%s: NewBytesDecoder: got %q, %v", name, v, err)
+		} else if err := dec.Decode(&n); err != nil || n != 1 {
+			t.Errorf("%s: NewBytesDecoder: got %d, %v", name, n, err)
+		}
+		if !bytes.Equal(data, orig) {
+			t.Errorf("%s: NewBytesDecoder changed data to %q", name, data)
+		}
+	}
+
+	dec := NewDecoder(bytes.NewReader([]byte{0x31, 0x00}))
+	dec.DetectEncoding()
+	var n int
+	if err := dec.Decode(&n); err != nil || n != 1 {
+		t.Errorf("short UTF-16LE: got %d, %v", n, err)
+	}
+	var v any
+	if err := NewDecoder(bytes.NewReader([]byte{0xEF, 0xBB, 0xBF, '1'})).Decode(&v); err == nil {
+		t.Error("BOM is skipped by default")
+	}
+}
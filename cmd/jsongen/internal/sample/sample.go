@@ -0,0 +1,39 @@
+// Package sample has types with methods generated by jsongen for its tests.
+package sample
+
+import (
+	"math/big"
+
+	json "github.com/nspcc-dev/go-ordered-json"
+)
+
+//go:generate go run ../.. -type Transfer,Account,Empty
+
+// Transfer has fields of all the kinds jsongen handles.
+type Transfer struct {
+	From    string   `json:"from"`
+	To      string   `json:"to,omitempty"`
+	Amount  uint64   `json:"amount,string"`
+	Fee     int32    `json:"fee,omitempty"`
+	Rate    float64  `json:"rate"`
+	Ratio   float32  `json:"ratio,omitempty"`
+	OK      bool     `json:"ok,string"`
+	Memo    string   `json:"memo,string,omitempty"`
+	Big     *big.Int `json:"big,omitempty"`
+	Tags    []string `json:"tags,omitempty"`
+	Extra   json.OrderedObject
+	Sender  Account  `json:"sender"`
+	Payee   *Account `json:"payee,omitempty"`
+	ID      int      `json:"id,order=-1"`
+	private int
+	Skipped int `json:"-"`
+}
+
+// Account is a generated type used by Transfer.
+type Account struct {
+	Address string `json:"address"`
+	Balance int64  `json:"balance,omitempty"`
+}
+
+// Empty has no fields.
+type Empty struct{}
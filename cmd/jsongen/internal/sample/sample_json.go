@@ -0,0 +1,362 @@
+// Code generated by jsongen; DO NOT EDIT.
+
+package sample
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+
+	json "github.com/nspcc-dev/go-ordered-json"
+)
+
+// MarshalJSON implements the json.Marshaler interface.
+func (v Transfer) MarshalJSON() ([]byte, error) {
+	return v.AppendJSON(make([]byte, 0, 226))
+}
+
+// AppendJSON implements the json.MarshalerAppend interface.
+func (v Transfer) AppendJSON(b []byte) ([]byte, error) {
+	var err error
+	b = append(b, '{')
+	b = append(b, "\"id\":"...)
+	b = strconv.AppendInt(b, int64(v.ID), 10)
+	b = append(b, ",\"from\":"...)
+	b = json.AppendString(b, v.From)
+	if v.To != "" {
+		b = append(b, ",\"to\":"...)
+		b = json.AppendString(b, v.To)
+	}
+	b = append(b, ",\"amount\":"...)
+	b = append(b, '"')
+	b = strconv.AppendUint(b, uint64(v.Amount), 10)
+	b = append(b, '"')
+	if v.Fee != 0 {
+		b = append(b, ",\"fee\":"...)
+		b = strconv.AppendInt(b, int64(v.Fee), 10)
+	}
+	b = append(b, ",\"rate\":"...)
+	if b, err = json.AppendFloat(b, float64(v.Rate), 64); err != nil {
+		return nil, err
+	}
+	if v.Ratio != 0 {
+		b = append(b, ",\"ratio\":"...)
+		if b, err = json.AppendFloat(b, float64(v.Ratio), 32); err != nil {
+			return nil, err
+		}
+	}
+	b = append(b, ",\"ok\":"...)
+	b = append(b, '"')
+	b = strconv.AppendBool(b, v.OK)
+	b = append(b, '"')
+	if v.Memo != "" {
+		b = append(b, ",\"memo\":"...)
+		b = json.AppendString(b, string(json.AppendString(nil, v.Memo)))
+	}
+	if v.Big != nil {
+		b = append(b, ",\"big\":"...)
+		if b, err = json.AppendMarshal(b, v.Big); err != nil {
+			return nil, err
+		}
+	}
+	if len(v.Tags) != 0 {
+		b = append(b, ",\"tags\":"...)
+		if b, err = json.AppendMarshal(b, v.Tags); err != nil {
+			return nil, err
+		}
+	}
+	b = append(b, ",\"Extra\":"...)
+	if b, err = json.AppendMarshal(b, v.Extra); err != nil {
+		return nil, err
+	}
+	b = append(b, ",\"sender\":"...)
+	if b, err = v.Sender.AppendJSON(b); err != nil {
+		return nil, err
+	}
+	if v.Payee != nil {
+		b = append(b, ",\"payee\":"...)
+		if b, err = v.Payee.AppendJSON(b); err != nil {
+			return nil, err
+		}
+	}
+	return append(b, '}'), nil
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface.
+func (v *Transfer) UnmarshalJSON(data []byte) error {
+	var l json.Lexer
+	l.Reset(data)
+	if err := v.unmarshalLexer(&l); err != nil {
+		return err
+	}
+	return l.End()
+}
+
+// unmarshalLexer decodes the next value read by l into v, errors of l
+// are left in it.
+func (v *Transfer) unmarshalLexer(l *json.Lexer) error {
+	if l.Null() || !l.Object(reflect.TypeFor[Transfer]()) {
+		return nil
+	}
+	for l.More() {
+		key := l.Key()
+		i := -1
+		switch string(key) {
+		case "id":
+			i = 0
+		case "from":
+			i = 1
+		case "to":
+			i = 2
+		case "amount":
+			i = 3
+		case "fee":
+			i = 4
+		case "rate":
+			i = 5
+		case "ratio":
+			i = 6
+		case "ok":
+			i = 7
+		case "memo":
+			i = 8
+		case "big":
+			i = 9
+		case "tags":
+			i = 10
+		case "Extra":
+			i = 11
+		case "sender":
+			i = 12
+		case "payee":
+			i = 13
+		}
+		if i < 0 {
+			switch {
+			case strings.EqualFold(string(key), "id"):
+				i = 0
+			case strings.EqualFold(string(key), "from"):
+				i = 1
+			case strings.EqualFold(string(key), "to"):
+				i = 2
+			case strings.EqualFold(string(key), "amount"):
+				i = 3
+			case strings.EqualFold(string(key), "fee"):
+				i = 4
+			case strings.EqualFold(string(key), "rate"):
+				i = 5
+			case strings.EqualFold(string(key), "ratio"):
+				i = 6
+			case strings.EqualFold(string(key), "ok"):
+				i = 7
+			case strings.EqualFold(string(key), "memo"):
+				i = 8
+			case strings.EqualFold(string(key), "big"):
+				i = 9
+			case strings.EqualFold(string(key), "tags"):
+				i = 10
+			case strings.EqualFold(string(key), "Extra"):
+				i = 11
+			case strings.EqualFold(string(key), "sender"):
+				i = 12
+			case strings.EqualFold(string(key), "payee"):
+				i = 13
+			}
+		}
+		switch i {
+		case 0:
+			if !l.Null() {
+				v.ID = int(l.ReadInt(0))
+			}
+		case 1:
+			if !l.Null() {
+				v.From = l.ReadString()
+			}
+		case 2:
+			if !l.Null() {
+				v.To = l.ReadString()
+			}
+		case 3:
+			if s := l.Quoted(); s != nil {
+				var q json.Lexer
+				q.Reset(s)
+				if !q.Null() {
+					v.Amount = q.ReadUint(64)
+				}
+				if err := q.End(); err != nil {
+					return err
+				}
+			}
+		case 4:
+			if !l.Null() {
+				v.Fee = int32(l.ReadInt(32))
+			}
+		case 5:
+			if !l.Null() {
+				v.Rate = l.ReadFloat(64)
+			}
+		case 6:
+			if !l.Null() {
+				v.Ratio = float32(l.ReadFloat(32))
+			}
+		case 7:
+			if s := l.Quoted(); s != nil {
+				var q json.Lexer
+				q.Reset(s)
+				if !q.Null() {
+					v.OK = q.ReadBool()
+				}
+				if err := q.End(); err != nil {
+					return err
+				}
+			}
+		case 8:
+			if s := l.Quoted(); s != nil {
+				var q json.Lexer
+				q.Reset(s)
+				if !q.Null() {
+					v.Memo = q.ReadString()
+				}
+				if err := q.End(); err != nil {
+					return err
+				}
+			}
+		case 9:
+			if raw := l.Raw(); raw != nil {
+				if err := json.Unmarshal(raw, &v.Big); err != nil {
+					return err
+				}
+			}
+		case 10:
+			if raw := l.Raw(); raw != nil {
+				if err := json.Unmarshal(raw, &v.Tags); err != nil {
+					return err
+				}
+			}
+		case 11:
+			if raw := l.Raw(); raw != nil {
+				if err := json.Unmarshal(raw, &v.Extra); err != nil {
+					return err
+				}
+			}
+		case 12:
+			if err := v.Sender.unmarshalLexer(l); err != nil {
+				return err
+			}
+		case 13:
+			if l.Null() {
+				v.Payee = nil
+			} else {
+				if v.Payee == nil {
+					v.Payee = new(Account)
+				}
+				if err := v.Payee.unmarshalLexer(l); err != nil {
+					return err
+				}
+			}
+		default:
+			l.Skip()
+		}
+	}
+	return nil
+}
+
+// MarshalJSON implements the json.Marshaler interface.
+func (v Account) MarshalJSON() ([]byte, error) {
+	return v.AppendJSON(make([]byte, 0, 34))
+}
+
+// AppendJSON implements the json.MarshalerAppend interface.
+func (v Account) AppendJSON(b []byte) ([]byte, error) {
+	b = append(b, '{')
+	b = append(b, "\"address\":"...)
+	b = json.AppendString(b, v.Address)
+	if v.Balance != 0 {
+		b = append(b, ",\"balance\":"...)
+		b = strconv.AppendInt(b, int64(v.Balance), 10)
+	}
+	return append(b, '}'), nil
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface.
+func (v *Account) UnmarshalJSON(data []byte) error {
+	var l json.Lexer
+	l.Reset(data)
+	if err := v.unmarshalLexer(&l); err != nil {
+		return err
+	}
+	return l.End()
+}
+
+// unmarshalLexer decodes the next value read by l into v, errors of l
+// are left in it.
+func (v *Account) unmarshalLexer(l *json.Lexer) error {
+	if l.Null() || !l.Object(reflect.TypeFor[Account]()) {
+		return nil
+	}
+	for l.More() {
+		key := l.Key()
+		i := -1
+		switch string(key) {
+		case "address":
+			i = 0
+		case "balance":
+			i = 1
+		}
+		if i < 0 {
+			switch {
+			case strings.EqualFold(string(key), "address"):
+				i = 0
+			case strings.EqualFold(string(key), "balance"):
+				i = 1
+			}
+		}
+		switch i {
+		case 0:
+			if !l.Null() {
+				v.Address = l.ReadString()
+			}
+		case 1:
+			if !l.Null() {
+				v.Balance = l.ReadInt(64)
+			}
+		default:
+			l.Skip()
+		}
+	}
+	return nil
+}
+
+// MarshalJSON implements the json.Marshaler interface.
+func (v Empty) MarshalJSON() ([]byte, error) {
+	return v.AppendJSON(make([]byte, 0, 2))
+}
+
+// AppendJSON implements the json.MarshalerAppend interface.
+func (v Empty) AppendJSON(b []byte) ([]byte, error) {
+	b = append(b, '{')
+	return append(b, '}'), nil
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface.
+func (v *Empty) UnmarshalJSON(data []byte) error {
+	var l json.Lexer
+	l.Reset(data)
+	if err := v.unmarshalLexer(&l); err != nil {
+		return err
+	}
+	return l.End()
+}
+
+// unmarshalLexer decodes the next value read by l into v, errors of l
+// are left in it.
+func (v *Empty) unmarshalLexer(l *json.Lexer) error {
+	if l.Null() || !l.Object(reflect.TypeFor[Empty]()) {
+		return nil
+	}
+	for l.More() {
+		l.Key()
+		l.Skip()
+	}
+	return nil
+}
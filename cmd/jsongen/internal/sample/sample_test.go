@@ -0,0 +1,121 @@
+package sample
+
+import (
+	"math"
+	"math/big"
+	"reflect"
+	"testing"
+
+	json "github.com/nspcc-dev/go-ordered-json"
+)
+
+// plain is Transfer without the generated methods.
+type plain Transfer
+
+func TestMarshal(t *testing.T) {
+	for _, v := range []Transfer{
+		{},
+		{From: "a<b>\u00e9", To: "c", Amount: math.MaxUint64, Fee: -3, Rate: 1e21, Ratio: 0.1, OK: true, Memo: `"q"`, Big: big.NewInt(5), Tags: []string{"x"},
+			Extra: json.OrderedObject{{Key: "z", Value: 1}, {Key: "a", Value: nil}}, Sender: Account{"s", 1}, Payee: &Account{Address: "p"},
+			ID: 7, private: 1, Skipped: 2},
+	} {
+		want, err := json.Marshal(plain(v))
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := json.Marshal(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != string(want) {
+			t.Errorf("got  %s\nwant %s", got, want)
+		}
+	}
+	if _, err := json.Marshal(Transfer{Rate: math.NaN()}); err == nil {
+		t.Error("no error for NaN")
+	}
+	if b, err := json.Marshal(Empty{}); err != nil || string(b) != "{}" {
+		t.Errorf("Empty: got %s, %v", b, err)
+	}
+}
+
+func TestUnmarshal(t *testing.T) {
+	for _, in := range []string{
+		`{"from": "a\u00e9\n", "TO": "b", "amount": "18446744073709551615", "fee": -3, "rate": 1.5e3, "ratio": 0.25,
+			"ok": "true", "memo": "\"m\"", "big": 12345678901234567890, "tags": ["x"], "extra": {"z": 1}, "id": 7, "unknown": [1, {}],
+			"Skipped": 2, "private": 3, "sender": {"address": "s", "BALANCE": 1}, "payee": {"address": "p"}}`,
+		`{"sender": null, "payee": null}`,
+		`{"sender": {"balance": "1"}}`,
+		`{"payee": []}`,
+		`{"sender": {"address": "s",}}`,
+		`{"from": null, "amount": null, "ok": null, "fee": null}`,
+		`{"fee": 1e2}`,
+		`{"fee": 2147483648}`,
+		`{"id": 4294967296}`,
+		`{"id": 9223372036854775808}`,
+		`{"sender": {"balance": 9223372036854775808}}`,
+		`{"amount": 1}`,
+		`{"amount": "x"}`,
+		`{"ok": "yes"}`,
+		`{"from": 1}`,
+		`{"from": "\xff"}`,
+		`{"memo": "m"}`,
+		`null`,
+		`[]`,
+	} {
+		var want plain
+		wantErr := json.Unmarshal([]byte(in), &want)
+		var got Transfer
+		err := json.Unmarshal([]byte(in), &got)
+		if (err != nil) != (wantErr != nil) {
+			t.Errorf("%s: got error %v, want %v", in, err, wantErr)
+			continue
+		}
+		if err == nil && !reflect.DeepEqual(plain(got), want) {
+			t.Errorf("%s:\ngot  %+v\nwant %+v", in, got, want)
+		}
+	}
+}
+
+var sample = Transfer{From: "NbMJqYtfHNEnejghbgBMrZpihXhiY4eJhW", To: "NfgHwwTi3wHAS8aFAN243C5vGbkYDpqLHP", Amount: 100000000, Fee: 1000,
+	Rate: 0.5, OK: true, Memo: "payment", Tags: []string{"neo", "gas"}, Sender: Account{"NbMJqYtfHNEnejghbgBMrZpihXhiY4eJhW", 5}, ID: 1}
+
+func BenchmarkMarshal(b *testing.B) {
+	b.Run("generated", func(b *testing.B) {
+		for b.Loop() {
+			if _, err := json.Marshal(sample); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("reflection", func(b *testing.B) {
+		for b.Loop() {
+			if _, err := json.Marshal(plain(sample)); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func BenchmarkUnmarshal(b *testing.B) {
+	data, err := json.Marshal(sample)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.Run("generated", func(b *testing.B) {
+		for b.Loop() {
+			var v Transfer
+			if err := v.UnmarshalJSON(data); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("reflection", func(b *testing.B) {
+		for b.Loop() {
+			var v plain
+			if err := json.Unmarshal(data, &v); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
@@ -0,0 +1,504 @@
+// Command jsongen generates MarshalJSON and UnmarshalJSON methods for
+// struct types, so they're encoded and decoded like the json package does
+// it by default (with NeoCompat escaping and fields in their order), but
+// without reflection for fields of basic types.
+//
+// Usage:
+//
+//	//go:generate go run github.com/nspcc-dev/go-ordered-json/cmd/jsongen [-type T,U] [-output file] [dir]
+//
+// Struct types are selected by the -type flag or, if there is none, by the
+// "//jsongen:generate" line in their doc comments. The methods are written
+// to the file given by -output, by default it's the name of the file with
+// the go:generate directive with a "_json.go" suffix. The "omitempty",
+// "string" and "order" tag options are supported, other options and
+// embedded fields are reported as errors. The generated code appends the
+// encoding to a byte slice (MarshalJSON is AppendJSON of the
+// json.MarshalerAppend interface) and reads input with json.Lexer. Fields
+// of types generated in the same run (and pointers to them) use the
+// generated methods directly, fields of other than basic types (including
+// named ones) are encoded and decoded with AppendMarshal and Unmarshal, so
+// their own methods are used.
+//
+// Unlike Unmarshal, UnmarshalJSON returns the first error it finds without
+// decoding the rest of the object.
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"log"
+	"maps"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+
+	json "github.com/nspcc-dev/go-ordered-json"
+)
+
+const directive = "//jsongen:generate"
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("jsongen: ")
+	typeNames := flag.String("type", "", "comma-separated list of type names")
+	output := flag.String("output", "", "output file name (default <GOFILE>_json.go)")
+	flag.Parse()
+
+	dir := "."
+	if flag.NArg() > 0 {
+		dir = flag.Arg(0)
+	}
+	out := *output
+	if out == "" {
+		base := strings.TrimSuffix(os.Getenv("GOFILE"), ".go")
+		if base == "" {
+			base = "jsongen"
+		}
+		out = filepath.Join(dir, base+"_json.go")
+	}
+	var names []string
+	if *typeNames != "" {
+		names = strings.Split(*typeNames, ",")
+	}
+	src, err := generate(dir, names, filepath.Base(out))
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := os.WriteFile(out, src, 0o644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// Kinds of fields.
+const (
+	kindOther = iota
+	kindString
+	kindBool
+	kindInt
+	kindUint
+	kindFloat
+)
+
+// basicKinds maps names of the predeclared types to their kinds and sizes,
+// 0 is the size of int which depends on the target.
+var basicKinds = map[string]struct{ kind, bits int }{
+	"string": {kindString, 0},
+	"bool":   {kindBool, 0},
+	"int":    {kindInt, 0}, "int8": {kindInt, 8}, "int16": {kindInt, 16}, "int32": {kindInt, 32}, "int64": {kindInt, 64},
+	"rune": {kindInt, 32},
+	"uint": {kindUint, 0}, "uint8": {kindUint, 8}, "uint16": {kindUint, 16}, "uint32": {kindUint, 32}, "uint64": {kindUint, 64},
+	"uintptr": {kindUint, 0}, "byte": {kindUint, 8},
+	"float32": {kindFloat, 32}, "float64": {kindFloat, 64},
+}
+
+type genField struct {
+	goName    string
+	name      string // member name
+	typ       string // type expression
+	kind      int
+	bits      int
+	omitEmpty string // condition for writing the field if it has "omitempty"
+	quoted    bool
+	order     int
+	ordered   bool
+}
+
+type genType struct {
+	name   string
+	fields []genField
+}
+
+// generate returns the source of the methods for the selected types of the
+// package in dir, the file named skip (previous output) is not read.
+func generate(dir string, names []string, skip string) ([]byte, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.go"))
+	if err != nil {
+		return nil, err
+	}
+	fset := token.NewFileSet()
+	var (
+		pkg   string
+		specs = make(map[string]*ast.TypeSpec)
+		order []string // type names in source order
+	)
+	for _, path := range files {
+		if strings.HasSuffix(path, "_test.go") || filepath.Base(path) == skip {
+			continue
+		}
+		f, err := parser.ParseFile(fset, path, nil, parser.ParseComments)
+		if err != nil {
+			return nil, err
+		}
+		pkg = f.Name.Name
+		for _, decl := range f.Decls {
+			gd, ok := decl.(*ast.GenDecl)
+			if !ok || gd.Tok != token.TYPE {
+				continue
+			}
+			for _, s := range gd.Specs {
+				ts := s.(*ast.TypeSpec)
+				doc := ts.Doc
+				if doc == nil && len(gd.Specs) == 1 {
+					doc = gd.Doc
+				}
+				specs[ts.Name.Name] = ts
+				if names == nil && hasDirective(doc) {
+					order = append(order, ts.Name.Name)
+				}
+			}
+		}
+	}
+	if names != nil {
+		order = names
+	}
+	if len(order) == 0 {
+		return nil, errors.New("no types to generate methods for")
+	}
+
+	var types []genType
+	for _, name := range order {
+		ts, ok := specs[name]
+		if !ok {
+			return nil, fmt.Errorf("type %s not found", name)
+		}
+		st, ok := ts.Type.(*ast.StructType)
+		if !ok || ts.TypeParams != nil {
+			return nil, fmt.Errorf("type %s is not a non-generic struct", name)
+		}
+		fields, err := structFields(st)
+		if err != nil {
+			return nil, fmt.Errorf("type %s: %w", name, err)
+		}
+		types = append(types, genType{name, fields})
+	}
+
+	g := &generator{imports: make(map[string]bool), types: make(map[string]bool)}
+	for _, t := range types {
+		g.types[t.name] = true
+	}
+	for _, t := range types {
+		g.marshal(t)
+		g.unmarshal(t)
+	}
+	var src bytes.Buffer
+	fmt.Fprintf(&src, "// Code generated by jsongen; DO NOT EDIT.\n\npackage %s\n\nimport (\n", pkg)
+	for _, imp := range slices.Sorted(maps.Keys(g.imports)) {
+		fmt.Fprintf(&src, "\t%q\n", imp)
+	}
+	fmt.Fprintf(&src, "\n\tjson %q\n)\n", "github.com/nspcc-dev/go-ordered-json")
+	src.Write(g.Bytes())
+	return format.Source(src.Bytes())
+}
+
+func hasDirective(doc *ast.CommentGroup) bool {
+	if doc == nil {
+		return false
+	}
+	for _, c := range doc.List {
+		if strings.TrimSpace(c.Text) == directive {
+			return true
+		}
+	}
+	return false
+}
+
+// structFields returns the fields of st to be encoded in their order.
+func structFields(st *ast.StructType) ([]genField, error) {
+	var fields []genField
+	for _, f := range st.Fields.List {
+		if len(f.Names) == 0 {
+			return nil, fmt.Errorf("embedded field %s is not supported", types.ExprString(f.Type))
+		}
+		var tag string
+		if f.Tag != nil {
+			s, err := strconv.Unquote(f.Tag.Value)
+			if err != nil {
+				return nil, err
+			}
+			tag = reflect.StructTag(s).Get("json")
+		}
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		for _, n := range f.Names {
+			if !n.IsExported() {
+				continue
+			}
+			gf := genField{goName: n.Name, name: n.Name, typ: types.ExprString(f.Type)}
+			if isValidTag(name) {
+				gf.name = name
+			}
+			if id, ok := f.Type.(*ast.Ident); ok {
+				bk := basicKinds[id.Name]
+				gf.kind, gf.bits = bk.kind, bk.bits
+			}
+			for _, o := range strings.Split(opts, ",") {
+				switch {
+				case o == "":
+				case o == "omitempty":
+					cond, ok := nonEmpty(f.Type, "v."+n.Name)
+					if !ok {
+						return nil, fmt.Errorf("field %s: omitempty is not supported for %s", n.Name, gf.typ)
+					}
+					gf.omitEmpty = cond
+				case o == "string":
+					if gf.kind == kindOther {
+						return nil, fmt.Errorf("field %s: string option is only supported for basic types", n.Name)
+					}
+					gf.quoted = true
+				case strings.HasPrefix(o, "order="):
+					v, err := strconv.Atoi(strings.TrimPrefix(o, "order="))
+					if err != nil {
+						return nil, fmt.Errorf("field %s: invalid order option", n.Name)
+					}
+					gf.order, gf.ordered = v, true
+				default:
+					return nil, fmt.Errorf("field %s: option %q is not supported", n.Name, o)
+				}
+			}
+			fields = append(fields, gf)
+		}
+	}
+	slices.SortStableFunc(fields, func(a, b genField) int {
+		switch {
+		case a.ordered && b.ordered:
+			return a.order - b.order
+		case a.ordered:
+			return -1
+		case b.ordered:
+			return 1
+		}
+		return 0
+	})
+	return fields, nil
+}
+
+// nonEmpty returns the condition for the field x of type t to be written
+// with the "omitempty" option.
+func nonEmpty(t ast.Expr, x string) (string, bool) {
+	switch t := t.(type) {
+	case *ast.Ident:
+		switch basicKinds[t.Name].kind {
+		case kindString:
+			return x + ` != ""`, true
+		case kindBool:
+			return x, true
+		case kindInt, kindUint, kindFloat:
+			return x + " != 0", true
+		}
+		if t.Name == "any" {
+			return x + " != nil", true
+		}
+	case *ast.ArrayType, *ast.MapType:
+		return "len(" + x + ") != 0", true
+	case *ast.StarExpr, *ast.InterfaceType:
+		return x + " != nil", true
+	}
+	return "", false
+}
+
+// isValidTag is the same as in the json package.
+func isValidTag(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, c := range s {
+		if !strings.ContainsRune("!#$%&()*+-./:<=>?@[]^_{|}~ ", c) && !unicode.IsLetter(c) && !unicode.IsDigit(c) {
+			return false
+		}
+	}
+	return true
+}
+
+type generator struct {
+	bytes.Buffer
+	imports map[string]bool
+	types   map[string]bool // names of the types methods are generated for
+}
+
+func (g *generator) printf(format string, args ...any) {
+	fmt.Fprintf(g, format, args...)
+}
+
+func (g *generator) marshal(t genType) {
+	needErr := slices.ContainsFunc(t.fields, func(f genField) bool { return f.kind == kindOther || f.kind == kindFloat })
+	g.printf("\n// MarshalJSON implements the json.Marshaler interface.\n")
+	g.printf("func (v %s) MarshalJSON() ([]byte, error) {\n", t.name)
+	g.printf("return v.AppendJSON(make([]byte, 0, %d))\n}\n", 16*len(t.fields)+2)
+	g.printf("\n// AppendJSON implements the json.MarshalerAppend interface.\n")
+	g.printf("func (v %s) AppendJSON(b []byte) ([]byte, error) {\n", t.name)
+	if needErr {
+		g.printf("var err error\n")
+	}
+	g.printf("b = append(b, '{')\n")
+	written := false // whether a field before is always written
+	for i, f := range t.fields {
+		if f.omitEmpty != "" {
+			g.printf("if %s {\n", f.omitEmpty)
+		}
+		key := string(json.AppendString(nil, f.name)) + ":"
+		switch {
+		case written:
+			key = "," + key
+		case i > 0:
+			// Only the fields with "omitempty" come before.
+			g.printf("if b[len(b)-1] != '{' {\nb = append(b, ',')\n}\n")
+		}
+		written = written || f.omitEmpty == ""
+		g.printf("b = append(b, %s...)\n", strconv.Quote(key))
+		x := "v." + f.goName
+		if f.quoted && f.kind != kindString {
+			g.printf("b = append(b, '\"')\n")
+		}
+		switch f.kind {
+		case kindString:
+			if f.quoted {
+				g.printf("b = json.AppendString(b, string(json.AppendString(nil, %s)))\n", x)
+			} else {
+				g.printf("b = json.AppendString(b, %s)\n", x)
+			}
+		case kindBool:
+			g.imports["strconv"] = true
+			g.printf("b = strconv.AppendBool(b, %s)\n", x)
+		case kindInt:
+			g.imports["strconv"] = true
+			g.printf("b = strconv.AppendInt(b, int64(%s), 10)\n", x)
+		case kindUint:
+			g.imports["strconv"] = true
+			g.printf("b = strconv.AppendUint(b, uint64(%s), 10)\n", x)
+		case kindFloat:
+			g.printf("if b, err = json.AppendFloat(b, float64(%s), %d); err != nil {\nreturn nil, err\n}\n", x, f.bits)
+		default:
+			switch g.generated(f.typ) {
+			case genValue:
+				g.printf("if b, err = %s.AppendJSON(b); err != nil {\nreturn nil, err\n}\n", x)
+			case genPointer:
+				if f.omitEmpty != "" { // Nil pointers are omitted.
+					g.printf("if b, err = %s.AppendJSON(b); err != nil {\nreturn nil, err\n}\n", x)
+					break
+				}
+				g.printf("if %s == nil {\nb = append(b, \"null\"...)\n} else if b, err = %s.AppendJSON(b); err != nil {\nreturn nil, err\n}\n", x, x)
+			default:
+				g.printf("if b, err = json.AppendMarshal(b, %s); err != nil {\nreturn nil, err\n}\n", x)
+			}
+		}
+		if f.quoted && f.kind != kindString {
+			g.printf("b = append(b, '\"')\n")
+		}
+		if f.omitEmpty != "" {
+			g.printf("}\n")
+		}
+	}
+	g.printf("return append(b, '}'), nil\n}\n")
+}
+
+// Ways a field type can be one of the generated types.
+const (
+	genNone = iota
+	genValue
+	genPointer
+)
+
+// generated tells whether the type expression typ is one of the types
+// methods are generated for or a pointer to it.
+func (g *generator) generated(typ string) int {
+	switch {
+	case g.types[typ]:
+		return genValue
+	case strings.HasPrefix(typ, "*") && g.types[typ[1:]]:
+		return genPointer
+	}
+	return genNone
+}
+
+func (g *generator) unmarshal(t genType) {
+	g.imports["reflect"] = true
+	g.printf("\n// UnmarshalJSON implements the json.Unmarshaler interface.\n")
+	g.printf("func (v *%s) UnmarshalJSON(data []byte) error {\n", t.name)
+	g.printf("var l json.Lexer\nl.Reset(data)\nif err := v.unmarshalLexer(&l); err != nil {\nreturn err\n}\nreturn l.End()\n}\n")
+	g.printf("\n// unmarshalLexer decodes the next value read by l into v, errors of l\n// are left in it.\n")
+	g.printf("func (v *%s) unmarshalLexer(l *json.Lexer) error {\n", t.name)
+	g.printf("if l.Null() || !l.Object(reflect.TypeFor[%s]()) {\nreturn nil\n}\n", t.name)
+	g.printf("for l.More() {\n")
+	if len(t.fields) == 0 {
+		g.printf("l.Key()\nl.Skip()\n}\nreturn nil\n}\n")
+		return
+	}
+	g.imports["strings"] = true
+	g.printf("key := l.Key()\ni := -1\n")
+	g.printf("switch string(key) {\n")
+	for i, f := range t.fields {
+		g.printf("case %q:\ni = %d\n", f.name, i)
+	}
+	g.printf("}\nif i < 0 {\nswitch {\n")
+	for i, f := range t.fields {
+		g.printf("case strings.EqualFold(string(key), %q):\ni = %d\n", f.name, i)
+	}
+	g.printf("}\n}\n")
+	g.printf("switch i {\n")
+	for i, f := range t.fields {
+		g.printf("case %d:\n", i)
+		g.decodeField(f)
+	}
+	g.printf("default:\nl.Skip()\n}\n}\nreturn nil\n}\n")
+}
+
+// conv returns the conversion of x of the largest type of its kind to typ.
+func conv(typ, x string) string {
+	switch typ {
+	case "int64", "uint64", "float64":
+		return x
+	}
+	return typ + "(" + x + ")"
+}
+
+// read returns the expression reading the value of the basic type field f
+// with the lexer l.
+func read(f genField, l string) string {
+	switch f.kind {
+	case kindString:
+		return l + ".ReadString()"
+	case kindBool:
+		return l + ".ReadBool()"
+	case kindInt:
+		return conv(f.typ, fmt.Sprintf("%s.ReadInt(%d)", l, f.bits))
+	case kindUint:
+		return conv(f.typ, fmt.Sprintf("%s.ReadUint(%d)", l, f.bits))
+	}
+	return conv(f.typ, fmt.Sprintf("%s.ReadFloat(%d)", l, f.bits))
+}
+
+// decodeField writes the code decoding the value of f read by l. Values
+// of basic types are read with l like Unmarshal decodes them (nulls are
+// ignored), values of the other generated types with their own methods,
+// the rest with Unmarshal.
+func (g *generator) decodeField(f genField) {
+	x := "v." + f.goName
+	switch {
+	case f.kind == kindOther && g.generated(f.typ) == genValue:
+		g.printf("if err := %s.unmarshalLexer(l); err != nil {\nreturn err\n}\n", x)
+	case f.kind == kindOther && g.generated(f.typ) == genPointer:
+		g.printf("if l.Null() {\n%s = nil\n} else {\nif %s == nil {\n%s = new(%s)\n}\n", x, x, x, f.typ[1:])
+		g.printf("if err := %s.unmarshalLexer(l); err != nil {\nreturn err\n}\n}\n", x)
+	case f.kind == kindOther:
+		g.printf("if raw := l.Raw(); raw != nil {\nif err := json.Unmarshal(raw, &%s); err != nil {\nreturn err\n}\n}\n", x)
+	case f.quoted:
+		g.printf("if s := l.Quoted(); s != nil {\nvar q json.Lexer\nq.Reset(s)\nif !q.Null() {\n%s = %s\n}\n", x, read(f, "q"))
+		g.printf("if err := q.End(); err != nil {\nreturn err\n}\n}\n")
+	default:
+		g.printf("if !l.Null() {\n%s = %s\n}\n", x, read(f, "l"))
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGenerateUpToDate(t *testing.T) {
+	dir := filepath.Join("internal", "sample")
+	got, err := generate(dir, []string{"Transfer", "Account", "Empty"}, "sample_json.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := os.ReadFile(filepath.Join(dir, "sample_json.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Error("sample_json.go is outdated, run go generate")
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	for _, tc := range []struct {
+		src   string
+		names []string
+		err   string // expected error substring, empty for success
+	}{
+		{"type T struct{ A int }", nil, "no types"},
+		{"//jsongen:generate\ntype T struct{ A int }", nil, ""},
+		{"type T struct{ A int }", []string{"T"}, ""},
+		{"type T struct{ A int }", []string{"U"}, "type U not found"},
+		{"type T []int", []string{"T"}, "not a non-generic struct"},
+		{"type T struct{ U }\ntype U struct{}", []string{"T"}, "embedded field U"},
+		{"type T struct{ A int `json:\",inline\"` }", []string{"T"}, `option "inline"`},
+		{"type T struct{ A U `json:\",omitempty\"` }\ntype U int", []string{"T"}, "omitempty is not supported for U"},
+		{"type T struct{ A []int `json:\",string\"` }", []string{"T"}, "string option"},
+	} {
+		dir := t.TempDir()
+		if err := os.WriteFile(filepath.Join(dir, "t.go"), []byte("package p\n\n"+tc.src+"\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		src, err := generate(dir, tc.names, "t_json.go")
+		if tc.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tc.err) {
+				t.Errorf("%q: got error %v, want %q", tc.src, err, tc.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: %v", tc.src, err)
+			continue
+		}
+		if !bytes.Contains(src, []byte("func (v T) MarshalJSON()")) || !bytes.Contains(src, []byte("func (v *T) UnmarshalJSON(")) {
+			t.Errorf("%q: no methods generated:\n%s", tc.src, src)
+		}
+	}
+}
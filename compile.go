@@ -0,0 +1,311 @@
+package json
+
+import (
+	"errors"
+	"math"
+	"reflect"
+	"strconv"
+)
+
+// A TypeEncoder is an encoder for values of a single type resolved in
+// advance by CompileEncoder. It encodes values exactly like Marshal, but
+// with a plan built once: struct fields come with their encoders and
+// escaped member names and the options Marshal doesn't use are not checked
+// on every value. It's safe for concurrent use.
+type TypeEncoder struct {
+	t   reflect.Type
+	enc encoderFunc
+}
+
+// CompileEncoder returns a TypeEncoder for values of type t. It returns an
+// UnsupportedTypeError if values of t can't be encoded at all, nested
+// values of unsupported types are still reported by the TypeEncoder
+// methods. The encoder uses the methods of t and the encoders registered
+// at the time of the call.
+func CompileEncoder(t reflect.Type) (TypeEncoder, error) {
+	if isPlainType(t) && !isEncodableKind(t) {
+		return TypeEncoder{}, &UnsupportedTypeError{t}
+	}
+	c := planCompiler{plans: make(map[reflect.Type]planFunc)}
+	plan := c.compile(t)
+	return TypeEncoder{t, func(e *encodeState, v reflect.Value, _ encOpts) { plan(e, v) }}, nil
+}
+
+// Type returns the type of the values te encodes.
+func (te TypeEncoder) Type() reflect.Type {
+	return te.t
+}
+
+// Marshal returns the JSON encoding of v which must be of the type te was
+// compiled for.
+func (te TypeEncoder) Marshal(v any) ([]byte, error) {
+	e := &encodeState{}
+	err := te.marshal(e, v)
+	if err != nil {
+		return nil, err
+	}
+	return e.Bytes(), nil
+}
+
+// AppendMarshal is like Marshal, but appends the encoding of v to dst like
+// the AppendMarshal function does.
+func (te TypeEncoder) AppendMarshal(dst []byte, v any) ([]byte, error) {
+	e := newEncodeState()
+	defer putEncodeState(e)
+	err := te.marshal(e, v)
+	if err != nil {
+		return dst, err
+	}
+	return append(dst, e.Bytes()...), nil
+}
+
+func (te TypeEncoder) marshal(e *encodeState, v any) error {
+	if t := reflect.TypeOf(v); te.t == nil || t != te.t {
+		return errors.New("json: TypeEncoder for " + typeString(te.t) + " used with " + typeString(t))
+	}
+	return e.marshalWith(te.enc, reflect.ValueOf(v), planOpts)
+}
+
+// typeString returns the name of t or "nil" if t is nil.
+func typeString(t reflect.Type) string {
+	if t == nil {
+		return "nil"
+	}
+	return t.String()
+}
+
+// planOpts are the options of Marshal, which TypeEncoder plans are built
+// for.
+var planOpts = encOpts{escapeHTML: true}
+
+// planFunc writes the JSON encoding of v with planOpts.
+type planFunc func(e *encodeState, v reflect.Value)
+
+// planCompiler builds the plans of a TypeEncoder.
+type planCompiler struct {
+	plans map[reflect.Type]planFunc
+}
+
+// compile returns the plan for values of type t.
+func (c *planCompiler) compile(t reflect.Type) planFunc {
+	if f, ok := c.plans[t]; ok {
+		return f
+	}
+	// Recursive types get an indirect func like in typeEncoder.
+	var f planFunc
+	c.plans[t] = func(e *encodeState, v reflect.Value) { f(e, v) }
+	f = c.build(t)
+	c.plans[t] = f
+	return f
+}
+
+func (c *planCompiler) build(t reflect.Type) planFunc {
+	if !isPlainType(t) {
+		return genericPlan(typeEncoder(nil, t), planOpts)
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return func(e *encodeState, v reflect.Value) {
+			if v.Bool() {
+				e.WriteString("true")
+			} else {
+				e.WriteString("false")
+			}
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return func(e *encodeState, v reflect.Value) {
+			e.Write(appendInt(e.available(maxIntLen), v.Int()))
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return func(e *encodeState, v reflect.Value) {
+			e.Write(appendUint(e.available(maxIntLen), v.Uint()))
+		}
+	case reflect.Float32, reflect.Float64:
+		bits := t.Bits()
+		return func(e *encodeState, v reflect.Value) {
+			f := v.Float()
+			if math.IsInf(f, 0) || math.IsNaN(f) {
+				e.error(&UnsupportedValueError{v, strconv.FormatFloat(f, 'g', -1, bits)})
+			}
+			e.Write(appendFloatJS(e.AvailableBuffer(), f, bits))
+		}
+	case reflect.String:
+		if t == numberType {
+			break
+		}
+		return func(e *encodeState, v reflect.Value) {
+			s := v.String()
+			e.Write(appendString(e.available(len(s)+2), s, planOpts))
+		}
+	case reflect.Struct:
+		if f := c.structPlan(t); f != nil {
+			return f
+		}
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			break // Byte slices are strings.
+		}
+		return slicePlan(c.arrayPlan(t))
+	case reflect.Array:
+		return c.arrayPlan(t)
+	case reflect.Ptr:
+		return ptrPlan(c.compile(t.Elem()))
+	}
+	return genericPlan(typeEncoder(nil, t), planOpts)
+}
+
+// genericPlan returns the plan calling the encoder f with opts.
+func genericPlan(f encoderFunc, opts encOpts) planFunc {
+	return func(e *encodeState, v reflect.Value) { f(e, v, opts) }
+}
+
+// isPlainType reports whether values of t are encoded according to their
+// kind, without methods and registered encoders.
+func isPlainType(t reflect.Type) bool {
+	if _, ok := typeEncoders.Load(t); ok || t == orderedObjectType {
+		return false
+	}
+	if t.Kind() == reflect.Ptr {
+		if _, ok := typeEncoders.Load(t.Elem()); ok {
+			return false
+		}
+	}
+	for _, m := range []reflect.Type{marshalerToType, appendMarshalerType, orderedMarshalerType, ctxMarshalerType, marshalerType, textMarshalerType} {
+		if t.Implements(m) || t.Kind() != reflect.Ptr && reflect.PointerTo(t).Implements(m) {
+			return false
+		}
+	}
+	return true
+}
+
+// isEncodableKind reports whether values of the plain type t can be
+// encoded, see newTypeEncoder.
+func isEncodableKind(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Complex64, reflect.Complex128, reflect.UnsafePointer:
+		return false
+	case reflect.Func:
+		if _, ok := seqElem(t); ok {
+			return true
+		}
+		key, _, ok := seq2Elems(t)
+		return ok && key.Kind() == reflect.String
+	case reflect.Chan:
+		return t.ChanDir() == reflect.RecvDir
+	}
+	return true
+}
+
+// planField is a struct field in a plan.
+type planField struct {
+	index     []int
+	key       []byte // the escaped member name preceded by a comma and followed by a colon
+	omitEmpty bool
+	omitZero  bool
+	isZero    func(reflect.Value) bool
+	enc       planFunc
+}
+
+// structPlan returns the plan for the struct type t or nil if its fields
+// need the generic encoder.
+func (c *planCompiler) structPlan(t reflect.Type) planFunc {
+	fields := cachedTypeFields(t)
+	pfs := make([]planField, len(fields))
+	for i, f := range fields {
+		if f.inline {
+			return nil
+		}
+		pf := planField{
+			index:     f.index,
+			key:       append(appendString([]byte{','}, f.name, planOpts), ':'),
+			omitEmpty: f.omitEmpty,
+			omitZero:  f.omitZero,
+			isZero:    f.isZero,
+		}
+		ft := typeByIndex(t, f.index)
+		switch {
+		case f.timeFormat != "":
+			pf.enc = genericPlan(newTimeEncoder(f.typ, f.timeFormat), planOpts)
+		case f.quoted || f.quotedElems || f.format != bytesBase64:
+			opts := planOpts
+			opts.quoted = f.quoted || f.quotedElems
+			opts.bytesFormat = f.format
+			pf.enc = genericPlan(typeEncoder(nil, ft), opts)
+		default:
+			pf.enc = c.compile(ft)
+		}
+		pfs[i] = pf
+	}
+	return func(e *encodeState, v reflect.Value) {
+		e.WriteByte('{')
+		first := true
+		for i := range pfs {
+			f := &pfs[i]
+			fv := fieldByIndex(v, f.index)
+			if !fv.IsValid() || f.omitEmpty && isEmptyValue(fv) ||
+				f.omitZero && (f.isZero == nil && fv.IsZero() || f.isZero != nil && f.isZero(fv)) {
+				continue
+			}
+			if first {
+				first = false
+				e.Write(f.key[1:])
+			} else {
+				e.Write(f.key)
+			}
+			f.enc(e, fv)
+		}
+		e.WriteByte('}')
+	}
+}
+
+// arrayPlan returns the plan writing the elements of the slice or array
+// type t.
+func (c *planCompiler) arrayPlan(t reflect.Type) planFunc {
+	elem := c.compile(t.Elem())
+	return func(e *encodeState, v reflect.Value) {
+		e.WriteByte('[')
+		for i, n := 0, v.Len(); i < n; i++ {
+			if i > 0 {
+				e.WriteByte(',')
+			}
+			elem(e, v.Index(i))
+		}
+		e.WriteByte(']')
+	}
+}
+
+// slicePlan returns the plan of a slice type writing the elements with
+// array, it detects cycles like sliceEncoder.
+func slicePlan(array planFunc) planFunc {
+	return func(e *encodeState, v reflect.Value) {
+		if v.IsNil() {
+			e.WriteString("null")
+			return
+		}
+		if e.ptrLevel++; e.ptrLevel > startDetectingCyclesAfter {
+			ptr := slicePtr{v.UnsafePointer(), v.Len()}
+			e.markSeen(v, ptr)
+			defer delete(e.ptrSeen, ptr)
+		}
+		array(e, v)
+		e.ptrLevel--
+	}
+}
+
+// ptrPlan returns the plan of a pointer type writing the values pointed
+// to with elem, it detects cycles like ptrEncoder.
+func ptrPlan(elem planFunc) planFunc {
+	return func(e *encodeState, v reflect.Value) {
+		if v.IsNil() {
+			e.WriteString("null")
+			return
+		}
+		if e.ptrLevel++; e.ptrLevel > startDetectingCyclesAfter {
+			ptr := v.Interface()
+			e.markSeen(v, ptr)
+			defer delete(e.ptrSeen, ptr)
+		}
+		elem(e, v.Elem())
+		e.ptrLevel--
+	}
+}
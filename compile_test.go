@@ -0,0 +1,91 @@
+package json
+
+import (
+	"bytes"
+	"errors"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestCompileEncoder(t *testing.T) {
+	type item struct {
+		Name  string `json:"name"`
+		Count int    `json:"count,omitempty"`
+		Tags  []string
+	}
+	te, err := CompileEncoder(reflect.TypeFor[item]())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if te.Type() != reflect.TypeFor[item]() {
+		t.Errorf("got type %v", te.Type())
+	}
+	for _, v := range []item{{}, {"a<b", 3, []string{"x"}}} {
+		want, _ := Marshal(v)
+		got, err := te.Marshal(v)
+		if err != nil || !bytes.Equal(got, want) {
+			t.Errorf("Marshal(%#v): got %s, %v, want %s", v, got, err, want)
+		}
+		got, err = te.AppendMarshal([]byte("x"), v)
+		if err != nil || string(got) != "x"+string(want) {
+			t.Errorf("AppendMarshal(%#v): got %s, %v", v, got, err)
+		}
+	}
+	if _, err := te.Marshal(&item{}); err == nil {
+		t.Error("no error for a different type")
+	}
+	if _, err := te.Marshal(nil); err == nil {
+		t.Error("no error for nil")
+	}
+
+	type embedded struct{ E float32 }
+	type node struct {
+		Kids  []*node `json:"kids,omitempty"`
+		Bytes []byte  `json:",omitzero"`
+		Hex   []byte  `json:",format:hex"`
+		N     int64   `json:",string"`
+		Num   Number
+		Arr   [2]uint8
+		Ref   Ref
+		When  time.Time `json:",omitzero,format:unix"`
+		Any   any       `json:"<any>"`
+		Map   map[string]int
+		*embedded
+	}
+	codeInit()
+	for _, v := range []any{
+		node{},
+		&node{[]*node{{Any: "&"}, nil}, []byte("x"), []byte{1}, 1, "2", [2]uint8{3}, 4, time.Unix(5, 0), 6.5, map[string]int{"b": 1, "a": 2}, &embedded{7}},
+		Optionals{Sr: "<", Slo: []string{}, Mo: map[string]any{"x": 1}, Fo: 1.5},
+		codeStruct,
+		sliceNoCycle,
+		samePointerNoCycle,
+		pointerCycle,
+		math.Inf(1),
+		float32(math.NaN()),
+	} {
+		te, err := CompileEncoder(reflect.TypeOf(v))
+		if err != nil {
+			t.Fatal(err)
+		}
+		want, wantErr := Marshal(v)
+		got, err := te.Marshal(v)
+		if !bytes.Equal(got, want) || (err == nil) != (wantErr == nil) || err != nil && err.Error() != wantErr.Error() {
+			t.Errorf("%T: got %.100s, %v, want %.100s, %v", v, got, err, want, wantErr)
+		}
+	}
+
+	var ute *UnsupportedTypeError
+	for _, typ := range []reflect.Type{reflect.TypeFor[chan<- int](), reflect.TypeFor[func()](), reflect.TypeFor[complex64]()} {
+		if _, err := CompileEncoder(typ); !errors.As(err, &ute) {
+			t.Errorf("unsupported type %v: got %v", typ, err)
+		}
+	}
+	if ptr, err := CompileEncoder(reflect.TypeFor[*Ref]()); err != nil {
+		t.Error(err)
+	} else if b, err := ptr.Marshal(new(Ref)); err != nil || string(b) != `"ref"` {
+		t.Errorf("Marshaler: got %s, %v", b, err)
+	}
+}
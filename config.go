@@ -0,0 +1,223 @@
+package json
+
+import (
+	"io"
+	"reflect"
+	"sync"
+)
+
+// An Extension customizes the encoding and decoding done with a Config.
+// Its methods are called once per type when the type is encoded or decoded
+// for the first time, the results are cached by the Config. Embed
+// ExtensionBase to implement only some of them.
+type Extension interface {
+	// UpdateFields can change the bindings of the fields of the struct
+	// type structType. fields are the fields to be encoded and decoded
+	// (including the promoted ones) in their order.
+	UpdateFields(structType reflect.Type, fields []*FieldBinding)
+	// WrapEncoder returns the function to use for encoding values of t
+	// instead of next (the one provided by the default encoding or by
+	// the extensions registered before), it's called with an Encoder
+	// just like the functions passed to RegisterEncoder (but Encode
+	// called with a value of t uses the returned function again, next
+	// is to be used instead). nil keeps next.
+	WrapEncoder(t reflect.Type, next func(*Encoder, reflect.Value) error) func(*Encoder, reflect.Value) error
+	// WrapDecoder returns the function to use for decoding values of t
+	// instead of next, it's called with a Decoder just like the functions
+	// passed to RegisterDecoder. nil keeps next.
+	WrapDecoder(t reflect.Type, next func(*Decoder, reflect.Value) error) func(*Decoder, reflect.Value) error
+}
+
+// ExtensionBase implements Extension without changing anything.
+type ExtensionBase struct{}
+
+// UpdateFields implements Extension.
+func (ExtensionBase) UpdateFields(reflect.Type, []*FieldBinding) {}
+
+// WrapEncoder implements Extension.
+func (ExtensionBase) WrapEncoder(reflect.Type, func(*Encoder, reflect.Value) error) func(*Encoder, reflect.Value) error {
+	return nil
+}
+
+// WrapDecoder implements Extension.
+func (ExtensionBase) WrapDecoder(reflect.Type, func(*Decoder, reflect.Value) error) func(*Decoder, reflect.Value) error {
+	return nil
+}
+
+// FieldBinding describes how a struct field is bound to an object member,
+// Extension.UpdateFields can change everything except Field.
+type FieldBinding struct {
+	Field     reflect.StructField // the field, Index is the path from the struct
+	Name      string              // member name
+	Skip      bool                // ignore the field
+	OmitEmpty bool                // the "omitempty" option
+	String    bool                // the "string" option
+}
+
+// A Config is a set of extensions used by its Marshal, Unmarshal, NewEncoder
+// and NewDecoder methods. It's safe for concurrent use, its caches are
+// separate from the ones of the package functions.
+type Config struct {
+	mu         sync.RWMutex
+	extensions []Extension
+	encoders   sync.Map // map[reflect.Type]encoderFunc
+	fields     sync.Map // map[reflect.Type][]field
+	decoders   sync.Map // map[reflect.Type]func(*Decoder, reflect.Value) error, nil if unchanged
+}
+
+// NewConfig returns a Config using the given extensions in order.
+func NewConfig(extensions ...Extension) *Config {
+	return &Config{extensions: extensions}
+}
+
+// RegisterExtension adds x to the extensions of c. Values already encoded
+// or decoded with c are processed again with x the next time.
+func (c *Config) RegisterExtension(x Extension) {
+	c.mu.Lock()
+	c.extensions = append(c.extensions, x)
+	c.mu.Unlock()
+	c.encoders.Clear()
+	c.fields.Clear()
+	c.decoders.Clear()
+}
+
+// exts returns the current extensions of c.
+func (c *Config) exts() []Extension {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.extensions
+}
+
+// Marshal is like the Marshal function, but uses the extensions of c.
+func (c *Config) Marshal(v any) ([]byte, error) {
+	e := &encodeState{}
+	err := e.marshal(v, encOpts{escapeHTML: true, extra: &extraOpts{config: c}})
+	if err != nil {
+		return nil, err
+	}
+	return e.Bytes(), nil
+}
+
+// Unmarshal is like the Unmarshal function, but uses the extensions of c.
+func (c *Config) Unmarshal(data []byte, v any) error {
+	var d decodeState
+	err := checkValid(data, &d.scan)
+	if err != nil {
+		return err
+	}
+
+	d.init(data)
+	d.config = c
+	return d.unmarshal(v)
+}
+
+// NewEncoder is like the NewEncoder function, but the Encoder uses the
+// extensions of c.
+func (c *Config) NewEncoder(w io.Writer) *Encoder {
+	enc := NewEncoder(w)
+	enc.setExtras().config = c
+	return enc
+}
+
+// NewDecoder is like the NewDecoder function, but the Decoder uses the
+// extensions of c.
+func (c *Config) NewDecoder(r io.Reader) *Decoder {
+	dec := NewDecoder(r)
+	dec.d.config = c
+	return dec
+}
+
+// wrapEncoder returns f wrapped by the extensions of c.
+func (c *Config) wrapEncoder(t reflect.Type, f encoderFunc) encoderFunc {
+	if c == nil {
+		return f
+	}
+	next := func(enc *Encoder, v reflect.Value) error { return enc.encodeWith(f, v) }
+	wrapped := false
+	for _, x := range c.exts() {
+		if w := x.WrapEncoder(t, next); w != nil {
+			next, wrapped = w, true
+		}
+	}
+	if !wrapped {
+		return f
+	}
+	return newRegisteredEncoder(next)
+}
+
+// decoder returns the decoding function for t provided by the extensions
+// of c or nil if there is none.
+func (c *Config) decoder(t reflect.Type) func(*Decoder, reflect.Value) error {
+	if fn, ok := c.decoders.Load(t); ok {
+		return fn.(func(*Decoder, reflect.Value) error)
+	}
+	next := func(dec *Decoder, v reflect.Value) error {
+		dec.d.bypass = t
+		return dec.Decode(v.Addr().Interface())
+	}
+	var fn func(*Decoder, reflect.Value) error
+	for _, x := range c.exts() {
+		if w := x.WrapDecoder(t, next); w != nil {
+			next, fn = w, w
+		}
+	}
+	c.decoders.Store(t, fn)
+	return fn
+}
+
+// typeFields returns the fields of t updated by the extensions of c.
+func (c *Config) typeFields(t reflect.Type) []field {
+	if c == nil {
+		return cachedTypeFields(t)
+	}
+	if f, ok := c.fields.Load(t); ok {
+		return f.([]field)
+	}
+	fields := cachedTypeFields(t)
+	exts := c.exts()
+	if len(exts) > 0 {
+		bindings := make([]*FieldBinding, len(fields))
+		for i, f := range fields {
+			bindings[i] = &FieldBinding{
+				Field:     fieldAt(t, f.index),
+				Name:      f.name,
+				OmitEmpty: f.omitEmpty,
+				String:    f.quoted || f.quotedElems,
+			}
+		}
+		for _, x := range exts {
+			x.UpdateFields(t, bindings)
+		}
+		updated := make([]field, 0, len(fields))
+		for i, b := range bindings {
+			if b.Skip {
+				continue
+			}
+			f := fields[i]
+			if b.Name != f.name {
+				f.name = b.Name
+				f.tag = true
+				f = fillField(f)
+			}
+			f.omitEmpty = b.OmitEmpty
+			ft := f.typ
+			if ft.Name() == "" && ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			f.quoted = b.String && quotable(ft)
+			f.quotedElems = b.String && quotableElems(ft)
+			updated = append(updated, f)
+		}
+		fields = updated
+	}
+	f, _ := c.fields.LoadOrStore(t, fields)
+	return f.([]field)
+}
+
+// fieldAt returns the struct field of t at the path index, unlike
+// reflect.Type.FieldByIndex it returns the whole path in Index.
+func fieldAt(t reflect.Type, index []int) reflect.StructField {
+	sf := t.FieldByIndex(index)
+	sf.Index = append([]int(nil), index...)
+	return sf
+}
@@ -0,0 +1,109 @@
+package json
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// lowerFields names fields in lower case, skips the ones starting with X
+// and quotes ints.
+type lowerFields struct{ ExtensionBase }
+
+func (lowerFields) UpdateFields(_ reflect.Type, fields []*FieldBinding) {
+	for _, f := range fields {
+		f.Name = strings.ToLower(f.Name)
+		f.Skip = strings.HasPrefix(f.Field.Name, "X")
+		f.String = f.Field.Type.Kind() == reflect.Int
+	}
+}
+
+// upperStrings encodes strings in upper case and decodes them in lower
+// case.
+type upperStrings struct{ ExtensionBase }
+
+func (upperStrings) WrapEncoder(t reflect.Type, next func(*Encoder, reflect.Value) error) func(*Encoder, reflect.Value) error {
+	if t.Kind() != reflect.String {
+		return nil
+	}
+	return func(enc *Encoder, v reflect.Value) error {
+		return next(enc, reflect.ValueOf(strings.ToUpper(v.String())).Convert(t))
+	}
+}
+
+func (upperStrings) WrapDecoder(t reflect.Type, next func(*Decoder, reflect.Value) error) func(*Decoder, reflect.Value) error {
+	if t.Kind() != reflect.String {
+		return nil
+	}
+	return func(dec *Decoder, v reflect.Value) error {
+		if err := next(dec, v); err != nil {
+			return err
+		}
+		v.SetString(strings.ToLower(v.String()))
+		return nil
+	}
+}
+
+// boxedInts wraps ints into objects using the default encoding.
+type boxedInts struct{ ExtensionBase }
+
+func (boxedInts) WrapEncoder(t reflect.Type, next func(*Encoder, reflect.Value) error) func(*Encoder, reflect.Value) error {
+	if t.Kind() != reflect.Int {
+		return nil
+	}
+	return func(enc *Encoder, v reflect.Value) error {
+		if err := enc.BeginObject(); err != nil {
+			return err
+		}
+		if err := enc.WriteKey("int"); err != nil {
+			return err
+		}
+		if err := next(enc, v); err != nil {
+			return err
+		}
+		return enc.End()
+	}
+}
+
+type configValue struct {
+	Name  string
+	Count int
+	XTmp  string
+	Inner struct{ Value string }
+}
+
+func TestConfig(t *testing.T) {
+	v := configValue{"a", 1, "tmp", struct{ Value string }{"b"}}
+	if b, err := NewConfig().Marshal(v); err != nil || string(b) != `{"Name":"a","Count":1,"XTmp":"tmp","Inner":{"Value":"b"}}` {
+		t.Errorf("no extensions: got %s, %v", b, err)
+	}
+
+	c := NewConfig(lowerFields{})
+	b, err := c.Marshal(v)
+	if want := `{"name":"a","count":"1","inner":{"value":"b"}}`; err != nil || string(b) != want {
+		t.Errorf("fields: got %s, %v, want %s", b, err, want)
+	}
+	var got configValue
+	if err := c.Unmarshal([]byte(`{"name":"x","count":"2","xtmp":"y"}`), &got); err != nil || got.Name != "x" || got.Count != 2 || got.XTmp != "" {
+		t.Errorf("fields: decoded %+v, %v", got, err)
+	}
+	if b, err := Marshal(v); err != nil || !bytes.Contains(b, []byte(`"XTmp"`)) {
+		t.Errorf("global encoding changed: %s, %v", b, err)
+	}
+
+	c.RegisterExtension(upperStrings{})
+	b, err = c.Marshal(v)
+	if want := `{"name":"A","count":"1","inner":{"value":"B"}}`; err != nil || string(b) != want {
+		t.Errorf("encoder: got %s, %v, want %s", b, err, want)
+	}
+	got = configValue{}
+	if err := c.NewDecoder(strings.NewReader(`{"name":"ABC","inner":{"value":"D"}}`)).Decode(&got); err != nil || got.Name != "abc" || got.Inner.Value != "d" {
+		t.Errorf("decoder: decoded %+v, %v", got, err)
+	}
+
+	var buf bytes.Buffer
+	if err := NewConfig(boxedInts{}).NewEncoder(&buf).Encode(map[string]any{"a": 1, "b": []int{2}}); err != nil || buf.String() != `{"a":{"int":1},"b":[{"int":2}]}`+"\n" {
+		t.Errorf("next encoder: got %s, %v", buf.Bytes(), err)
+	}
+}
@@ -9,13 +9,16 @@ package json
 
 import (
 	"bytes"
+	"context"
 	"encoding"
 	"encoding/base64"
 	"errors"
 	"fmt"
+	"math/big"
 	"reflect"
 	"runtime"
 	"strconv"
+	"strings"
 	"unicode"
 	"unicode/utf16"
 	"unicode/utf8"
@@ -37,7 +40,10 @@ import (
 //
 // To unmarshal JSON into a value implementing the Unmarshaler interface,
 // Unmarshal calls that value's UnmarshalJSON method, including
-// when the input is a JSON null.
+// when the input is a JSON null. The UnmarshalerContext interface is
+// handled the same way and takes precedence, its method gets
+// context.Background() unless UnmarshalContext or Decoder.DecodeContext
+// is used.
 // Otherwise, if the value implements encoding.TextUnmarshaler
 // and the input is a JSON quoted string, Unmarshal calls that value's
 // UnmarshalText method with the unquoted form of the string.
@@ -45,7 +51,12 @@ import (
 // To unmarshal JSON into a struct, Unmarshal matches incoming object
 // keys to the keys used by Marshal (either the struct field name or its tag),
 // preferring an exact match but also accepting a case-insensitive match.
-// Unmarshal will only set exported fields of the struct.
+// Unmarshal will only set exported fields of the struct. Keys that match
+// no field are ignored unless the struct has an OrderedObject or map field
+// with the "inline" tag option, such members are appended to the
+// OrderedObject in input order (nested objects are stored as OrderedObject
+// then) or stored into the map. Fields having the "default"
+// tag option get the default value if the object lacks their members.
 //
 // To unmarshal JSON into an interface value,
 // Unmarshal stores one of these in the interface value:
@@ -82,11 +93,16 @@ import (
 // an UnmarshalTypeError describing the earliest such error. In any
 // case, it's not guaranteed that all the remaining fields following
 // the problematic one will be unmarshaled into the target object.
+// Numbers are never truncated: a number with a fraction or an exponent
+// (even 1.0 or 1e2) is not appropriate for integer types and a negative
+// number is not appropriate for unsigned ones, these errors always
+// include the path of the offending value.
 //
 // The JSON null value unmarshals into an interface, map, pointer, or slice
 // by setting that Go value to nil. Because null is often used in JSON to mean
 // “not present,” unmarshaling a JSON null into any other Go type has no effect
-// on the value and produces no error.
+// on the value and produces no error. A Decoder can be configured to behave
+// differently with SetNullPolicy.
 //
 // When unmarshaling quoted strings, invalid UTF-8 or
 // invalid UTF-16 surrogate pairs are not treated as an error.
@@ -106,6 +122,72 @@ func Unmarshal(data []byte, v any) error {
 	return d.unmarshal(v)
 }
 
+// UnmarshalContext is like Unmarshal, but it aborts with the ctx error
+// once ctx is done. ctx is checked periodically while the data is validated
+// and decoded, v may be partially filled then. ctx is also passed to the
+// UnmarshalJSONContext methods of UnmarshalerContext values.
+func UnmarshalContext(ctx context.Context, data []byte, v any) error {
+	var d decodeState
+	err := checkValidContext(ctx, data, &d.scan)
+	if err != nil {
+		return err
+	}
+
+	d.init(data)
+	d.ctx = ctx
+	return d.unmarshal(v)
+}
+
+// UnmarshalLimited is like Unmarshal, but rejects input exceeding the given
+// limits with a LimitError before decoding anything into v.
+func UnmarshalLimited(data []byte, v any, l Limits) error {
+	if l.MaxBytes > 0 && int64(len(data)) > l.MaxBytes {
+		return &LimitError{Limit: "MaxBytes", Max: l.MaxBytes, Offset: l.MaxBytes}
+	}
+	var d decodeState
+	d.scan.limits = l
+	err := checkValid(data, &d.scan)
+	if err != nil {
+		return err
+	}
+
+	d.init(data)
+	return d.unmarshal(v)
+}
+
+// FieldSetter is the interface implemented by structs that need to know
+// which of their fields were present in the input, for example to tell an
+// explicit zero value from a missing member. After decoding an object into
+// the struct Unmarshal calls SetFields with a map of all the fields' JSON
+// names to whether the object had a member for them (null included).
+// It's not called if the struct is not decoded from an object.
+type FieldSetter interface {
+	SetFields(fields map[string]bool)
+}
+
+// UnmarshalFields is like Unmarshal, but if data is an object it stops
+// reading it right after the members with the given names (matched
+// exactly) are decoded, as if the object ended there. The rest of data is
+// not even checked to be valid then. If some of the members are missing,
+// it's the same as Unmarshal.
+//
+// v should point to a struct, a map, an OrderedObject or an any. Defaults,
+// required fields and FieldSetter are not handled for the top-level object
+// if the decoding stops early, since the rest of its members is unknown.
+func UnmarshalFields(data []byte, v any, fields ...string) error {
+	var d decodeState
+	end, err := checkValidFields(data, &d.scan, fields)
+	if err != nil {
+		return err
+	}
+
+	d.init(data[:end])
+	if end < len(data) {
+		d.stopAt = end
+	}
+	return d.unmarshal(v)
+}
+
 // Unmarshaler is the interface implemented by types
 // that can unmarshal a JSON description of themselves.
 // The input can be assumed to be a valid encoding of
@@ -118,6 +200,12 @@ type Unmarshaler interface {
 	UnmarshalJSON([]byte) error
 }
 
+// UnmarshalerContext is like Unmarshaler, but the method also gets the
+// context given to UnmarshalContext or Decoder.DecodeContext.
+type UnmarshalerContext interface {
+	UnmarshalJSONContext(ctx context.Context, data []byte) error
+}
+
 // An UnmarshalTypeError describes a JSON value that was
 // not appropriate for a value of a specific Go type.
 type UnmarshalTypeError struct {
@@ -126,13 +214,18 @@ type UnmarshalTypeError struct {
 	Offset int64        // error occurred after reading Offset bytes
 	Struct string       // name of the struct type containing the field
 	Field  string       // name of the field holding the Go value
+	Path   string       // path to the value in the JSON input, like "a.b[3].c"
 }
 
 func (e *UnmarshalTypeError) Error() string {
+	value := e.Value
+	if e.Path != "" {
+		value += " at " + e.Path
+	}
 	if e.Struct != "" || e.Field != "" {
-		return "json: cannot unmarshal " + e.Value + " into Go struct field " + e.Struct + "." + e.Field + " of type " + e.Type.String()
+		return "json: cannot unmarshal " + value + " into Go struct field " + e.Struct + "." + e.Field + " of type " + e.Type.String()
 	}
-	return "json: cannot unmarshal " + e.Value + " into Go value of type " + e.Type.String()
+	return "json: cannot unmarshal " + value + " into Go value of type " + e.Type.String()
 }
 
 // An UnmarshalFieldError describes a JSON object key that
@@ -184,9 +277,23 @@ func (d *decodeState) unmarshal(v any) (err error) {
 	// We decode rv not rv.Elem because the Unmarshaler interface
 	// test must be applied at the top level of the value.
 	d.value(rv)
+	if d.savedError == nil && len(d.missing) > 0 {
+		return &MissingFieldsError{Paths: d.missing}
+	}
 	return d.savedError
 }
 
+// A MissingFieldsError is returned by Unmarshal when the input lacks members
+// for struct fields with the "required" tag option. Nothing else is wrong
+// with the input, so the value is otherwise fully decoded.
+type MissingFieldsError struct {
+	Paths []string // full paths of all the missing members in document order
+}
+
+func (e *MissingFieldsError) Error() string {
+	return "json: missing required fields: " + strings.Join(e.Paths, ", ")
+}
+
 // A Number represents a JSON number literal.
 type Number string
 
@@ -272,10 +379,193 @@ type decodeState struct {
 	errorContext struct { // provides context for type errors
 		Struct string
 		Field  string
+		Path   []pathElem
 	}
-	savedError       error
+	savedError  error
+	missing     []string    // paths of missing required members
+	bytesFormat bytesFormat // encoding of the []byte field being decoded
+	quotedElems bool        // whether elements of the value being decoded are quoted
+	stopAt      int         // offset of the end of the last member to decode, if not 0
+	ctxValues   int
+	bypass      reflect.Type // type to decode without extensions at the top level
+
+	decodeSettings
+}
+
+// decodeSettings are the options of a decodeState, they're inherited by
+// the Decoders of functions registered with RegisterDecoder.
+type decodeSettings struct {
 	useNumber        bool
 	useOrderedObject bool
+	useBigNumbers    bool
+	useInt64         bool
+	exactNumbers     bool
+	weakTypes        bool
+	quotedInts       bool
+	naming           NamingConvention
+	strictUTF8       bool
+	surrogates       SurrogatePolicy
+	nulls            NullPolicy
+	duplicateKeys    DuplicateKeyPolicy
+	unknownField     func(path, key string, raw RawMessage) // called for members matching no struct field
+	intern           map[string]string                      // interned strings if not nil
+	internMaxLen     int                                    // longest string value to intern
+
+	ctx    context.Context // checked every ctxPeriod values if not nil
+	config *Config         // extensions to use if not nil
+	arena  *Arena          // allocates values decoded into interfaces if not nil
+}
+
+// ctxPeriod is the number of values decoded between context checks.
+const ctxPeriod = 1024
+
+// checkContext aborts decoding if d.ctx is done, it only checks it
+// every ctxPeriod calls.
+func (d *decodeState) checkContext() {
+	d.ctxValues++
+	if d.ctxValues%ctxPeriod != 0 {
+		return
+	}
+	if err := d.ctx.Err(); err != nil {
+		d.error(err)
+	}
+}
+
+// pathElem is an element of the path to the value being decoded.
+type pathElem struct {
+	key   []byte // quoted object member name from the input, nil for array elements
+	index int    // array element index
+}
+
+// formatPath returns the textual representation of p, prepending it to rest
+// (which is the path of the same kind relative to p).
+func formatPath(p []pathElem, rest string) string {
+	var b strings.Builder
+	for _, e := range p {
+		if e.key == nil {
+			b.WriteByte('[')
+			b.WriteString(strconv.Itoa(e.index))
+			b.WriteByte(']')
+			continue
+		}
+		key, _ := unquote(e.key)
+		if isPathIdent(key) {
+			if b.Len() > 0 {
+				b.WriteByte('.')
+			}
+			b.WriteString(key)
+		} else {
+			b.WriteByte('[')
+			b.WriteString(strconv.Quote(key))
+			b.WriteByte(']')
+		}
+	}
+	if rest != "" && rest[0] != '[' && b.Len() > 0 {
+		b.WriteByte('.')
+	}
+	b.WriteString(rest)
+	return b.String()
+}
+
+// isPathIdent reports whether key can be used in a path without quoting.
+func isPathIdent(key string) bool {
+	if key == "" || '0' <= key[0] && key[0] <= '9' {
+		return false
+	}
+	for _, c := range []byte(key) {
+		if c != '_' && !('a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9') {
+			return false
+		}
+	}
+	return true
+}
+
+// pushPath adds a path element for the value being decoded,
+// key is the quoted member name or nil for array elements.
+func (d *decodeState) pushPath(key []byte, index int) {
+	d.errorContext.Path = append(d.errorContext.Path, pathElem{key: key, index: index})
+}
+
+// quoteKey returns key as a JSON string suitable for pathElem.
+func quoteKey(key string) []byte {
+	b, _ := Marshal(key)
+	return b
+}
+
+// popPath removes the last path element.
+func (d *decodeState) popPath() {
+	d.errorContext.Path = d.errorContext.Path[:len(d.errorContext.Path)-1]
+}
+
+// DuplicateKeyPolicy determines how a JSON object containing the same key
+// more than once is decoded. See Decoder.SetDuplicateKeyPolicy.
+type DuplicateKeyPolicy int
+
+const (
+	// KeepAll stores every member of the object. OrderedObject targets get
+	// all the duplicate members in document order, while maps and structs,
+	// which can't hold more than one value per key, end up with the last
+	// value. This is the default policy.
+	KeepAll DuplicateKeyPolicy = iota
+	// FirstWins uses the value of the first occurrence of a key and skips
+	// all subsequent ones.
+	FirstWins
+	// LastWins uses the value of the last occurrence of a key. For
+	// OrderedObject targets the member keeps the position of the first
+	// occurrence, but gets the value of the last one.
+	LastWins
+)
+
+// NullPolicy determines what a JSON null does to a Go value that can't be
+// nil: a boolean, number, string, struct or array. Interfaces, pointers,
+// maps and slices are always set to nil. See Decoder.SetNullPolicy.
+type NullPolicy int
+
+const (
+	// NullIgnore leaves the value untouched. This is the default policy.
+	NullIgnore NullPolicy = iota
+	// NullZero sets the value to the zero value of its type.
+	NullZero
+	// NullError makes decoding fail with an UnmarshalTypeError.
+	NullError
+)
+
+// SurrogatePolicy determines how \u escapes of unpaired UTF-16 surrogates
+// (U+D800 to U+DFFF) in strings are decoded. See
+// Decoder.SetSurrogatePolicy.
+type SurrogatePolicy int
+
+const (
+	// SurrogateReplace replaces them with U+FFFD. This is the default policy.
+	SurrogateReplace SurrogatePolicy = iota
+	// SurrogateError makes decoding fail with a SyntaxError.
+	SurrogateError
+	// SurrogatePreserve keeps them as WTF-8, that is they're encoded like
+	// any other code point producing three bytes that are not valid UTF-8.
+	SurrogatePreserve
+)
+
+// loneSurrogate returns the position of the first \u escape of an unpaired
+// surrogate in the valid JSON b or -1 if there are none.
+func loneSurrogate(b []byte) int {
+	for i := 0; i < len(b); i++ {
+		if b[i] != '\\' {
+			continue
+		}
+		r := getu4(b[i:])
+		if r < 0 {
+			i++ // Skip other escapes (including \\).
+			continue
+		}
+		if utf16.IsSurrogate(r) {
+			if utf16.DecodeRune(r, getu4(b[i+6:])) == unicode.ReplacementChar {
+				return i
+			}
+			i += 6
+		}
+		i += 5
+	}
+	return -1
 }
 
 // errPhase is used for errors that should not happen unless
@@ -287,8 +577,11 @@ func (d *decodeState) init(data []byte) *decodeState {
 	d.data = data
 	d.off = 0
 	d.savedError = nil
+	d.missing = nil
 	d.errorContext.Struct = ""
 	d.errorContext.Field = ""
+	d.errorContext.Path = d.errorContext.Path[:0]
+	d.ctxValues = 0
 	return d
 }
 
@@ -307,11 +600,16 @@ func (d *decodeState) saveError(err error) {
 
 // addErrorContext returns a new error enhanced with information from d.errorContext.
 func (d *decodeState) addErrorContext(err error) error {
-	if d.errorContext.Struct != "" || d.errorContext.Field != "" {
+	if d.errorContext.Struct != "" || d.errorContext.Field != "" || len(d.errorContext.Path) > 0 {
 		var e *UnmarshalTypeError
 		if errors.As(err, &e) {
-			e.Struct = d.errorContext.Struct
-			e.Field = d.errorContext.Field
+			if d.errorContext.Struct != "" || d.errorContext.Field != "" {
+				e.Struct = d.errorContext.Struct
+				e.Field = d.errorContext.Field
+			}
+			if len(d.errorContext.Path) > 0 {
+				e.Path = formatPath(d.errorContext.Path, e.Path)
+			}
 			return e
 		}
 	}
@@ -381,6 +679,9 @@ var (
 // It updates d.off to point past the decoded value. If v is
 // invalid, the JSON value is discarded.
 func (d *decodeState) value(v reflect.Value) {
+	if d.ctx != nil {
+		d.checkContext()
+	}
 	switch op := d.scanWhile(scanSkipSpace); op {
 	default:
 		d.error(errPhase)
@@ -460,7 +761,22 @@ func (d *decodeState) indirect(v reflect.Value, decodingNull bool) (Unmarshaler,
 		if v.IsNil() {
 			v.Set(reflect.New(v.Type().Elem()))
 		}
+		if d.config != nil {
+			if t := v.Type().Elem(); d.bypass == t {
+				d.bypass = nil
+			} else if fn := d.config.decoder(t); fn != nil {
+				return registeredUnmarshaler{fn, v.Elem(), &d.decodeSettings}, nil, reflect.Value{}
+			}
+		}
+		if hasTypeDecoders.Load() {
+			if fn, ok := typeDecoders.Load(v.Type().Elem()); ok {
+				return registeredUnmarshaler{fn.(func(*Decoder, reflect.Value) error), v.Elem(), &d.decodeSettings}, nil, reflect.Value{}
+			}
+		}
 		if v.Type().NumMethod() > 0 {
+			if u, ok := reflect.TypeAssert[UnmarshalerContext](v); ok {
+				return ctxUnmarshaler{u, d.context()}, nil, reflect.Value{}
+			}
 			if u, ok := reflect.TypeAssert[Unmarshaler](v); ok {
 				return u, nil, reflect.Value{}
 			}
@@ -475,9 +791,29 @@ func (d *decodeState) indirect(v reflect.Value, decodingNull bool) (Unmarshaler,
 	return nil, nil, v
 }
 
+// context returns the context for UnmarshalerContext values.
+func (d *decodeState) context() context.Context {
+	if d.ctx == nil {
+		return context.Background()
+	}
+	return d.ctx
+}
+
+// ctxUnmarshaler adapts an UnmarshalerContext to Unmarshaler.
+type ctxUnmarshaler struct {
+	u   UnmarshalerContext
+	ctx context.Context
+}
+
+func (c ctxUnmarshaler) UnmarshalJSON(data []byte) error {
+	return c.u.UnmarshalJSONContext(c.ctx, data)
+}
+
 // array consumes an array from d.data[d.off-1:], decoding into the value v.
 // the first byte of the array ('[') has been read already.
 func (d *decodeState) array(v reflect.Value) {
+	quoted := d.quotedElems
+	d.quotedElems = false
 	// Check for unmarshaler.
 	u, ut, pv := d.indirect(v, false)
 	if u != nil {
@@ -502,7 +838,7 @@ func (d *decodeState) array(v reflect.Value) {
 	case reflect.Interface:
 		if v.NumMethod() == 0 {
 			// Decoding into nil interface?  Switch to non-reflect code.
-			v.Set(reflect.ValueOf(d.arrayInterface()))
+			v.Set(reflect.ValueOf(d.arena.array(d.arrayInterface())))
 			return
 		}
 		// Otherwise it's invalid.
@@ -542,13 +878,19 @@ func (d *decodeState) array(v reflect.Value) {
 			}
 		}
 
+		d.pushPath(nil, i)
 		if i < v.Len() {
 			// Decode into element.
-			d.value(v.Index(i))
+			if quoted {
+				d.quotedValue(v.Index(i))
+			} else {
+				d.value(v.Index(i))
+			}
 		} else {
 			// Ran out of fixed array: skip.
 			d.value(reflect.Value{})
 		}
+		d.popPath()
 		i++
 
 		// Next token must be , or ].
@@ -578,11 +920,16 @@ func (d *decodeState) array(v reflect.Value) {
 }
 
 var nullLiteral = []byte("null")
-var textUnmarshalerType = reflect.TypeFor[encoding.TextUnmarshaler]()
+var (
+	textUnmarshalerType = reflect.TypeFor[encoding.TextUnmarshaler]()
+	fieldSetterType     = reflect.TypeFor[FieldSetter]()
+)
 
 // object consumes an object from d.data[d.off-1:], decoding into the value v.
 // the first byte ('{') of the object has been read already.
 func (d *decodeState) object(v reflect.Value) {
+	quoted := d.quotedElems
+	d.quotedElems = false
 	// Check for unmarshaler.
 	u, ut, pv := d.indirect(v, false)
 	if u != nil {
@@ -644,7 +991,29 @@ func (d *decodeState) object(v reflect.Value) {
 		return
 	}
 
-	var mapElem reflect.Value
+	var (
+		mapElem reflect.Value
+		seen    map[string]struct{} // keys already decoded, only used by FirstWins
+		inline  *field              // the field collecting unknown members, if any
+		fields  []field
+		present []bool // fields found in the input, tracked for defaults, required fields and FieldSetter
+		setter  FieldSetter
+	)
+	if d.duplicateKeys == FirstWins {
+		seen = make(map[string]struct{})
+	}
+	if v.Kind() == reflect.Struct {
+		fields = d.config.typeFields(v.Type())
+		if v.CanAddr() && reflect.PointerTo(v.Type()).Implements(fieldSetterType) {
+			setter, _ = reflect.TypeAssert[FieldSetter](v.Addr())
+			present = make([]bool, len(fields))
+		}
+		for i := 0; present == nil && i < len(fields); i++ {
+			if fields[i].defValue != nil || fields[i].required {
+				present = make([]bool, len(fields))
+			}
+		}
+	}
 
 	for {
 		// Read opening " of string key or closing }.
@@ -661,16 +1030,28 @@ func (d *decodeState) object(v reflect.Value) {
 		start := d.off - 1
 		op = d.scanWhile(scanContinue)
 		item := d.data[start : d.off-1]
-		key, ok := unquoteBytes(item)
+		key, ok := d.unquoteBytes(item)
 		if !ok {
 			d.error(errPhase)
 		}
 
 		// Figure out field corresponding to key.
-		var subv reflect.Value
-		destring := false // whether the value is wrapped in a string to be decoded first
+		var (
+			subv      reflect.Value
+			destring  bool // whether the value is wrapped in a string to be decoded first
+			elems     bool // whether the elements of the value are wrapped in strings
+			duplicate bool
+			format    bytesFormat // encoding of a []byte field
+			layout    string      // format of a time.Time field
+			unknown   bool        // whether the member goes to the inline field
+			report    bool        // whether the member is to be passed to d.unknownField
+		)
 
 		if v.Kind() == reflect.Map {
+			if seen != nil {
+				_, duplicate = seen[string(key)]
+				seen[string(key)] = struct{}{}
+			}
 			elemType := v.Type().Elem()
 			if !mapElem.IsValid() {
 				mapElem = reflect.New(elemType).Elem()
@@ -678,32 +1059,55 @@ func (d *decodeState) object(v reflect.Value) {
 				mapElem.Set(reflect.Zero(elemType))
 			}
 			subv = mapElem
+			destring = quoted
 		} else {
 			var f *field
-			fields := cachedTypeFields(v.Type())
+			fi := -1
 			for i := range fields {
 				ff := &fields[i]
-				if bytes.Equal(ff.nameBytes, key) {
-					f = ff
+				if ff.inline {
+					if inline == nil {
+						inline = ff
+					}
+					continue
+				}
+				nameBytes, equalFold := ff.nameBytes, ff.equalFold
+				if d.naming != KeepNames && ff.conv != nil {
+					n := &ff.conv[d.naming-1]
+					nameBytes, equalFold = n.nameBytes, n.equalFold
+				}
+				if bytes.Equal(nameBytes, key) {
+					f, fi = ff, i
 					break
 				}
-				if f == nil && ff.equalFold(ff.nameBytes, key) {
-					f = ff
+				if f == nil && equalFold(nameBytes, key) {
+					f, fi = ff, i
 				}
 			}
-			if f != nil {
-				subv = v
+			if f != nil && present != nil {
+				present[fi] = true
+			}
+			if f != nil && seen != nil {
+				_, duplicate = seen[f.name]
+				seen[f.name] = struct{}{}
+			}
+			if f == nil && inline != nil {
+				unknown = true
+				f = inline
+			}
+			// Skipped objects are decoded into discardObject which is not
+			// addressable, only the members of real targets are reported.
+			report = f == nil && d.unknownField != nil && v.CanAddr()
+			if f != nil && !duplicate {
+				subv = structField(v, f.index)
 				destring = f.quoted
-				for _, i := range f.index {
-					if subv.Kind() == reflect.Ptr {
-						if subv.IsNil() {
-							subv.Set(reflect.New(subv.Type().Elem()))
-						}
-						subv = subv.Elem()
-					}
-					subv = subv.Field(i)
-				}
+				elems = f.quotedElems
+				format = f.format
+				layout = f.timeFormat
 				d.errorContext.Field = f.name
+				if unknown {
+					d.errorContext.Field = string(key)
+				}
 				d.errorContext.Struct = v.Type().Name()
 			}
 		}
@@ -716,27 +1120,37 @@ func (d *decodeState) object(v reflect.Value) {
 			d.error(errPhase)
 		}
 
-		if destring {
-			switch qv := d.valueQuoted().(type) {
-			case nil:
-				d.literalStore(nullLiteral, subv, false)
-			case string:
-				d.literalStore([]byte(qv), subv, true)
-			default:
-				d.saveError(fmt.Errorf("json: invalid use of ,string struct tag, trying to unmarshal unquoted value into %v", subv.Type()))
-			}
+		d.pushPath(item, 0)
+		if unknown {
+			d.inlineValue(subv, d.keyString(key))
+		} else if report {
+			start := d.off
+			d.value(reflect.Value{})
+			raw := bytes.TrimLeft(d.data[start:d.off], " \t\r\n")
+			p := d.errorContext.Path
+			d.unknownField(formatPath(p[:len(p)-1], ""), string(key), raw)
+		} else if duplicate {
+			d.value(reflect.Value{})
+		} else if layout != "" {
+			d.timeValue(subv, layout)
+		} else if destring {
+			d.quotedValue(subv)
 		} else {
+			d.bytesFormat = format
+			d.quotedElems = elems
 			d.value(subv)
+			d.bytesFormat = bytesBase64
+			d.quotedElems = false
 		}
 
 		// Write value back to map;
 		// if using struct, subv points into struct already.
-		if v.Kind() == reflect.Map {
+		if v.Kind() == reflect.Map && !duplicate {
 			kt := v.Type().Key()
 			var kv reflect.Value
 			switch {
 			case kt.Kind() == reflect.String:
-				kv = reflect.ValueOf(key).Convert(kt)
+				kv = reflect.ValueOf(d.keyString(key)).Convert(kt)
 			case reflect.PointerTo(kt).Implements(textUnmarshalerType):
 				kv = reflect.New(v.Type().Key())
 				d.literalStore(item, kv, true)
@@ -748,6 +1162,7 @@ func (d *decodeState) object(v reflect.Value) {
 					n, err := strconv.ParseInt(s, 10, 64)
 					if err != nil || reflect.Zero(kt).OverflowInt(n) {
 						d.saveError(&UnmarshalTypeError{Value: "number " + s, Type: kt, Offset: int64(start + 1)})
+						d.popPath()
 						return
 					}
 					kv = reflect.ValueOf(n).Convert(kt)
@@ -756,6 +1171,7 @@ func (d *decodeState) object(v reflect.Value) {
 					n, err := strconv.ParseUint(s, 10, 64)
 					if err != nil || reflect.Zero(kt).OverflowUint(n) {
 						d.saveError(&UnmarshalTypeError{Value: "number " + s, Type: kt, Offset: int64(start + 1)})
+						d.popPath()
 						return
 					}
 					kv = reflect.ValueOf(n).Convert(kt)
@@ -765,6 +1181,11 @@ func (d *decodeState) object(v reflect.Value) {
 			}
 			v.SetMapIndex(kv, subv)
 		}
+		d.popPath()
+		if d.off == d.stopAt {
+			// Only the top-level object can have a member ending there.
+			return
+		}
 
 		// Next token must be , or }.
 		op = d.scanWhile(scanSkipSpace)
@@ -778,6 +1199,67 @@ func (d *decodeState) object(v reflect.Value) {
 		d.errorContext.Struct = ""
 		d.errorContext.Field = ""
 	}
+
+	for i := range present {
+		f := &fields[i]
+		if present[i] {
+			continue
+		}
+		if f.required {
+			d.pushPath(quoteKey(f.name), 0)
+			d.missing = append(d.missing, formatPath(d.errorContext.Path, ""))
+			d.popPath()
+		}
+		if f.defValue != nil {
+			if err := Unmarshal(f.defValue, structField(v, f.index).Addr().Interface()); err != nil {
+				d.saveError(fmt.Errorf("json: invalid default value of %s.%s: %w", v.Type().Name(), f.name, err))
+			}
+		}
+	}
+	if setter != nil {
+		set := make(map[string]bool, len(fields))
+		for i := range fields {
+			if !fields[i].inline {
+				set[fields[i].name] = present[i]
+			}
+		}
+		setter.SetFields(set)
+	}
+}
+
+// structField returns the field of the struct v with the given index
+// sequence allocating embedded structs pointers on the way.
+func structField(v reflect.Value, index []int) reflect.Value {
+	for _, i := range index {
+		if v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(i)
+	}
+	return v
+}
+
+// inlineValue stores the next value with the given key into the map v or
+// appends it as a member to the OrderedObject v, objects inside of the
+// latter are decoded as OrderedObject too.
+func (d *decodeState) inlineValue(v reflect.Value, key string) {
+	if v.Kind() == reflect.Map {
+		if v.IsNil() {
+			v.Set(reflect.MakeMap(v.Type()))
+		}
+		elem := reflect.New(v.Type().Elem()).Elem()
+		d.value(elem)
+		v.SetMapIndex(reflect.ValueOf(key).Convert(v.Type().Key()), elem)
+		return
+	}
+	useOrderedObject := d.useOrderedObject
+	d.useOrderedObject = true
+	val := d.valueInterface()
+	d.useOrderedObject = useOrderedObject
+	v.Set(reflect.Append(v, reflect.ValueOf(Member{Key: key, Value: val})))
 }
 
 // literal consumes a literal from d.data[d.off-1:], decoding into the value v.
@@ -797,17 +1279,182 @@ func (d *decodeState) literal(v reflect.Value) {
 	}
 }
 
+// decodeBytes decodes the []byte value encoded as the string s according
+// to d.bytesFormat.
+func (d *decodeState) decodeBytes(s []byte) ([]byte, error) {
+	switch d.bytesFormat {
+	case bytesHex:
+		if len(s)%2 != 0 {
+			return nil, errors.New("json: odd length hex string")
+		}
+		b := make([]byte, len(s)/2)
+		for i := range b {
+			hi, ok1 := unhex(s[2*i])
+			lo, ok2 := unhex(s[2*i+1])
+			if !ok1 || !ok2 {
+				return nil, fmt.Errorf("json: invalid hex string %q", s)
+			}
+			b[i] = hi<<4 | lo
+		}
+		return b, nil
+	case bytesBase64URL:
+		s = bytes.TrimSuffix(bytes.TrimSuffix(s, []byte("=")), []byte("="))
+		b := make([]byte, base64.RawURLEncoding.DecodedLen(len(s)))
+		n, err := base64.RawURLEncoding.Decode(b, s)
+		return b[:n], err
+	}
+	b := make([]byte, base64.StdEncoding.DecodedLen(len(s)))
+	n, err := base64.StdEncoding.Decode(b, s)
+	return b[:n], err
+}
+
+// unhex returns the value of the hex digit c.
+func unhex(c byte) (byte, bool) {
+	switch {
+	case '0' <= c && c <= '9':
+		return c - '0', true
+	case 'a' <= c && c <= 'f':
+		return c - 'a' + 10, true
+	case 'A' <= c && c <= 'F':
+		return c - 'A' + 10, true
+	}
+	return 0, false
+}
+
+// weakString stores the string s into the bool or number v if it can be
+// converted, it returns false otherwise. An empty string means false or 0.
+func (d *decodeState) weakString(s []byte, v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64:
+	default:
+		return false
+	}
+	if len(s) == 0 {
+		v.SetZero()
+		return true
+	}
+	if v.Kind() == reflect.Bool {
+		b, err := strconv.ParseBool(string(s))
+		if err != nil {
+			return false
+		}
+		v.SetBool(b)
+		return true
+	}
+	if !isValidNumber(string(s)) {
+		return false
+	}
+	d.literalStore(s, v, false)
+	return true
+}
+
+// quotedValue decodes the value wrapped in a string (or null) into v as
+// the ",string" option requires it.
+func (d *decodeState) quotedValue(v reflect.Value) {
+	switch qv := d.valueQuoted().(type) {
+	case nil:
+		d.literalStore(nullLiteral, v, false)
+	case string:
+		d.literalStore([]byte(qv), v, true)
+	default:
+		d.saveError(fmt.Errorf("json: invalid use of ,string struct tag, trying to unmarshal unquoted value into %v", v.Type()))
+	}
+}
+
+// quotedInt stores the integer held by the string s into the integer v, it
+// returns false if v is not an integer or s is not an integer literal.
+func (d *decodeState) quotedInt(s []byte, v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+	default:
+		return false
+	}
+	if !isValidNumber(string(s)) || bytes.ContainsAny(s, ".eE") {
+		return false
+	}
+	d.literalStore(s, v, false)
+	return true
+}
+
 // convertNumber converts the number literal s to a float64 or a Number
-// depending on the setting of d.useNumber.
+// depending on the setting of d.useNumber, oversized numbers are converted
+// to *big.Int or *big.Float if d.useBigNumbers is set and integers are
+// converted to int64 (or Number if they don't fit) if d.useInt64 is set.
 func (d *decodeState) convertNumber(s string) (any, error) {
+	if d.useBigNumbers {
+		if n, ok := bigNumber(s); ok {
+			return n, nil
+		}
+	}
+	if d.useInt64 && strings.IndexAny(s, ".eE") < 0 {
+		if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return d.arena.int64(n), nil
+		}
+		return d.arena.number(s), nil
+	}
 	if d.useNumber {
-		return Number(s), nil
+		return d.arena.number(s), nil
 	}
 	f, err := strconv.ParseFloat(s, 64)
-	if err != nil {
+	if err != nil || d.exactNumbers && !isExactFloat(s, f, 64) {
 		return nil, &UnmarshalTypeError{Value: "number " + s, Type: reflect.TypeFor[float64](), Offset: int64(d.off)}
 	}
-	return f, nil
+	return d.arena.float(f), nil
+}
+
+// isExactFloat reports whether the number literal s can be restored from its
+// floating-point approximation f of the given size, that is whether the
+// shortest decimal representation of f has the same value as s.
+func isExactFloat(s string, f float64, bitSize int) bool {
+	mant, exp := s, ""
+	if i := strings.IndexAny(s, "eE"); i >= 0 {
+		mant, exp = s[:i], s[i+1:]
+	}
+	if !strings.ContainsAny(mant, "123456789") {
+		return true // Zero is always exact.
+	}
+	if f == 0 {
+		return false // Underflow.
+	}
+	// Finite non-zero floats are within 1e±325, values with greater
+	// exponents can't be them and would make big.Rat compute huge powers
+	// of ten.
+	if exp != "" {
+		e, err := strconv.Atoi(exp)
+		if limit := 350 + len(mant); err != nil || e > limit || e < -limit {
+			return false
+		}
+	}
+	x, ok := new(big.Rat).SetString(s)
+	if !ok {
+		return false
+	}
+	y, ok := new(big.Rat).SetString(strconv.FormatFloat(f, 'g', -1, bitSize))
+	return ok && x.Cmp(y) == 0
+}
+
+// bigNumber returns s as a *big.Int if it's an integer that doesn't fit
+// into int64 and uint64 or as a *big.Float if it's out of float64 range.
+func bigNumber(s string) (any, bool) {
+	if strings.IndexAny(s, ".eE") < 0 {
+		if _, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return nil, false
+		}
+		if _, err := strconv.ParseUint(s, 10, 64); err == nil {
+			return nil, false
+		}
+		n, ok := new(big.Int).SetString(s, 10)
+		return n, ok
+	}
+	if _, err := strconv.ParseFloat(s, 64); err == nil {
+		return nil, false
+	}
+	f, _, err := big.ParseFloat(s, 10, max(64, 4*uint(len(s))), big.ToNearestEven)
+	return f, err == nil
 }
 
 var numberType = reflect.TypeFor[Number]()
@@ -851,7 +1498,7 @@ func (d *decodeState) literalStore(item []byte, v reflect.Value, fromQuoted bool
 			}
 			return
 		}
-		s, ok := unquoteBytes(item)
+		s, ok := d.unquoteBytes(item)
 		if !ok {
 			if fromQuoted {
 				d.error(fmt.Errorf("json: invalid use of ,string struct tag, trying to unmarshal %q into %v", item, v.Type()))
@@ -879,8 +1526,14 @@ func (d *decodeState) literalStore(item []byte, v reflect.Value, fromQuoted bool
 		switch v.Kind() {
 		case reflect.Interface, reflect.Ptr, reflect.Map, reflect.Slice:
 			v.Set(reflect.Zero(v.Type()))
-			// otherwise, ignore null for primitives/string
+			// otherwise, ignore null for primitives/string unless told otherwise
 		default:
+			switch d.nulls {
+			case NullZero:
+				v.Set(reflect.Zero(v.Type()))
+			case NullError:
+				d.saveError(&UnmarshalTypeError{Value: "null", Type: v.Type(), Offset: int64(d.off)})
+			}
 		}
 	case 't', 'f': // true, false
 		value := item[0] == 't'
@@ -908,7 +1561,7 @@ func (d *decodeState) literalStore(item []byte, v reflect.Value, fromQuoted bool
 		}
 
 	case '"': // string
-		s, ok := unquoteBytes(item)
+		s, ok := d.unquoteBytes(item)
 		if !ok {
 			if fromQuoted {
 				d.error(fmt.Errorf("json: invalid use of ,string struct tag, trying to unmarshal %q into %v", item, v.Type()))
@@ -918,24 +1571,29 @@ func (d *decodeState) literalStore(item []byte, v reflect.Value, fromQuoted bool
 		}
 		switch v.Kind() {
 		default:
+			if d.weakTypes && d.weakString(s, v) {
+				break
+			}
+			if d.quotedInts && d.quotedInt(s, v) {
+				break
+			}
 			d.saveError(&UnmarshalTypeError{Value: "string", Type: v.Type(), Offset: int64(d.off)})
 		case reflect.Slice:
 			if v.Type().Elem().Kind() != reflect.Uint8 {
 				d.saveError(&UnmarshalTypeError{Value: "string", Type: v.Type(), Offset: int64(d.off)})
 				break
 			}
-			b := make([]byte, base64.StdEncoding.DecodedLen(len(s)))
-			n, err := base64.StdEncoding.Decode(b, s)
+			b, err := d.decodeBytes(s)
 			if err != nil {
 				d.saveError(err)
 				break
 			}
-			v.SetBytes(b[:n])
+			v.SetBytes(b)
 		case reflect.String:
-			v.SetString(string(s))
+			v.SetString(d.valueString(s))
 		case reflect.Interface:
 			if v.NumMethod() == 0 {
-				v.Set(reflect.ValueOf(string(s)))
+				v.Set(reflect.ValueOf(d.valueString(s)))
 			} else {
 				d.saveError(&UnmarshalTypeError{Value: "string", Type: v.Type(), Offset: int64(d.off)})
 			}
@@ -959,6 +1617,17 @@ func (d *decodeState) literalStore(item []byte, v reflect.Value, fromQuoted bool
 				}
 				break
 			}
+			if d.weakTypes && !fromQuoted {
+				if v.Kind() == reflect.String {
+					v.SetString(s)
+					break
+				}
+				if v.Kind() == reflect.Bool {
+					f, _ := strconv.ParseFloat(s, 64)
+					v.SetBool(f != 0)
+					break
+				}
+			}
 			if fromQuoted {
 				d.error(fmt.Errorf("json: invalid use of ,string struct tag, trying to unmarshal %q into %v", item, v.Type()))
 			} else {
@@ -994,7 +1663,7 @@ func (d *decodeState) literalStore(item []byte, v reflect.Value, fromQuoted bool
 
 		case reflect.Float32, reflect.Float64:
 			n, err := strconv.ParseFloat(s, v.Type().Bits())
-			if err != nil || v.OverflowFloat(n) {
+			if err != nil || v.OverflowFloat(n) || d.exactNumbers && !isExactFloat(s, n, v.Type().Bits()) {
 				d.saveError(&UnmarshalTypeError{Value: "number " + s, Type: v.Type(), Offset: int64(d.off)})
 				break
 			}
@@ -1009,12 +1678,15 @@ func (d *decodeState) literalStore(item []byte, v reflect.Value, fromQuoted bool
 
 // valueInterface is like value but returns any.
 func (d *decodeState) valueInterface() any {
+	if d.ctx != nil {
+		d.checkContext()
+	}
 	switch d.scanWhile(scanSkipSpace) {
 	default:
 		d.error(errPhase)
 		panic("unreachable")
 	case scanBeginArray:
-		return d.arrayInterface()
+		return d.arena.array(d.arrayInterface())
 	case scanBeginObject:
 		return d.objectInterface(false)
 	case scanBeginLiteral:
@@ -1024,7 +1696,8 @@ func (d *decodeState) valueInterface() any {
 
 // arrayInterface is like array but returns []any.
 func (d *decodeState) arrayInterface() []any {
-	var v = make([]any, 0)
+	level := len(d.errorContext.Path)
+	v := d.arena.valueBuf(level)
 	for {
 		// Look ahead for ] - can only happen on first iteration.
 		op := d.scanWhile(scanSkipSpace)
@@ -1036,7 +1709,9 @@ func (d *decodeState) arrayInterface() []any {
 		d.off--
 		d.scan.undo(op)
 
+		d.pushPath(nil, len(v))
 		v = append(v, d.valueInterface())
+		d.popPath()
 
 		// Next token must be , or ].
 		op = d.scanWhile(scanSkipSpace)
@@ -1047,13 +1722,27 @@ func (d *decodeState) arrayInterface() []any {
 			d.error(errPhase)
 		}
 	}
-	return v
+	return d.arena.keepValues(level, v)
 }
 
 // objectInterface is like object but returns map[string]any or []OrderedObject.
 func (d *decodeState) objectInterface(forceOrderedObject bool) any {
-	m := make(map[string]any)
-	v := make(OrderedObject, 0)
+	ordered := d.useOrderedObject || forceOrderedObject
+	level := len(d.errorContext.Path)
+	var m map[string]any
+	var v OrderedObject
+	if ordered {
+		v = d.arena.memberBuf(level)
+	} else {
+		m = make(map[string]any)
+	}
+
+	// index maps keys to their position in v,
+	// it's only needed to resolve duplicates.
+	var index map[string]int
+	if ordered && d.duplicateKeys != KeepAll {
+		index = make(map[string]int)
+	}
 	for {
 		// Read opening " of string key or closing }.
 		op := d.scanWhile(scanSkipSpace)
@@ -1069,10 +1758,11 @@ func (d *decodeState) objectInterface(forceOrderedObject bool) any {
 		start := d.off - 1
 		op = d.scanWhile(scanContinue)
 		item := d.data[start : d.off-1]
-		key, ok := unquote(item)
+		rawKey, ok := d.unquoteBytes(item)
 		if !ok {
 			d.error(errPhase)
 		}
+		key := d.keyString(rawKey)
 
 		// Read : before value.
 		if op == scanSkipSpace {
@@ -1083,10 +1773,31 @@ func (d *decodeState) objectInterface(forceOrderedObject bool) any {
 		}
 
 		// Read value.
-		if d.useOrderedObject || forceOrderedObject {
+		d.pushPath(item, 0)
+		switch {
+		case index != nil:
+			i, duplicate := index[key]
+			switch {
+			case !duplicate:
+				index[key] = len(v)
+				v = append(v, Member{Key: key, Value: d.valueInterface()})
+			case d.duplicateKeys == LastWins:
+				v[i].Value = d.valueInterface()
+			default:
+				d.value(reflect.Value{})
+			}
+		case ordered:
 			v = append(v, Member{Key: key, Value: d.valueInterface()})
-		} else {
-			m[key] = d.valueInterface()
+		default:
+			if _, duplicate := m[key]; duplicate && d.duplicateKeys == FirstWins {
+				d.value(reflect.Value{})
+			} else {
+				m[key] = d.valueInterface()
+			}
+		}
+		d.popPath()
+		if d.off == d.stopAt {
+			break
 		}
 
 		// Next token must be , or }.
@@ -1099,8 +1810,8 @@ func (d *decodeState) objectInterface(forceOrderedObject bool) any {
 		}
 	}
 
-	if d.useOrderedObject || forceOrderedObject {
-		return v
+	if ordered {
+		return d.arena.object(d.arena.keepMembers(level, v))
 	}
 	return m
 }
@@ -1124,17 +1835,17 @@ func (d *decodeState) literalInterface() any {
 		return c == 't'
 
 	case '"': // string
-		s, ok := unquote(item)
+		s, ok := d.unquoteBytes(item)
 		if !ok {
 			d.error(errPhase)
 		}
-		return s
+		return d.arena.stringValue(d.valueString(s))
 
 	default: // number
 		if c != '-' && (c < '0' || c > '9') {
 			d.error(errPhase)
 		}
-		n, err := d.convertNumber(string(item))
+		n, err := d.convertNumber(d.arena.string(item))
 		if err != nil {
 			d.saveError(err)
 		}
@@ -1164,6 +1875,59 @@ func unquote(s []byte) (t string, ok bool) {
 }
 
 func unquoteBytes(s []byte) (t []byte, ok bool) {
+	return unquoteBytesWTF8(s, false)
+}
+
+// internMaxEntries limits the number of strings interned by a decodeState.
+const internMaxEntries = 1 << 14
+
+// keyString returns the object key b as a string, interned if d.intern is
+// enabled.
+func (d *decodeState) keyString(b []byte) string {
+	if d.intern == nil {
+		return d.arena.string(b)
+	}
+	return d.internString(b)
+}
+
+// valueString returns the string value b as a string, interned if it's
+// not longer than d.internMaxLen.
+func (d *decodeState) valueString(b []byte) string {
+	if d.intern == nil || len(b) > d.internMaxLen {
+		return d.arena.string(b)
+	}
+	return d.internString(b)
+}
+
+// internString returns the string from d.intern equal to b, adding it there
+// unless the table is full.
+func (d *decodeState) internString(b []byte) string {
+	if s, ok := d.intern[string(b)]; ok {
+		return s
+	}
+	s := string(b)
+	if len(d.intern) < internMaxEntries {
+		d.intern[s] = s
+	}
+	return s
+}
+
+// unquote is like the package-level unquote, but follows d.surrogates.
+func (d *decodeState) unquote(s []byte) (t string, ok bool) {
+	s, ok = d.unquoteBytes(s)
+	t = string(s)
+	return
+}
+
+// unquoteBytes is like the package-level unquoteBytes, but follows
+// d.surrogates.
+func (d *decodeState) unquoteBytes(s []byte) (t []byte, ok bool) {
+	return unquoteBytesWTF8(s, d.surrogates == SurrogatePreserve)
+}
+
+// unquoteBytesWTF8 unquotes s encoding unpaired surrogates as WTF-8 if wtf8
+// is set or replacing them with U+FFFD otherwise.
+func unquoteBytesWTF8(s []byte, wtf8 bool) (t []byte, ok bool) {
 	if len(s) < 2 || s[0] != '"' || s[len(s)-1] != '"' {
 		return
 	}
@@ -1251,6 +2015,13 @@ func unquoteBytes(s []byte) (t []byte, ok bool) {
 						w += utf8.EncodeRune(b[w:], dec)
 						break
 					}
+					if wtf8 {
+						b[w] = 0xE0 | byte(rr>>12)
+						b[w+1] = 0x80 | byte(rr>>6)&0x3F
+						b[w+2] = 0x80 | byte(rr)&0x3F
+						w += 3
+						break
+					}
 					// Invalid surrogate; fall back to replacement rune.
 					rr = unicode.ReplacementChar
 				}
@@ -6,6 +6,7 @@ package json
 
 import (
 	"bytes"
+	"context"
 	"encoding"
 	"errors"
 	"fmt"
@@ -400,7 +401,7 @@ var unmarshalTests = []unmarshalTest{
 	{in: `"g-clef: \uD834\uDD1E"`, ptr: new(string), out: "g-clef: \U0001D11E"},
 	{in: `"invalid: \uD834x\uDD1E"`, ptr: new(string), out: "invalid: \uFFFDx\uFFFD"},
 	{in: "null", ptr: new(any), out: nil},
-	{in: `{"X": [1,2,3], "Y": 4}`, ptr: new(T), out: T{Y: 4}, err: &UnmarshalTypeError{"array", reflect.TypeFor[string](), 7, "T", "X"}},
+	{in: `{"X": [1,2,3], "Y": 4}`, ptr: new(T), out: T{Y: 4}, err: &UnmarshalTypeError{"array", reflect.TypeFor[string](), 7, "T", "X", "X"}},
 	{in: `{"x": 1}`, ptr: new(tx), out: tx{}},
 	{in: `{"F1":1,"F2":2,"F3":3}`, ptr: new(V), out: V{F1: float64(1), F2: int32(2), F3: Number("3")}},
 	{in: `{"F1":1,"F2":2,"F3":3}`, ptr: new(V), out: V{F1: Number("1"), F2: int32(2), F3: Number("3")}, useNumber: true},
@@ -422,19 +423,19 @@ var unmarshalTests = []unmarshalTest{
 	{in: `{"alphabet": "xyz"}`, ptr: new(U), out: U{}},
 
 	// syntax errors
-	{in: `{"X": "foo", "Y"}`, err: &SyntaxError{"invalid character '}' after object key", 17}},
-	{in: `[1, 2, 3+]`, err: &SyntaxError{"invalid character '+' after array element", 9}},
-	{in: `{"X":12x}`, err: &SyntaxError{"invalid character 'x' after object key:value pair", 8}, useNumber: true},
+	{in: `{"X": "foo", "Y"}`, err: &SyntaxError{"invalid character '}' after object key", 17, 1, 17, `{"X": "foo", "Y"}`}},
+	{in: `[1, 2, 3+]`, err: &SyntaxError{"invalid character '+' after array element", 9, 1, 9, `[1, 2, 3+]`}},
+	{in: `{"X":12x}`, err: &SyntaxError{"invalid character 'x' after object key:value pair", 8, 1, 8, `{"X":12x}`}, useNumber: true},
 
 	// raw value errors
-	{in: "\x01 42", err: &SyntaxError{"invalid character '\\x01' looking for beginning of value", 1}},
-	{in: " 42 \x01", err: &SyntaxError{"invalid character '\\x01' after top-level value", 5}},
-	{in: "\x01 true", err: &SyntaxError{"invalid character '\\x01' looking for beginning of value", 1}},
-	{in: " false \x01", err: &SyntaxError{"invalid character '\\x01' after top-level value", 8}},
-	{in: "\x01 1.2", err: &SyntaxError{"invalid character '\\x01' looking for beginning of value", 1}},
-	{in: " 3.4 \x01", err: &SyntaxError{"invalid character '\\x01' after top-level value", 6}},
-	{in: "\x01 \"string\"", err: &SyntaxError{"invalid character '\\x01' looking for beginning of value", 1}},
-	{in: " \"string\" \x01", err: &SyntaxError{"invalid character '\\x01' after top-level value", 11}},
+	{in: "\x01 42", err: &SyntaxError{"invalid character '\\x01' looking for beginning of value", 1, 1, 1, "\u0001 42"}},
+	{in: " 42 \x01", err: &SyntaxError{"invalid character '\\x01' after top-level value", 5, 1, 5, " 42 \u0001"}},
+	{in: "\x01 true", err: &SyntaxError{"invalid character '\\x01' looking for beginning of value", 1, 1, 1, "\u0001 true"}},
+	{in: " false \x01", err: &SyntaxError{"invalid character '\\x01' after top-level value", 8, 1, 8, " false \u0001"}},
+	{in: "\x01 1.2", err: &SyntaxError{"invalid character '\\x01' looking for beginning of value", 1, 1, 1, "\u0001 1.2"}},
+	{in: " 3.4 \x01", err: &SyntaxError{"invalid character '\\x01' after top-level value", 6, 1, 6, " 3.4 \u0001"}},
+	{in: "\x01 \"string\"", err: &SyntaxError{"invalid character '\\x01' looking for beginning of value", 1, 1, 1, "\u0001 \"string\""}},
+	{in: " \"string\" \x01", err: &SyntaxError{"invalid character '\\x01' after top-level value", 11, 1, 11, " \"string\" \u0001"}},
 
 	// array tests
 	{in: `[1, 2, 3]`, ptr: new([3]int), out: [3]int{1, 2, 3}},
@@ -515,22 +516,22 @@ var unmarshalTests = []unmarshalTest{
 	{
 		in:  `{"abc":"abc"}`,
 		ptr: new(map[int]string),
-		err: &UnmarshalTypeError{Value: "number abc", Type: reflect.TypeFor[int](), Offset: 2},
+		err: &UnmarshalTypeError{Value: "number abc", Type: reflect.TypeFor[int](), Offset: 2, Path: `abc`},
 	},
 	{
 		in:  `{"256":"abc"}`,
 		ptr: new(map[uint8]string),
-		err: &UnmarshalTypeError{Value: "number 256", Type: reflect.TypeFor[uint8](), Offset: 2},
+		err: &UnmarshalTypeError{Value: "number 256", Type: reflect.TypeFor[uint8](), Offset: 2, Path: `["256"]`},
 	},
 	{
 		in:  `{"128":"abc"}`,
 		ptr: new(map[int8]string),
-		err: &UnmarshalTypeError{Value: "number 128", Type: reflect.TypeFor[int8](), Offset: 2},
+		err: &UnmarshalTypeError{Value: "number 128", Type: reflect.TypeFor[int8](), Offset: 2, Path: `["128"]`},
 	},
 	{
 		in:  `{"-1":"abc"}`,
 		ptr: new(map[uint8]string),
-		err: &UnmarshalTypeError{Value: "number -1", Type: reflect.TypeFor[uint8](), Offset: 2},
+		err: &UnmarshalTypeError{Value: "number -1", Type: reflect.TypeFor[uint8](), Offset: 2, Path: `["-1"]`},
 	},
 
 	// Map keys can be encoding.TextUnmarshalers.
@@ -771,6 +772,7 @@ var unmarshalTests = []unmarshalTest{
 			Field:  "F2",
 			Type:   reflect.TypeFor[int32](),
 			Offset: 20,
+			Path:   "V.F2",
 		},
 	},
 	{
@@ -782,6 +784,7 @@ var unmarshalTests = []unmarshalTest{
 			Field:  "F2",
 			Type:   reflect.TypeFor[int32](),
 			Offset: 30,
+			Path:   "V.F2",
 		},
 	},
 
@@ -2071,3 +2074,627 @@ func TestUnmarshalOrdered(t *testing.T) {
 		t.Errorf("%v, want %v", v, exp)
 	}
 }
+
+func TestDuplicateKeyPolicy(t *testing.T) {
+	const in = `{"A": 1, "B": 2, "A": 3, "a": 4}`
+	type S struct {
+		A int
+		B int
+	}
+	var tests = []struct {
+		policy  DuplicateKeyPolicy
+		ordered OrderedObject
+		mp      map[string]int
+		st      S
+	}{
+		{
+			policy:  KeepAll,
+			ordered: OrderedObject{{"A", float64(1)}, {"B", float64(2)}, {"A", float64(3)}, {"a", float64(4)}},
+			mp:      map[string]int{"A": 3, "B": 2, "a": 4},
+			st:      S{A: 4, B: 2},
+		},
+		{
+			policy:  FirstWins,
+			ordered: OrderedObject{{"A", float64(1)}, {"B", float64(2)}, {"a", float64(4)}},
+			mp:      map[string]int{"A": 1, "B": 2, "a": 4},
+			st:      S{A: 1, B: 2},
+		},
+		{
+			policy:  LastWins,
+			ordered: OrderedObject{{"A", float64(3)}, {"B", float64(2)}, {"a", float64(4)}},
+			mp:      map[string]int{"A": 3, "B": 2, "a": 4},
+			st:      S{A: 4, B: 2},
+		},
+	}
+	for _, tt := range tests {
+		var (
+			o OrderedObject
+			m map[string]int
+			s S
+		)
+		for _, v := range []any{&o, &m, &s} {
+			dec := NewDecoder(strings.NewReader(in))
+			dec.SetDuplicateKeyPolicy(tt.policy)
+			if err := dec.Decode(v); err != nil {
+				t.Fatalf("policy %d: Decode(%T): %v", tt.policy, v, err)
+			}
+		}
+		if !reflect.DeepEqual(o, tt.ordered) {
+			t.Errorf("policy %d: OrderedObject %v, want %v", tt.policy, o, tt.ordered)
+		}
+		if !reflect.DeepEqual(m, tt.mp) {
+			t.Errorf("policy %d: map %v, want %v", tt.policy, m, tt.mp)
+		}
+		if s != tt.st {
+			t.Errorf("policy %d: struct %+v, want %+v", tt.policy, s, tt.st)
+		}
+	}
+}
+
+type pathParam struct {
+	Type int `json:"type"`
+}
+
+type pathMethod struct {
+	Parameters []pathParam `json:"parameters"`
+}
+
+type pathUnmarshaler struct{}
+
+func (pathUnmarshaler) UnmarshalJSON(b []byte) error {
+	var v struct{ Inner []int }
+	return Unmarshal(b, &v)
+}
+
+func TestUnmarshalTypeErrorPath(t *testing.T) {
+	type manifest struct {
+		ABI struct {
+			Methods []pathMethod `json:"methods"`
+		} `json:"abi"`
+		Extra  map[string]any
+		Custom map[string]pathUnmarshaler
+	}
+	for _, tc := range []struct {
+		in   string
+		path string
+	}{
+		{`{"abi": {"methods": [{}, {"parameters": [{"type": 1}, {"type": "x"}]}]}}`, "abi.methods[1].parameters[1].type"},
+		{`{"Extra": {"a b": [0, 1e1000]}}`, `Extra["a b"][1]`},
+		{`{"Custom": {"k": {"Inner": [1, true]}}}`, "Custom.k.Inner[1]"},
+	} {
+		var m manifest
+		var ute *UnmarshalTypeError
+		if err := Unmarshal([]byte(tc.in), &m); !errors.As(err, &ute) || ute.Path != tc.path {
+			t.Errorf("%s: got error %v, want path %s", tc.in, err, tc.path)
+		}
+	}
+}
+
+func TestUnmarshalContext(t *testing.T) {
+	data := []byte("[" + strings.Repeat(`{"a": [1, 2]}, `, 10000) + "3]")
+	ctx, cancel := context.WithCancel(context.Background())
+	var v []any
+	if err := UnmarshalContext(ctx, data, &v); err != nil || len(v) != 10001 {
+		t.Fatalf("got %d values, %v", len(v), err)
+	}
+	cancel()
+	if err := UnmarshalContext(ctx, data, &v); !errors.Is(err, context.Canceled) {
+		t.Errorf("UnmarshalContext: got %v, want context.Canceled", err)
+	}
+
+	// Validated data, the decoding itself is to be aborted.
+	var d decodeState
+	d.init(data)
+	d.ctx = ctx
+	if err := d.unmarshal(&v); !errors.Is(err, context.Canceled) {
+		t.Errorf("unmarshal: got %v, want context.Canceled", err)
+	}
+
+	dec := NewDecoder(bytes.NewReader(data))
+	if err := dec.DecodeContext(ctx, &v); !errors.Is(err, context.Canceled) {
+		t.Errorf("DecodeContext: got %v, want context.Canceled", err)
+	}
+	if err := dec.DecodeContext(context.Background(), &v); err != nil || len(v) != 10001 {
+		t.Errorf("DecodeContext after cancellation: got %d values, %v", len(v), err)
+	}
+}
+
+func TestUseBigNumbers(t *testing.T) {
+	const in = `[1, -9223372036854775808, 18446744073709551615, 18446744073709551616, -9223372036854775809, 1.5, 1e400]`
+	big1, _ := new(big.Int).SetString("18446744073709551616", 10)
+	big2, _ := new(big.Int).SetString("-9223372036854775809", 10)
+	bigf, _, _ := big.ParseFloat("1e400", 10, 64, big.ToNearestEven)
+	for _, useNumber := range []bool{false, true} {
+		dec := NewDecoder(strings.NewReader(in))
+		dec.UseBigNumbers()
+		if useNumber {
+			dec.UseNumber()
+		}
+		var v []any
+		if err := dec.Decode(&v); err != nil {
+			t.Fatal(err)
+		}
+		if len(v) != 7 {
+			t.Fatalf("got %v", v)
+		}
+		if n, ok := v[3].(*big.Int); !ok || n.Cmp(big1) != 0 {
+			t.Errorf("got %T %v, want %v", v[3], v[3], big1)
+		}
+		if n, ok := v[4].(*big.Int); !ok || n.Cmp(big2) != 0 {
+			t.Errorf("got %T %v, want %v", v[4], v[4], big2)
+		}
+		if f, ok := v[6].(*big.Float); !ok || f.Cmp(bigf) != 0 {
+			t.Errorf("got %T %v, want %v", v[6], v[6], bigf)
+		}
+		for _, i := range []int{0, 1, 2, 5} {
+			_, isNumber := v[i].(Number)
+			_, isFloat := v[i].(float64)
+			if useNumber && !isNumber || !useNumber && !isFloat {
+				t.Errorf("UseNumber %t: got %T for %v", useNumber, v[i], v[i])
+			}
+		}
+	}
+}
+
+func TestUseInt64(t *testing.T) {
+	const in = `{"height": 12345678901234567, "neg": -3, "big": 9223372036854775808, "f": 1.0, "e": 1e3}`
+	dec := NewDecoder(strings.NewReader(in))
+	dec.UseInt64()
+	dec.UseOrderedObject()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		t.Fatal(err)
+	}
+	want := OrderedObject{
+		{"height", int64(12345678901234567)},
+		{"neg", int64(-3)},
+		{"big", Number("9223372036854775808")},
+		{"f", float64(1)},
+		{"e", float64(1000)},
+	}
+	if !reflect.DeepEqual(v, want) {
+		t.Errorf("got %#v, want %#v", v, want)
+	}
+}
+
+func TestDisallowInexactNumbers(t *testing.T) {
+	type amounts struct {
+		F64 float64
+		F32 float32
+		Any any
+	}
+	for _, tc := range []struct {
+		in string
+		ok bool
+	}{
+		{`{"F64": 0.1, "F32": 0.1, "Any": 123456789012345}`, true},
+		{`{"F64": 1e22, "F32": 16777216, "Any": -2.5e-10}`, true},
+		{`{"F64": 12345678901234567}`, false},
+		{`{"F64": 1e-400}`, false},
+		{`{"F64": 1e-999999999, "F32": 1e-999999999}`, false},
+		{`{"F64": 0.0e-999999999, "Any": -0e999999999}`, true},
+		{`{"F64": 0.00001e-999999999}`, false},
+		{`{"F64": 1000000000000000000000000000000000000000e-999999999}`, false},
+		{`{"F64": 1` + strings.Repeat("0", 400) + `e-400}`, true},
+		{`{"F32": 16777217}`, false},
+		{`{"F32": 0.1000000001}`, false},
+		{`{"Any": 9007199254740993}`, false},
+	} {
+		dec := NewDecoder(strings.NewReader(tc.in))
+		dec.DisallowInexactNumbers()
+		var v amounts
+		err := dec.Decode(&v)
+		var ute *UnmarshalTypeError
+		if tc.ok && err != nil || !tc.ok && !errors.As(err, &ute) {
+			t.Errorf("%s: unexpected error %v", tc.in, err)
+		}
+		if err := Unmarshal([]byte(tc.in), &v); err != nil {
+			t.Errorf("%s: Unmarshal is not affected, got %v", tc.in, err)
+		}
+	}
+}
+
+func TestSurrogatePolicy(t *testing.T) {
+	const in = `["😀", "a\uD800b", "\uDC00", "\\uD800", "\uD800A"]`
+	for _, tc := range []struct {
+		policy SurrogatePolicy
+		want   []string
+	}{
+		{SurrogateReplace, []string{"😀", "a�b", "�", `\uD800`, "�A"}},
+		{SurrogatePreserve, []string{"😀", "a\xed\xa0\x80b", "\xed\xb0\x80", `\uD800`, "\xed\xa0\x80A"}},
+		{SurrogateError, nil},
+	} {
+		dec := NewDecoder(strings.NewReader(in))
+		dec.SetSurrogatePolicy(tc.policy)
+		var v []string
+		err := dec.Decode(&v)
+		if tc.want == nil {
+			var se *SyntaxError
+			if !errors.As(err, &se) || se.Offset != 12 {
+				t.Errorf("policy %d: got %#v, want SyntaxError", tc.policy, err)
+			}
+			continue
+		}
+		if err != nil || !reflect.DeepEqual(v, tc.want) {
+			t.Errorf("policy %d: got %q, %v, want %q", tc.policy, v, err, tc.want)
+		}
+	}
+	dec := NewDecoder(strings.NewReader(`"😀\\uD800"`))
+	dec.SetSurrogatePolicy(SurrogateError)
+	var s string
+	if err := dec.Decode(&s); err != nil {
+		t.Errorf("escaped backslash with SurrogateError: %v", err)
+	}
+}
+
+type RemainEmbed struct {
+	Extra OrderedObject `json:",remain"`
+}
+
+func TestRemainField(t *testing.T) {
+	var v struct {
+		A int
+		*RemainEmbed
+		B string `json:"b"`
+	}
+	const in = `{"z":1,"A":2,"y":{"q":true,"p":null},"b":"x","x":[1]}`
+	if err := Unmarshal([]byte(in), &v); err != nil {
+		t.Fatal(err)
+	}
+	want := OrderedObject{{"z", float64(1)}, {"y", OrderedObject{{"q", true}, {"p", nil}}}, {"x", []any{float64(1)}}}
+	if v.A != 2 || v.B != "x" || v.RemainEmbed == nil || !reflect.DeepEqual(v.Extra, want) {
+		t.Fatalf("got %+v, %#v", v, v.RemainEmbed)
+	}
+	b, err := Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const out = `{"A":2,"z":1,"y":{"q":true,"p":null},"x":[1],"b":"x"}`
+	if string(b) != out {
+		t.Errorf("Marshal: got %s, want %s", b, out)
+	}
+
+	// Without unknown members the field stays empty.
+	var w struct {
+		A     int
+		Extra OrderedObject `json:",remain"`
+	}
+	if err := Unmarshal([]byte(`{"A":1}`), &w); err != nil || w.Extra != nil {
+		t.Errorf("got %+v, %v", w, err)
+	}
+	if b, _ := Marshal(w); string(b) != `{"A":1}` {
+		t.Errorf("Marshal: got %s", b)
+	}
+}
+
+type DefaultsEmbed struct {
+	Level int `json:",default=3"`
+}
+
+func TestDefaultValues(t *testing.T) {
+	type config struct {
+		Host  string   `json:"host,default=local,host"`
+		Port  int      `json:"port,omitempty,default=8080"`
+		Tags  []string `json:"tags,default=[\"a\"]"`
+		Ptr   *float64 `json:",default=1.5"`
+		Empty string   `json:",default="`
+		*DefaultsEmbed
+	}
+	var c config
+	if err := Unmarshal([]byte(`{"port": 0, "Empty": "x"}`), &c); err != nil {
+		t.Fatal(err)
+	}
+	if c.Host != "local,host" || c.Port != 0 || !reflect.DeepEqual(c.Tags, []string{"a"}) ||
+		c.Ptr == nil || *c.Ptr != 1.5 || c.Empty != "x" || c.DefaultsEmbed == nil || c.Level != 3 {
+		t.Errorf("got %+v", c)
+	}
+
+	var cs []config
+	if err := Unmarshal([]byte(`[{"host": "h", "tags": null, "level": 1}, null]`), &cs); err != nil {
+		t.Fatal(err)
+	}
+	if len(cs) != 2 || cs[0].Host != "h" || cs[0].Tags != nil || cs[0].Port != 8080 || cs[0].Level != 1 ||
+		cs[1].Host != "" || cs[1].Port != 0 {
+		t.Errorf("got %+v", cs)
+	}
+
+	var bad struct {
+		N int `json:",default=x"`
+	}
+	if err := Unmarshal([]byte(`{}`), &bad); err == nil {
+		t.Error("no error for invalid default")
+	}
+}
+
+func TestRequiredFields(t *testing.T) {
+	type item struct {
+		ID   int    `json:"id,required"`
+		Name string `json:"name,required,default=x"`
+	}
+	var v struct {
+		Items []item `json:"items,required"`
+		Opt   *item  `json:"opt"`
+		Other item   `json:",required"`
+	}
+	err := Unmarshal([]byte(`{"items": [{"id": 1, "name": null}, {"name": "a"}, {}], "opt": {"id": 2}}`), &v)
+	want := &MissingFieldsError{[]string{"items[1].id", "items[2].id", "items[2].name", "opt.name", "Other"}}
+	if !reflect.DeepEqual(err, want) {
+		t.Fatalf("got error %v, want %v", err, want)
+	}
+	if len(v.Items) != 3 || v.Items[2].Name != "x" || v.Opt.ID != 2 {
+		t.Errorf("got %+v", v)
+	}
+
+	// Other errors take precedence.
+	err = Unmarshal([]byte(`{"items": "x"}`), &v)
+	if _, ok := err.(*UnmarshalTypeError); !ok {
+		t.Errorf("got error %v, want UnmarshalTypeError", err)
+	}
+	if err := Unmarshal([]byte(`{"items": null, "Other": {"id": 0, "name": ""}}`), &v); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+type presencePatch struct {
+	Name  string   `json:"name"`
+	Count int      `json:"count"`
+	Tags  []string `json:",omitempty"`
+	set   map[string]bool
+}
+
+func (p *presencePatch) SetFields(fields map[string]bool) { p.set = fields }
+
+func TestFieldSetter(t *testing.T) {
+	var v struct {
+		A presencePatch
+		B []presencePatch
+		C *presencePatch
+	}
+	if err := Unmarshal([]byte(`{"A": {"count": 0, "Tags": null, "x": 1}, "B": [{}, {"NAME": "n"}], "C": null}`), &v); err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		got  map[string]bool
+		want map[string]bool
+	}{
+		{v.A.set, map[string]bool{"name": false, "count": true, "Tags": true}},
+		{v.B[0].set, map[string]bool{"name": false, "count": false, "Tags": false}},
+		{v.B[1].set, map[string]bool{"name": true, "count": false, "Tags": false}},
+	} {
+		if !reflect.DeepEqual(tc.got, tc.want) {
+			t.Errorf("got %v, want %v", tc.got, tc.want)
+		}
+	}
+	if v.C != nil {
+		t.Errorf("got %+v for null", v.C)
+	}
+}
+
+func TestNullPolicy(t *testing.T) {
+	type T struct {
+		N int
+		S string
+		A [1]int
+		P *int
+	}
+	const in = `{"N": null, "S": null, "A": null, "P": null}`
+	one := 1
+	for _, tc := range []struct {
+		policy NullPolicy
+		want   T
+		err    error
+	}{
+		{NullIgnore, T{1, "s", [1]int{1}, nil}, nil},
+		{NullZero, T{0, "", [1]int{}, nil}, nil},
+		{NullError, T{1, "s", [1]int{1}, nil}, &UnmarshalTypeError{"null", reflect.TypeFor[int](), 10, "T", "N", "N"}},
+	} {
+		v := T{1, "s", [1]int{1}, &one}
+		dec := NewDecoder(strings.NewReader(in))
+		dec.SetNullPolicy(tc.policy)
+		err := dec.Decode(&v)
+		if !reflect.DeepEqual(err, tc.err) {
+			t.Errorf("policy %d: got error %#v, want %#v", tc.policy, err, tc.err)
+		}
+		if !reflect.DeepEqual(v, tc.want) {
+			t.Errorf("policy %d: got %+v, want %+v", tc.policy, v, tc.want)
+		}
+	}
+}
+
+func TestWeakTyping(t *testing.T) {
+	type T struct {
+		I  int
+		U  uint8
+		F  float64
+		B  bool
+		B2 bool
+		E  int
+		S  string
+		S2 string
+	}
+	const in = `{"I": "-42", "U": "7", "F": "1.5e2", "B": "true", "B2": 1, "E": "", "S": 12.50, "S2": "x"}`
+	var v T
+	dec := NewDecoder(strings.NewReader(in))
+	dec.AllowWeakTyping()
+	if err := dec.Decode(&v); err != nil {
+		t.Fatal(err)
+	}
+	want := T{-42, 7, 150, true, true, 0, "12.50", "x"}
+	if v != want {
+		t.Errorf("got %+v, want %+v", v, want)
+	}
+
+	for _, in := range []string{`{"I": "4x"}`, `{"U": "300"}`, `{"B": "yes"}`, `{"I": true}`} {
+		dec := NewDecoder(strings.NewReader(in))
+		dec.AllowWeakTyping()
+		var ute *UnmarshalTypeError
+		if err := dec.Decode(&v); !errors.As(err, &ute) {
+			t.Errorf("%s: got error %v, want UnmarshalTypeError", in, err)
+		}
+	}
+	// Without the option the types must match.
+	if err := Unmarshal([]byte(in), &v); err == nil {
+		t.Error("no error without AllowWeakTyping")
+	}
+}
+
+func TestIntegerTargetsNoTruncation(t *testing.T) {
+	var v struct {
+		A []int
+		M map[string]uint
+		Q int `json:",string"`
+		K map[int8]int
+		P *uint16
+	}
+	for _, tc := range []struct {
+		in, value, path string
+	}{
+		{`{"A": [1, 2.5]}`, "number 2.5", "A[1]"},
+		{`{"A": [1.0]}`, "number 1.0", "A[0]"},
+		{`{"A": [1e2]}`, "number 1e2", "A[0]"},
+		{`{"M": {"x": -1}}`, "number -1", "M.x"},
+		{`{"Q": "1.5"}`, "number 1.5", "Q"},
+		{`{"K": {"-0.5": 1}}`, "number -0.5", `K["-0.5"]`},
+		{`{"P": -0}`, "number -0", "P"},
+	} {
+		var ute *UnmarshalTypeError
+		err := Unmarshal([]byte(tc.in), &v)
+		if !errors.As(err, &ute) || ute.Value != tc.value || ute.Path != tc.path {
+			t.Errorf("%s: got error %v, want %s at %s", tc.in, err, tc.value, tc.path)
+		}
+	}
+}
+
+func TestUnmarshalFields(t *testing.T) {
+	type response struct {
+		JSONRPC string `json:"jsonrpc"`
+		ID      any    `json:"id"`
+		Result  []int  `json:"result"`
+	}
+	for _, tc := range []struct {
+		in     string
+		fields []string
+		want   response
+		err    bool
+	}{
+		{`{"jsonrpc": "2.0", "id": 1 , "result": [1, 2]}`, []string{"jsonrpc", "id"}, response{"2.0", float64(1), nil}, false},
+		{`{"id": {"a": [1]}, "x": 0, "jsonrpc": "2.0"` + "\n, bad", []string{"jsonrpc", "id"}, response{"2.0", map[string]any{"a": []any{float64(1)}}, nil}, false},
+		{`{"result": [3], "jsonrpc": "2.0"}`, []string{"result", "result"}, response{"", nil, []int{3}}, false},
+		{`{"jsonrpc": "2.0"}`, []string{"jsonrpc", "id"}, response{"2.0", nil, nil}, false},
+		{`{"jsonrpc": "2.0"} x`, []string{"id"}, response{}, true},
+		{`{"Jsonrpc": "2.0", "id": "1", "jsonrpc": "x"}`, []string{"id"}, response{"2.0", "1", nil}, false},
+		{`[]`, []string{"id"}, response{}, true},
+	} {
+		var v response
+		err := UnmarshalFields([]byte(tc.in), &v, tc.fields...)
+		if (err != nil) != tc.err || !tc.err && !reflect.DeepEqual(v, tc.want) {
+			t.Errorf("%s: got %+v, %v", tc.in, v, err)
+		}
+	}
+
+	var o OrderedObject
+	if err := UnmarshalFields([]byte(`{"a": 1, "b": 2, "c": 3`), &o, "b"); err != nil || !reflect.DeepEqual(o, OrderedObject{{"a", float64(1)}, {"b", float64(2)}}) {
+		t.Errorf("got %v, %v", o, err)
+	}
+}
+
+func TestInlineField(t *testing.T) {
+	type ext struct {
+		ID    int           `json:"id"`
+		Extra OrderedObject `json:",inline"`
+		Name  string        `json:"name"`
+	}
+	const in = `{"z":1,"id":2,"name":"n","y":{"b":1,"a":2}}`
+	var e ext
+	if err := Unmarshal([]byte(in), &e); err != nil {
+		t.Fatal(err)
+	}
+	want := OrderedObject{{"z", float64(1)}, {"y", OrderedObject{{"b", float64(1)}, {"a", float64(2)}}}}
+	if e.ID != 2 || e.Name != "n" || !reflect.DeepEqual(e.Extra, want) {
+		t.Fatalf("got %+v", e)
+	}
+	if b, err := Marshal(e); err != nil || string(b) != `{"id":2,"z":1,"y":{"b":1,"a":2},"name":"n"}` {
+		t.Errorf("Marshal: got %s, %v", b, err)
+	}
+
+	type label string
+	type extMap struct {
+		Labels map[label]int `json:",inline"`
+		ID     int           `json:"id"`
+	}
+	var m extMap
+	if err := Unmarshal([]byte(`{"b":1,"id":2,"a":3}`), &m); err != nil {
+		t.Fatal(err)
+	}
+	if m.ID != 2 || !reflect.DeepEqual(m.Labels, map[label]int{"a": 3, "b": 1}) {
+		t.Fatalf("got %+v", m)
+	}
+	if b, err := Marshal(m); err != nil || string(b) != `{"a":3,"b":1,"id":2}` {
+		t.Errorf("Marshal: got %s, %v", b, err)
+	}
+	var bad extMap
+	err := Unmarshal([]byte(`{"x":"s"}`), &bad)
+	var ute *UnmarshalTypeError
+	if !errors.As(err, &ute) || ute.Field != "x" {
+		t.Errorf("got error %v", err)
+	}
+	if b, _ := Marshal(extMap{}); string(b) != `{"id":0}` {
+		t.Errorf("Marshal: got %s", b)
+	}
+}
+
+type magicKey struct{}
+
+// netAddr is marshaled with the network magic taken from the context.
+type netAddr struct {
+	Magic int
+	Addr  string
+}
+
+func ctxMagic(ctx context.Context) int {
+	m, _ := ctx.Value(magicKey{}).(int)
+	return m
+}
+
+func (a netAddr) MarshalJSONContext(ctx context.Context) ([]byte, error) {
+	return Marshal(fmt.Sprintf("%d:%s", ctxMagic(ctx), a.Addr))
+}
+
+func (a *netAddr) UnmarshalJSONContext(ctx context.Context, data []byte) error {
+	a.Magic = ctxMagic(ctx)
+	return Unmarshal(data, &a.Addr)
+}
+
+func TestContextMarshalers(t *testing.T) {
+	ctx := context.WithValue(context.Background(), magicKey{}, 42)
+	v := struct {
+		A  netAddr
+		PA *netAddr
+	}{A: netAddr{Addr: "x"}, PA: &netAddr{Addr: "y"}}
+	b, err := MarshalContext(ctx, v)
+	if err != nil || string(b) != `{"A":"42:x","PA":"42:y"}` {
+		t.Errorf("MarshalContext: got %s, %v", b, err)
+	}
+	if b, err := Marshal(v); err != nil || string(b) != `{"A":"0:x","PA":"0:y"}` {
+		t.Errorf("Marshal: got %s, %v", b, err)
+	}
+	var buf bytes.Buffer
+	if err := NewEncoder(&buf).EncodeContext(ctx, []netAddr{{Addr: "z"}}); err != nil || buf.String() != "[\"42:z\"]\n" {
+		t.Errorf("EncodeContext: got %q, %v", buf.String(), err)
+	}
+
+	var w []*netAddr
+	if err := UnmarshalContext(ctx, []byte(`["a", null, "b"]`), &w); err != nil {
+		t.Fatal(err)
+	}
+	if len(w) != 3 || *w[0] != (netAddr{42, "a"}) || w[1] != nil || *w[2] != (netAddr{42, "b"}) {
+		t.Errorf("UnmarshalContext: got %+v", w)
+	}
+	var a netAddr
+	if err := Unmarshal([]byte(`"c"`), &a); err != nil || a != (netAddr{0, "c"}) {
+		t.Errorf("Unmarshal: got %+v, %v", a, err)
+	}
+	if err := NewDecoder(strings.NewReader(`"d"`)).DecodeContext(ctx, &a); err != nil || a != (netAddr{42, "d"}) {
+		t.Errorf("DecodeContext: got %+v, %v", a, err)
+	}
+}
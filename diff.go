@@ -0,0 +1,185 @@
+package json
+
+import (
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// ChangeKind is a kind of difference between two values found by Diff.
+type ChangeKind int
+
+const (
+	// ChangeValue replaces the value at the path with a different one,
+	// including a value of a different type.
+	ChangeValue ChangeKind = iota
+	// ChangeAdd adds an object member or an array element missing in the
+	// old value.
+	ChangeAdd
+	// ChangeRemove removes an object member or an array element missing in
+	// the new value.
+	ChangeRemove
+	// ChangeOrder reorders the object at the path, Old and New are the
+	// []string keys of the members present in both objects in their order.
+	ChangeOrder
+)
+
+var changeKindNames = [...]string{"value", "add", "remove", "order"}
+
+func (k ChangeKind) String() string {
+	if k >= 0 && int(k) < len(changeKindNames) {
+		return changeKindNames[k]
+	}
+	return "ChangeKind(" + strconv.Itoa(int(k)) + ")"
+}
+
+// A Change is a difference between two values found by Diff.
+type Change struct {
+	Path Pointer    // the changed value
+	Kind ChangeKind // the kind of the change
+	Old  any        // the old value, nil for ChangeAdd
+	New  any        // the new value, nil for ChangeRemove
+}
+
+// String returns a human-readable description of the change with values
+// encoded as JSON.
+func (c Change) String() string {
+	var sb strings.Builder
+	path := c.Path.String()
+	if path == "" {
+		path = "/"
+	}
+	sb.WriteString(c.Kind.String() + " " + path)
+	if c.Kind != ChangeAdd {
+		b, _ := Marshal(c.Old)
+		sb.WriteString(" " + string(b))
+	}
+	if c.Kind == ChangeValue || c.Kind == ChangeOrder {
+		sb.WriteString(" ->")
+	}
+	if c.Kind != ChangeRemove {
+		b, _ := Marshal(c.New)
+		sb.WriteString(" " + string(b))
+	}
+	return sb.String()
+}
+
+// Diff returns the changes turning the value a into b, both consisting of
+// OrderedObject, map[string]any, []any and basic values like the ones
+// decoded into an any, or nil if they're equal. Objects are compared member
+// by member: a reordering of OrderedObject members is reported first, then
+// the changes of members of a in their order, then the members added in b.
+// Arrays are compared element by element, the elements past the end of the
+// shorter one are reported as added or removed. Other values are compared
+// with reflect.DeepEqual, so numbers decoded in different ways (like
+// float64 and Number) differ. If an object has several members with the
+// same name, the first one is used.
+func Diff(a, b any) []Change {
+	return appendDiff(nil, nil, a, b)
+}
+
+// appendDiff appends the changes turning a into b at path to changes.
+func appendDiff(changes []Change, path Pointer, a, b any) []Change {
+	switch a := a.(type) {
+	case OrderedObject:
+		if b, ok := b.(OrderedObject); ok {
+			return appendObjectDiff(changes, path, a, b)
+		}
+	case map[string]any:
+		if b, ok := b.(map[string]any); ok {
+			keys := make([]string, 0, len(a))
+			for k := range a {
+				keys = append(keys, k)
+			}
+			slices.Sort(keys)
+			for _, k := range keys {
+				if v, ok := b[k]; ok {
+					changes = appendDiff(changes, path.append(k), a[k], v)
+				} else {
+					changes = append(changes, Change{path.append(k), ChangeRemove, a[k], nil})
+				}
+			}
+			keys = keys[:0]
+			for k := range b {
+				if _, ok := a[k]; !ok {
+					keys = append(keys, k)
+				}
+			}
+			slices.Sort(keys)
+			for _, k := range keys {
+				changes = append(changes, Change{path.append(k), ChangeAdd, nil, b[k]})
+			}
+			return changes
+		}
+	case []any:
+		if b, ok := b.([]any); ok {
+			for i := range max(len(a), len(b)) {
+				p := path.append(strconv.Itoa(i))
+				switch {
+				case i >= len(b):
+					changes = append(changes, Change{p, ChangeRemove, a[i], nil})
+				case i >= len(a):
+					changes = append(changes, Change{p, ChangeAdd, nil, b[i]})
+				default:
+					changes = appendDiff(changes, p, a[i], b[i])
+				}
+			}
+			return changes
+		}
+	}
+	if !reflect.DeepEqual(a, b) {
+		changes = append(changes, Change{path, ChangeValue, a, b})
+	}
+	return changes
+}
+
+// appendObjectDiff is appendDiff for objects.
+func appendObjectDiff(changes []Change, path Pointer, a, b OrderedObject) []Change {
+	inA, inB := memberPositions(a), memberPositions(b)
+	var oldOrder, newOrder []string
+	for i, m := range a {
+		if _, ok := inB[m.Key]; ok && inA[m.Key] == i {
+			oldOrder = append(oldOrder, m.Key)
+		}
+	}
+	for i, m := range b {
+		if _, ok := inA[m.Key]; ok && inB[m.Key] == i {
+			newOrder = append(newOrder, m.Key)
+		}
+	}
+	if !slices.Equal(oldOrder, newOrder) {
+		changes = append(changes, Change{path, ChangeOrder, oldOrder, newOrder})
+	}
+	for i, m := range a {
+		if inA[m.Key] != i {
+			continue
+		}
+		if j, ok := inB[m.Key]; ok {
+			changes = appendDiff(changes, path.append(m.Key), m.Value, b[j].Value)
+		} else {
+			changes = append(changes, Change{path.append(m.Key), ChangeRemove, m.Value, nil})
+		}
+	}
+	for i, m := range b {
+		if _, ok := inA[m.Key]; !ok && inB[m.Key] == i {
+			changes = append(changes, Change{path.append(m.Key), ChangeAdd, nil, m.Value})
+		}
+	}
+	return changes
+}
+
+// memberPositions returns the positions of the first members of o with
+// every key.
+func memberPositions(o OrderedObject) map[string]int {
+	pos := make(map[string]int, len(o))
+	for i := len(o) - 1; i >= 0; i-- {
+		pos[o[i].Key] = i
+	}
+	return pos
+}
+
+// append returns a copy of p with the token added.
+func (p Pointer) append(tok string) Pointer {
+	return append(p[:len(p):len(p)], tok)
+}
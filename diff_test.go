@@ -0,0 +1,65 @@
+package json
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	for _, tc := range []struct {
+		a, b string
+		want []string
+	}{
+		{`{"a":[1,{"b":null}]}`, `{"a":[1,{"b":null}]}`, nil},
+		{`1`, `2`, []string{`value / 1 -> 2`}},
+		{`{"a":1}`, `[1]`, []string{`value / {"a":1} -> [1]`}},
+		{`{"a":1,"b":"x"}`, `{"a":1,"b":"y"}`, []string{`value /b "x" -> "y"`}},
+		{`{"a":1,"b":2}`, `{"b":2,"a":1}`, []string{`order / ["a","b"] -> ["b","a"]`}},
+		{`{"a":1,"b":2,"c":3}`, `{"d":4,"c":3,"a":0}`, []string{
+			`order / ["a","c"] -> ["c","a"]`,
+			`value /a 1 -> 0`,
+			`remove /b 2`,
+			`add /d 4`,
+		}},
+		{`{"a":1,"b":2}`, `{"a":1,"c":2,"b":2}`, []string{`add /c 2`}},
+		{`{"x":{"a/b":[1,2,3]}}`, `{"x":{"a/b":[1,5]}}`, []string{
+			`value /x/a~1b/1 2 -> 5`,
+			`remove /x/a~1b/2 3`,
+		}},
+		{`[1]`, `[1,[],{}]`, []string{`add /1 []`, `add /2 {}`}},
+		{`{"a":1,"a":2}`, `{"a":1}`, nil},
+		{`{"m":{"b":1,"a":2}}`, `{"m":{"a":3,"c":4}}`, []string{
+			`remove /m/b 1`,
+			`value /m/a 2 -> 3`,
+			`add /m/c 4`,
+		}},
+	} {
+		a, b := decodeOrdered(t, tc.a), decodeOrdered(t, tc.b)
+		var got []string
+		for _, c := range Diff(a, b) {
+			got = append(got, c.String())
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s -> %s:\ngot  %q\nwant %q", tc.a, tc.b, got, tc.want)
+		}
+	}
+}
+
+func TestDiffMap(t *testing.T) {
+	var a, b any
+	if err := Unmarshal([]byte(`{"c":1,"b":{"x":1},"a":0}`), &a); err != nil {
+		t.Fatal(err)
+	}
+	if err := Unmarshal([]byte(`{"d":1,"b":{"x":2},"a":0,"0":null}`), &b); err != nil {
+		t.Fatal(err)
+	}
+	want := []Change{
+		{Pointer{"b", "x"}, ChangeValue, 1.0, 2.0},
+		{Pointer{"c"}, ChangeRemove, 1.0, nil},
+		{Pointer{"0"}, ChangeAdd, nil, nil},
+		{Pointer{"d"}, ChangeAdd, nil, 1.0},
+	}
+	if got := Diff(a, b); !reflect.DeepEqual(got, want) {
+		t.Errorf("got  %v\nwant %v", got, want)
+	}
+}
@@ -0,0 +1,166 @@
+package json
+
+import (
+	"errors"
+	"slices"
+	"strings"
+)
+
+// NewNode returns the node of the value v encoded like Marshal does it, to
+// be added to the Children of a parsed node. Object members need the Key
+// too, see Node.Set.
+func NewNode(v any) (*Node, error) {
+	b, err := Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return ParseTree(b)
+}
+
+// SetValue replaces the value of the node with v encoded like Marshal does
+// it, the node keeps its Key. Positions of the new value and its children
+// refer to the new text.
+func (n *Node) SetValue(v any) error {
+	m, err := NewNode(v)
+	if err != nil {
+		return err
+	}
+	m.Key, m.edited = n.Key, true
+	*n = *m
+	return nil
+}
+
+// Member returns the value of the first member of the object node with the
+// given name or nil if there is none.
+func (n *Node) Member(name string) *Node {
+	if i := n.memberIndex(name); i >= 0 {
+		return n.Children[i]
+	}
+	return nil
+}
+
+// Set sets the value of the first member of the object node with the given
+// name to v like SetValue does or, if there is none, adds the member after
+// the existing ones.
+func (n *Node) Set(name string, v any) error {
+	if n.Kind != NodeObject {
+		return errors.New("json: Set on " + n.Kind.String() + " node")
+	}
+	if m := n.Member(name); m != nil {
+		return m.SetValue(v)
+	}
+	m, err := NewNode(v)
+	if err != nil {
+		return err
+	}
+	m.Key, _ = ParseTree(appendString(nil, name, encOpts{escapeHTML: true}))
+	n.Children = append(n.Children, m)
+	return nil
+}
+
+// Delete removes the first member of the object node with the given name
+// and reports whether there was one.
+func (n *Node) Delete(name string) bool {
+	i := n.memberIndex(name)
+	if i >= 0 {
+		n.Children = slices.Delete(n.Children, i, i+1)
+	}
+	return i >= 0
+}
+
+// Append adds v encoded like Marshal does it to the end of the array node.
+func (n *Node) Append(v any) error {
+	if n.Kind != NodeArray {
+		return errors.New("json: Append on " + n.Kind.String() + " node")
+	}
+	m, err := NewNode(v)
+	if err != nil {
+		return err
+	}
+	n.Children = append(n.Children, m)
+	return nil
+}
+
+// memberIndex returns the position of the first member of the object node
+// with the given name or -1.
+func (n *Node) memberIndex(name string) int {
+	if n.Kind != NodeObject {
+		return -1
+	}
+	return slices.IndexFunc(n.Children, func(c *Node) bool {
+		return c.Key != nil && unquoteKey(c.Key.Raw) == name
+	})
+}
+
+// Bytes returns the JSON text of the node with all the edits made by
+// SetValue, Set and the like or directly to Children. Everything not
+// affected by the edits is kept byte for byte: whitespace, escapes in strings
+// and number literals. Added members and elements use the separators of the
+// existing ones. An unedited node returns its Raw text.
+func (n *Node) Bytes() []byte {
+	if !n.changed() {
+		return n.src
+	}
+	return n.appendText(nil)
+}
+
+// changed reports whether the node or any of its children is edited.
+func (n *Node) changed() bool {
+	if n.edited || !slices.Equal(n.Children, n.orig) {
+		return true
+	}
+	return slices.ContainsFunc(n.Children, (*Node).changed)
+}
+
+// appendText appends the text of the node to b.
+func (n *Node) appendText(b []byte) []byte {
+	if !n.changed() || n.Kind != NodeObject && n.Kind != NodeArray {
+		return append(b, n.src...)
+	}
+	src, spans := n.src, n.spans
+	if len(n.Children) == 0 {
+		return append(b, src[0], src[len(src)-1])
+	}
+	// Existing children keep the separators preceding them, the others get
+	// the last existing separator or, for a single child on its own line,
+	// the line break with the indentation.
+	gap, colon := ",", ":"
+	open, close := src[:1], src[1:]
+	if last := len(spans) - 1; last >= 0 {
+		open, close = src[:spans[0].member], src[spans[last].end:]
+		colon = string(src[spans[0].keyEnd:spans[0].value])
+		if last > 0 {
+			gap = string(src[spans[last-1].end:spans[last].member])
+		} else if indent := open[1:]; strings.IndexByte(string(indent), '\n') >= 0 {
+			gap = "," + string(indent)
+		}
+	}
+
+	pos := make(map[*Node]int, len(n.orig))
+	for i, c := range n.orig {
+		pos[c] = i
+	}
+	b = append(b, open...)
+	for i, c := range n.Children {
+		j, ok := pos[c]
+		if !ok {
+			j = -1
+		}
+		if i > 0 {
+			if j > 0 {
+				b = append(b, src[spans[j-1].end:spans[j].member]...)
+			} else {
+				b = append(b, gap...)
+			}
+		}
+		if n.Kind == NodeObject {
+			if j >= 0 {
+				b = append(b, src[spans[j].member:spans[j].value]...)
+			} else {
+				b = append(append(b, c.Key.Bytes()...), colon...)
+			}
+		}
+		b = c.appendText(b)
+	}
+	return append(b, close...)
+}
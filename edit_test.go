@@ -0,0 +1,132 @@
+package json
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestNodeEdits(t *testing.T) {
+	const in = `{
+  "name": "node",   "ratio": 1.50,
+  "greeting": "héllo",
+  "peers": [ "a",
+    "b" ],
+  "empty": { },
+  "one": {"x": 1}
+}`
+	for _, tc := range []struct {
+		name string
+		edit func(*Node) error
+		want string
+	}{
+		{"none", func(*Node) error { return nil }, in},
+		{"set", func(n *Node) error { return n.Set("name", "other") }, `{
+  "name": "other",   "ratio": 1.50,
+  "greeting": "héllo",
+  "peers": [ "a",
+    "b" ],
+  "empty": { },
+  "one": {"x": 1}
+}`},
+		{"nested", func(n *Node) error { return n.Member("one").Set("x", []int{2, 3}) }, `{
+  "name": "node",   "ratio": 1.50,
+  "greeting": "héllo",
+  "peers": [ "a",
+    "b" ],
+  "empty": { },
+  "one": {"x": [2,3]}
+}`},
+		{"add", func(n *Node) error { return n.Set("<new>", 1) }, `{
+  "name": "node",   "ratio": 1.50,
+  "greeting": "héllo",
+  "peers": [ "a",
+    "b" ],
+  "empty": { },
+  "one": {"x": 1},
+  "\u003Cnew\u003E": 1
+}`},
+		{"add to single", func(n *Node) error { return n.Member("one").Set("y", true) }, `{
+  "name": "node",   "ratio": 1.50,
+  "greeting": "héllo",
+  "peers": [ "a",
+    "b" ],
+  "empty": { },
+  "one": {"x": 1,"y": true}
+}`},
+		{"add to empty", func(n *Node) error { return n.Member("empty").Set("a", nil) }, `{
+  "name": "node",   "ratio": 1.50,
+  "greeting": "héllo",
+  "peers": [ "a",
+    "b" ],
+  "empty": {"a":null },
+  "one": {"x": 1}
+}`},
+		{"append", func(n *Node) error { return n.Member("peers").Append("c") }, `{
+  "name": "node",   "ratio": 1.50,
+  "greeting": "héllo",
+  "peers": [ "a",
+    "b",
+    "c" ],
+  "empty": { },
+  "one": {"x": 1}
+}`},
+		{"delete", func(n *Node) error {
+			n.Delete("ratio")
+			n.Delete("peers")
+			n.Member("one").Delete("x")
+			return nil
+		}, `{
+  "name": "node",
+  "greeting": "héllo",
+  "empty": { },
+  "one": {}
+}`},
+		{"reorder", func(n *Node) error {
+			n.Children = append(n.Children[5:], n.Children[:5]...)
+			return nil
+		}, `{
+  "one": {"x": 1},
+  "name": "node",   "ratio": 1.50,
+  "greeting": "héllo",
+  "peers": [ "a",
+    "b" ],
+  "empty": { }
+}`},
+		{"replace root", func(n *Node) error { return n.SetValue([]any{1, "x"}) }, `[1,"x"]`},
+	} {
+		root, err := ParseTree([]byte(in))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := tc.edit(root); err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		if got := string(root.Bytes()); got != tc.want {
+			t.Errorf("%s: got\n%s\nwant\n%s", tc.name, got, tc.want)
+		}
+		var v any
+		if err := root.Decode(&v); err != nil {
+			t.Errorf("%s: %v", tc.name, err)
+		}
+		if tc.name != "replace root" && string(root.Raw) != in {
+			t.Errorf("%s: Raw changed", tc.name)
+		}
+	}
+
+	root, _ := ParseTree([]byte(`[1]`))
+	if err := root.Set("a", 1); err == nil {
+		t.Error("Set on array: no error")
+	}
+	if root.Member("a") != nil || root.Delete("a") {
+		t.Error("member of array found")
+	}
+	if err := root.Children[0].Append(1); err == nil {
+		t.Error("Append on number: no error")
+	}
+	if err := root.Children[0].SetValue(2); err != nil || string(root.Bytes()) != `[2]` {
+		t.Errorf("got %s, %v", root.Bytes(), err)
+	}
+	if !slices.Equal(root.Children[0].Raw, []byte("2")) {
+		t.Errorf("new node Raw %s", root.Children[0].Raw)
+	}
+}
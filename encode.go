@@ -12,11 +12,16 @@ package json
 
 import (
 	"bytes"
+	"cmp"
+	"context"
 	"encoding"
 	"encoding/base64"
+	"errors"
 	"fmt"
+	"io"
 	"maps"
 	"math"
+	"math/bits"
 	"reflect"
 	"runtime"
 	"slices"
@@ -33,11 +38,18 @@ import (
 // Marshal returns the JSON encoding of v.
 //
 // Marshal traverses the value v recursively.
-// If an encountered value implements the Marshaler interface
-// and is not a nil pointer, Marshal calls its MarshalJSON method
-// to produce JSON. If no MarshalJSON method is present but the
-// value implements encoding.TextMarshaler instead, Marshal calls
-// its MarshalText method and encodes the result as a JSON string.
+// If an encountered value implements the MarshalerTo interface
+// and is not a nil pointer, Marshal calls its MarshalJSONTo method
+// to write the value.
+// Otherwise, if the value implements the MarshalerOrdered interface
+// and is not a nil pointer, Marshal calls its MarshalJSONOrdered method
+// and encodes the returned OrderedObject.
+// Otherwise, if the value implements the MarshalerContext or the
+// Marshaler interface and is not a nil pointer, Marshal calls its
+// MarshalJSONContext (with context.Background() unless MarshalContext is
+// used) or MarshalJSON method to produce JSON. If no MarshalJSON method is
+// present but the value implements encoding.TextMarshaler instead, Marshal
+// calls its MarshalText method and encodes the result as a JSON string.
 // The nil pointer exception is not strictly necessary
 // but mimics a similar, necessary exception in the behavior of
 // UnmarshalJSON.
@@ -76,6 +88,17 @@ import (
 // false, 0, a nil pointer, a nil interface value, and any empty array,
 // slice, map, or string.
 //
+// The "omitzero" option specifies that the field should be omitted
+// from the encoding if the field has a zero value, according to rules:
+//
+// 1) If the field type has an "IsZero() bool" method, that will be used to
+// determine whether the value is zero.
+//
+// 2) Otherwise, the value is zero if it is the zero value for its type.
+//
+// If both "omitempty" and "omitzero" are specified, the field will be omitted
+// if the value is either empty or zero (or both).
+//
 // As a special case, if the field tag is "-", the field is always omitted.
 // Note that a field with name "-" can still be generated using the tag "-,".
 //
@@ -107,6 +130,82 @@ import (
 //
 //	Int64String int64 `json:",string"`
 //
+// For slices, arrays and map values of these types (except []byte) the
+// option applies to every element:
+//
+//	Amounts []uint64 `json:",string"` // ["1","2"]
+//
+// The "inline" option can be given to a field of OrderedObject type or of
+// a map type with string keys to collect all the object members that don't
+// correspond to other fields on Unmarshal. They are marshaled in place of
+// this field as siblings of the other fields (no key is used for it), in
+// the same order for OrderedObject and sorted as usual for maps, so
+// documents with extension members can be round-tripped:
+//
+//	Extra OrderedObject `json:",inline"`
+//
+// "remain" is a synonym of "inline" accepted for OrderedObject fields.
+//
+// The "required" option makes Unmarshal fail with a MissingFieldsError if
+// the decoded object has no member for the field (null counts as a value).
+// It doesn't affect Marshal.
+//
+// The "default" option gives the value Unmarshal sets the field to when the
+// decoded object has no corresponding member, it doesn't affect Marshal.
+// It must be the last option since the value extends to the end of the tag.
+// For string fields the value is the string itself, for fields of other
+// types it's JSON:
+//
+//	Host string   `json:"host,default=localhost"`
+//	Port int      `json:"port,omitempty,default=8080"`
+//	Tags []string `json:"tags,default=[\"a\", \"b\"]"`
+//
+// The "format:hex" option makes a []byte field marshal as a string of
+// lowercase hex digits instead of base64, Unmarshal accepts digits of any
+// case for such a field:
+//
+//	Hash []byte `json:"hash,format:hex"`
+//
+// Similarly, "format:array" makes a []byte field marshal as an array of
+// numbers, Unmarshal accepts both an array and a base64 string for it.
+// "format:base64url" selects the URL-safe base64 alphabet without padding
+// (RFC 4648 section 5) for both Marshal and Unmarshal, the latter also
+// accepts padded strings then.
+//
+// The "format" option of a time.Time or *time.Time field gives the layout
+// used to marshal it as a string instead of RFC 3339 with nanoseconds. It's
+// either a name of a layout constant of the time package or a layout itself
+// (not containing commas). "unix", "unixmilli", "unixmicro" and "unixnano"
+// make the time be marshaled as a JSON number of seconds, milliseconds,
+// microseconds or nanoseconds since the Unix epoch (with the fractional
+// part if needed) instead. Unmarshal expects the same format then, times
+// decoded from numbers are in UTC:
+//
+//	Created time.Time `json:"created,format:unixmilli"`
+//	Day     time.Time `json:"day,format:DateOnly"`
+//
+// For a time.Duration or *time.Duration field the "format" option can be
+// "nano" (an integer number of nanoseconds, the same as without the
+// option), "micro", "milli" or "sec" for a JSON number of these units (with
+// the fractional part if needed) or "string" for a string produced by
+// Duration.String and parsed with time.ParseDuration:
+//
+//	Timeout time.Duration `json:"timeout,format:string"`
+//
+// The "order" option changes the position of the field in the encoded
+// object. Fields having it are marshaled first in increasing order of its
+// integer value (fields with equal values keep the declaration order),
+// then all the other fields follow in the declaration order:
+//
+//	Type    string `json:"type,order=1"`
+//	Version int    `json:"version,order=2"`
+//
+// The "redact" option marks a field holding sensitive data. Marshal
+// doesn't treat it specially, but an Encoder with a redactor set by
+// SetRedactor replaces its value with whatever the redactor returns:
+//
+//	Password string `json:"password,redact"`
+//
 // The key name will be used if it's a non-empty string consisting of
 // only Unicode letters, digits, and ASCII punctuation except quotation
 // marks, backslash, and comma.
@@ -154,13 +253,23 @@ import (
 // Interface values encode as the value contained in the interface.
 // A nil interface value encodes as the null JSON value.
 //
-// Channel, complex, and function values cannot be encoded in JSON.
-// Attempting to encode such a value causes Marshal to return
+// Iterator functions (iter.Seq) and receive-only channels encode as
+// JSON arrays of the values they produce, the values are pulled (or
+// received until the channel is closed) as the array is written and an
+// Encoder passes the output to its writer in chunks as it goes.
+// iter.Seq2 functions with string keys (of any string kind) encode as
+// JSON objects the same way, members are written in iteration order, and
+// so do values of other types with an All method returning such a function
+// (like ordered map types usually have).
+// A nil function or channel encodes as the null JSON value.
+//
+// Other channel types, complex, and function values cannot be encoded
+// in JSON. Attempting to encode such a value causes Marshal to return
 // an UnsupportedTypeError.
 //
 // JSON cannot represent cyclic data structures and Marshal does not
 // handle them. Passing cyclic structures to Marshal will result in
-// an infinite recursion.
+// an UnsupportedValueError.
 func Marshal(v any) ([]byte, error) {
 	e := &encodeState{}
 	err := e.marshal(v, encOpts{escapeHTML: true})
@@ -170,6 +279,43 @@ func Marshal(v any) ([]byte, error) {
 	return e.Bytes(), nil
 }
 
+// MarshalContext is like Marshal, but passes ctx to MarshalJSONContext
+// methods of MarshalerContext values, so they can use request-scoped
+// configuration.
+func MarshalContext(ctx context.Context, v any) ([]byte, error) {
+	e := &encodeState{ctx: ctx}
+	err := e.marshal(v, encOpts{escapeHTML: true})
+	if err != nil {
+		return nil, err
+	}
+	return e.Bytes(), nil
+}
+
+// AppendMarshal appends the JSON encoding of v to dst and returns the
+// extended buffer, see Marshal for details. The encoding is done in an
+// internal reusable buffer, so marshaling into a dst with enough capacity
+// doesn't allocate. dst is returned unchanged on error.
+func AppendMarshal(dst []byte, v any) ([]byte, error) {
+	e := newEncodeState()
+	defer putEncodeState(e)
+	err := e.marshal(v, encOpts{escapeHTML: true})
+	if err != nil {
+		return dst, err
+	}
+	return append(dst, e.Bytes()...), nil
+}
+
+// MarshalEscaping is like Marshal but escapes strings according to the
+// given profile instead of NeoCompat. Unknown profiles mean NeoCompat.
+func MarshalEscaping(v any, p EscapeProfile) ([]byte, error) {
+	e := &encodeState{}
+	err := e.marshal(v, encOpts{escapeHTML: true, extra: &extraOpts{escaping: p.valid()}})
+	if err != nil {
+		return nil, err
+	}
+	return e.Bytes(), nil
+}
+
 // MarshalIndent is like Marshal but applies Indent to format the output.
 func MarshalIndent(v any, prefix, indent string) ([]byte, error) {
 	b, err := Marshal(v)
@@ -177,7 +323,7 @@ func MarshalIndent(v any, prefix, indent string) ([]byte, error) {
 		return nil, err
 	}
 	var buf bytes.Buffer
-	err = Indent(&buf, b, prefix, indent)
+	err = indentEscaped(&buf, b, prefix, indent, false)
 	if err != nil {
 		return nil, err
 	}
@@ -219,12 +365,79 @@ func HTMLEscape(dst *bytes.Buffer, src []byte) {
 	}
 }
 
+// NeoEscape appends to dst the JSON-encoded src with all string literals
+// re-escaped the way Marshal does it (see NeoCompat), so that JSON produced
+// by other encoders can be compared byte by byte with (or hashed like) the
+// output of this package. Everything outside of string literals is copied
+// as is. Strings with invalid escapes are left unchanged.
+func NeoEscape(dst *bytes.Buffer, src []byte) {
+	opts := encOpts{escapeHTML: true}
+	start := 0
+	for i := 0; i < len(src); i++ {
+		if src[i] != '"' {
+			continue
+		}
+		end := i + 1
+		for end < len(src) && src[end] != '"' {
+			if src[end] == '\\' {
+				end++
+			}
+			end++
+		}
+		if end >= len(src) {
+			break // Unterminated string.
+		}
+		end++
+		if s, ok := unquoteBytes(src[i:end]); ok {
+			dst.Write(src[start:i])
+			dst.Write(appendString(dst.AvailableBuffer(), s, opts))
+			start = end
+		}
+		i = end - 1
+	}
+	dst.Write(src[start:])
+}
+
 // Marshaler is the interface implemented by types that
 // can marshal themselves into valid JSON.
 type Marshaler interface {
 	MarshalJSON() ([]byte, error)
 }
 
+// MarshalerContext is like Marshaler, but the method also gets the context
+// given to MarshalContext or Encoder.EncodeContext. It takes precedence over
+// Marshaler.
+type MarshalerContext interface {
+	MarshalJSONContext(ctx context.Context) ([]byte, error)
+}
+
+// MarshalerTo is the interface implemented by types that can write
+// themselves through an Encoder without producing the whole encoding in
+// memory first. MarshalJSONTo must write exactly one value with
+// enc.WriteToken and enc.Encode calls, enc is only valid during the call.
+// MarshalerTo takes precedence over MarshalerOrdered and Marshaler.
+type MarshalerTo interface {
+	MarshalJSONTo(enc *Encoder) error
+}
+
+// MarshalerAppend is the interface implemented by types that append
+// their encoding to a buffer, like the methods generated by cmd/jsongen.
+// AppendJSON must append exactly one compact JSON value escaped like
+// AppendString does it, its output is trusted and written as is (unless the
+// encoder reformats it, for canonical output or a KeyTemplate). It takes
+// precedence over the other marshaler interfaces except MarshalerTo.
+type MarshalerAppend interface {
+	AppendJSON(dst []byte) ([]byte, error)
+}
+
+// MarshalerOrdered is the interface implemented by types that can
+// represent themselves as an OrderedObject. It takes precedence over
+// Marshaler, the members are encoded as any other values, so there is no
+// need to produce (and escape) JSON by hand.
+type MarshalerOrdered interface {
+	MarshalJSONOrdered() (OrderedObject, error)
+}
+
 // An UnsupportedTypeError is returned by Marshal when attempting
 // to encode an unsupported value type.
 type UnsupportedTypeError struct {
@@ -267,26 +480,152 @@ func (e *MarshalerError) Error() string {
 	return "json: error calling MarshalJSON for type " + e.Type.String() + ": " + e.Err.Error()
 }
 
-var hex = "0123456789ABCDEF"
+var (
+	hex      = "0123456789ABCDEF"
+	lowerHex = "0123456789abcdef"
+)
 
 // An encodeState encodes JSON into a bytes.Buffer.
 type encodeState struct {
 	bytes.Buffer // accumulated output
 	scratch      [64]byte
+
+	depth int             // current nesting of arrays and objects
+	ctx   context.Context // passed to MarshalerContext values if not nil
+
+	sink      io.Writer // receives the output of streams as it's produced if not nil
+	streams   int       // number of iterators and channels being written
+	flushSize int       // output buffered before flushing, flushSize if 0
+	workers   int       // goroutines encoding top-level arrays if more than 1
+	pinned    int       // number of canonical objects being written
+	flushed   bool      // some output is written to sink already
+
+	// Keep track of what pointers we've seen in the current recursive call
+	// path, to avoid cycles that could lead to a stack overflow. Only do
+	// the relatively expensive map operations if ptrLevel is larger than
+	// startDetectingCyclesAfter, so that we skip the work if we're within a
+	// reasonable amount of nested pointers deep.
+	ptrLevel uint
+	ptrSeen  map[any]struct{}
+}
+
+const startDetectingCyclesAfter = 1000
+
+// flushSize is the amount of output buffered before it's written to
+// encodeState.sink.
+const flushSize = 4096
+
+// base64Chunk is the number of bytes base64-encoded at once, it's a multiple
+// of 3 giving flushSize bytes of output, so no padding is produced in the
+// middle.
+const base64Chunk = flushSize / 4 * 3
+
+// streaming reports whether the output is passed to e.sink as it's
+// produced.
+func (e *encodeState) streaming() bool {
+	return e.sink != nil && e.streams > 0 && e.pinned == 0
+}
+
+// flush writes the buffered output to e.sink if a stream is being written,
+// there is enough output and no canonical object being written needs it to
+// be sorted.
+func (e *encodeState) flush() {
+	if !e.streaming() || e.Len() < cmp.Or(e.flushSize, flushSize) {
+		return
+	}
+	e.flushed = true
+	if _, err := e.sink.Write(e.Bytes()); err != nil {
+		e.error(err)
+	}
+	e.Reset()
+}
+
+// markSeen records ptr of the pointer-like value v as being encoded,
+// it fails if it's already in the current recursive call path.
+func (e *encodeState) markSeen(v reflect.Value, ptr any) {
+	if _, ok := e.ptrSeen[ptr]; ok {
+		e.error(&UnsupportedValueError{v, fmt.Sprintf("encountered a cycle via %s", v.Type())})
+	}
+	if e.ptrSeen == nil {
+		e.ptrSeen = make(map[any]struct{})
+	}
+	e.ptrSeen[ptr] = struct{}{}
+}
+
+// slicePtr identifies a slice for cycle detection, the pointer to the
+// first element is not enough since slices can share the array.
+type slicePtr struct {
+	ptr any
+	len int
 }
 
 var encodeStatePool sync.Pool
 
+var (
+	poolDisabled atomic.Bool
+	poolMaxSize  atomic.Int64 // no limit if 0
+)
+
+// SetPooling enables or disables reusing the internal buffers of AppendMarshal,
+// Encoder and other functions that don't return them. Pooling is enabled by
+// default, short-lived programs may save a little by disabling it. It's safe
+// to call concurrently with encoding.
+func SetPooling(enabled bool) {
+	poolDisabled.Store(!enabled)
+}
+
+// SetPoolMaxBufferSize limits the capacity of the buffers kept for reuse
+// to n bytes, buffers that have grown larger while encoding a huge value
+// are left to the garbage collector, so that a long-running process doesn't
+// keep them forever. A non-positive n (the default) means no limit. It's
+// safe to call concurrently with encoding.
+func SetPoolMaxBufferSize(n int) {
+	poolMaxSize.Store(int64(max(n, 0)))
+}
+
 func newEncodeState() *encodeState {
+	if poolDisabled.Load() {
+		return new(encodeState)
+	}
 	if v := encodeStatePool.Get(); v != nil {
 		e := v.(*encodeState)
 		e.Reset()
+		e.depth = 0
+		e.ctx = nil
+		e.sink = nil
+		e.streams = 0
+		e.flushSize = 0
+		e.workers = 0
+		e.pinned = 0
+		e.flushed = false
+		if len(e.ptrSeen) > 0 {
+			panic("ptrEncoder.encode should have emptied ptrSeen via defers")
+		}
+		e.ptrLevel = 0
 		return e
 	}
 	return new(encodeState)
 }
 
-func (e *encodeState) marshal(v any, opts encOpts) (err error) {
+// putEncodeState returns e obtained from newEncodeState to the pool if
+// pooling is enabled and its buffer is not too large.
+func putEncodeState(e *encodeState) {
+	if poolDisabled.Load() {
+		return
+	}
+	if l := poolMaxSize.Load(); l > 0 && int64(e.Cap()) > l {
+		return
+	}
+	encodeStatePool.Put(e)
+}
+
+func (e *encodeState) marshal(v any, opts encOpts) error {
+	rv := reflect.ValueOf(v)
+	return e.marshalWith(valueEncoder(opts.extras().config, rv), rv, opts)
+}
+
+// marshalWith is like marshal, but uses the encoder f for v.
+func (e *encodeState) marshalWith(f encoderFunc, v reflect.Value, opts encOpts) (err error) {
 	defer func() {
 		if r := recover(); r != nil {
 			if _, ok := r.(runtime.Error); ok {
@@ -298,7 +637,7 @@ func (e *encodeState) marshal(v any, opts encOpts) (err error) {
 			err = r.(error)
 		}
 	}()
-	e.reflectValue(reflect.ValueOf(v), opts)
+	f(e, v, opts)
 	return nil
 }
 
@@ -306,6 +645,29 @@ func (e *encodeState) error(err error) {
 	panic(err)
 }
 
+// enter must be called when starting to encode an array or object,
+// it checks the nesting depth against the limit in opts.
+func (e *encodeState) enter(opts encOpts) {
+	e.depth++
+	x := opts.extra
+	if x == nil {
+		return
+	}
+	if x.maxDepth > 0 && e.depth > x.maxDepth {
+		e.error(&DepthError{Limit: x.maxDepth})
+	}
+	// The size is only checked here to stop early, the caller checks the
+	// whole value.
+	if x.maxSize > 0 && e.Len() > x.maxSize {
+		e.error(&SizeError{Limit: x.maxSize})
+	}
+}
+
+// leave must be called after an array or object opened with enter is done.
+func (e *encodeState) leave() {
+	e.depth--
+}
+
 func isEmptyValue(v reflect.Value) bool {
 	switch v.Kind() {
 	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
@@ -325,8 +687,51 @@ func isEmptyValue(v reflect.Value) bool {
 	return false
 }
 
+type isZeroer interface {
+	IsZero() bool
+}
+
+var isZeroerType = reflect.TypeFor[isZeroer]()
+
+// isZeroFunc returns a function calling the IsZero method of values of
+// type t or nil if there is no such method.
+func isZeroFunc(t reflect.Type) func(reflect.Value) bool {
+	switch {
+	case t.Kind() == reflect.Interface && t.Implements(isZeroerType):
+		return func(v reflect.Value) bool {
+			// Avoid panics calling IsZero on a nil interface or
+			// non-nil interface with nil pointer.
+			return v.IsNil() ||
+				(v.Elem().Kind() == reflect.Ptr && v.Elem().IsNil()) ||
+				v.Interface().(isZeroer).IsZero()
+		}
+	case t.Kind() == reflect.Ptr && t.Implements(isZeroerType):
+		return func(v reflect.Value) bool {
+			if v.IsNil() {
+				return true
+			}
+			return v.Interface().(isZeroer).IsZero()
+		}
+	case t.Implements(isZeroerType):
+		return func(v reflect.Value) bool {
+			return v.Interface().(isZeroer).IsZero()
+		}
+	case reflect.PointerTo(t).Implements(isZeroerType):
+		return func(v reflect.Value) bool {
+			if !v.CanAddr() {
+				// Temporarily box v so we can take the address.
+				v2 := reflect.New(v.Type()).Elem()
+				v2.Set(v)
+				v = v2
+			}
+			return v.Addr().Interface().(isZeroer).IsZero()
+		}
+	}
+	return nil
+}
+
 func (e *encodeState) reflectValue(v reflect.Value, opts encOpts) {
-	valueEncoder(v)(e, v, opts)
+	valueEncoder(opts.extras().config, v)(e, v, opts)
 }
 
 type encOpts struct {
@@ -334,21 +739,222 @@ type encOpts struct {
 	quoted bool
 	// escapeHTML causes '<', '>', and '&' to be escaped in JSON strings.
 	escapeHTML bool
+	// bytesFormat is the encoding of []byte fields given with the "format"
+	// tag option.
+	bytesFormat bytesFormat
+	// keyTemplate orders the members of the object being written and its
+	// children if not nil.
+	keyTemplate *KeyTemplate
+	// extra holds the options that are rarely set, nil if none is. It keeps
+	// encOpts small enough to be passed to every encoder in registers.
+	extra *extraOpts
+}
+
+// extraOpts are the encoding options that are rarely set, they're never
+// changed after encoding has started.
+type extraOpts struct {
+	// maxDepth limits the nesting of arrays and objects if positive.
+	maxDepth int
+	// maxSize limits the size of the encoded value in bytes if positive.
+	maxSize int
+	// escaping determines how strings are escaped.
+	escaping EscapeProfile
+	// hexCase overrides the escaping hex digits case if not zero.
+	hexCase HexCase
+	// printable causes printable non-ASCII characters to be written as
+	// is and the others to be escaped whatever the escaping is.
+	printable bool
+	// escapeSlash causes '/' to be escaped as \/.
+	escapeSlash bool
+	// escaper replaces all of the escaping options if not nil.
+	escaper Escaper
+	// floatFormat determines how floating point numbers are written.
+	floatFormat FloatFormat
+	// jsSafeInts causes integers not representable exactly in float64 to
+	// be written as strings.
+	jsSafeInts bool
+	// fixedMin and fixedMax limit the absolute values of floating point
+	// numbers written in fixed notation if fixedMax is positive.
+	fixedMin, fixedMax float64
+	// config holds extensions and encoders using them if not nil.
+	config *Config
+	// naming converts the names of struct fields without tags.
+	naming NamingConvention
+	// renames replaces the member names of struct fields.
+	renames map[string]string
+	// fieldFilter tells whether struct fields are to be written if not nil.
+	fieldFilter func(structType reflect.Type, field string) bool
+	// redactor replaces the values of fields with the "redact" option
+	// if not nil.
+	redactor func(name string, v any) any
+	// canonical causes objects to be written with members sorted and
+	// numbers converted as required by RFC 8785.
+	canonical bool
+	// keyCmp orders map keys if not nil, they're sorted bytewise otherwise.
+	keyCmp func(a, b string) int
+	// keyCheck causes the member order to be checked against keyTemplate
+	// instead of being changed.
+	keyCheck bool
+}
+
+// noExtraOpts are the defaults of extraOpts.
+var noExtraOpts extraOpts
+
+// extras returns the rarely set options of o.
+func (o encOpts) extras() *extraOpts {
+	if o.extra == nil {
+		return &noExtraOpts
+	}
+	return o.extra
+}
+
+// An Escaper decides how characters in JSON strings are escaped, it can be
+// set with Encoder.SetEscaper to replace the built-in rules.
+type Escaper interface {
+	// NeedsEscape reports whether the character r must be escaped.
+	// Invalid UTF-8 bytes are passed one by one as utf8.RuneError.
+	NeedsEscape(r rune) bool
+	// AppendEscaped appends the escaped form of s (holding a single
+	// character for which NeedsEscape returned true) to dst and returns the
+	// extended buffer. The result must be valid in a JSON string.
+	AppendEscaped(dst []byte, s string) []byte
+}
+
+// HexCase is the case of hex digits in \u escapes, see
+// Encoder.SetUppercaseHex.
+type HexCase int8
+
+const (
+	HexDefault HexCase = iota // depends on the EscapeProfile
+	HexUpper
+	HexLower
+)
+
+// A FloatFormat determines how the Encoder writes floating point numbers,
+// see Encoder.SetFloatFormat.
+type FloatFormat int
+
+const (
+	// FloatJS writes the shortest representation that round-trips using
+	// exponents for absolute values below 1e-6 and from 1e21 on, like
+	// ECMAScript number to string conversion does. This is the default.
+	FloatJS FloatFormat = iota
+	// FloatCSharp writes numbers like .NET double.ToString("R") (and
+	// float.ToString("R") for float32 values) does since .NET Core 3.0: the
+	// shortest representation that round-trips, the exponent is used when
+	// it's -5 or less or the integer part has more digits than the number has
+	// significant digits and more than 15 (7 for float32) ones, it's at
+	// least two digits long and always signed, as in 1E+15 and 1E-05.
+	FloatCSharp
+)
+
+// An EscapeProfile determines which characters are escaped in JSON strings
+// produced by the Encoder, see Encoder.SetEscaping.
+type EscapeProfile int
+
+const (
+	// NeoCompat escapes strings like the C# Neo node does: all non-ASCII
+	// characters, ", &, ', +, ` (and < and > with HTML escaping) are
+	// written as \uXXXX with uppercase hex digits, the bytes of invalid
+	// UTF-8 sequences are escaped as \u00XX. This is the default.
+	NeoCompat EscapeProfile = iota
+	// GoStd escapes strings like the standard library encoding/json does:
+	// only ", \ and control characters (and <, >, & with HTML escaping) are
+	// escaped, using lowercase hex digits, other characters are written as
+	// UTF-8 (except U+2028 and U+2029), invalid UTF-8 is replaced with
+	// U+FFFD.
+	GoStd
+	// ASCIIOnly is like GoStd, but escapes all non-ASCII characters, so the
+	// output is pure ASCII.
+	ASCIIOnly
+)
+
+// valid returns p if it's a known profile and NeoCompat otherwise.
+func (p EscapeProfile) valid() EscapeProfile {
+	if p < NeoCompat || p > ASCIIOnly {
+		return NeoCompat
+	}
+	return p
+}
+
+// safe reports whether the ASCII character b can be written as is.
+func (o encOpts) safe(b byte) bool {
+	x := o.extras()
+	if b == '/' {
+		return !x.escapeSlash
+	}
+	if x.escaping == NeoCompat {
+		return htmlSafeSet[b] || (!o.escapeHTML && safeSet[b])
+	}
+	return stdHTMLSafeSet[b] || (!o.escapeHTML && stdSafeSet[b])
+}
+
+// escapeTable returns the escapeTable for o.
+func (o encOpts) escapeTable() *escapeTable {
+	x := o.extras()
+	return &escapeTables[x.escaping][b2i(o.escapeHTML)][b2i(x.escapeSlash)]
+}
+
+// b2i returns 1 for true and 0 for false.
+func b2i(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// escapeRune reports whether the valid non-ASCII character c is to be
+// escaped.
+func (o encOpts) escapeRune(c rune) bool {
+	x := o.extras()
+	if x.printable {
+		if c < 0x10000 {
+			bmp := printableBMP()
+			return bmp[c>>6]&(1<<(c&63)) == 0
+		}
+		return !unicode.IsPrint(c)
+	}
+	return x.escaping != GoStd
+}
+
+// printableBMP returns the bitmap of the characters of the Basic
+// Multilingual Plane that are printable according to unicode.IsPrint,
+// it's built on first use.
+var printableBMP = sync.OnceValue(func() *[0x10000 / 64]uint64 {
+	var bmp [0x10000 / 64]uint64
+	for c := range rune(0x10000) {
+		if unicode.IsPrint(c) {
+			bmp[c>>6] |= 1 << (c & 63)
+		}
+	}
+	return &bmp
+})
+
+// hexDigits returns the digits to use in \u escapes.
+func (o encOpts) hexDigits() string {
+	if x := o.extras(); x.hexCase == HexUpper || x.hexCase == HexDefault && x.escaping == NeoCompat {
+		return hex
+	}
+	return lowerHex
 }
 
 type encoderFunc func(e *encodeState, v reflect.Value, opts encOpts)
 
 var encoderCache sync.Map // map[reflect.Type]encoderFunc
 
-func valueEncoder(v reflect.Value) encoderFunc {
+func valueEncoder(c *Config, v reflect.Value) encoderFunc {
 	if !v.IsValid() {
 		return invalidValueEncoder
 	}
-	return typeEncoder(v.Type())
+	return typeEncoder(c, v.Type())
 }
 
-func typeEncoder(t reflect.Type) encoderFunc {
-	if fi, ok := encoderCache.Load(t); ok {
+func typeEncoder(c *Config, t reflect.Type) encoderFunc {
+	cache := &encoderCache
+	if c != nil {
+		cache = &c.encoders
+	}
+	if fi, ok := cache.Load(t); ok {
 		return fi.(encoderFunc)
 	}
 
@@ -361,7 +967,7 @@ func typeEncoder(t reflect.Type) encoderFunc {
 		f  encoderFunc
 	)
 	wg.Add(1)
-	fi, loaded := encoderCache.LoadOrStore(t, encoderFunc(func(e *encodeState, v reflect.Value, opts encOpts) {
+	fi, loaded := cache.LoadOrStore(t, encoderFunc(func(e *encodeState, v reflect.Value, opts encOpts) {
 		wg.Wait()
 		f(e, v, opts)
 	}))
@@ -370,27 +976,75 @@ func typeEncoder(t reflect.Type) encoderFunc {
 	}
 
 	// Compute the real encoder and replace the indirect func with it.
-	f = newTypeEncoder(t, true)
+	f = c.wrapEncoder(t, newTypeEncoder(c, t, true))
 	wg.Done()
-	encoderCache.Store(t, f)
+	cache.Store(t, f)
 	return f
 }
 
 var (
-	marshalerType     = reflect.TypeFor[Marshaler]()
-	textMarshalerType = reflect.TypeFor[encoding.TextMarshaler]()
-	orderedObjectType = reflect.TypeFor[OrderedObject]()
+	marshalerType        = reflect.TypeFor[Marshaler]()
+	orderedMarshalerType = reflect.TypeFor[MarshalerOrdered]()
+	marshalerToType      = reflect.TypeFor[MarshalerTo]()
+	appendMarshalerType  = reflect.TypeFor[MarshalerAppend]()
+	ctxMarshalerType     = reflect.TypeFor[MarshalerContext]()
+	textMarshalerType    = reflect.TypeFor[encoding.TextMarshaler]()
+	orderedObjectType    = reflect.TypeFor[OrderedObject]()
 )
 
 // newTypeEncoder constructs an encoderFunc for a type.
 // The returned encoder only checks CanAddr when allowAddr is true.
-func newTypeEncoder(t reflect.Type, allowAddr bool) encoderFunc {
+func newTypeEncoder(c *Config, t reflect.Type, allowAddr bool) encoderFunc {
+	if fn, ok := typeEncoders.Load(t); ok {
+		return newRegisteredEncoder(fn.(func(*Encoder, reflect.Value) error))
+	}
+	if t.Kind() == reflect.Ptr {
+		if _, ok := typeEncoders.Load(t.Elem()); ok {
+			return newPtrEncoder(c, t)
+		}
+	}
+	if t.Implements(marshalerToType) {
+		return marshalerToEncoder
+	}
+	if t.Kind() != reflect.Ptr && allowAddr {
+		if reflect.PointerTo(t).Implements(marshalerToType) {
+			return newCondAddrEncoder(addrMarshalerToEncoder, newTypeEncoder(c, t, false))
+		}
+	}
+
+	if t.Implements(appendMarshalerType) {
+		return appendMarshalerEncoder
+	}
+	if t.Kind() != reflect.Ptr && allowAddr {
+		if reflect.PointerTo(t).Implements(appendMarshalerType) {
+			return newCondAddrEncoder(addrAppendMarshalerEncoder, newTypeEncoder(c, t, false))
+		}
+	}
+
+	if t.Implements(orderedMarshalerType) {
+		return orderedMarshalerEncoder
+	}
+	if t.Kind() != reflect.Ptr && allowAddr {
+		if reflect.PointerTo(t).Implements(orderedMarshalerType) {
+			return newCondAddrEncoder(addrOrderedMarshalerEncoder, newTypeEncoder(c, t, false))
+		}
+	}
+
+	if t.Implements(ctxMarshalerType) {
+		return ctxMarshalerEncoder
+	}
+	if t.Kind() != reflect.Ptr && allowAddr {
+		if reflect.PointerTo(t).Implements(ctxMarshalerType) {
+			return newCondAddrEncoder(addrCtxMarshalerEncoder, newTypeEncoder(c, t, false))
+		}
+	}
+
 	if t.Implements(marshalerType) {
 		return marshalerEncoder
 	}
 	if t.Kind() != reflect.Ptr && allowAddr {
 		if reflect.PointerTo(t).Implements(marshalerType) {
-			return newCondAddrEncoder(addrMarshalerEncoder, newTypeEncoder(t, false))
+			return newCondAddrEncoder(addrMarshalerEncoder, newTypeEncoder(c, t, false))
 		}
 	}
 
@@ -399,7 +1053,7 @@ func newTypeEncoder(t reflect.Type, allowAddr bool) encoderFunc {
 	}
 	if t.Kind() != reflect.Ptr && allowAddr {
 		if reflect.PointerTo(t).Implements(textMarshalerType) {
-			return newCondAddrEncoder(addrTextMarshalerEncoder, newTypeEncoder(t, false))
+			return newCondAddrEncoder(addrTextMarshalerEncoder, newTypeEncoder(c, t, false))
 		}
 	}
 
@@ -407,6 +1061,15 @@ func newTypeEncoder(t reflect.Type, allowAddr bool) encoderFunc {
 		return orderedObjectEncoder
 	}
 
+	if i, elem, ok := allMethod(t); ok {
+		return newAllEncoder(c, i, elem, false)
+	}
+	if t.Kind() != reflect.Ptr && allowAddr {
+		if i, elem, ok := allMethod(reflect.PointerTo(t)); ok {
+			return newCondAddrEncoder(newAllEncoder(c, i, elem, true), newTypeEncoder(c, t, false))
+		}
+	}
+
 	switch t.Kind() {
 	case reflect.Bool:
 		return boolEncoder
@@ -423,15 +1086,28 @@ func newTypeEncoder(t reflect.Type, allowAddr bool) encoderFunc {
 	case reflect.Interface:
 		return interfaceEncoder
 	case reflect.Struct:
-		return newStructEncoder(t)
+		return newStructEncoder(c, t)
 	case reflect.Map:
-		return newMapEncoder(t)
+		return newMapEncoder(c, t)
 	case reflect.Slice:
-		return newSliceEncoder(t)
+		return newSliceEncoder(c, t)
 	case reflect.Array:
-		return newArrayEncoder(t)
+		return newArrayEncoder(c, t)
 	case reflect.Ptr:
-		return newPtrEncoder(t)
+		return newPtrEncoder(c, t)
+	case reflect.Func:
+		if elem, ok := seqElem(t); ok {
+			return newStreamEncoder(c, elem)
+		}
+		if key, elem, ok := seq2Elems(t); ok && key.Kind() == reflect.String {
+			return newObjectStreamEncoder(c, elem)
+		}
+		return unsupportedTypeEncoder
+	case reflect.Chan:
+		if t.ChanDir() == reflect.RecvDir {
+			return newStreamEncoder(c, t.Elem())
+		}
+		return unsupportedTypeEncoder
 	default:
 		return unsupportedTypeEncoder
 	}
@@ -441,6 +1117,201 @@ func invalidValueEncoder(e *encodeState, v reflect.Value, _ encOpts) {
 	e.WriteString("null")
 }
 
+func marshalerToEncoder(e *encodeState, v reflect.Value, opts encOpts) {
+	if v.Kind() == reflect.Ptr && v.IsNil() {
+		e.WriteString("null")
+		return
+	}
+	m, ok := reflect.TypeAssert[MarshalerTo](v)
+	if !ok {
+		e.WriteString("null")
+		return
+	}
+	e.marshalTo(m.MarshalJSONTo, v.Type(), opts)
+}
+
+func addrMarshalerToEncoder(e *encodeState, v reflect.Value, opts encOpts) {
+	va := v.Addr()
+	if va.IsNil() {
+		e.WriteString("null")
+		return
+	}
+	m, _ := reflect.TypeAssert[MarshalerTo](va)
+	e.marshalTo(m.MarshalJSONTo, v.Type(), opts)
+}
+
+func appendMarshalerEncoder(e *encodeState, v reflect.Value, opts encOpts) {
+	if v.Kind() == reflect.Ptr && v.IsNil() {
+		e.WriteString("null")
+		return
+	}
+	m, ok := reflect.TypeAssert[MarshalerAppend](v)
+	if !ok {
+		e.WriteString("null")
+		return
+	}
+	e.appendMarshal(m, v.Type(), opts)
+}
+
+func addrAppendMarshalerEncoder(e *encodeState, v reflect.Value, opts encOpts) {
+	va := v.Addr()
+	if va.IsNil() {
+		e.WriteString("null")
+		return
+	}
+	m, _ := reflect.TypeAssert[MarshalerAppend](va)
+	e.appendMarshal(m, v.Type(), opts)
+}
+
+// appendMarshal writes the output of the AppendJSON method of m (of type
+// t) to e.
+func (e *encodeState) appendMarshal(m MarshalerAppend, t reflect.Type, opts encOpts) {
+	if opts.extras().canonical || opts.keyTemplate != nil {
+		b, err := m.AppendJSON(nil)
+		if err == nil {
+			err = e.writeJSON(b, opts, opts.escapeHTML)
+		}
+		if err != nil {
+			e.error(&MarshalerError{t, err})
+		}
+		return
+	}
+	// Values are appended to the free space of the buffer, so that they
+	// don't grow a slice of their own from scratch only to be copied.
+	if e.Available() < appendMarshalSize {
+		e.Grow(appendMarshalSize)
+	}
+	b, err := m.AppendJSON(e.AvailableBuffer())
+	if err != nil {
+		e.error(&MarshalerError{t, err})
+	}
+	e.Write(b)
+}
+
+// appendMarshalSize is the free space made for AppendJSON methods.
+const appendMarshalSize = 512
+
+// marshalTo lets write (MarshalJSONTo method or a registered encoder)
+// write the value of type t into e through a nested Encoder.
+func (e *encodeState) marshalTo(write func(*Encoder) error, t reflect.Type, opts encOpts) {
+	opts.quoted = false
+	opts.bytesFormat = bytesBase64
+	var buf bytes.Buffer // canonical output is collected to be reordered
+	w := &e.Buffer
+	if opts.extras().canonical {
+		w = &buf
+	}
+	start, tmpl := e.Len(), opts.keyTemplate
+	opts.keyTemplate = nil // The whole output is ordered at once.
+	enc := &Encoder{w: w, opts: opts, nested: true, depth: e.depth, ctx: e.ctx}
+	err := write(enc)
+	if err == nil && (enc.values == 0 || len(enc.tokenStack) > 0) {
+		err = errors.New("json: incomplete value written for " + t.String())
+	}
+	if err == nil && opts.extras().canonical {
+		err = e.canonicalJSON(buf.Bytes(), opts)
+	}
+	if err != nil {
+		e.error(&MarshalerError{t, err})
+	}
+	if tmpl != nil {
+		e.orderValue(start, tmpl, opts)
+	}
+}
+
+func orderedMarshalerEncoder(e *encodeState, v reflect.Value, opts encOpts) {
+	if v.Kind() == reflect.Ptr && v.IsNil() {
+		e.WriteString("null")
+		return
+	}
+	m, ok := reflect.TypeAssert[MarshalerOrdered](v)
+	if !ok {
+		e.WriteString("null")
+		return
+	}
+	ov, err := m.MarshalJSONOrdered()
+	if err != nil {
+		e.error(&MarshalerError{v.Type(), err})
+	}
+	orderedObjectEncoder(e, reflect.ValueOf(ov), opts)
+}
+
+func addrOrderedMarshalerEncoder(e *encodeState, v reflect.Value, opts encOpts) {
+	va := v.Addr()
+	if va.IsNil() {
+		e.WriteString("null")
+		return
+	}
+	m, _ := reflect.TypeAssert[MarshalerOrdered](va)
+	ov, err := m.MarshalJSONOrdered()
+	if err != nil {
+		e.error(&MarshalerError{v.Type(), err})
+	}
+	orderedObjectEncoder(e, reflect.ValueOf(ov), opts)
+}
+
+// context returns the context for MarshalerContext values.
+func (e *encodeState) context() context.Context {
+	if e.ctx == nil {
+		return context.Background()
+	}
+	return e.ctx
+}
+
+func ctxMarshalerEncoder(e *encodeState, v reflect.Value, opts encOpts) {
+	if v.Kind() == reflect.Ptr && v.IsNil() {
+		e.WriteString("null")
+		return
+	}
+	m, ok := reflect.TypeAssert[MarshalerContext](v)
+	if !ok {
+		e.WriteString("null")
+		return
+	}
+	b, err := m.MarshalJSONContext(e.context())
+	if err == nil {
+		// copy JSON into buffer, checking validity.
+		err = e.writeJSON(b, opts, opts.escapeHTML)
+	}
+	if err != nil {
+		e.error(&MarshalerError{v.Type(), err})
+	}
+}
+
+func addrCtxMarshalerEncoder(e *encodeState, v reflect.Value, opts encOpts) {
+	va := v.Addr()
+	if va.IsNil() {
+		e.WriteString("null")
+		return
+	}
+	m, _ := reflect.TypeAssert[MarshalerContext](va)
+	b, err := m.MarshalJSONContext(e.context())
+	if err == nil {
+		// copy JSON into buffer, checking validity.
+		err = e.writeJSON(b, opts, opts.escapeHTML)
+	}
+	if err != nil {
+		e.error(&MarshalerError{v.Type(), err})
+	}
+}
+
+// writeJSON writes the JSON produced by a Marshaler compacting it (and
+// ordering its members if opts.keyTemplate is set) or converting to the
+// canonical form if the canonical option is set.
+func (e *encodeState) writeJSON(b []byte, opts encOpts, escapeHTML bool) error {
+	if opts.extras().canonical {
+		return e.canonicalJSON(b, opts)
+	}
+	start := e.Len()
+	if err := compact(&e.Buffer, b, escapeHTML); err != nil {
+		return err
+	}
+	if opts.keyTemplate != nil {
+		e.orderValue(start, opts.keyTemplate, opts)
+	}
+	return nil
+}
+
 func marshalerEncoder(e *encodeState, v reflect.Value, opts encOpts) {
 	if v.Kind() == reflect.Ptr && v.IsNil() {
 		e.WriteString("null")
@@ -454,14 +1325,14 @@ func marshalerEncoder(e *encodeState, v reflect.Value, opts encOpts) {
 	b, err := m.MarshalJSON()
 	if err == nil {
 		// copy JSON into buffer, checking validity.
-		err = compact(&e.Buffer, b, opts.escapeHTML)
+		err = e.writeJSON(b, opts, opts.escapeHTML)
 	}
 	if err != nil {
 		e.error(&MarshalerError{v.Type(), err})
 	}
 }
 
-func addrMarshalerEncoder(e *encodeState, v reflect.Value, _ encOpts) {
+func addrMarshalerEncoder(e *encodeState, v reflect.Value, opts encOpts) {
 	va := v.Addr()
 	if va.IsNil() {
 		e.WriteString("null")
@@ -471,7 +1342,7 @@ func addrMarshalerEncoder(e *encodeState, v reflect.Value, _ encOpts) {
 	b, err := m.MarshalJSON()
 	if err == nil {
 		// copy JSON into buffer, checking validity.
-		err = compact(&e.Buffer, b, true)
+		err = e.writeJSON(b, opts, true)
 	}
 	if err != nil {
 		e.error(&MarshalerError{v.Type(), err})
@@ -488,7 +1359,7 @@ func textMarshalerEncoder(e *encodeState, v reflect.Value, opts encOpts) {
 	if err != nil {
 		e.error(&MarshalerError{v.Type(), err})
 	}
-	e.stringBytes(b, opts.escapeHTML)
+	e.stringBytes(b, opts)
 }
 
 func addrTextMarshalerEncoder(e *encodeState, v reflect.Value, opts encOpts) {
@@ -502,7 +1373,7 @@ func addrTextMarshalerEncoder(e *encodeState, v reflect.Value, opts encOpts) {
 	if err != nil {
 		e.error(&MarshalerError{v.Type(), err})
 	}
-	e.stringBytes(b, opts.escapeHTML)
+	e.stringBytes(b, opts)
 }
 
 func boolEncoder(e *encodeState, v reflect.Value, opts encOpts) {
@@ -519,26 +1390,96 @@ func boolEncoder(e *encodeState, v reflect.Value, opts encOpts) {
 	}
 }
 
+// maxSafeInteger is the largest integer a float64 can represent along
+// with all the smaller ones, Number.MAX_SAFE_INTEGER of JavaScript.
+const maxSafeInteger = 1<<53 - 1
+
 func intEncoder(e *encodeState, v reflect.Value, opts encOpts) {
-	b := strconv.AppendInt(e.scratch[:0], v.Int(), 10)
-	if opts.quoted {
+	n := v.Int()
+	if !opts.quoted && (n <= maxSafeInteger && n >= -maxSafeInteger || !opts.extras().canonical && !opts.extras().jsSafeInts) {
+		e.Write(appendInt(e.available(maxIntLen), n))
+		return
+	}
+	b := strconv.AppendInt(e.scratch[:0], n, 10)
+	if opts.extras().canonical && !opts.quoted && (n > maxSafeInteger || n < -maxSafeInteger) {
+		e.Write(appendFloatJS(b[:0], float64(n), 64))
+		return
+	}
+	if opts.quoted || opts.extras().jsSafeInts && (n > maxSafeInteger || n < -maxSafeInteger) {
+		e.WriteByte('"')
+		e.Write(b)
 		e.WriteByte('"')
+		return
 	}
 	e.Write(b)
-	if opts.quoted {
+}
+
+func uintEncoder(e *encodeState, v reflect.Value, opts encOpts) {
+	n := v.Uint()
+	if !opts.quoted && (n <= maxSafeInteger || !opts.extras().canonical && !opts.extras().jsSafeInts) {
+		e.Write(appendUint(e.available(maxIntLen), n))
+		return
+	}
+	b := strconv.AppendUint(e.scratch[:0], n, 10)
+	if opts.extras().canonical && !opts.quoted && n > maxSafeInteger {
+		e.Write(appendFloatJS(b[:0], float64(n), 64))
+		return
+	}
+	if opts.quoted || opts.extras().jsSafeInts && n > maxSafeInteger {
+		e.WriteByte('"')
+		e.Write(b)
 		e.WriteByte('"')
+		return
+	}
+	e.Write(b)
+}
+
+// digitPairs holds the decimal representations of 00 to 99.
+const digitPairs = "0001020304050607080910111213141516171819202122232425262728293031323334353637383940414243444546474849" +
+	"5051525354555657585960616263646566676869707172737475767778798081828384858687888990919293949596979899"
+
+// appendUint appends the decimal representation of n to dst like
+// strconv.AppendUint, but two digits at a time straight into dst.
+func appendUint(dst []byte, n uint64) []byte {
+	// log10(2) is about 1233/4096.
+	digits := bits.Len64(n) * 1233 >> 12
+	if digits < len(pow10) && n >= pow10[digits] {
+		digits++
+	}
+	digits = max(digits, 1)
+	dst = slices.Grow(dst, digits)
+	i := len(dst) + digits
+	dst = dst[:i]
+	for n >= 100 {
+		q := n / 100
+		j := (n - q*100) * 2
+		i -= 2
+		dst[i], dst[i+1] = digitPairs[j], digitPairs[j+1]
+		n = q
+	}
+	if n >= 10 {
+		dst[i-2], dst[i-1] = digitPairs[n*2], digitPairs[n*2+1]
+	} else {
+		dst[i-1] = byte('0' + n)
 	}
+	return dst
 }
 
-func uintEncoder(e *encodeState, v reflect.Value, opts encOpts) {
-	b := strconv.AppendUint(e.scratch[:0], v.Uint(), 10)
-	if opts.quoted {
-		e.WriteByte('"')
+// pow10 holds the powers of 10 that fit into uint64.
+var pow10 = func() (p [20]uint64) {
+	p[0] = 1
+	for i := 1; i < len(p); i++ {
+		p[i] = p[i-1] * 10
 	}
-	e.Write(b)
-	if opts.quoted {
-		e.WriteByte('"')
+	return
+}()
+
+// appendInt is like appendUint, but for signed integers.
+func appendInt(dst []byte, n int64) []byte {
+	if n < 0 {
+		return appendUint(append(dst, '-'), uint64(-n))
 	}
+	return appendUint(dst, uint64(n))
 }
 
 type floatEncoder int // number of bits
@@ -548,13 +1489,49 @@ func (bits floatEncoder) encode(e *encodeState, v reflect.Value, opts encOpts) {
 	if math.IsInf(f, 0) || math.IsNaN(f) {
 		e.error(&UnsupportedValueError{v, strconv.FormatFloat(f, 'g', -1, int(bits))})
 	}
+	x := opts.extras()
+	if x.fixedMax > 0 {
+		if abs := math.Abs(f); abs != 0 && (abs < x.fixedMin || abs >= x.fixedMax) {
+			e.error(&UnsupportedValueError{v, strconv.FormatFloat(f, 'g', -1, int(bits)) + " is out of the fixed notation range"})
+		}
+		if opts.quoted {
+			e.WriteByte('"')
+		}
+		e.Write(strconv.AppendFloat(e.scratch[:0], f, 'f', -1, int(bits)))
+		if opts.quoted {
+			e.WriteByte('"')
+		}
+		return
+	}
+	if x.floatFormat == FloatCSharp {
+		if opts.quoted {
+			e.WriteByte('"')
+		}
+		e.Write(appendFloatCSharp(e.scratch[:0], f, int(bits)))
+		if opts.quoted {
+			e.WriteByte('"')
+		}
+		return
+	}
+
+	b := appendFloatJS(e.scratch[:0], f, int(bits))
+	if opts.quoted {
+		e.WriteByte('"')
+	}
+	e.Write(b)
+	if opts.quoted {
+		e.WriteByte('"')
+	}
+}
 
+// appendFloatJS appends f formatted as if by ES6 number to string
+// conversion.
+func appendFloatJS(b []byte, f float64, bits int) []byte {
 	// Convert as if by ES6 number to string conversion.
 	// This matches most other JSON generators.
 	// See golang.org/issue/6384 and golang.org/issue/14135.
 	// Like fmt %g, but the exponent cutoffs are different
 	// and exponents themselves are not padded to two digits.
-	b := e.scratch[:0]
 	abs := math.Abs(f)
 	fmt := byte('f')
 	// Note: Must use float32 comparisons for underlying float32 value to get precise cutoffs right.
@@ -563,23 +1540,81 @@ func (bits floatEncoder) encode(e *encodeState, v reflect.Value, opts encOpts) {
 			fmt = 'e'
 		}
 	}
-	b = strconv.AppendFloat(b, f, fmt, -1, int(bits))
+	start := len(b)
+	b = strconv.AppendFloat(b, f, fmt, -1, bits)
 	if fmt == 'e' {
 		// clean up e-09 to e-9
 		n := len(b)
-		if n >= 4 && b[n-4] == 'e' && b[n-3] == '-' && b[n-2] == '0' {
+		if n-start >= 4 && b[n-4] == 'e' && b[n-3] == '-' && b[n-2] == '0' {
 			b[n-2] = b[n-1]
 			b = b[:n-1]
 		}
 	}
+	return b
+}
 
-	if opts.quoted {
-		e.WriteByte('"')
+// appendFloatCSharp appends f formatted like .NET "R" format does.
+func appendFloatCSharp(b []byte, f float64, bits int) []byte {
+	var buf, db [32]byte
+	s := strconv.AppendFloat(buf[:0], f, 'e', -1, bits)
+	if s[0] == '-' {
+		b = append(b, '-')
+		s = s[1:]
 	}
-	e.Write(b)
-	if opts.quoted {
-		e.WriteByte('"')
+	mant, exp, _ := bytes.Cut(s, []byte("e"))
+	digits := append(db[:0], mant[0])
+	if len(mant) > 2 {
+		digits = append(digits, mant[2:]...)
+	}
+	scale := 0
+	for _, c := range exp[1:] {
+		scale = scale*10 + int(c-'0')
+	}
+	if exp[0] == '-' {
+		scale = -scale
+	}
+	scale++ // Position of the decimal point relative to the digits.
+	precision := 15
+	if bits == 32 {
+		precision = 7
+	}
+	if scale > max(len(digits), precision) || scale < -3 {
+		b = append(b, digits[0])
+		if len(digits) > 1 {
+			b = append(b, '.')
+			b = append(b, digits[1:]...)
+		}
+		b = append(b, 'E')
+		if scale-1 >= 0 {
+			b = append(b, '+')
+		} else {
+			b = append(b, '-')
+		}
+		exp := max(scale-1, 1-scale)
+		if exp < 10 {
+			b = append(b, '0')
+		}
+		return strconv.AppendInt(b, int64(exp), 10)
+	}
+	if scale <= 0 {
+		b = append(b, "0."...)
+		for range -scale {
+			b = append(b, '0')
+		}
+		return append(b, digits...)
+	}
+	for i := range scale {
+		if i < len(digits) {
+			b = append(b, digits[i])
+		} else {
+			b = append(b, '0')
+		}
 	}
+	if len(digits) > scale {
+		b = append(b, '.')
+		b = append(b, digits[scale:]...)
+	}
+	return b
 }
 
 var (
@@ -598,6 +1633,14 @@ func stringEncoder(e *encodeState, v reflect.Value, opts encOpts) {
 		if !isValidNumber(numStr) {
 			e.error(fmt.Errorf("json: invalid number literal %q", numStr))
 		}
+		if opts.extras().canonical {
+			f, err := strconv.ParseFloat(numStr, 64)
+			if err != nil {
+				e.error(&UnsupportedValueError{v, numStr})
+			}
+			e.Write(appendFloatJS(e.scratch[:0], f, 64))
+			return
+		}
 		e.WriteString(numStr)
 		return
 	}
@@ -606,9 +1649,9 @@ func stringEncoder(e *encodeState, v reflect.Value, opts encOpts) {
 		if err != nil {
 			e.error(err)
 		}
-		e.string(string(sb), opts.escapeHTML)
+		e.string(string(sb), opts)
 	} else {
-		e.string(v.String(), opts.escapeHTML)
+		e.string(v.String(), opts)
 	}
 }
 
@@ -625,16 +1668,52 @@ func unsupportedTypeEncoder(e *encodeState, v reflect.Value, _ encOpts) {
 }
 
 type structEncoder struct {
-	fields    []field
-	fieldEncs []encoderFunc
+	fields     []field
+	fieldEncs  []encoderFunc
+	inlineEncs []*mapEncoder // encoders of inline map fields
 }
 
 func (se *structEncoder) encode(e *encodeState, v reflect.Value, opts encOpts) {
+	start, t := e.Len(), opts.keyTemplate
+	if opts.extras().canonical || t != nil {
+		e.pinned++
+	}
+	e.enter(opts)
 	e.WriteByte('{')
 	first := true
-	for i, f := range se.fields {
+	x := opts.extras()
+	for i := range se.fields {
+		f := &se.fields[i]
+		name := f.nameIn(x.naming)
+		if renamed, ok := x.renames[name]; ok {
+			name = renamed
+		}
+		opts.keyTemplate = t
+		if x.fieldFilter != nil && !f.inline && !x.fieldFilter(v.Type(), name) {
+			continue
+		}
 		fv := fieldByIndex(v, f.index)
-		if !fv.IsValid() || f.omitEmpty && isEmptyValue(fv) {
+		if !fv.IsValid() || f.omitEmpty && isEmptyValue(fv) ||
+			f.omitZero && (f.isZero == nil && fv.IsZero() || f.isZero != nil && f.isZero(fv)) {
+			continue
+		}
+		if f.inline {
+			if me := se.inlineEncs[i]; me != nil {
+				first = me.encodeMembers(e, fv, opts, first)
+				continue
+			}
+			ov, _ := reflect.TypeAssert[OrderedObject](fv)
+			for _, o := range ov {
+				if first {
+					first = false
+				} else {
+					e.WriteByte(',')
+				}
+				e.string(o.Key, opts)
+				e.WriteByte(':')
+				opts.keyTemplate = t.member(o.Key)
+				e.reflectValue(reflect.ValueOf(o.Value), opts)
+			}
 			continue
 		}
 		if first {
@@ -642,22 +1721,58 @@ func (se *structEncoder) encode(e *encodeState, v reflect.Value, opts encOpts) {
 		} else {
 			e.WriteByte(',')
 		}
-		e.string(f.name, opts.escapeHTML)
+		if name == f.name && f.quotedName != "" && x.escaper == nil {
+			e.WriteString(f.quotedName)
+		} else {
+			e.string(name, opts)
+		}
 		e.WriteByte(':')
-		opts.quoted = f.quoted
+		opts.keyTemplate = t.member(name)
+		if f.redact && x.redactor != nil {
+			e.redacted(name, fv, opts)
+			continue
+		}
+		opts.quoted = f.quoted || f.quotedElems
+		opts.bytesFormat = f.format
 		se.fieldEncs[i](e, fv, opts)
+		e.flush()
 	}
 	e.WriteByte('}')
+	e.leave()
+	if opts.extras().canonical {
+		e.sortMembers(start)
+	} else if t != nil {
+		e.orderMembers(start, t, opts)
+	}
 }
 
-func newStructEncoder(t reflect.Type) encoderFunc {
-	fields := cachedTypeFields(t)
+// redacted writes the value returned by the redactor of opts for the field
+// name with the value v.
+func (e *encodeState) redacted(name string, v reflect.Value, opts encOpts) {
+	var fv any
+	if v.CanInterface() {
+		fv = v.Interface()
+	}
+	opts.quoted = false
+	opts.bytesFormat = bytesBase64
+	e.reflectValue(reflect.ValueOf(opts.extras().redactor(name, fv)), opts)
+}
+
+func newStructEncoder(c *Config, t reflect.Type) encoderFunc {
+	fields := c.typeFields(t)
 	se := &structEncoder{
-		fields:    fields,
-		fieldEncs: make([]encoderFunc, len(fields)),
+		fields:     fields,
+		fieldEncs:  make([]encoderFunc, len(fields)),
+		inlineEncs: make([]*mapEncoder, len(fields)),
 	}
 	for i, f := range fields {
-		se.fieldEncs[i] = typeEncoder(typeByIndex(t, f.index))
+		se.fieldEncs[i] = typeEncoder(c, typeByIndex(t, f.index))
+		if f.inline && f.typ.Kind() == reflect.Map {
+			se.inlineEncs[i] = &mapEncoder{typeEncoder(c, f.typ.Elem())}
+		}
+		if f.timeFormat != "" {
+			se.fieldEncs[i] = newTimeEncoder(f.typ, f.timeFormat)
+		}
 	}
 	return se.encode
 }
@@ -671,8 +1786,32 @@ func (me *mapEncoder) encode(e *encodeState, v reflect.Value, opts encOpts) {
 		e.WriteString("null")
 		return
 	}
+	if e.ptrLevel++; e.ptrLevel > startDetectingCyclesAfter {
+		// We're a large number of nested ptrEncoder.encode calls deep;
+		// start checking if we've run into a pointer cycle.
+		ptr := v.UnsafePointer()
+		e.markSeen(v, ptr)
+		defer delete(e.ptrSeen, ptr)
+	}
+	start := e.Len()
+	if opts.keyTemplate != nil {
+		e.pinned++
+	}
+	e.enter(opts)
 	e.WriteByte('{')
+	me.encodeMembers(e, v, opts, true)
+	e.WriteByte('}')
+	e.leave()
+	if opts.keyTemplate != nil {
+		e.orderMembers(start, opts.keyTemplate, opts)
+	}
+	e.ptrLevel--
+}
 
+// encodeMembers writes the members of the map v without braces, first
+// tells whether there are no members before them. It returns the updated
+// first value.
+func (me *mapEncoder) encodeMembers(e *encodeState, v reflect.Value, opts encOpts, first bool) bool {
 	// Extract and sort the keys.
 	keys := v.MapKeys()
 	sv := make([]reflectWithString, len(keys))
@@ -682,20 +1821,57 @@ func (me *mapEncoder) encode(e *encodeState, v reflect.Value, opts encOpts) {
 			e.error(&MarshalerError{v.Type(), err})
 		}
 	}
-	sort.Slice(sv, func(i, j int) bool { return sv[i].s < sv[j].s })
+	if cmp := opts.extras().keyCmp; cmp != nil {
+		sort.Slice(sv, func(i, j int) bool { return cmp(sv[i].s, sv[j].s) < 0 })
+	} else {
+		sort.Slice(sv, func(i, j int) bool { return sv[i].s < sv[j].s })
+	}
 
-	for i, kv := range sv {
-		if i > 0 {
+	t := opts.keyTemplate
+	for _, kv := range sv {
+		if first {
+			first = false
+		} else {
 			e.WriteByte(',')
 		}
-		e.string(kv.s, opts.escapeHTML)
+		e.string(kv.s, opts)
 		e.WriteByte(':')
+		opts.keyTemplate = t.member(kv.s)
 		me.elemEnc(e, v.MapIndex(kv.v), opts)
+		e.flush()
 	}
-	e.WriteByte('}')
+	return first
+}
+
+// CompareUTF16 compares strings by their UTF-16 code units like ordinal
+// string comparison in C# does, it can be passed to Encoder.SetMapKeyOrder.
+// The result differs from the byte order of UTF-8 strings only for
+// characters above U+FFFF which go before U+E000-U+FFFF in UTF-16.
+func CompareUTF16(a, b string) int {
+	for a != "" && b != "" {
+		ra, na := utf8.DecodeRuneInString(a)
+		rb, nb := utf8.DecodeRuneInString(b)
+		if ra != rb {
+			return cmp.Compare(utf16Key(ra), utf16Key(rb))
+		}
+		if c := strings.Compare(a[:na], b[:nb]); c != 0 {
+			return c // Different invalid bytes.
+		}
+		a, b = a[na:], b[nb:]
+	}
+	return cmp.Compare(len(a), len(b))
+}
+
+// utf16Key returns a number ordering r like its UTF-16 encoding.
+func utf16Key(r rune) uint32 {
+	if r < 0x10000 {
+		return uint32(r) << 16
+	}
+	r1, r2 := utf16.EncodeRune(r)
+	return uint32(r1)<<16 | uint32(r2)
 }
 
-func newMapEncoder(t reflect.Type) encoderFunc {
+func newMapEncoder(c *Config, t reflect.Type) encoderFunc {
 	switch t.Key().Kind() {
 	case reflect.String,
 		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
@@ -705,7 +1881,7 @@ func newMapEncoder(t reflect.Type) encoderFunc {
 			return unsupportedTypeEncoder
 		}
 	}
-	me := &mapEncoder{typeEncoder(t.Elem())}
+	me := &mapEncoder{typeEncoder(c, t.Elem())}
 	return me.encode
 }
 
@@ -714,40 +1890,87 @@ func orderedObjectEncoder(e *encodeState, v reflect.Value, opts encOpts) {
 		e.WriteString("null")
 		return
 	}
-	e.WriteByte('{')
 	var ov, _ = reflect.TypeAssert[OrderedObject](v)
+	if e.ptrLevel++; e.ptrLevel > startDetectingCyclesAfter {
+		ptr := slicePtr{v.UnsafePointer(), v.Len()}
+		e.markSeen(v, ptr)
+		defer delete(e.ptrSeen, ptr)
+	}
+	start, t := e.Len(), opts.keyTemplate
+	if opts.extras().canonical || t != nil {
+		e.pinned++
+	}
+	e.enter(opts)
+	e.WriteByte('{')
 	for i, o := range ov {
 		if i > 0 {
 			e.WriteByte(',')
 		}
-		e.string(o.Key, opts.escapeHTML)
+		e.string(o.Key, opts)
 		e.WriteByte(':')
+		opts.keyTemplate = t.member(o.Key)
 		e.reflectValue(reflect.ValueOf(o.Value), opts)
+		e.flush()
 	}
 	e.WriteByte('}')
+	e.leave()
+	if opts.extras().canonical {
+		e.sortMembers(start)
+	} else if t != nil {
+		e.orderMembers(start, t, opts)
+	}
+	e.ptrLevel--
 }
 
-func encodeByteSlice(e *encodeState, v reflect.Value, _ encOpts) {
+func encodeByteSlice(e *encodeState, v reflect.Value, opts encOpts) {
 	if v.IsNil() {
 		e.WriteString("null")
 		return
 	}
 	s := v.Bytes()
+	if opts.bytesFormat == bytesArray {
+		b := append(e.AvailableBuffer(), '[')
+		for i, c := range s {
+			if i > 0 {
+				b = append(b, ',')
+			}
+			b = strconv.AppendUint(b, uint64(c), 10)
+		}
+		e.Write(append(b, ']'))
+		return
+	}
 	e.WriteByte('"')
+	if opts.bytesFormat == bytesHex {
+		b := e.AvailableBuffer()
+		for _, c := range s {
+			b = append(b, lowerHex[c>>4], lowerHex[c&0xF])
+		}
+		e.Write(b)
+		e.WriteByte('"')
+		return
+	}
+	b64 := base64.StdEncoding
+	if opts.bytesFormat == bytesBase64URL {
+		b64 = base64.RawURLEncoding
+	}
 	if len(s) < 1024 {
 		// for small buffers, using Encode directly is much faster.
-		dst := make([]byte, base64.StdEncoding.EncodedLen(len(s)))
-		base64.StdEncoding.Encode(dst, s)
+		dst := make([]byte, b64.EncodedLen(len(s)))
+		b64.Encode(dst, s)
 		e.Write(dst)
 	} else {
-		// for large buffers, avoid unnecessary extra temporary
-		// buffer space.
-		enc := base64.NewEncoder(base64.StdEncoding, e)
-		_, err := enc.Write(s)
-		if err != nil {
-			panic(err)
+		// for large buffers, encode in chunks straight into the output,
+		// so that they're flushed to the sink as they're produced when
+		// streaming and the output doesn't grow several times otherwise.
+		if !e.streaming() {
+			e.Grow(b64.EncodedLen(len(s)) + 1)
+		}
+		for len(s) > 0 {
+			n := min(len(s), base64Chunk)
+			e.Write(b64.AppendEncode(e.AvailableBuffer(), s[:n]))
+			s = s[n:]
+			e.flush()
 		}
-		enc.Close()
 	}
 	e.WriteByte('"')
 }
@@ -762,18 +1985,25 @@ func (se *sliceEncoder) encode(e *encodeState, v reflect.Value, opts encOpts) {
 		e.WriteString("null")
 		return
 	}
+	if e.ptrLevel++; e.ptrLevel > startDetectingCyclesAfter {
+		ptr := slicePtr{v.UnsafePointer(), v.Len()}
+		e.markSeen(v, ptr)
+		defer delete(e.ptrSeen, ptr)
+	}
 	se.arrayEnc(e, v, opts)
+	e.ptrLevel--
 }
 
-func newSliceEncoder(t reflect.Type) encoderFunc {
+func newSliceEncoder(c *Config, t reflect.Type) encoderFunc {
 	// Byte slices get special treatment; arrays don't.
 	if t.Elem().Kind() == reflect.Uint8 {
 		p := reflect.PointerTo(t.Elem())
-		if !p.Implements(marshalerType) && !p.Implements(textMarshalerType) {
+		if !p.Implements(marshalerType) && !p.Implements(textMarshalerType) && !p.Implements(orderedMarshalerType) &&
+			!p.Implements(marshalerToType) && !p.Implements(ctxMarshalerType) && !p.Implements(appendMarshalerType) {
 			return encodeByteSlice
 		}
 	}
-	enc := &sliceEncoder{newArrayEncoder(t)}
+	enc := &sliceEncoder{newArrayEncoder(c, t)}
 	return enc.encode
 }
 
@@ -782,19 +2012,104 @@ type arrayEncoder struct {
 }
 
 func (ae *arrayEncoder) encode(e *encodeState, v reflect.Value, opts encOpts) {
+	e.enter(opts)
+	opts.keyTemplate = opts.keyTemplate.elements()
 	e.WriteByte('[')
 	n := v.Len()
-	for i := range n {
+	if e.workers > 1 && e.depth == 1 && n >= parallelMinLen {
+		ae.encodeParallel(e, v, opts)
+	} else {
+		ae.encodeRange(e, v, 0, n, opts)
+	}
+	e.WriteByte(']')
+	e.leave()
+}
+
+// encodeRange writes the elements of v from i to j separated by commas.
+func (ae *arrayEncoder) encodeRange(e *encodeState, v reflect.Value, i, j int, opts encOpts) {
+	for k := i; k < j; k++ {
+		if k > i {
+			e.WriteByte(',')
+		}
+		ae.elemEnc(e, v.Index(k), opts)
+		e.flush()
+	}
+}
+
+// parallelMinLen is the minimal number of elements of a top-level array
+// encoded in parallel, shorter ones aren't worth it.
+const parallelMinLen = 256
+
+// encodeParallel writes the elements of v split into chunks encoded by
+// e.workers goroutines into their own buffers. The chunks are written in
+// order as they're done, at most e.workers of them are kept in memory. If
+// encoding fails, the chunks still being encoded are stopped and their
+// output is dropped, a panic in a worker is raised again by the caller.
+func (ae *arrayEncoder) encodeParallel(e *encodeState, v reflect.Value, opts encOpts) {
+	type chunk struct {
+		e        *encodeState
+		err      error
+		panicked any
+	}
+	n := v.Len()
+	size := max((n+8*e.workers-1)/(8*e.workers), 16)
+	var (
+		pending []chan chunk
+		next    int
+		stop    atomic.Bool // set when the output of the other chunks is not needed
+	)
+	defer stop.Store(true)
+	start := func() {
+		i, j := next, min(next+size, n)
+		next = j
+		ch := make(chan chunk, 1)
+		pending = append(pending, ch)
+		go func() {
+			defer func() {
+				if r := recover(); r != nil {
+					ch <- chunk{panicked: r}
+				}
+			}()
+			ce := newEncodeState()
+			ce.ctx = e.ctx
+			ce.depth = e.depth
+			err := ce.marshalWith(func(ce *encodeState, v reflect.Value, opts encOpts) {
+				for k := i; k < j && !stop.Load(); k++ {
+					if k > i {
+						ce.WriteByte(',')
+					}
+					ae.elemEnc(ce, v.Index(k), opts)
+				}
+			}, v, opts)
+			ch <- chunk{e: ce, err: err}
+		}()
+	}
+	for next < n && len(pending) < e.workers {
+		start()
+	}
+	for i := 0; len(pending) > 0; i++ {
+		c := <-pending[0]
+		pending = pending[1:]
+		if c.panicked != nil {
+			panic(c.panicked)
+		}
+		if c.err != nil {
+			e.error(c.err)
+		}
+		if next < n {
+			start()
+		}
 		if i > 0 {
 			e.WriteByte(',')
 		}
-		ae.elemEnc(e, v.Index(i), opts)
+		e.Write(c.e.Bytes())
+		putEncodeState(c.e)
+		e.flush()
 	}
-	e.WriteByte(']')
 }
 
-func newArrayEncoder(t reflect.Type) encoderFunc {
-	enc := &arrayEncoder{typeEncoder(t.Elem())}
+func newArrayEncoder(c *Config, t reflect.Type) encoderFunc {
+	enc := &arrayEncoder{typeEncoder(c, t.Elem())}
 	return enc.encode
 }
 
@@ -807,11 +2122,17 @@ func (pe *ptrEncoder) encode(e *encodeState, v reflect.Value, opts encOpts) {
 		e.WriteString("null")
 		return
 	}
+	if e.ptrLevel++; e.ptrLevel > startDetectingCyclesAfter {
+		ptr := v.Interface()
+		e.markSeen(v, ptr)
+		defer delete(e.ptrSeen, ptr)
+	}
 	pe.elemEnc(e, v.Elem(), opts)
+	e.ptrLevel--
 }
 
-func newPtrEncoder(t reflect.Type) encoderFunc {
-	enc := &ptrEncoder{typeEncoder(t.Elem())}
+func newPtrEncoder(c *Config, t reflect.Type) encoderFunc {
+	enc := &ptrEncoder{typeEncoder(c, t.Elem())}
 	return enc.encode
 }
 
@@ -903,61 +2224,84 @@ func (w *reflectWithString) resolve() error {
 	panic("unexpected map key type")
 }
 
-// NOTE: keep in sync with stringBytes below.
-func (e *encodeState) string(s string, escapeHTML bool) int {
-	len0 := e.Len()
-	e.WriteByte('"')
+func (e *encodeState) string(s string, opts encOpts) {
+	e.Write(appendString(e.available(len(s)+2), s, opts))
+}
+
+func (e *encodeState) stringBytes(s []byte, opts encOpts) {
+	e.Write(appendString(e.available(len(s)+2), s, opts))
+}
+
+// available returns the free space of the buffer after making it at least
+// n bytes long, so that appending up to n bytes to it doesn't allocate a
+// slice only to be copied into the buffer.
+func (e *encodeState) available(n int) []byte {
+	if e.Available() < n {
+		e.Grow(n)
+	}
+	return e.AvailableBuffer()
+}
+
+// maxIntLen is the length of the longest decimal integer of 64 bits.
+const maxIntLen = 20
+
+// appendString appends src to dst as a JSON string escaped according to
+// opts.
+func appendString[Bytes []byte | string](dst []byte, src Bytes, opts encOpts) []byte {
+	x := opts.extras()
+	if x.escaper != nil {
+		return appendEscaperString(dst, string(src), x.escaper)
+	}
+	neo := x.escaping == NeoCompat
+	digits := opts.hexDigits()
+	tab := opts.escapeTable()
+	dst = append(dst, '"')
 	start := 0
-	for i := 0; i < len(s); {
-		if b := s[i]; b < utf8.RuneSelf {
-			if htmlSafeSet[b] || (!escapeHTML && safeSet[b]) {
-				i++
-				continue
-			}
-			if start < i {
-				e.WriteString(s[start:i])
-			}
-			switch b {
-			case '\\':
-				e.WriteByte('\\')
-				e.WriteByte(b)
-			case 0x08:
-				e.WriteByte('\\')
-				e.WriteByte('b')
-			case '\n':
-				e.WriteByte('\\')
-				e.WriteByte('n')
-			case 0x0c:
-				e.WriteByte('\\')
-				e.WriteByte('f')
-			case '\r':
-				e.WriteByte('\\')
-				e.WriteByte('r')
-			case '\t':
-				e.WriteByte('\\')
-				e.WriteByte('t')
-			default:
-				// This encodes bytes < 0x20 and " except for \t, \n and \r.
+	for i := 0; i < len(src); {
+		for i+8 <= len(src) && plainWord(loadWord(src, i)) {
+			i += 8
+		}
+		if i == len(src) {
+			break
+		}
+		b := src[i]
+		c := tab[b]
+		if c == 0 {
+			i++
+			continue
+		}
+		if c != escNonASCII {
+			dst = append(dst, src[start:i]...)
+			if c == escU {
+				// This encodes bytes < 0x20 except for \b, \f, \t, \n and \r.
 				// If escapeHTML is set, it also escapes <, >, and &
 				// because they can lead to security holes when
 				// user-controlled strings are rendered into JSON
-				// and served to some browsers.
-				e.WriteString(`\u00`)
-				e.WriteByte(hex[b>>4])
-				e.WriteByte(hex[b&0xF])
+				// and served to some browsers. NeoCompat also escapes
+				// " ' + and ` like C# does.
+				dst = append(dst, '\\', 'u', '0', '0', digits[b>>4], digits[b&0xF])
+			} else {
+				dst = append(dst, '\\', c)
 			}
 			i++
 			start = i
 			continue
 		}
-		c, size := utf8.DecodeRuneInString(s[i:])
-		if c == utf8.RuneError && size == 1 {
-			if start < i {
-				e.WriteString(s[start:i])
+		// TODO(https://go.dev/issue/56948): Use generic utf8 functionality.
+		// For now, cast only a small portion of byte slices to a string
+		// so that it can be stack allocated. This slows down []byte slightly
+		// due to the extra copy, but keeps string performance roughly the same.
+		n := min(len(src)-i, utf8.UTFMax)
+		r, size := utf8.DecodeRuneInString(string(src[i : i+n]))
+		if r == utf8.RuneError && size == 1 {
+			dst = append(dst, src[start:i]...)
+			if opts.extras().canonical {
+				dst = append(dst, "\ufffd"...)
+			} else if neo {
+				dst = append(dst, '\\', 'u', '0', '0', digits[src[i]>>4], digits[src[i]&0xF])
+			} else {
+				dst = appendU4(dst, utf8.RuneError, digits)
 			}
-			e.WriteString(`\u00`)
-			e.WriteByte(hex[s[i]>>4])
-			e.WriteByte(hex[s[i]&0xF])
 			i += size
 			start = i
 			continue
@@ -969,149 +2313,248 @@ func (e *encodeState) string(s string, escapeHTML bool) int {
 		// and can lead to security holes there. It is valid JSON to
 		// escape them, so we do so unconditionally.
 		// See http://timelessrepo.com/json-isnt-a-javascript-subset for discussion.
-		if c == '\u2028' || c == '\u2029' {
-			if start < i {
-				e.WriteString(s[start:i])
+		if (r == '\u2028' || r == '\u2029') && !opts.extras().canonical || opts.escapeRune(r) {
+			dst = append(dst, src[start:i]...)
+			if r < 0x10000 {
+				dst = appendU4(dst, r, digits)
+			} else {
+				r1, r2 := utf16.EncodeRune(r)
+				dst = appendU4(appendU4(dst, r1, digits), r2, digits)
 			}
-			e.WriteString(`\u202`)
-			e.WriteByte(hex[c&0xF])
-			i += size
-			start = i
-			continue
-		}
-		if start < i {
-			e.WriteString(s[start:i])
-		}
-		if c < 0x10000 {
-			fmt.Fprintf(e, `\u%04X`, c)
-		} else {
-			r1, r2 := utf16.EncodeRune(c)
-			fmt.Fprintf(e, `\u%04X\u%04X`, r1, r2)
+			start = i + size
 		}
 		i += size
-		start = i
 	}
-	if start < len(s) {
-		e.WriteString(s[start:])
-	}
-	e.WriteByte('"')
-	return e.Len() - len0
+	dst = append(dst, src[start:]...)
+	dst = append(dst, '"')
+	return dst
 }
 
-// NOTE: keep in sync with string above.
-func (e *encodeState) stringBytes(s []byte, escapeHTML bool) int {
-	len0 := e.Len()
-	e.WriteByte('"')
+// appendEscaperString is appendString for a custom Escaper. The
+// characters that can't be written as is in JSON strings are escaped
+// like GoStd does unless the escaper handles them.
+func appendEscaperString(dst []byte, s string, esc Escaper) []byte {
+	dst = append(dst, '"')
 	start := 0
 	for i := 0; i < len(s); {
-		if b := s[i]; b < utf8.RuneSelf {
-			if htmlSafeSet[b] || (!escapeHTML && safeSet[b]) {
-				i++
-				continue
-			}
-			if start < i {
-				e.Write(s[start:i])
-			}
-			switch b {
-			case '\\':
-				e.WriteByte('\\')
-				e.WriteByte(b)
-			case 0x08:
-				e.WriteByte('\\')
-				e.WriteByte('b')
-			case '\n':
-				e.WriteByte('\\')
-				e.WriteByte('n')
-			case 0x0c:
-				e.WriteByte('\\')
-				e.WriteByte('f')
-			case '\r':
-				e.WriteByte('\\')
-				e.WriteByte('r')
-			case '\t':
-				e.WriteByte('\\')
-				e.WriteByte('t')
-			default:
-				// This encodes bytes < 0x20 and " except for \t, \n and \r.
-				// If escapeHTML is set, it also escapes <, >, and &
-				// because they can lead to security holes when
-				// user-controlled strings are rendered into JSON
-				// and served to some browsers.
-				e.WriteString(`\u00`)
-				e.WriteByte(hex[b>>4])
-				e.WriteByte(hex[b&0xF])
-			}
-			i++
-			start = i
-			continue
+		c, size := rune(s[i]), 1
+		if c >= utf8.RuneSelf {
+			c, size = utf8.DecodeRuneInString(s[i:])
 		}
-		c, size := utf8.DecodeRune(s[i:])
-		if c == utf8.RuneError && size == 1 {
-			if start < i {
-				e.Write(s[start:i])
-			}
-			e.WriteString(`\u00`)
-			e.WriteByte(hex[s[i]>>4])
-			e.WriteByte(hex[s[i]&0xF])
+		needs := esc.NeedsEscape(c)
+		if !needs && (c < utf8.RuneSelf && stdSafeSet[c] || c >= utf8.RuneSelf && size > 1) {
 			i += size
-			start = i
 			continue
 		}
-		// U+2028 is LINE SEPARATOR.
-		// U+2029 is PARAGRAPH SEPARATOR.
-		// They are both technically valid characters in JSON strings,
-		// but don't work in JSONP, which has to be evaluated as JavaScript,
-		// and can lead to security holes there. It is valid JSON to
-		// escape them, so we do so unconditionally.
-		// See http://timelessrepo.com/json-isnt-a-javascript-subset for discussion.
-		if c == '\u2028' || c == '\u2029' {
-			if start < i {
-				e.Write(s[start:i])
+		dst = append(dst, s[start:i]...)
+		switch {
+		case needs:
+			dst = esc.AppendEscaped(dst, s[i:i+size])
+		case c == '"' || c == '\\':
+			dst = append(dst, '\\', byte(c))
+		default:
+			// Control characters and invalid UTF-8.
+			if c == utf8.RuneError {
+				c = unicode.ReplacementChar
 			}
-			e.WriteString(`\u202`)
-			e.WriteByte(hex[c&0xF])
-			i += size
-			start = i
-			continue
-		}
-		if start < i {
-			e.Write(s[start:i])
-		}
-		if c < 0x10000 {
-			fmt.Fprintf(e, `\u%04X`, c)
-		} else {
-			r1, r2 := utf16.EncodeRune(c)
-			fmt.Fprintf(e, `\u%04X\u%04X`, r1, r2)
+			dst = appendU4(dst, c, lowerHex)
 		}
 		i += size
 		start = i
 	}
-	if start < len(s) {
-		e.Write(s[start:])
+	dst = append(dst, s[start:]...)
+	return append(dst, '"')
+}
+
+// loadWord returns the 8 bytes of src starting at i as a little-endian
+// word.
+func loadWord[Bytes []byte | string](src Bytes, i int) uint64 {
+	_ = src[i+7]
+	return uint64(src[i]) | uint64(src[i+1])<<8 | uint64(src[i+2])<<16 | uint64(src[i+3])<<24 |
+		uint64(src[i+4])<<32 | uint64(src[i+5])<<40 | uint64(src[i+6])<<48 | uint64(src[i+7])<<56
+}
+
+const (
+	lsbs = 0x0101010101010101 // the lowest bit of every byte
+	msbs = 0x8080808080808080 // the highest bit of every byte
+)
+
+// hasZeroByte reports whether any byte of x is zero.
+func hasZeroByte(x uint64) bool {
+	return (x-lsbs)&^x&msbs != 0
+}
+
+// hasByte reports whether any byte of x is c.
+func hasByte(x uint64, c byte) bool {
+	return hasZeroByte(x ^ lsbs*uint64(c))
+}
+
+// plainWord reports whether the 8 bytes of x are written as is by any
+// escaping profile, i.e. whether they're printable ASCII characters other
+// than ", \, /, <, >, &, ', + and `. It allows appendString to copy runs
+// of such characters a word at a time, skipping per-byte checks.
+func plainWord(x uint64) bool {
+	// Bytes below 0x20 or above 0x7E have their highest bit set either in
+	// x-0x20 or in x+1 (or in x itself).
+	if (x|(x-lsbs*0x20)|(x+lsbs))&msbs != 0 {
+		return false
 	}
-	e.WriteByte('"')
-	return e.Len() - len0
+	return !hasByte(x, '"') && !hasByte(x, '\\') && !hasByte(x, '/') &&
+		!hasByte(x, '<') && !hasByte(x, '>') && !hasByte(x, '&') &&
+		!hasByte(x, '\'') && !hasByte(x, '+') && !hasByte(x, '`')
+}
+
+// appendU4 appends the \uXXXX escape of the UTF-16 code unit r to dst.
+func appendU4(dst []byte, r rune, digits string) []byte {
+	return append(dst, '\\', 'u', digits[r>>12&0xF], digits[r>>8&0xF], digits[r>>4&0xF], digits[r&0xF])
+}
+
+// bytesFormat is the encoding of a []byte value.
+type bytesFormat uint8
+
+const (
+	bytesBase64    bytesFormat = iota // standard padded base64, the default
+	bytesHex                          // lowercase hex digits
+	bytesArray                        // array of numbers
+	bytesBase64URL                    // unpadded URL-safe base64
+)
+
+// bytesFormatOf returns the []byte encoding given with the "format" option,
+// it's only used for fields of byte slice types.
+func bytesFormatOf(opts tagOptions, t reflect.Type) bytesFormat {
+	if t.Kind() != reflect.Slice || t.Elem().Kind() != reflect.Uint8 {
+		return bytesBase64
+	}
+	switch {
+	case opts.Contains("format:hex"):
+		return bytesHex
+	case opts.Contains("format:array"):
+		return bytesArray
+	case opts.Contains("format:base64url"):
+		return bytesBase64URL
+	}
+	return bytesBase64
+}
+
+// orderValue returns the value of the "order" option and whether it's
+// present and valid.
+func orderValue(opts tagOptions) (int, bool) {
+	v, ok := opts.Value("order")
+	if !ok {
+		return 0, false
+	}
+	v, _, _ = strings.Cut(v, ",")
+	n, err := strconv.Atoi(v)
+	return n, err == nil
+}
+
+// defaultValue returns the JSON text of the "default" option value for a
+// field of type t or nil if there is no such option. Strings are given
+// as is and quoted here, values of other types are JSON already.
+func defaultValue(opts tagOptions, t reflect.Type) []byte {
+	v, ok := opts.Value("default")
+	if !ok {
+		return nil
+	}
+	if t.Kind() == reflect.String {
+		b, _ := Marshal(v)
+		return b
+	}
+	return []byte(v)
 }
 
 // A field represents a single field found in a struct.
 type field struct {
-	name      string
-	nameBytes []byte                 // []byte(name)
-	equalFold func(s, t []byte) bool // bytes.EqualFold or equivalent
-
-	tag       bool
-	index     []int
-	typ       reflect.Type
-	omitEmpty bool
-	quoted    bool
+	name       string
+	nameBytes  []byte                 // []byte(name)
+	equalFold  func(s, t []byte) bool // bytes.EqualFold or equivalent
+	quotedName string                 // name as a JSON string if no escaping changes it, empty otherwise
+
+	tag         bool
+	index       []int
+	typ         reflect.Type
+	omitEmpty   bool
+	omitZero    bool
+	isZero      func(reflect.Value) bool // IsZero method caller if the type has one
+	quoted      bool
+	quotedElems bool   // the "string" option applies to elements of a slice, array or map
+	inline      bool   // collects unknown members, name is empty then
+	defValue    []byte // JSON value to decode when the member is missing
+	required    bool
+	format      bytesFormat // "format" option of []byte fields
+	timeFormat  string      // "format" option of time.Time and time.Duration fields
+	order       int         // "order" option value
+	ordered     bool        // whether there is an "order" option
+	redact      bool        // the value is replaced by the Redactor
+	conv        []fieldName // names in NamingConventions, nil for names given in tags
+}
+
+// nameIn returns the member name of f in the naming convention c.
+func (f *field) nameIn(c NamingConvention) string {
+	if c == KeepNames || f.conv == nil {
+		return f.name
+	}
+	return f.conv[c-1].name
+}
+
+// plainName reports whether name is written as is by every escaping
+// profile.
+func plainName(name string) bool {
+	for i := 0; i < len(name); i++ {
+		b := name[i]
+		if b >= utf8.RuneSelf || b == '/' || !htmlSafeSet[b] || !stdHTMLSafeSet[b] {
+			return false
+		}
+	}
+	return true
 }
 
 func fillField(f field) field {
 	f.nameBytes = []byte(f.name)
 	f.equalFold = foldFunc(f.nameBytes)
+	f.quotedName = ""
+	if plainName(f.name) {
+		f.quotedName = `"` + f.name + `"`
+	}
+	if !f.tag && !f.inline {
+		f.conv = conventionalNames(f.name)
+	}
 	return f
 }
 
+// quotable reports whether the "string" option applies to fields of type t
+// (after following a pointer).
+func quotable(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64,
+		reflect.String:
+		return true
+	}
+	return false
+}
+
+// quotableElems reports whether the "string" option applies to elements of
+// a slice, array or map of type t.
+func quotableElems(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return false // Base64 string.
+		}
+	case reflect.Array, reflect.Map:
+	default:
+		return false
+	}
+	et := t.Elem()
+	if et.Name() == "" && et.Kind() == reflect.Pointer {
+		et = et.Elem()
+	}
+	return quotable(et)
+}
+
 func cmpFieldsByIndex(a, b field) int {
 	for k, ak := range a.index {
 		if k >= len(b.index) {
@@ -1158,7 +2601,7 @@ func typeFields(t reflect.Type) []field {
 				if sf.PkgPath != "" && (!sf.Anonymous || sf.Type.Kind() != reflect.Struct) { // unexported
 					continue
 				}
-				tag := sf.Tag.Get("json")
+				tag := fieldTag(f.typ, sf)
 				if tag == "-" {
 					continue
 				}
@@ -1177,17 +2620,18 @@ func typeFields(t reflect.Type) []field {
 				}
 
 				// Only strings, floats, integers, and booleans can be quoted.
-				quoted := false
-				if opts.Contains("string") {
-					switch ft.Kind() {
-					case reflect.Bool,
-						reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
-						reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
-						reflect.Float32, reflect.Float64,
-						reflect.String:
-						quoted = true
-					default:
-					}
+				quoted := opts.Contains("string") && quotable(ft)
+				quotedElems := opts.Contains("string") && quotableElems(ft)
+
+				inlineMap := ft.Kind() == reflect.Map && ft.Key().Kind() == reflect.String
+				if (opts.Contains("inline") || opts.Contains("remain")) && ft == orderedObjectType ||
+					opts.Contains("inline") && inlineMap {
+					fields = append(fields, fillField(field{
+						index:  index,
+						typ:    ft,
+						inline: true,
+					}))
+					continue
 				}
 
 				// Record found field and index sequence.
@@ -1196,13 +2640,29 @@ func typeFields(t reflect.Type) []field {
 					if name == "" {
 						name = sf.Name
 					}
+					omitZero := opts.Contains("omitzero")
+					var isZero func(reflect.Value) bool
+					if omitZero {
+						isZero = isZeroFunc(sf.Type)
+					}
+					order, ordered := orderValue(opts)
 					fields = append(fields, fillField(field{
-						name:      name,
-						tag:       tagged,
-						index:     index,
-						typ:       ft,
-						omitEmpty: opts.Contains("omitempty"),
-						quoted:    quoted,
+						name:        name,
+						tag:         tagged,
+						index:       index,
+						typ:         ft,
+						omitEmpty:   opts.Contains("omitempty"),
+						omitZero:    omitZero,
+						isZero:      isZero,
+						quoted:      quoted,
+						quotedElems: quotedElems,
+						defValue:    defaultValue(opts, ft),
+						required:    opts.Contains("required"),
+						format:      bytesFormatOf(opts, sf.Type),
+						timeFormat:  timeFormatOf(opts, sf.Type),
+						order:       order,
+						ordered:     ordered,
+						redact:      opts.Contains("redact"),
 					}))
 					if count[f.typ] > 1 {
 						// If there were multiple instances, add a second,
@@ -1270,6 +2730,17 @@ func typeFields(t reflect.Type) []field {
 
 	fields = out
 	slices.SortFunc(fields, cmpFieldsByIndex)
+	slices.SortStableFunc(fields, func(a, b field) int {
+		switch {
+		case a.ordered && b.ordered:
+			return cmp.Compare(a.order, b.order)
+		case a.ordered:
+			return -1
+		case b.ordered:
+			return 1
+		}
+		return 0
+	})
 
 	return fields
 }
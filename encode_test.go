@@ -6,14 +6,20 @@ package json
 
 import (
 	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
 	"fmt"
 	"log"
 	"math"
 	"reflect"
 	"regexp"
 	"strconv"
+	"strings"
 	"testing"
+	"time"
 	"unicode"
+	"unicode/utf8"
 )
 
 type Optionals struct {
@@ -106,6 +112,38 @@ func TestStringTag(t *testing.T) {
 	}
 }
 
+func TestStringTagElems(t *testing.T) {
+	type elems struct {
+		Amounts []uint64           `json:",string"`
+		Pair    [2]int8            `json:",string"`
+		Rates   map[string]float64 `json:",string"`
+		Flags   []*bool            `json:",string"`
+		Bytes   []byte             `json:",string"`
+		Nested  [][]int            `json:",string"`
+	}
+	yes := true
+	v := elems{[]uint64{1, 18446744073709551615}, [2]int8{-1, 2}, map[string]float64{"a": 0.5}, []*bool{&yes, nil}, []byte{1}, [][]int{{1}}}
+	const want = `{"Amounts":["1","18446744073709551615"],"Pair":["-1","2"],"Rates":{"a":"0.5"},"Flags":["true",null],"Bytes":"AQ==","Nested":[[1]]}`
+	b, err := Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != want {
+		t.Fatalf("got %s, want %s", b, want)
+	}
+	var got elems
+	if err := Unmarshal(b, &got); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, v) {
+		t.Errorf("round trip: got %#v, want %#v", got, v)
+	}
+
+	if err := Unmarshal([]byte(`{"Amounts":["1",2]}`), &got); err == nil || !strings.Contains(err.Error(), "invalid use of ,string") {
+		t.Errorf("unquoted element: got %v", err)
+	}
+}
+
 // byte slices are special even if they're renamed types.
 type renamedByte byte
 type renamedByteSlice []byte
@@ -131,10 +169,69 @@ func TestEncodeRenamedByteSlice(t *testing.T) {
 	}
 }
 
+type PointerCycle struct {
+	Ptr *PointerCycle
+}
+
+var pointerCycle = &PointerCycle{}
+
+type PointerCycleIndirect struct {
+	Ptrs []any
+}
+
+var (
+	pointerCycleIndirect = &PointerCycleIndirect{}
+	mapCycle             = make(map[string]any)
+	sliceCycle           = []any{nil}
+	sliceNoCycle         = []any{nil, nil}
+	orderedCycle         = OrderedObject{{"a", nil}}
+)
+
+func init() {
+	pointerCycle.Ptr = pointerCycle
+	pointerCycleIndirect.Ptrs = []any{pointerCycleIndirect}
+	mapCycle["x"] = mapCycle
+	sliceCycle[0] = sliceCycle
+	sliceNoCycle[1] = sliceNoCycle[:1]
+	for i := startDetectingCyclesAfter; i > 0; i-- {
+		sliceNoCycle = []any{sliceNoCycle}
+	}
+	orderedCycle[0].Value = orderedCycle
+}
+
+func TestSamePointerNoCycle(t *testing.T) {
+	if _, err := Marshal(samePointerNoCycle); err != nil {
+		t.Fatalf("Marshal error: %v", err)
+	}
+}
+
+func TestSliceNoCycle(t *testing.T) {
+	if _, err := Marshal(sliceNoCycle); err != nil {
+		t.Fatalf("Marshal error: %v", err)
+	}
+}
+
+var samePointerNoCycle = &SamePointerNoCycle{}
+
+type SamePointerNoCycle struct {
+	Ptr1, Ptr2 *SamePointerNoCycle
+}
+
+func init() {
+	ptr := &SamePointerNoCycle{}
+	samePointerNoCycle.Ptr1 = ptr
+	samePointerNoCycle.Ptr2 = ptr
+}
+
 var unsupportedValues = []any{
 	math.NaN(),
 	math.Inf(-1),
 	math.Inf(1),
+	pointerCycle,
+	pointerCycleIndirect,
+	mapCycle,
+	sliceCycle,
+	orderedCycle,
 }
 
 func TestUnsupportedValues(t *testing.T) {
@@ -491,10 +588,10 @@ func TestStringBytes(t *testing.T) {
 
 	for _, escapeHTML := range []bool{true, false} {
 		es := &encodeState{}
-		es.string(s, escapeHTML)
+		es.string(s, encOpts{escapeHTML: escapeHTML})
 
 		esBytes := &encodeState{}
-		esBytes.stringBytes([]byte(s), escapeHTML)
+		esBytes.stringBytes([]byte(s), encOpts{escapeHTML: escapeHTML})
 
 		enc := es.String()
 		encBytes := esBytes.String()
@@ -625,6 +722,21 @@ func tenc(format string, a ...any) ([]byte, error) {
 	return buf.Bytes(), nil
 }
 
+func TestMarshalIndentMarshaler(t *testing.T) {
+	v := struct {
+		A jsonint
+		B []jsonint
+	}{1, []jsonint{2}}
+	b, err := MarshalIndent(v, ">", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "{\n>  \"A\": {\n>    \"JI\": 1\n>  },\n>  \"B\": [\n>    {\n>      \"JI\": 2\n>    }\n>  ]\n>}"
+	if string(b) != want {
+		t.Errorf("MarshalIndent:\ngot  %s\nwant %s", b, want)
+	}
+}
+
 // Issue 13783.
 func TestEncodeBytekind(t *testing.T) {
 	testdata := []struct {
@@ -910,3 +1022,750 @@ func TestEncodeOrderedObject(t *testing.T) {
 		}
 	}
 }
+
+func TestEncodeOrderedObjectAllocs(t *testing.T) {
+	// Nested objects are written straight into the output without
+	// intermediate buffers.
+	if raceEnabled {
+		t.Skip("allocation counts are unreliable with the race detector")
+	}
+	var v any = OrderedObject{{"x", 1.5}}
+	for range 10 {
+		v = OrderedObject{{"s", "str"}, {"a", []any{true, nil}}, {"o", v}}
+	}
+	buf, err := AppendMarshal(nil, v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	allocs := testing.AllocsPerRun(100, func() {
+		buf, _ = AppendMarshal(buf[:0], v)
+	})
+	if allocs != 0 {
+		t.Errorf("got %v allocations per AppendMarshal", allocs)
+	}
+}
+
+func TestEscapeProfiles(t *testing.T) {
+	const in = "a\"\\/<&'+`\x7f\t\x01é€\U0001F600\u2028\xff"
+	for _, tc := range []struct {
+		profile EscapeProfile
+		html    bool
+		want    string
+	}{
+		{NeoCompat, true, `"a\u0022\\/\u003C\u0026\u0027\u002B\u0060\u007F\t\u0001\u00E9\u20AC\uD83D\uDE00\u2028\u00FF"`},
+		{GoStd, true, "\"a\\\"\\\\/\\u003c\\u0026'+`\x7f\\t\\u0001é€\U0001F600\\u2028\\ufffd\""},
+		{GoStd, false, "\"a\\\"\\\\/<&'+`\x7f\\t\\u0001é€\U0001F600\\u2028\\ufffd\""},
+		{ASCIIOnly, true, `"a\"\\/\u003c\u0026'+` + "`\x7f" + `\t\u0001\u00e9\u20ac\ud83d\ude00\u2028\ufffd"`},
+	} {
+		var buf bytes.Buffer
+		enc := NewEncoder(&buf)
+		enc.SetEscaping(tc.profile)
+		enc.SetEscapeHTML(tc.html)
+		if err := enc.Encode(in); err != nil {
+			t.Fatal(err)
+		}
+		if got := strings.TrimSuffix(buf.String(), "\n"); got != tc.want {
+			t.Errorf("profile %d, html %v:\n got %s\nwant %s", tc.profile, tc.html, got, tc.want)
+		}
+		var s string
+		if err := Unmarshal(buf.Bytes(), &s); err != nil || s != strings.ToValidUTF8(in, "\uFFFD") && tc.profile != NeoCompat {
+			t.Errorf("profile %d: got %q, %v after decoding", tc.profile, s, err)
+		}
+	}
+	b, err := MarshalEscaping(map[string]string{"ключ": "<значение>"}, GoStd)
+	if want := `{"ключ":"\u003cзначение\u003e"}`; err != nil || string(b) != want {
+		t.Errorf("MarshalEscaping: got %s, %v, want %s", b, err, want)
+	}
+	want := must(Marshal("é<"))
+	for _, p := range []EscapeProfile{-1, ASCIIOnly + 1} {
+		if b, err := MarshalEscaping("é<", p); err != nil || !bytes.Equal(b, want) {
+			t.Errorf("MarshalEscaping with profile %d: got %s, %v, want %s", p, b, err, want)
+		}
+		var buf bytes.Buffer
+		enc := NewEncoder(&buf)
+		enc.SetEscaping(p)
+		enc.SetTrailingNewline(false)
+		if err := enc.Encode("é<"); err != nil || !bytes.Equal(buf.Bytes(), want) {
+			t.Errorf("SetEscaping(%d): got %s, %v, want %s", p, buf.Bytes(), err, want)
+		}
+	}
+}
+
+func TestAppendInt(t *testing.T) {
+	var ns []int64
+	for p := int64(1); p > 0 && p <= math.MaxInt64/10; p *= 10 {
+		ns = append(ns, p-1, p, p+1, -p, 5*p+3)
+	}
+	ns = append(ns, 0, math.MaxInt64, math.MinInt64, math.MinInt64+1)
+	for _, n := range ns {
+		if got, want := appendInt([]byte("x"), n), strconv.AppendInt([]byte("x"), n, 10); !bytes.Equal(got, want) {
+			t.Errorf("%d: got %s, want %s", n, got, want)
+		}
+		u := uint64(n) * 3
+		if got, want := appendUint(nil, u), strconv.AppendUint(nil, u, 10); !bytes.Equal(got, want) {
+			t.Errorf("%d: got %s, want %s", u, got, want)
+		}
+	}
+	if got := string(appendUint(nil, math.MaxUint64)); got != "18446744073709551615" {
+		t.Errorf("MaxUint64: got %s", got)
+	}
+}
+
+func TestEscapeTables(t *testing.T) {
+	for _, e := range []EscapeProfile{NeoCompat, GoStd, ASCIIOnly} {
+		for _, html := range []bool{false, true} {
+			for _, slash := range []bool{false, true} {
+				o := encOpts{escapeHTML: html, extra: &extraOpts{escaping: e, escapeSlash: slash}}
+				for b := range byte(utf8.RuneSelf) {
+					out := appendString(nil, []byte{b}, o)
+					var s string
+					if err := Unmarshal(out, &s); err != nil || s != string(rune(b)) {
+						t.Errorf("%d %v %v: %q encoded as %s decodes to %q, %v", e, html, slash, b, out, s, err)
+					}
+					if plain := len(out) == 3; plain != o.safe(b) {
+						t.Errorf("%d %v %v: %q encoded as %s", e, html, slash, b, out)
+					}
+				}
+			}
+		}
+	}
+	bmp := printableBMP()
+	for c := range rune(0x10000) {
+		if got := bmp[c>>6]&(1<<(c&63)) != 0; got != unicode.IsPrint(c) {
+			t.Errorf("%U: printable %v", c, got)
+		}
+	}
+}
+
+func TestPlainWord(t *testing.T) {
+	var profiles []encOpts
+	for _, e := range []EscapeProfile{NeoCompat, GoStd, ASCIIOnly} {
+		for _, html := range []bool{false, true} {
+			profiles = append(profiles, encOpts{escapeHTML: html, extra: &extraOpts{escaping: e}}, encOpts{escapeHTML: html, extra: &extraOpts{escaping: e, escapeSlash: true}})
+		}
+	}
+	for c := range 256 {
+		b := byte(c)
+		want := b >= 0x20 && b < utf8.RuneSelf
+		for _, o := range profiles {
+			want = want && o.safe(b)
+		}
+		for i := range 8 {
+			word := []byte("abcdefgh")
+			word[i] = b
+			if got := plainWord(loadWord(word, 0)); got != want {
+				t.Errorf("%q at %d: got %v, want %v", b, i, got, want)
+			}
+		}
+	}
+}
+
+func TestEncoderUppercaseHex(t *testing.T) {
+	for _, tc := range []struct {
+		profile EscapeProfile
+		upper   bool
+		want    string
+	}{
+		{NeoCompat, false, `"\u00e9\u003c\ud83d\ude00\u00ff"`},
+		{GoStd, true, `"é\u003C😀\uFFFD"`},
+		{ASCIIOnly, true, `"\u00E9\u003C\uD83D\uDE00\uFFFD"`},
+	} {
+		var buf bytes.Buffer
+		enc := NewEncoder(&buf)
+		enc.SetEscaping(tc.profile)
+		enc.SetUppercaseHex(tc.upper)
+		if err := enc.Encode("é<\U0001F600\xff"); err != nil {
+			t.Fatal(err)
+		}
+		if got := strings.TrimSuffix(buf.String(), "\n"); got != tc.want {
+			t.Errorf("profile %d, upper %v: got %s, want %s", tc.profile, tc.upper, got, tc.want)
+		}
+	}
+}
+
+func TestEncoderUnescapedUnicode(t *testing.T) {
+	const in = "é€\U0001F600\u0085\u200b\u2028\x01<\xff"
+	for _, tc := range []struct {
+		profile EscapeProfile
+		want    string
+	}{
+		{NeoCompat, `"é€😀\u0085\u200B\u2028\u0001\u003C\u00FF"`},
+		{GoStd, `"é€😀\u0085\u200b\u2028\u0001\u003c\ufffd"`},
+	} {
+		var buf bytes.Buffer
+		enc := NewEncoder(&buf)
+		enc.SetEscaping(tc.profile)
+		enc.SetUnescapedUnicode(true)
+		if err := enc.Encode(in); err != nil {
+			t.Fatal(err)
+		}
+		if got := strings.TrimSuffix(buf.String(), "\n"); got != tc.want {
+			t.Errorf("profile %d: got %s, want %s", tc.profile, got, tc.want)
+		}
+	}
+}
+
+func TestEncoderEscapeSlash(t *testing.T) {
+	var buf bytes.Buffer
+	enc := NewEncoder(&buf)
+	enc.SetEscapeSlash(true)
+	if err := enc.Encode(map[string]string{"a/b": "</script>"}); err != nil {
+		t.Fatal(err)
+	}
+	enc.SetEscapeSlash(false)
+	if err := enc.Encode("a/b"); err != nil {
+		t.Fatal(err)
+	}
+	const want = `{"a\/b":"\u003C\/script\u003E"}` + "\n" + `"a/b"` + "\n"
+	if got := buf.String(); got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
+
+// percentEscaper escapes non-ASCII characters and '%' as \u escapes of their
+// UTF-8 bytes.
+type percentEscaper struct{}
+
+func (percentEscaper) NeedsEscape(r rune) bool { return r >= utf8.RuneSelf || r == '%' }
+
+func (percentEscaper) AppendEscaped(dst []byte, s string) []byte {
+	for _, b := range []byte(s) {
+		dst = append(dst, fmt.Sprintf(`\u%04x`, b)...)
+	}
+	return dst
+}
+
+func TestEncoderEscaper(t *testing.T) {
+	var buf bytes.Buffer
+	enc := NewEncoder(&buf)
+	enc.SetEscaper(percentEscaper{})
+	enc.SetEscaping(NeoCompat)
+	if err := enc.Encode(map[string]string{"%": "é<\"\\\n\x01\xff"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.Encode(struct {
+		P string `json:"%"`
+	}{"%"}); err != nil {
+		t.Fatal(err)
+	}
+	const want = `{"\u0025":"\u00c3\u00a9<\"\\\u000a\u0001\u00ff"}` + "\n" + `{"\u0025":"\u0025"}` + "\n"
+	if got := buf.String(); got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
+
+func TestNeoEscape(t *testing.T) {
+	const in = `{"k\u00e9y": ["<a href=\"/x\">", 1.5e3, "\ud83d\ude00 ok"], "plain" :  true, "bad": "\x"}`
+	var buf bytes.Buffer
+	NeoEscape(&buf, []byte(in))
+	const want = `{"k\u00E9y": ["\u003Ca href=\u0022/x\u0022\u003E", 1.5e3, "\uD83D\uDE00 ok"], "plain" :  true, "bad": "\x"}`
+	if got := buf.String(); got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+	b, _ := Marshal(map[string]string{"k": "<a href=\"/x\">"})
+	buf.Reset()
+	NeoEscape(&buf, b)
+	if buf.String() != string(b) {
+		t.Errorf("Marshal output changed: %s to %s", b, buf.String())
+	}
+}
+
+func TestEncodeLargeByteSlice(t *testing.T) {
+	b := make([]byte, 1<<20+1)
+	for i := range b {
+		b[i] = byte(i * 7)
+	}
+	got, err := Marshal(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `"` + base64.StdEncoding.EncodeToString(b) + `"`; string(got) != want {
+		t.Fatalf("got %d bytes, want %d", len(got), len(want))
+	}
+
+	// When streaming, the encoding is passed on in chunks.
+	h := &countingHash{Hash: sha256.New()}
+	sum, err := HashValue(h, b, CanonicalOrdered)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := sha256.Sum256(got); !bytes.Equal(sum, want[:]) {
+		t.Errorf("hash mismatch")
+	}
+	if h.writes < len(got)/(2*flushSize) {
+		t.Errorf("%d bytes written in %d chunks", len(got), h.writes)
+	}
+}
+
+func TestPoolSettings(t *testing.T) {
+	defer SetPooling(true)
+	defer SetPoolMaxBufferSize(0)
+	drain := func() {
+		for encodeStatePool.Get() != nil {
+		}
+	}
+
+	drain()
+	SetPoolMaxBufferSize(1024)
+	if _, err := AppendMarshal(nil, strings.Repeat("x", 4096)); err != nil {
+		t.Fatal(err)
+	}
+	if v := encodeStatePool.Get(); v != nil && v.(*encodeState).Cap() > 1024 {
+		t.Errorf("buffer of %d bytes is pooled", v.(*encodeState).Cap())
+	}
+
+	drain()
+	SetPooling(false)
+	if _, err := AppendMarshal(nil, "x"); err != nil {
+		t.Fatal(err)
+	}
+	if encodeStatePool.Get() != nil {
+		t.Errorf("buffer is pooled with pooling disabled")
+	}
+}
+
+func TestAppendMarshal(t *testing.T) {
+	v := &struct {
+		A int
+		B string
+	}{1, "x"}
+	buf := []byte("prefix ")
+	buf, err := AppendMarshal(buf, v)
+	if err != nil || string(buf) != `prefix {"A":1,"B":"x"}` {
+		t.Fatalf("got %s, %v", buf, err)
+	}
+	if b, err := AppendMarshal(buf, make(chan int)); err == nil || len(b) != len(buf) {
+		t.Errorf("unsupported type: got %s, %v", b, err)
+	}
+	allocs := testing.AllocsPerRun(100, func() {
+		buf, _ = AppendMarshal(buf[:0], v)
+	})
+	if allocs != 0 {
+		t.Errorf("got %v allocations per AppendMarshal", allocs)
+	}
+
+	out, err := AppendIndent([]byte(">"), buf, "", " ")
+	if want := ">{\n \"A\": 1,\n \"B\": \"x\"\n}"; err != nil || string(out) != want {
+		t.Errorf("AppendIndent: got %q, %v", out, err)
+	}
+	if out, err := AppendIndent([]byte(">"), []byte(`[1`), "", " "); err == nil || string(out) != ">" {
+		t.Errorf("AppendIndent: got %q, %v for invalid input", out, err)
+	}
+}
+
+func TestCompareUTF16(t *testing.T) {
+	for _, tc := range []struct {
+		a, b string
+		want int
+	}{
+		{"a", "b", -1},
+		{"ab", "a", 1},
+		{"", "", 0},
+		{"\U0001F600", "�", -1},
+		{"\U0001F600", "퟿", 1},
+		{"\U0001F600", "\U0001F601", -1},
+		{"x\xfe", "x\xff", -1},
+	} {
+		if got := CompareUTF16(tc.a, tc.b); got != tc.want {
+			t.Errorf("CompareUTF16(%q, %q) = %d, want %d", tc.a, tc.b, got, tc.want)
+		}
+	}
+}
+
+func TestEncoderMapKeyOrder(t *testing.T) {
+	m := map[string]int{"b": 1, "！": 2, "\U00010000": 3, "a": 4}
+	for _, tc := range []struct {
+		cmp  func(a, b string) int
+		want string
+	}{
+		{nil, `{"a":4,"b":1,"！":2,"𐀀":3}`},
+		{CompareUTF16, `{"a":4,"b":1,"𐀀":3,"！":2}`},
+		{func(a, b string) int { return strings.Compare(b, a) }, `{"𐀀":3,"！":2,"b":1,"a":4}`},
+	} {
+		var buf bytes.Buffer
+		enc := NewEncoder(&buf)
+		enc.SetEscaping(GoStd)
+		enc.SetMapKeyOrder(tc.cmp)
+		if err := enc.Encode(m); err != nil {
+			t.Fatal(err)
+		}
+		if got := strings.TrimSuffix(buf.String(), "\n"); got != tc.want {
+			t.Errorf("got %s, want %s", got, tc.want)
+		}
+	}
+}
+
+type zeroer struct{ n int }
+
+func (z zeroer) IsZero() bool { return z.n < 0 }
+
+type ptrZeroer struct{ n int }
+
+func (z *ptrZeroer) IsZero() bool { return z.n == 42 }
+
+func TestOmitZero(t *testing.T) {
+	type T struct {
+		Time  time.Time       `json:",omitzero"`
+		Z     zeroer          `json:",omitzero"`
+		PZ    ptrZeroer       `json:",omitzero"`
+		PP    *zeroer         `json:",omitzero"`
+		I     isZeroer        `json:",omitzero"`
+		S     struct{ A int } `json:",omitzero"`
+		Both  []int           `json:",omitempty,omitzero"`
+		Empty []int           `json:",omitzero"`
+	}
+	for _, tc := range []struct {
+		v    T
+		want string
+	}{
+		{T{Z: zeroer{-1}, PZ: ptrZeroer{42}, Both: []int{}}, `{}`},
+		{T{}, `{"Z":{},"PZ":{}}`},
+		{T{Time: time.Unix(0, 0).UTC(), Z: zeroer{1}, PP: &zeroer{-1}, I: zeroer{-1}, S: struct{ A int }{1}, Empty: []int{}},
+			`{"Time":"1970-01-01T00:00:00Z","Z":{},"PZ":{},"S":{"A":1},"Empty":[]}`},
+	} {
+		b, err := Marshal(tc.v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != tc.want {
+			t.Errorf("got %s, want %s", b, tc.want)
+		}
+	}
+}
+
+type OrderEmbed struct {
+	Kind string `json:"kind,order=1"`
+	Note string `json:"note"`
+}
+
+func TestFieldOrder(t *testing.T) {
+	type T struct {
+		Data    []int  `json:"data"`
+		Version int    `json:"version,order=2,omitempty"`
+		Name    string `json:"name"`
+		ID      int    `json:"id,order=-1"`
+		Bad     bool   `json:"bad,order=x"`
+		OrderEmbed
+		Hash string `json:"hash,order=2"`
+	}
+	v := T{Version: 3, Data: []int{1}, Name: "n", Hash: "h", OrderEmbed: OrderEmbed{"k", "x"}}
+	b, err := Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const want = `{"id":0,"kind":"k","version":3,"hash":"h","data":[1],"name":"n","bad":false,"note":"x"}`
+	if string(b) != want {
+		t.Errorf("got %s, want %s", b, want)
+	}
+	var w T
+	if err := Unmarshal(b, &w); err != nil || !reflect.DeepEqual(w, v) {
+		t.Errorf("Unmarshal: got %+v, %v", w, err)
+	}
+}
+
+type orderedPoint struct{ X, Y int }
+
+func (p orderedPoint) MarshalJSON() ([]byte, error) { return []byte(`"unused"`), nil }
+
+func (p orderedPoint) MarshalJSONOrdered() (OrderedObject, error) {
+	if p.X < 0 {
+		return nil, errors.New("negative")
+	}
+	return OrderedObject{{"y", p.Y}, {"x", p.X}, {"tag", "<a>"}}, nil
+}
+
+type addrOrdered struct{ N int }
+
+func (a *addrOrdered) MarshalJSONOrdered() (OrderedObject, error) {
+	return OrderedObject{{"n", a.N}}, nil
+}
+
+func TestMarshalerOrdered(t *testing.T) {
+	v := struct {
+		P   orderedPoint
+		PP  *orderedPoint
+		A   addrOrdered
+		Arr []orderedPoint
+	}{P: orderedPoint{1, 2}, A: addrOrdered{3}, Arr: []orderedPoint{{4, 5}}}
+	b, err := Marshal(&v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const want = `{"P":{"y":2,"x":1,"tag":"\u003Ca\u003E"},"PP":null,"A":{"n":3},"Arr":[{"y":5,"x":4,"tag":"\u003Ca\u003E"}]}`
+	if string(b) != want {
+		t.Errorf("got %s, want %s", b, want)
+	}
+	// Not addressable, the pointer method can't be used.
+	if b, _ := Marshal(v.A); string(b) != `{"N":3}` {
+		t.Errorf("got %s", b)
+	}
+	_, err = Marshal(orderedPoint{-1, 0})
+	var me *MarshalerError
+	if !errors.As(err, &me) || me.Err.Error() != "negative" {
+		t.Errorf("got error %v", err)
+	}
+}
+
+type streamedList struct {
+	n   int
+	bad int // 1: two values, 2: incomplete value
+}
+
+func (l streamedList) MarshalJSONTo(enc *Encoder) error {
+	enc.WriteToken(Delim('{'))
+	enc.WriteToken("items<")
+	enc.WriteToken(Delim('['))
+	for i := range l.n {
+		if err := enc.Encode(map[string]int{"i": i}); err != nil {
+			return err
+		}
+	}
+	if l.bad == 2 {
+		return nil
+	}
+	enc.WriteToken(Delim(']'))
+	if err := enc.WriteToken(Delim('}')); err != nil {
+		return err
+	}
+	if l.bad == 1 {
+		return enc.Encode(1)
+	}
+	return nil
+}
+
+func TestMarshalerTo(t *testing.T) {
+	v := []any{streamedList{n: 2}, &streamedList{}, (*streamedList)(nil)}
+	b, err := Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `[{"items\u003C":[{"i":0},{"i":1}]},{"items\u003C":[]},null]`
+	if string(b) != want {
+		t.Errorf("got %s, want %s", b, want)
+	}
+	for _, bad := range []int{1, 2} {
+		_, err := Marshal(streamedList{n: 1, bad: bad})
+		var me *MarshalerError
+		if !errors.As(err, &me) {
+			t.Errorf("bad %d: got error %v", bad, err)
+		}
+	}
+	var buf bytes.Buffer
+	enc := NewEncoder(&buf)
+	enc.SetMaxDepth(2)
+	if err := enc.Encode(streamedList{n: 1}); err == nil {
+		t.Errorf("no depth error, got %s", buf.Bytes())
+	}
+}
+
+type appendedPair struct {
+	b, a int
+	err  bool
+}
+
+func (p appendedPair) AppendJSON(dst []byte) ([]byte, error) {
+	if p.err {
+		return append(dst, "garbage"...), errors.New("bad pair")
+	}
+	dst = append(dst, `{"b":`...)
+	dst = strconv.AppendInt(dst, int64(p.b), 10)
+	dst = append(dst, `,"a":`...)
+	dst = strconv.AppendInt(dst, int64(p.a), 10)
+	return append(dst, '}'), nil
+}
+
+func (p appendedPair) MarshalJSON() ([]byte, error) {
+	return []byte(`"not used"`), nil
+}
+
+func TestMarshalerAppend(t *testing.T) {
+	v := []any{appendedPair{b: 2, a: 1}, &appendedPair{}, OrderedObject{{"p", appendedPair{b: 3}}}}
+	b, err := Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `[{"b":2,"a":1},{"b":0,"a":0},{"p":{"b":3,"a":0}}]`; string(b) != want {
+		t.Errorf("got %s, want %s", b, want)
+	}
+	if b, err = MarshalCanonical(v); err != nil {
+		t.Fatal(err)
+	}
+	if want := `[{"a":1,"b":2},{"a":0,"b":0},{"p":{"a":0,"b":3}}]`; string(b) != want {
+		t.Errorf("canonical: got %s, want %s", b, want)
+	}
+	tmpl := MustKeyTemplate([]byte(`[{"a": 0, "b": 0}]`))
+	if b, err = MarshalWithOptions(v[:2], Options{KeyTemplate: tmpl}); err != nil {
+		t.Fatal(err)
+	}
+	if want := `[{"a":1,"b":2},{"a":0,"b":0}]`; string(b) != want {
+		t.Errorf("key template: got %s, want %s", b, want)
+	}
+	b, err = Marshal([]any{1, appendedPair{err: true}})
+	var me *MarshalerError
+	if !errors.As(err, &me) || me.Err.Error() != "bad pair" || b != nil {
+		t.Errorf("got %s, error %v", b, err)
+	}
+}
+
+// unsafeInts writes its value with the JS-safe integers option disabled.
+type unsafeInts int64
+
+func (n unsafeInts) MarshalJSONTo(enc *Encoder) error {
+	enc.SetJSSafeIntegers(false)
+	return enc.Encode(int64(n))
+}
+
+func TestMarshalerToOptions(t *testing.T) {
+	var buf bytes.Buffer
+	enc := NewEncoder(&buf)
+	enc.SetJSSafeIntegers(true)
+	enc.SetTrailingNewline(false)
+	const big = 1 << 60
+	if err := enc.Encode([]any{unsafeInts(big), int64(big)}); err != nil {
+		t.Fatal(err)
+	}
+	if want := `[1152921504606846976,"1152921504606846976"]`; buf.String() != want {
+		t.Errorf("got %s, want %s", buf.Bytes(), want)
+	}
+}
+
+func TestBytesFormatHex(t *testing.T) {
+	type T struct {
+		Hash  []byte `json:"hash,format:hex"`
+		Key   []byte `json:"key,omitempty,format:hex"`
+		Plain []byte `json:"plain"`
+		Str   string `json:"str,format:hex"`
+	}
+	v := T{Hash: []byte{0xde, 0xad, 0x0b}, Plain: []byte{1}, Str: "s"}
+	b, err := Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const want = `{"hash":"dead0b","plain":"AQ==","str":"s"}`
+	if string(b) != want {
+		t.Errorf("got %s, want %s", b, want)
+	}
+	var w T
+	if err := Unmarshal([]byte(`{"hash":"DEAD0b","key":"","plain":"AQ==","str":"s"}`), &w); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(w, T{Hash: v.Hash, Key: []byte{}, Plain: v.Plain, Str: "s"}) {
+		t.Errorf("got %+v", w)
+	}
+	for _, in := range []string{`{"hash":"abc"}`, `{"hash":"zz"}`, `{"plain":"00"}`} {
+		if err := Unmarshal([]byte(in), &w); err == nil {
+			t.Errorf("%s: no error", in)
+		}
+	}
+}
+
+func TestBytesFormatArray(t *testing.T) {
+	type T struct {
+		A []byte `json:"a,format:array"`
+		E []byte `json:"e,format:array"`
+		N []byte `json:"n,format:array"`
+	}
+	b, err := Marshal(T{A: []byte{1, 0, 255}, E: []byte{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	const want = `{"a":[1,0,255],"e":[],"n":null}`
+	if string(b) != want {
+		t.Errorf("got %s, want %s", b, want)
+	}
+	var w T
+	if err := Unmarshal([]byte(`{"a":[1,0,255],"e":"AQI=","n":null}`), &w); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(w, T{A: []byte{1, 0, 255}, E: []byte{1, 2}}) {
+		t.Errorf("got %+v", w)
+	}
+	if err := Unmarshal([]byte(`{"a":[256]}`), &w); err == nil {
+		t.Error("no error for an out of range element")
+	}
+}
+
+func TestBytesFormatBase64URL(t *testing.T) {
+	type T struct {
+		Sig []byte `json:"sig,format:base64url"`
+	}
+	big := bytes.Repeat([]byte{0xfb, 0xff, 0xbf}, 700)
+	for _, tc := range []struct {
+		in   []byte
+		want string
+	}{
+		{[]byte{0xfb, 0xff}, "-_8"},
+		{[]byte{}, ""},
+		{big, strings.Repeat("-_-_", 700)},
+	} {
+		b, err := Marshal(T{tc.in})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := `{"sig":"` + tc.want + `"}`; string(b) != want {
+			t.Errorf("got %.40s, want %.40s", b, want)
+		}
+		var w T
+		if err := Unmarshal(b, &w); err != nil || !bytes.Equal(w.Sig, tc.in) {
+			t.Errorf("Unmarshal %.40s: got %x, %v", b, w.Sig, err)
+		}
+	}
+	var w T
+	if err := Unmarshal([]byte(`{"sig":"-_8="}`), &w); err != nil || !bytes.Equal(w.Sig, []byte{0xfb, 0xff}) {
+		t.Errorf("padded: got %x, %v", w.Sig, err)
+	}
+	if err := Unmarshal([]byte(`{"sig":"+/8="}`), &w); err == nil {
+		t.Error("no error for the standard alphabet")
+	}
+}
+
+func TestFloatCSharp(t *testing.T) {
+	for _, tc := range []struct {
+		f    float64
+		bits int
+		want string
+	}{
+		{0, 64, "0"},
+		{math.Copysign(0, -1), 64, "-0"},
+		{-60, 64, "-60"},
+		{0.1, 64, "0.1"},
+		{math.Pi, 64, "3.141592653589793"},
+		{0.0001, 64, "0.0001"},
+		{0.00001, 64, "1E-05"},
+		{-1.5e-7, 64, "-1.5E-07"},
+		{1e14, 64, "100000000000000"},
+		{1e15, 64, "1E+15"},
+		{1234567890123456, 64, "1234567890123456"},
+		{12345678901234567890, 64, "1.2345678901234567E+19"},
+		{1e21, 64, "1E+21"},
+		{math.MaxFloat64, 64, "1.7976931348623157E+308"},
+		{5e-324, 64, "5E-324"},
+		{float64(float32(0.1)), 32, "0.1"},
+		{1e7, 32, "1E+07"},
+		{16777216, 32, "16777216"},
+		{float64(float32(3.4028235e38)), 32, "3.4028235E+38"},
+	} {
+		if got := string(appendFloatCSharp(nil, tc.f, tc.bits)); got != tc.want {
+			t.Errorf("%v/%d: got %s, want %s", tc.f, tc.bits, got, tc.want)
+		}
+	}
+
+	var buf bytes.Buffer
+	enc := NewEncoder(&buf)
+	enc.SetFloatFormat(FloatCSharp)
+	v := struct {
+		F float64
+		G float32
+		S float64 `json:",string"`
+	}{1e15, 1e-5, 2.5}
+	if err := enc.Encode(v); err != nil {
+		t.Fatal(err)
+	}
+	if want := "{\"F\":1E+15,\"G\":1E-05,\"S\":\"2.5\"}\n"; buf.String() != want {
+		t.Errorf("got %q, want %q", buf.String(), want)
+	}
+	var w struct{ F, G float64 }
+	if err := Unmarshal(buf.Bytes(), &w); err != nil || w.F != 1e15 || w.G != 1e-5 {
+		t.Errorf("Unmarshal: got %+v, %v", w, err)
+	}
+}
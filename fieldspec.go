@@ -0,0 +1,110 @@
+package json
+
+import (
+	"errors"
+	"maps"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// FieldSpec describes how a struct field is marshaled and unmarshaled, it's
+// an alternative to the json struct tag for types that can't be changed,
+// see RegisterFields.
+type FieldSpec struct {
+	Name      string // member name, the field name is used if empty, see RegisterFields
+	Skip      bool   // ignore the field like the "-" tag does
+	OmitEmpty bool   // the "omitempty" option
+	OmitZero  bool   // the "omitzero" option
+	String    bool   // the "string" option
+	Order     int    // the "order" option value if not zero
+	Options   string // other comma-separated options like "inline" or "format:hex"
+}
+
+// tag returns the struct tag equivalent to s.
+func (s FieldSpec) tag() string {
+	if s.Skip {
+		return "-"
+	}
+	var b strings.Builder
+	b.WriteString(s.Name)
+	for _, o := range []struct {
+		set  bool
+		name string
+	}{{s.OmitEmpty, "omitempty"}, {s.OmitZero, "omitzero"}, {s.String, "string"}} {
+		if o.set {
+			b.WriteString("," + o.name)
+		}
+	}
+	if s.Order != 0 {
+		b.WriteString(",order=" + strconv.Itoa(s.Order))
+	}
+	if s.Options != "" {
+		b.WriteString("," + s.Options)
+	}
+	return b.String()
+}
+
+// typeSpec is a set of field specs registered for a type.
+type typeSpec struct {
+	tags    map[string]string // tags by field name
+	replace bool
+}
+
+var fieldSpecs sync.Map // map[reflect.Type]typeSpec
+
+// RegisterFields makes Marshal, Unmarshal, Encoder and Decoder use specs
+// instead of json struct tags for the fields of the struct type t (when
+// values of t are encoded or decoded directly and when t is embedded).
+// specs are keyed by the names of the fields declared in t (promoted ones
+// are configured for their own types). If replace is set, struct tags of
+// t are ignored completely, the fields not in specs are treated as
+// fields without tags, otherwise they keep their tags. Names in specs
+// must be valid in struct tags: they can't contain commas, quotes or
+// backslashes.
+//
+// RegisterFields must be called before values of t (or of types containing
+// it) are marshaled or unmarshaled for the first time, usually in an init
+// function, since the encoders are cached. It's safe for concurrent use.
+func RegisterFields(t reflect.Type, specs map[string]FieldSpec, replace bool) error {
+	if t.Kind() != reflect.Struct {
+		return errors.New("json: RegisterFields of non-struct type " + t.String())
+	}
+	tags := make(map[string]string, len(specs))
+	for name, s := range specs {
+		if sf, ok := t.FieldByName(name); !ok || len(sf.Index) != 1 {
+			return errors.New("json: no field " + name + " in " + t.String())
+		}
+		if s.Name != "" && !isValidTag(s.Name) {
+			return errors.New("json: invalid member name " + strconv.Quote(s.Name) + " for field " + name + " of " + t.String())
+		}
+		tags[name] = s.tag()
+	}
+	fieldSpecs.Store(t, typeSpec{tags, replace})
+
+	fieldCache.mu.Lock()
+	if m, _ := fieldCache.value.Load().(map[reflect.Type][]field); m[t] != nil {
+		m = maps.Clone(m)
+		delete(m, t)
+		fieldCache.value.Store(m)
+	}
+	fieldCache.mu.Unlock()
+	encoderCache.Delete(t)
+	return nil
+}
+
+// fieldTag returns the json tag of the field sf of the struct type t
+// taking registered specs into account.
+func fieldTag(t reflect.Type, sf reflect.StructField) string {
+	if spec, ok := fieldSpecs.Load(t); ok {
+		spec := spec.(typeSpec)
+		if tag, ok := spec.tags[sf.Name]; ok {
+			return tag
+		}
+		if spec.replace {
+			return ""
+		}
+	}
+	return sf.Tag.Get("json")
+}
@@ -0,0 +1,76 @@
+package json
+
+import (
+	"reflect"
+	"testing"
+)
+
+type specBase struct {
+	ID int `json:"id"`
+}
+
+type specThirdParty struct {
+	Name    string `json:"name"`
+	Count   int    `json:"count,string"`
+	Comment string
+	Secret  string
+	specBase
+}
+
+func TestRegisterFields(t *testing.T) {
+	typ := reflect.TypeFor[specThirdParty]()
+	v := specThirdParty{"n", 0, "c", "s", specBase{7}}
+	b, err := Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"name":"n","count":"0","Comment":"c","Secret":"s","id":7}`; string(b) != want {
+		t.Errorf("before registration: got %s, want %s", b, want)
+	}
+
+	for _, tc := range []struct {
+		specs   map[string]FieldSpec
+		replace bool
+		want    string
+	}{
+		{map[string]FieldSpec{
+			"Count":  {Name: "n", OmitEmpty: true},
+			"Secret": {Skip: true},
+			"Name":   {Order: 1},
+		}, false, `{"Name":"n","Comment":"c","id":7}`},
+		{map[string]FieldSpec{
+			"Comment": {Name: "note", Order: 1},
+			"Count":   {String: true},
+		}, true, `{"note":"c","Name":"n","Count":"0","Secret":"s","id":7}`},
+	} {
+		if err := RegisterFields(typ, tc.specs, tc.replace); err != nil {
+			t.Fatal(err)
+		}
+		b, err := Marshal(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != tc.want {
+			t.Errorf("got %s, want %s", b, tc.want)
+		}
+		var got specThirdParty
+		if err := Unmarshal(b, &got); err != nil {
+			t.Fatal(err)
+		}
+		if got.Comment != "c" || got.ID != 7 {
+			t.Errorf("Unmarshal(%s): got %+v", b, got)
+		}
+	}
+
+	if err := RegisterFields(typ, map[string]FieldSpec{"ID": {}}, false); err == nil {
+		t.Error("promoted field: no error")
+	}
+	for _, name := range []string{"a,omitempty", `a"b`, `a\b`} {
+		if err := RegisterFields(typ, map[string]FieldSpec{"Name": {Name: name}}, false); err == nil {
+			t.Errorf("name %q: no error", name)
+		}
+	}
+	if err := RegisterFields(reflect.TypeFor[int](), nil, false); err == nil {
+		t.Error("non-struct type: no error")
+	}
+}
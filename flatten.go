@@ -0,0 +1,123 @@
+package json
+
+import (
+	"errors"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Flatten returns the object with all nested objects and arrays replaced by
+// their leaf values, the keys of which are the keys and array indices on the
+// way to them joined with sep. Members are in document order, the members
+// of map[string]any values are sorted by key. Empty objects and arrays are
+// leaves themselves, so that Unflatten can restore them. Keys containing
+// sep make the result ambiguous.
+func Flatten(obj OrderedObject, sep string) OrderedObject {
+	res := OrderedObject{}
+	for _, m := range obj {
+		res = appendFlat(res, m.Key, sep, m.Value)
+	}
+	return res
+}
+
+// appendFlat appends the leaves of the value v at key to res.
+func appendFlat(res OrderedObject, key, sep string, v any) OrderedObject {
+	switch v := v.(type) {
+	case OrderedObject:
+		if len(v) > 0 {
+			for _, m := range v {
+				res = appendFlat(res, key+sep+m.Key, sep, m.Value)
+			}
+			return res
+		}
+	case map[string]any:
+		if len(v) > 0 {
+			for _, k := range slices.Sorted(maps.Keys(v)) {
+				res = appendFlat(res, key+sep+k, sep, v[k])
+			}
+			return res
+		}
+	case []any:
+		if len(v) > 0 {
+			for i, elem := range v {
+				res = appendFlat(res, key+sep+strconv.Itoa(i), sep, elem)
+			}
+			return res
+		}
+	}
+	return append(res, Member{key, v})
+}
+
+// Unflatten is the inverse of Flatten: it splits the keys of obj by sep and
+// returns the nested objects described by them in the order of their first
+// keys. Objects with the keys "0", "1" and so on up to their length, in this
+// order, become arrays. A key being a prefix of another one (like "a" and
+// "a.b" with the "." separator) or repeated is an error.
+func Unflatten(obj OrderedObject, sep string) (OrderedObject, error) {
+	if sep == "" {
+		return nil, errors.New("json: Unflatten with empty separator")
+	}
+	root := new(flatNode)
+	for _, m := range obj {
+		n := root
+		parts := strings.Split(m.Key, sep)
+		for i, part := range parts {
+			child, ok := n.keys[part]
+			switch {
+			case !ok:
+				child = new(flatNode)
+				if n.keys == nil {
+					n.keys = make(map[string]*flatNode)
+				}
+				n.keys[part], n.members = child, append(n.members, flatMember{part, child})
+			case i == len(parts)-1 || child.leaf:
+				return nil, errors.New("json: Unflatten of conflicting key " + strconv.Quote(m.Key))
+			}
+			n = child
+		}
+		n.leaf, n.value = true, m.Value
+	}
+	return root.object(), nil
+}
+
+// flatNode is a value being restored by Unflatten.
+type flatNode struct {
+	leaf    bool
+	value   any // the leaf value
+	keys    map[string]*flatNode
+	members []flatMember
+}
+
+// flatMember is a member of a flatNode object.
+type flatMember struct {
+	key  string
+	node *flatNode
+}
+
+// object returns the non-leaf n as an object.
+func (n *flatNode) object() OrderedObject {
+	o := make(OrderedObject, 0, len(n.members))
+	for _, m := range n.members {
+		o = append(o, Member{m.key, m.node.get()})
+	}
+	return o
+}
+
+// get returns the value of n.
+func (n *flatNode) get() any {
+	if n.leaf {
+		return n.value
+	}
+	for i, m := range n.members {
+		if m.key != strconv.Itoa(i) {
+			return n.object()
+		}
+	}
+	a := make([]any, len(n.members))
+	for i, m := range n.members {
+		a[i] = m.node.get()
+	}
+	return a
+}
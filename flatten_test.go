@@ -0,0 +1,76 @@
+package json
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFlatten(t *testing.T) {
+	for _, tc := range []struct {
+		in, flat string
+		back     string // Unflatten result if not the same as in
+	}{
+		{`{}`, `{}`, ``},
+		{`{"a":1}`, `{"a":1}`, ``},
+		{`{"b":{"y":1,"x":[true,{"z":null}]},"a":"s"}`, `{"b.y":1,"b.x.0":true,"b.x.1.z":null,"a":"s"}`, ``},
+		{`{"e":{},"f":[],"g":[[]]}`, `{"e":{},"f":[],"g.0":[]}`, ``},
+		{`{"m":{"0":"a","1":"b"}}`, `{"m.0":"a","m.1":"b"}`, `{"m":["a","b"]}`},
+	} {
+		in := decodeOrdered(t, tc.in).(OrderedObject)
+		flat := Flatten(in, ".")
+		got, err := Marshal(flat)
+		if err != nil || string(got) != tc.flat {
+			t.Errorf("%s: got %s, %v, want %s", tc.in, got, err, tc.flat)
+		}
+		back, err := Unflatten(flat, ".")
+		if err != nil {
+			t.Errorf("%s: %v", tc.in, err)
+			continue
+		}
+		want := in
+		if tc.back != "" {
+			want = decodeOrdered(t, tc.back).(OrderedObject)
+		}
+		if !reflect.DeepEqual(back, want) {
+			t.Errorf("%s: Unflatten returned %#v", tc.in, back)
+		}
+	}
+
+	var m any
+	if err := Unmarshal([]byte(`{"b":{"d":1,"c":2},"a":[3]}`), &m); err != nil {
+		t.Fatal(err)
+	}
+	got, _ := Marshal(Flatten(OrderedObject{{"m", m}}, "__"))
+	if want := `{"m__a__0":3,"m__b__c":2,"m__b__d":1}`; string(got) != want {
+		t.Errorf("map: got %s, want %s", got, want)
+	}
+}
+
+func TestUnflatten(t *testing.T) {
+	for _, tc := range []struct {
+		in, want string
+	}{
+		{`{"a.b":1,"c":2,"a.c":3}`, `{"a":{"b":1,"c":3},"c":2}`},
+		{`{"a.1":1,"a.0":0}`, `{"a":{"1":1,"0":0}}`},
+		{`{"a.0":0,"a.2":2}`, `{"a":{"0":0,"2":2}}`},
+		{`{"":1,"x.":2}`, `{"":1,"x":{"":2}}`},
+		{`{"a":1,"a.b":2}`, ``},
+		{`{"a.b":1,"a":2}`, ``},
+		{`{"a.b":1,"a.b":2}`, ``},
+	} {
+		got, err := Unflatten(decodeOrdered(t, tc.in).(OrderedObject), ".")
+		if tc.want == "" {
+			if err == nil {
+				t.Errorf("%s: no error", tc.in)
+			}
+			continue
+		}
+		b, _ := Marshal(got)
+		if err != nil || string(b) != tc.want {
+			t.Errorf("%s: got %s, %v, want %s", tc.in, b, err, tc.want)
+		}
+	}
+	if _, err := Unflatten(OrderedObject{}, ""); err == nil {
+		t.Error("empty separator: no error")
+	}
+}
@@ -7,9 +7,37 @@ package json
 import "bytes"
 
 // Compact appends to dst the JSON-encoded src with
-// insignificant space characters elided.
+// insignificant space characters elided. Strings are re-escaped
+// the way Marshal does it, so the result is what Marshal would
+// produce for the same value (numbers are kept as they are).
 func Compact(dst *bytes.Buffer, src []byte) error {
-	return compact(dst, src, false)
+	return compactEscaped(dst, src, encOpts{escapeHTML: true})
+}
+
+// compactEscaped is like compact, but re-escapes all strings
+// according to opts.
+func compactEscaped(dst *bytes.Buffer, src []byte, opts encOpts) error {
+	var scan scanner
+	if err := checkValid(src, &scan); err != nil {
+		return err
+	}
+	b := dst.AvailableBuffer()
+	for i := 0; i < len(src); {
+		switch c := src[i]; {
+		case c == '"':
+			end := skipString(src, i)
+			s, _ := unquoteBytes(src[i:end])
+			b = appendString(b, s, opts)
+			i = end
+		case isSpace(c):
+			i++
+		default:
+			b = append(b, c)
+			i++
+		}
+	}
+	dst.Write(b)
+	return nil
 }
 
 func compact(dst *bytes.Buffer, src []byte, escape bool) error {
@@ -17,6 +45,7 @@ func compact(dst *bytes.Buffer, src []byte, escape bool) error {
 	var scan scanner
 	scan.reset()
 	start := 0
+	pos := len(src)
 	for i, c := range src {
 		if escape && (c == '<' || c == '>' || c == '&') {
 			if start < i {
@@ -38,7 +67,8 @@ func compact(dst *bytes.Buffer, src []byte, escape bool) error {
 		}
 		v := scan.step(&scan, c)
 		if v >= scanSkipSpace {
-			if v == scanError {
+			if v == scanError || v == scanEnd && scan.err != nil {
+				pos = i
 				break
 			}
 			if start < i {
@@ -49,6 +79,7 @@ func compact(dst *bytes.Buffer, src []byte, escape bool) error {
 	}
 	if scan.eof() == scanError {
 		dst.Truncate(origLen)
+		locate(scan.err, src, pos, 1, 1)
 		return scan.err
 	}
 	if start < len(src) {
@@ -76,19 +107,36 @@ func newline(dst *bytes.Buffer, prefix, indent string, depth int) {
 // at the end of src are preserved and copied to dst.
 // For example, if src has no trailing spaces, neither will dst;
 // if src ends in a trailing newline, so will dst.
+// Strings are re-escaped the way Marshal does it.
 func Indent(dst *bytes.Buffer, src []byte, prefix, indent string) error {
+	return indentEscaped(dst, src, prefix, indent, true)
+}
+
+// indentEscaped implements Indent, strings are copied as they are unless
+// escape is set. Marshal output doesn't need to be re-escaped, so
+// MarshalIndent and Encoder use it directly.
+func indentEscaped(dst *bytes.Buffer, src []byte, prefix, indent string, escape bool) error {
 	origLen := dst.Len()
 	var scan scanner
+	if escape {
+		// Strings are skipped as a whole, so they must be valid.
+		if err := checkValid(src, &scan); err != nil {
+			return err
+		}
+	}
 	scan.reset()
 	needIndent := false
 	depth := 0
-	for _, c := range src {
+	pos := len(src)
+	for i := 0; i < len(src); i++ {
+		c := src[i]
 		scan.bytes++
 		v := scan.step(&scan, c)
 		if v == scanSkipSpace {
 			continue
 		}
-		if v == scanError {
+		if v == scanError || v == scanEnd && scan.err != nil {
+			pos = i
 			break
 		}
 		if needIndent && v != scanEndObject && v != scanEndArray {
@@ -97,6 +145,18 @@ func Indent(dst *bytes.Buffer, src []byte, prefix, indent string) error {
 			newline(dst, prefix, indent, depth)
 		}
 
+		if escape && v == scanBeginLiteral && c == '"' {
+			end := skipString(src, i)
+			for _, c := range src[i+1 : end] {
+				scan.bytes++
+				scan.step(&scan, c)
+			}
+			s, _ := unquoteBytes(src[i:end])
+			dst.Write(appendString(dst.AvailableBuffer(), s, encOpts{escapeHTML: true}))
+			i = end - 1
+			continue
+		}
+
 		// Emit semantically uninteresting bytes
 		// (in particular, punctuation in strings) unmodified.
 		if v == scanContinue {
@@ -135,7 +195,18 @@ func Indent(dst *bytes.Buffer, src []byte, prefix, indent string) error {
 	}
 	if scan.eof() == scanError {
 		dst.Truncate(origLen)
+		locate(scan.err, src, pos, 1, 1)
 		return scan.err
 	}
 	return nil
 }
+
+// AppendIndent is like Indent, but appends the result to dst and returns
+// the extended buffer (or dst unchanged on error).
+func AppendIndent(dst, src []byte, prefix, indent string) ([]byte, error) {
+	buf := bytes.NewBuffer(dst)
+	if err := Indent(buf, src, prefix, indent); err != nil {
+		return dst, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,150 @@
+package json
+
+import (
+	"bytes"
+	"strconv"
+)
+
+// An Index records the positions of the values in a JSON document, so that
+// they can be extracted repeatedly without scanning the document again. It's
+// built by BuildIndex or BuildIndexDepth and is safe for concurrent use.
+type Index struct {
+	data []byte
+	root indexNode
+}
+
+// indexNode is a value of an indexed document.
+type indexNode struct {
+	member     int            // the offset of the key of an object member or of the value
+	start, end int            // the range of the value in the document
+	keys       map[string]int // object member names to their position in elems
+	elems      []indexNode    // array elements or object members, nil if not indexed
+}
+
+// BuildIndex checks data to be valid JSON and indexes the members (or the
+// elements) of the top-level object (or array), see BuildIndexDepth.
+func BuildIndex(data []byte) (*Index, error) {
+	return BuildIndexDepth(data, 1)
+}
+
+// BuildIndexDepth is like BuildIndex, but indexes the values down to the
+// given nesting depth, so that getting nested values is cheap too. A depth
+// of 1 indexes the members of the top-level value only, 0 doesn't index
+// anything and a negative depth indexes the whole document. Building the
+// index takes a single pass over data, which must not be modified while
+// the Index is used.
+func BuildIndexDepth(data []byte, depth int) (*Index, error) {
+	var scan scanner
+	if err := checkValid(data, &scan); err != nil {
+		return nil, err
+	}
+	root, _ := indexValue(data, skipSpace(data, 0), depth)
+	return &Index{data, root}, nil
+}
+
+// indexValue indexes the valid value starting at data[off] down to depth
+// and returns it along with the offset right after it.
+func indexValue(data []byte, off, depth int) (indexNode, int) {
+	n := indexNode{member: off, start: off}
+	if depth == 0 || data[off] != '{' && data[off] != '[' {
+		n.end = skipValue(data, off)
+		return n, n.end
+	}
+	object := data[off] == '{'
+	if object {
+		n.keys = make(map[string]int)
+	}
+	n.elems = make([]indexNode, 0)
+	off = skipSpace(data, off+1)
+	for data[off] != '}' && data[off] != ']' {
+		var key string
+		member := off
+		if object {
+			end := skipString(data, off)
+			key = unquoteKey(data[off:end])
+			off = skipSpace(data, skipSpace(data, end)+1) // Skip ':'.
+		}
+		elem, end := indexValue(data, off, depth-1)
+		elem.member = member
+		if _, dup := n.keys[key]; !object || !dup {
+			if object {
+				n.keys[key] = len(n.elems)
+			}
+			n.elems = append(n.elems, elem)
+		}
+		off = skipSpace(data, end)
+		if data[off] == ',' {
+			off = skipSpace(data, off+1)
+		}
+	}
+	n.end = off + 1
+	return n, n.end
+}
+
+// unquoteKey returns the valid string literal key as a string.
+func unquoteKey(key []byte) string {
+	if bytes.IndexByte(key, '\\') < 0 {
+		return string(key[1 : len(key)-1])
+	}
+	s, _ := unquote(key)
+	return s
+}
+
+// Get returns the raw value found at the given path, where path elements
+// are interpreted like by DecodePath: an empty path returns the whole
+// document and if an object has several members with the same name, the
+// first one is used. The indexed part of the path is resolved without
+// looking at the document, the rest of it is found by skipping over the
+// values like DecodePath does. The result shares the memory of the
+// document. A missing value yields a *PathError.
+func (ix *Index) Get(path ...string) (RawMessage, error) {
+	_, start, end, err := ix.find(path)
+	if err != nil {
+		return nil, err
+	}
+	return ix.data[start:end:end], nil
+}
+
+// find returns the offset of the member at path and the range of its value.
+func (ix *Index) find(path []string) (int, int, int, error) {
+	var d decodeState
+	d.init(ix.data)
+	n := &ix.root
+	for i, name := range path {
+		if n.elems == nil {
+			// Not indexed, look the rest up in the document.
+			var member int
+			off := n.start
+			for _, name := range path[i:] {
+				var err error
+				member, off, err = d.pathMember(off, name)
+				if err != nil {
+					return 0, 0, 0, err
+				}
+			}
+			return member, off, skipValue(ix.data, off), nil
+		}
+		pos := -1
+		if n.keys != nil {
+			d.pushPath(quoteKey(name), 0)
+			if p, ok := n.keys[name]; ok {
+				pos = p
+			}
+		} else {
+			index, err := strconv.Atoi(name)
+			if err != nil || index < 0 || name[0] == '+' {
+				d.pushPath(quoteKey(name), 0)
+			} else {
+				d.pushPath(nil, index)
+				if index < len(n.elems) {
+					pos = index
+				}
+			}
+		}
+		if pos < 0 {
+			return 0, 0, 0, &PathError{Path: formatPath(d.errorContext.Path, "")}
+		}
+		n = &n.elems[pos]
+	}
+	return n.member, n.start, n.end, nil
+}
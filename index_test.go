@@ -0,0 +1,53 @@
+package json
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestIndex(t *testing.T) {
+	const in = ` {"a": [1, {"b": "x", "c\"": [true, null]}, "s\"]}"], "d": -1.5e3 , "a": 0, "e": {}} `
+	paths := [][]string{
+		nil,
+		{"d"},
+		{"a"},
+		{"a", "0"},
+		{"a", "1", "b"},
+		{"a", "1", `c"`, "1"},
+		{"a", "1", `c"`},
+		{"a", "2"},
+		{"e"},
+		{"a", "3"},
+		{"a", "x"},
+		{"a", "+0"},
+		{"a", "1", "e", "f"},
+		{"d", "0"},
+		{"e", "f"},
+		{"f"},
+	}
+	for _, depth := range []int{0, 1, 2, 3, -1} {
+		ix, err := BuildIndexDepth([]byte(in), depth)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, path := range paths {
+			var want RawMessage
+			wantErr := DecodePath([]byte(in), &want, path...)
+			got, err := ix.Get(path...)
+			if wantErr != nil {
+				var pe *PathError
+				if !errors.As(err, &pe) || err.Error() != wantErr.Error() {
+					t.Errorf("depth %d, %q: got error %v, want %v", depth, path, err, wantErr)
+				}
+				continue
+			}
+			if err != nil || string(got) != string(want) {
+				t.Errorf("depth %d, %q: got %s, %v, want %s", depth, path, got, err, want)
+			}
+		}
+	}
+
+	if _, err := BuildIndex([]byte(`{"a": 1`)); err == nil {
+		t.Error("invalid input: no error")
+	}
+}
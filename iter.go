@@ -0,0 +1,157 @@
+package json
+
+import (
+	"reflect"
+)
+
+// seqElem returns the element type of t if it's an iter.Seq-like function
+// type, func(yield func(T) bool).
+func seqElem(t reflect.Type) (reflect.Type, bool) {
+	if t.NumIn() != 1 || t.NumOut() != 0 || t.IsVariadic() {
+		return nil, false
+	}
+	y := t.In(0)
+	if y.Kind() != reflect.Func || y.NumIn() != 1 || y.NumOut() != 1 || y.Out(0).Kind() != reflect.Bool || y.IsVariadic() {
+		return nil, false
+	}
+	return y.In(0), true
+}
+
+// seq2Elems returns the key and value types of t if it's an iter.Seq2-like
+// function type, func(yield func(K, V) bool).
+func seq2Elems(t reflect.Type) (reflect.Type, reflect.Type, bool) {
+	if t.NumIn() != 1 || t.NumOut() != 0 || t.IsVariadic() {
+		return nil, nil, false
+	}
+	y := t.In(0)
+	if y.Kind() != reflect.Func || y.NumIn() != 2 || y.NumOut() != 1 || y.Out(0).Kind() != reflect.Bool || y.IsVariadic() {
+		return nil, nil, false
+	}
+	return y.In(0), y.In(1), true
+}
+
+// allMethod returns the index of the All method of t and the value type of
+// the function it returns if that's an iter.Seq2-like function with string
+// keys.
+func allMethod(t reflect.Type) (int, reflect.Type, bool) {
+	if t.Kind() == reflect.Interface {
+		return 0, nil, false
+	}
+	m, ok := t.MethodByName("All")
+	if !ok || m.Type.NumIn() != 1 || m.Type.NumOut() != 1 || m.Type.Out(0).Kind() != reflect.Func {
+		return 0, nil, false
+	}
+	key, elem, ok := seq2Elems(m.Type.Out(0))
+	if !ok || key.Kind() != reflect.String {
+		return 0, nil, false
+	}
+	return m.Index, elem, true
+}
+
+// streamEncoder writes iter.Seq functions and receive-only channels as
+// arrays. Elements are pulled one by one and the output is passed to the
+// Encoder writer as it's produced.
+type streamEncoder struct {
+	elemEnc encoderFunc
+}
+
+func (se *streamEncoder) encode(e *encodeState, v reflect.Value, opts encOpts) {
+	if v.IsNil() {
+		e.WriteString("null")
+		return
+	}
+	e.enter(opts)
+	opts.keyTemplate = opts.keyTemplate.elements()
+	e.streams++
+	e.WriteByte('[')
+	first := true
+	for ev := range v.Seq() {
+		if first {
+			first = false
+		} else {
+			e.WriteByte(',')
+		}
+		se.elemEnc(e, ev, opts)
+		e.flush()
+	}
+	e.WriteByte(']')
+	e.streams--
+	e.leave()
+}
+
+func newStreamEncoder(c *Config, elem reflect.Type) encoderFunc {
+	enc := &streamEncoder{typeEncoder(c, elem)}
+	return enc.encode
+}
+
+// objectStreamEncoder writes iter.Seq2 functions with string keys as
+// objects in iteration order. Like streamEncoder it passes the output
+// to the Encoder writer as it's produced.
+type objectStreamEncoder struct {
+	elemEnc encoderFunc
+}
+
+func (se *objectStreamEncoder) encode(e *encodeState, v reflect.Value, opts encOpts) {
+	if v.IsNil() {
+		e.WriteString("null")
+		return
+	}
+	start, t := e.Len(), opts.keyTemplate
+	if opts.extras().canonical || t != nil {
+		e.pinned++
+	}
+	e.enter(opts)
+	e.streams++
+	e.WriteByte('{')
+	first := true
+	for k, ev := range v.Seq2() {
+		if first {
+			first = false
+		} else {
+			e.WriteByte(',')
+		}
+		e.string(k.String(), opts)
+		e.WriteByte(':')
+		opts.keyTemplate = t.member(k.String())
+		se.elemEnc(e, ev, opts)
+		e.flush()
+	}
+	e.WriteByte('}')
+	e.streams--
+	e.leave()
+	if opts.extras().canonical {
+		e.sortMembers(start)
+	} else if t != nil {
+		e.orderMembers(start, t, opts)
+	}
+}
+
+func newObjectStreamEncoder(c *Config, elem reflect.Type) encoderFunc {
+	enc := &objectStreamEncoder{typeEncoder(c, elem)}
+	return enc.encode
+}
+
+// allEncoder writes values with an All method returning an iter.Seq2
+// function with string keys as the objects objectStreamEncoder writes for
+// that function.
+type allEncoder struct {
+	method int
+	addr   bool // the method is called on the address of the value
+	seqEnc encoderFunc
+}
+
+func (ae *allEncoder) encode(e *encodeState, v reflect.Value, opts encOpts) {
+	if ae.addr {
+		v = v.Addr()
+	}
+	if v.Kind() == reflect.Pointer && v.IsNil() {
+		e.WriteString("null")
+		return
+	}
+	ae.seqEnc(e, v.Method(ae.method).Call(nil)[0], opts)
+}
+
+func newAllEncoder(c *Config, method int, elem reflect.Type, addr bool) encoderFunc {
+	enc := &allEncoder{method, addr, newObjectStreamEncoder(c, elem)}
+	return enc.encode
+}
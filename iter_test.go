@@ -0,0 +1,157 @@
+package json
+
+import (
+	"bytes"
+	"errors"
+	"iter"
+	"maps"
+	"slices"
+	"strings"
+	"testing"
+)
+
+type countingWriter struct {
+	bytes.Buffer
+	writes int
+	err    error
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	w.writes++
+	if w.err != nil {
+		return 0, w.err
+	}
+	return w.Buffer.Write(p)
+}
+
+func TestEncodeStreams(t *testing.T) {
+	ch := make(chan int, 3)
+	ch <- 1
+	ch <- 2
+	close(ch)
+	var nilSeq iter.Seq[string]
+	type T struct {
+		S iter.Seq[string]
+		C <-chan int
+		N iter.Seq[string]
+	}
+	b, err := Marshal(T{slices.Values([]string{"a", "b"}), ch, nilSeq})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"S":["a","b"],"C":[1,2],"N":null}`; string(b) != want {
+		t.Errorf("got %s, want %s", b, want)
+	}
+
+	type Key string
+	type Pairs iter.Seq2[string, any]
+	pairs := Pairs(func(yield func(string, any) bool) {
+		_ = yield("b", 1) && yield("a", []int{2}) && yield("b", nil)
+	})
+	b, err = Marshal(map[string]any{"p": pairs, "m": maps.All(map[Key]string{"k": "v"})})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"m":{"k":"v"},"p":{"b":1,"a":[2],"b":null}}`; string(b) != want {
+		t.Errorf("got %s, want %s", b, want)
+	}
+	b, err = MarshalCanonical(pairs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"a":[2],"b":1,"b":null}`; string(b) != want {
+		t.Errorf("canonical: got %s, want %s", b, want)
+	}
+
+	for _, v := range []any{make(chan int), func() {}, func(func(int)) {}, maps.All(map[int]int{})} {
+		var ute *UnsupportedTypeError
+		if _, err := Marshal(v); !errors.As(err, &ute) {
+			t.Errorf("%T: got error %v, want UnsupportedTypeError", v, err)
+		}
+	}
+}
+
+// pairList is an ordered map exposing its pairs with an All method.
+type pairList struct {
+	keys []string
+	vals []int
+}
+
+func (p pairList) All() iter.Seq2[string, int] {
+	return func(yield func(string, int) bool) {
+		for i, k := range p.keys {
+			if !yield(k, p.vals[i]) {
+				return
+			}
+		}
+	}
+}
+
+// ptrPairList has an All method with a pointer receiver.
+type ptrPairList struct{ p pairList }
+
+func (p *ptrPairList) All() iter.Seq2[string, int] { return p.p.All() }
+
+// intKeyed has an All method with non-string keys.
+type intKeyed struct{ X int }
+
+func (intKeyed) All() iter.Seq2[int, int] { return nil }
+
+func TestMarshalAllMethod(t *testing.T) {
+	p := pairList{[]string{"b", "a"}, []int{1, 2}}
+	v := struct {
+		P  pairList
+		PP *pairList
+		N  *pairList
+		A  ptrPairList
+		I  intKeyed
+	}{p, &p, nil, ptrPairList{p}, intKeyed{3}}
+	b, err := Marshal(&v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"P":{"b":1,"a":2},"PP":{"b":1,"a":2},"N":null,"A":{"b":1,"a":2},"I":{"X":3}}`; string(b) != want {
+		t.Errorf("got %s, want %s", b, want)
+	}
+	b, err = Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"P":{"b":1,"a":2},"PP":{"b":1,"a":2},"N":null,"A":{},"I":{"X":3}}`; string(b) != want {
+		t.Errorf("unaddressable: got %s, want %s", b, want)
+	}
+}
+
+func TestEncoderStreamsIncrementally(t *testing.T) {
+	const n = 10000
+	var pulled int
+	seq := func(yield func(string) bool) {
+		for pulled = 0; pulled < n; pulled++ {
+			if !yield("x") {
+				return
+			}
+		}
+	}
+	w := new(countingWriter)
+	if err := NewEncoder(w).Encode(seq); err != nil {
+		t.Fatal(err)
+	}
+	if want := "[" + strings.Repeat(`"x",`, n-1) + "\"x\"]\n"; w.String() != want {
+		t.Errorf("got %d bytes, want %d", w.Len(), len(want))
+	}
+	if w.writes < 2 {
+		t.Errorf("output written in %d chunks", w.writes)
+	}
+
+	w = &countingWriter{err: errors.New("broken")}
+	enc := NewEncoder(w)
+	if err := enc.Encode(seq); err == nil || err.Error() != "broken" {
+		t.Errorf("write error: got %v", err)
+	}
+	if pulled == n {
+		t.Error("iteration wasn't stopped by the write error")
+	}
+	if err := enc.Encode(1); err == nil {
+		t.Error("broken encoder: no error")
+	}
+}
@@ -0,0 +1,253 @@
+package json
+
+import (
+	"slices"
+	"strconv"
+)
+
+// A KeyTemplate describes the required order of object members at every
+// path of a document, it's used by Encoder.SetKeyTemplate to produce
+// documents matching the property order of another serializer (like the
+// C# one of Neo nodes). A KeyTemplate is safe for concurrent use.
+type KeyTemplate struct {
+	path    string                  // the path in the template, like "a.b[].c"
+	keys    []string                // member names in the required order
+	pos     map[string]int          // positions of keys, nil if there are no objects at the path
+	members map[string]*KeyTemplate // templates of member values
+	elems   *KeyTemplate            // the template of array elements
+}
+
+// NewKeyTemplate returns the template of the member order of the sample
+// document, which is usually a value written by the serializer to match.
+// Objects found at the same path (like the elements of an array) are
+// merged: members missing in some of them go after the preceding ones in
+// the order of the sample. Objects with the same members in different
+// orders yield a *KeyOrderError, invalid JSON yields a *SyntaxError.
+func NewKeyTemplate(sample []byte) (*KeyTemplate, error) {
+	var scan scanner
+	if err := checkValid(sample, &scan); err != nil {
+		return nil, err
+	}
+	t := new(KeyTemplate)
+	if err := t.add(sample, skipSpace(sample, 0)); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// MustKeyTemplate is like NewKeyTemplate, but panics if the sample is
+// not valid. It's meant for templates initializing global variables.
+func MustKeyTemplate(sample []byte) *KeyTemplate {
+	t, err := NewKeyTemplate(sample)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+// add merges the valid value starting at data[off] into t.
+func (t *KeyTemplate) add(data []byte, off int) error {
+	switch data[off] {
+	case '{':
+		if t.pos == nil {
+			t.pos = make(map[string]int)
+			t.members = make(map[string]*KeyTemplate)
+		}
+		prev := -1
+		for off = skipSpace(data, off+1); data[off] != '}'; {
+			end := skipString(data, off)
+			key := unquoteKey(data[off:end])
+			i, ok := t.pos[key]
+			if !ok {
+				i = prev + 1
+				t.keys = slices.Insert(t.keys, i, key)
+				for j, k := range t.keys[i:] {
+					t.pos[k] = i + j
+				}
+				t.members[key] = &KeyTemplate{path: memberPath(t.path, key)}
+			} else if i <= prev {
+				return &KeyOrderError{Path: t.path, Key: key}
+			}
+			prev = i
+			off = skipSpace(data, skipSpace(data, end)+1) // Skip ':'.
+			if err := t.members[key].add(data, off); err != nil {
+				return err
+			}
+			if off = skipSpace(data, skipValue(data, off)); data[off] == ',' {
+				off = skipSpace(data, off+1)
+			}
+		}
+	case '[':
+		for off = skipSpace(data, off+1); data[off] != ']'; {
+			if t.elems == nil {
+				t.elems = &KeyTemplate{path: t.path + "[]"}
+			}
+			if err := t.elems.add(data, off); err != nil {
+				return err
+			}
+			if off = skipSpace(data, skipValue(data, off)); data[off] == ',' {
+				off = skipSpace(data, off+1)
+			}
+		}
+	}
+	return nil
+}
+
+// memberPath returns the path of the member key of the object at path.
+func memberPath(path, key string) string {
+	if !isPathIdent(key) {
+		return path + "[" + strconv.Quote(key) + "]"
+	}
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// Keys returns the member names of the objects at the path of t in the
+// required order, the path is given by member names and "[]" for array
+// elements (so the path of the members of the objects in the "tx" array of
+// the top-level object is "tx", "[]"). The result is nil if there are no
+// objects at the path.
+func (t *KeyTemplate) Keys(path ...string) []string {
+	for _, elem := range path {
+		if elem == "[]" {
+			t = t.elements()
+		} else {
+			t = t.member(elem)
+		}
+	}
+	if t == nil {
+		return nil
+	}
+	return slices.Clone(t.keys)
+}
+
+// member returns the template of the value of the member name of the
+// objects at the path of t, it's nil if there is none or t is nil.
+func (t *KeyTemplate) member(name string) *KeyTemplate {
+	if t == nil {
+		return nil
+	}
+	return t.members[name]
+}
+
+// elements returns the template of the elements of the arrays at the path
+// of t, it's nil if there is none or t is nil.
+func (t *KeyTemplate) elements() *KeyTemplate {
+	if t == nil {
+		return nil
+	}
+	return t.elems
+}
+
+// KeyOrderPolicy determines what an Encoder with a KeyTemplate does to
+// object members. See Encoder.SetKeyTemplate.
+type KeyOrderPolicy int
+
+const (
+	// KeyOrderReorder writes the members named in the template in its
+	// order followed by the other ones in the order they'd be written
+	// without the template. This is the default policy.
+	KeyOrderReorder KeyOrderPolicy = iota
+	// KeyOrderCheck makes encoding fail with a KeyOrderError if the members
+	// are out of the template order or not in the template at all.
+	KeyOrderCheck
+)
+
+// A KeyOrderError describes an object member that is out of the order of
+// a KeyTemplate.
+type KeyOrderError struct {
+	Path    string // the path of the object in the template, like "a.b[].c"
+	Key     string // the name of the member
+	Unknown bool   // the member is not in the template
+}
+
+func (e *KeyOrderError) Error() string {
+	s := "json: member " + strconv.Quote(e.Key) + " of object"
+	if e.Path != "" {
+		s += " at " + e.Path
+	}
+	if e.Unknown {
+		return s + " is not in the key template"
+	}
+	return s + " is out of the key template order"
+}
+
+// orderMembers applies the template t to the members of the compact
+// object written to e starting at the offset start, its member values
+// are done already.
+func (e *encodeState) orderMembers(start int, t *KeyTemplate, opts encOpts) {
+	e.pinned--
+	if err := t.orderObject(e.Bytes()[start:], opts.extras().keyCheck); err != nil {
+		e.error(err)
+	}
+}
+
+// orderValue applies the template t to all the objects of the compact value
+// written to e starting at the offset start.
+func (e *encodeState) orderValue(start int, t *KeyTemplate, opts encOpts) {
+	if err := t.orderValue(e.Bytes()[start:], opts.extras().keyCheck); err != nil {
+		e.error(err)
+	}
+}
+
+// orderValue applies the template t to all the objects of the compact value
+// data, which is reordered in place.
+func (t *KeyTemplate) orderValue(data []byte, check bool) error {
+	if t == nil {
+		return nil
+	}
+	switch data[0] {
+	case '{':
+		for _, m := range objectMembers(data) {
+			if err := t.members[m.key].orderValue(m.raw[m.value:], check); err != nil {
+				return err
+			}
+		}
+		return t.orderObject(data, check)
+	case '[':
+		for off := 1; data[off] != ']'; {
+			next := skipValue(data, off)
+			if err := t.elems.orderValue(data[off:next], check); err != nil {
+				return err
+			}
+			if off = next; data[off] == ',' {
+				off++
+			}
+		}
+	}
+	return nil
+}
+
+// orderObject reorders the members of the compact object data in place or
+// checks their order.
+func (t *KeyTemplate) orderObject(data []byte, check bool) error {
+	if t.pos == nil {
+		return nil // No objects at the path in the template.
+	}
+	members := objectMembers(data)
+	if check {
+		prev := 0
+		for _, m := range members {
+			i, ok := t.pos[m.key]
+			if !ok || i < prev {
+				return &KeyOrderError{Path: t.path, Key: m.key, Unknown: !ok}
+			}
+			prev = i
+		}
+		return nil
+	}
+	rank := func(m rawMember) int {
+		if i, ok := t.pos[m.key]; ok {
+			return i
+		}
+		return len(t.keys)
+	}
+	order := func(a, b rawMember) int { return rank(a) - rank(b) }
+	if !slices.IsSortedFunc(members, order) {
+		slices.SortStableFunc(members, order)
+		writeMembers(data, members)
+	}
+	return nil
+}
@@ -0,0 +1,167 @@
+package json
+
+import (
+	"bytes"
+	"errors"
+	"iter"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const blockTemplate = `{
+	"hash": "",
+	"size": 0,
+	"version": 0,
+	"tx": [
+		{"hash": "", "signers": [{"account": "", "scopes": ""}], "script": ""},
+		{"hash": "", "sysfee": "", "script": "", "witnesses": [{"invocation": "", "verification": ""}]}
+	],
+	"nonce": ""
+}`
+
+type templateSigner struct {
+	Scopes  string `json:"scopes"`
+	Account string `json:"account"`
+}
+
+type templateTx struct {
+	Script    string            `json:"script"`
+	Witnesses []templateWitness `json:"witnesses,omitempty"`
+	Signers   []templateSigner  `json:"signers,omitempty"`
+	Extra     map[string]string `json:",inline"`
+	Hash      string            `json:"hash"`
+}
+
+type templateWitness struct {
+	Verification string `json:"verification"`
+	Invocation   string `json:"invocation"`
+}
+
+func (w templateWitness) MarshalJSON() ([]byte, error) {
+	return []byte(`{"verification": "` + w.Verification + `", "invocation": "` + w.Invocation + `"}`), nil
+}
+
+func TestKeyTemplate(t *testing.T) {
+	tmpl := MustKeyTemplate([]byte(blockTemplate))
+	for _, tc := range []struct {
+		path []string
+		want []string
+	}{
+		{nil, []string{"hash", "size", "version", "tx", "nonce"}},
+		{[]string{"tx", "[]"}, []string{"hash", "sysfee", "signers", "script", "witnesses"}},
+		{[]string{"tx", "[]", "witnesses", "[]"}, []string{"invocation", "verification"}},
+		{[]string{"tx"}, nil},
+		{[]string{"nonce", "x"}, nil},
+	} {
+		if got := tmpl.Keys(tc.path...); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%q: got %q, want %q", tc.path, got, tc.want)
+		}
+	}
+
+	for _, tc := range []struct {
+		sample string
+		err    string
+	}{
+		{`{"a": 1, "b": 2, "a": 3}`, `json: member "a" of object is out of the key template order`},
+		{`[{"a": {"x": 1, "y": 2}}, {"a": {"y": 2, "z": 3, "x": 1}}]`, `json: member "x" of object at [].a is out of the key template order`},
+		{`{"a": [1,]}`, `invalid character ']' looking for beginning of value`},
+	} {
+		if _, err := NewKeyTemplate([]byte(tc.sample)); err == nil || err.Error() != tc.err {
+			t.Errorf("%s: got error %v, want %s", tc.sample, err, tc.err)
+		}
+	}
+}
+
+func TestEncoderSetKeyTemplate(t *testing.T) {
+	tmpl := MustKeyTemplate([]byte(blockTemplate))
+	block := OrderedObject{
+		{"tx", []any{
+			templateTx{
+				Script:  "s1",
+				Signers: []templateSigner{{"Global", "a1"}},
+				Extra:   map[string]string{"sysfee": "1", "netfee": "2"},
+				Hash:    "h1",
+			},
+			map[string]any{"witnesses": []templateWitness{{"v", "i"}}, "script": "s2", "hash": "h2"},
+		}},
+		{"nonce", "n"},
+		{"x-extra", true},
+		{"size", 10},
+		{"hash", "h"},
+	}
+	want := `{"hash":"h","size":10,"tx":[` +
+		`{"hash":"h1","sysfee":"1","signers":[{"account":"a1","scopes":"Global"}],"script":"s1","netfee":"2"},` +
+		`{"hash":"h2","script":"s2","witnesses":[{"invocation":"i","verification":"v"}]}` +
+		`],"nonce":"n","x-extra":true}`
+	got, err := MarshalWithOptions(block, Options{KeyTemplate: tmpl})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != want {
+		t.Errorf("got  %s\nwant %s", got, want)
+	}
+
+	check := Options{KeyTemplate: tmpl, KeyOrder: KeyOrderCheck}
+	if _, err := MarshalWithOptions(RawMessage(blockTemplate), check); err != nil {
+		t.Errorf("check of sample: %v", err)
+	}
+	for _, tc := range []struct {
+		v   any
+		err KeyOrderError
+	}{
+		{block, KeyOrderError{"tx[].signers[]", "account", false}},
+		{RawMessage(got), KeyOrderError{"tx[]", "netfee", true}},
+		{OrderedObject{{"size", 1}, {"hash", "h"}}, KeyOrderError{"", "hash", false}},
+		{map[string]any{"hash": "", "x": 1}, KeyOrderError{"", "x", true}},
+		{OrderedObject{{"tx", []any{OrderedObject{{"script", ""}, {"hash", ""}}}}}, KeyOrderError{"tx[]", "hash", false}},
+		{OrderedObject{{"tx", []templateWitness{{"v", "i"}}}}, KeyOrderError{"tx[]", "verification", true}},
+		{[]OrderedObject{{{"a", 1}}}, KeyOrderError{}},
+	} {
+		_, err := MarshalWithOptions(tc.v, check)
+		var ke *KeyOrderError
+		if tc.err == (KeyOrderError{}) {
+			if err != nil {
+				t.Errorf("%v: %v", tc.v, err)
+			}
+		} else if !errors.As(err, &ke) || *ke != tc.err {
+			t.Errorf("%v: got error %v, want %v", tc.v, err, &tc.err)
+		}
+	}
+}
+
+func TestEncoderSetKeyTemplateStream(t *testing.T) {
+	objects := func(yield func(OrderedObject) bool) {
+		for range 100 {
+			if !yield(OrderedObject{{"b", strings.Repeat("x", 100)}, {"a", 1}}) {
+				return
+			}
+		}
+	}
+	var buf bytes.Buffer
+	enc := NewEncoder(&buf)
+	enc.SetFlushSize(64)
+	enc.SetKeyTemplate(MustKeyTemplate([]byte(`[{"a": 0, "b": ""}]`)), KeyOrderReorder)
+	if err := enc.Encode(iter.Seq[OrderedObject](objects)); err != nil {
+		t.Fatal(err)
+	}
+	var want bytes.Buffer
+	for i := range 100 {
+		if i > 0 {
+			want.WriteByte(',')
+		}
+		want.WriteString(`{"a":1,"b":"` + strings.Repeat("x", 100) + `"}`)
+	}
+	if got := buf.String(); got != "["+want.String()+"]\n" {
+		t.Errorf("got %s", got)
+	}
+
+	// Values between tokens are not affected.
+	buf.Reset()
+	enc.WriteToken(Delim('['))
+	enc.Encode(OrderedObject{{"b", ""}, {"a", 1}})
+	enc.WriteToken(Delim(']'))
+	if got, want := buf.String(), `[{"b":"","a":1}]`+"\n"; got != want {
+		t.Errorf("tokens: got %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,258 @@
+package json
+
+import "strconv"
+
+// A ParseError is a syntax error found by ParseTreeLenient.
+type ParseError struct {
+	Pos Position
+	Msg string
+}
+
+func (e *ParseError) Error() string {
+	return "json: " + e.Pos.String() + ": " + e.Msg
+}
+
+// ParseTreeLenient is like ParseTree, but doesn't stop at syntax errors:
+// it reports every error found and goes on parsing from the next token
+// that makes sense, so diagnostic tools can show all the problems of a
+// document at once. Malformed literals and numbers are left out, strings
+// are kept with their errors (unterminated ones end at the end of the line)
+// and unclosed objects and arrays end at the mismatching closing bracket or
+// the end of input. The result is nil only for input without any value.
+// Nodes of invalid documents reflect the recovered structure and are meant
+// for inspection rather than editing.
+func ParseTreeLenient(data []byte) (*Node, []*ParseError) {
+	p := lenientParser{treeParser: treeParser{data: data, line: 1}}
+	n, off := p.value(0)
+	if off = skipSpace(data, off); n != nil && off < len(data) {
+		p.fail(off, "invalid character "+quoteChar(data[off])+" after top-level value")
+	}
+	return n, p.errs
+}
+
+// lenientParser builds nodes of a document with errors.
+type lenientParser struct {
+	treeParser
+	errs []*ParseError
+}
+
+// fail records the error at data[off] unless it's just been recorded.
+func (p *lenientParser) fail(off int, msg string) {
+	if last := len(p.errs) - 1; last >= 0 && p.errs[last].Pos.Offset == off && p.errs[last].Msg == msg {
+		return
+	}
+	p.errs = append(p.errs, &ParseError{p.position(off), msg})
+}
+
+// failAt records the error for the unexpected byte at data[off], which
+// can be the end of input, found when looking for what.
+func (p *lenientParser) failAt(off int, what string) {
+	if off == len(p.data) {
+		p.fail(off, "unexpected end of JSON input")
+		return
+	}
+	p.fail(off, "invalid character "+quoteChar(p.data[off])+" "+what)
+}
+
+// value returns the node of the value at data[off] or after spaces and
+// the offset right after it. Malformed values yield nil, commas and closing
+// brackets are not consumed.
+func (p *lenientParser) value(off int) (*Node, int) {
+	data := p.data
+	off = skipSpace(data, off)
+	if off == len(data) {
+		p.failAt(off, "")
+		return nil, off
+	}
+	start := off
+	n := &Node{Start: p.position(off)}
+	switch c := data[off]; {
+	case c == '{' || c == '[':
+		off = p.container(n, off)
+	case c == '"':
+		n.Kind = NodeString
+		off = p.str(off, true)
+	case c == '-' || '0' <= c && c <= '9':
+		for off < len(data) && isNumberByte(data[off]) {
+			off++
+		}
+		if !isValidNumber(string(data[start:off])) {
+			p.fail(start, "invalid number literal "+strconv.Quote(string(data[start:off])))
+			return nil, off
+		}
+		n.Kind = NodeNumber
+	case 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z':
+		for off < len(data) && isWordByte(data[off]) {
+			off++
+		}
+		switch string(data[start:off]) {
+		case "null":
+			n.Kind = NodeNull
+		case "true", "false":
+			n.Kind = NodeBool
+		default:
+			p.fail(start, "invalid literal "+strconv.Quote(string(data[start:off])))
+			return nil, off
+		}
+	default:
+		p.failAt(off, "looking for beginning of value")
+		if c != ',' && c != ']' && c != '}' {
+			off++
+		}
+		return nil, off
+	}
+	n.End = p.position(off)
+	n.Raw = data[start:off:off]
+	n.src = n.Raw
+	return n, off
+}
+
+// container parses the object or array starting at data[off] into n and
+// returns the offset right after it.
+func (p *lenientParser) container(n *Node, off int) int {
+	data := p.data
+	start := off
+	n.Kind, n.Children = NodeArray, make([]*Node, 0)
+	closing, after := byte(']'), "after array element"
+	if data[off] == '{' {
+		n.Kind, closing, after = NodeObject, '}', "after object key:value pair"
+	}
+	off = skipSpace(data, off+1)
+	for {
+		if off == len(data) {
+			p.failAt(off, "")
+			break
+		}
+		if c := data[off]; c == closing {
+			off++
+			break
+		} else if c == ']' || c == '}' {
+			// Mismatched bracket, it probably closes the parent.
+			p.failAt(off, "looking for "+strconv.QuoteRune(rune(closing)))
+			break
+		}
+		var key *Node
+		span := nodeSpan{member: off - start, keyEnd: off - start}
+		if n.Kind == NodeObject {
+			if data[off] != '"' {
+				p.failAt(off, "looking for beginning of object key string")
+				if off = p.skip(off); off < len(data) && data[off] == ',' {
+					off = skipSpace(data, off+1)
+				}
+				continue
+			}
+			key, off = p.value(off)
+			span.keyEnd = off - start
+			off = skipSpace(data, off)
+			if off < len(data) && data[off] == ':' {
+				off++
+			} else {
+				p.failAt(off, "after object key")
+			}
+		}
+		span.value = skipSpace(data, off) - start
+		elem, next := p.value(off)
+		if elem != nil {
+			span.end = next - start
+			elem.Key = key
+			n.Children = append(n.Children, elem)
+			n.spans = append(n.spans, span)
+		}
+		if off = skipSpace(data, next); off == len(data) {
+			continue
+		}
+		switch c := data[off]; {
+		case c == ',':
+			if off = skipSpace(data, off+1); off < len(data) && data[off] == closing {
+				p.failAt(off, "looking for beginning of value")
+			}
+		case c == ']' || c == '}':
+		case c == '"' || c == '{' || c == '[' || c == '-' || '0' <= c && c <= '9' || 'a' <= c && c <= 'z':
+			// Missing comma, go on with the next member.
+			p.failAt(off, after)
+		default:
+			p.failAt(off, after)
+			if off = p.skip(off); off < len(data) && data[off] == ',' {
+				off = skipSpace(data, off+1)
+			}
+		}
+	}
+	n.orig = n.Children[:len(n.Children):len(n.Children)]
+	n.End = p.position(off)
+	n.Raw = data[start:off:off]
+	n.src = n.Raw
+	return off
+}
+
+// str returns the offset right after the string starting at data[off],
+// reporting the errors in it if report is set. A line break ends an
+// unterminated string.
+func (p *lenientParser) str(off int, report bool) int {
+	data := p.data
+	fail := func(off int, msg string) {
+		if report {
+			p.fail(off, msg)
+		}
+	}
+	for off++; off < len(data); off++ {
+		switch c := data[off]; {
+		case c == '"':
+			return off + 1
+		case c == '\n':
+			fail(off, "unterminated string literal")
+			return off
+		case c < ' ':
+			fail(off, "invalid character "+quoteChar(c)+" in string literal")
+		case c == '\\' && off+1 < len(data):
+			off++
+			switch data[off] {
+			case '"', '\\', '/', 'b', 'f', 'n', 'r', 't':
+			case 'u':
+				if getu4(data[off-1:]) < 0 {
+					fail(off, "invalid \\u escape in string literal")
+				}
+			default:
+				fail(off, "invalid character "+quoteChar(data[off])+" in string escape code")
+			}
+		}
+	}
+	fail(off, "unexpected end of JSON input")
+	return off
+}
+
+// skip returns the offset of the comma or the closing bracket ending the
+// malformed value at data[off] or of the end of input.
+func (p *lenientParser) skip(off int) int {
+	data := p.data
+	depth := 0
+	for off < len(data) {
+		switch data[off] {
+		case '"':
+			off = p.str(off, false)
+			continue
+		case '{', '[':
+			depth++
+		case '}', ']':
+			if depth == 0 {
+				return off
+			}
+			depth--
+		case ',':
+			if depth == 0 {
+				return off
+			}
+		}
+		off++
+	}
+	return off
+}
+
+// isWordByte reports whether c can be a part of a malformed literal.
+func isWordByte(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || c == '_'
+}
+
+// isNumberByte reports whether c can be a part of a number literal.
+func isNumberByte(c byte) bool {
+	return '0' <= c && c <= '9' || c == '-' || c == '+' || c == '.' || c == 'e' || c == 'E'
+}
@@ -0,0 +1,93 @@
+package json
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseTreeLenient(t *testing.T) {
+	for _, tc := range []struct {
+		in     string
+		value  string // the recovered value
+		errors []string
+	}{
+		{`{"a": [1, 2]}`, `{"a":[1,2]}`, nil},
+		{`{"a": 1,}`, `{"a":1}`, []string{`json: 1:9: invalid character '}' looking for beginning of value`}},
+		{`[1 2, tru, 3.]`, `[1,2]`, []string{
+			`json: 1:4: invalid character '2' after array element`,
+			`json: 1:7: invalid literal "tru"`,
+			`json: 1:12: invalid number literal "3."`,
+		}},
+		{"{\n  \"a\" 1,\n  b: 2,\n  \"c\": \"x\n}", `{"a":1,"c":"x}`, []string{
+			`json: 2:7: invalid character '1' after object key`,
+			`json: 3:3: invalid character 'b' looking for beginning of object key string`,
+			`json: 4:10: unterminated string literal`,
+		}},
+		{`{"a": [1`, `{"a":[1]}`, []string{`json: 1:9: unexpected end of JSON input`}},
+		{`{"a": [1, {"b": 2}}, "c": "\q"}`, `{"a":[1,{"b":2}]}`, []string{
+			`json: 1:19: invalid character '}' looking for ']'`,
+			`json: 1:20: invalid character ',' after top-level value`,
+		}},
+		{`[{"a": }, :, "\u12"]`, `[{},"\u12"]`, []string{
+			`json: 1:8: invalid character '}' looking for beginning of value`,
+			`json: 1:11: invalid character ':' looking for beginning of value`,
+			`json: 1:16: invalid \u escape in string literal`,
+		}},
+		{`[1] 2`, `[1]`, []string{`json: 1:5: invalid character '2' after top-level value`}},
+		{` `, ``, []string{`json: 1:2: unexpected end of JSON input`}},
+	} {
+		n, errs := ParseTreeLenient([]byte(tc.in))
+		var got []string
+		for _, err := range errs {
+			got = append(got, err.Error())
+		}
+		if !reflect.DeepEqual(got, tc.errors) {
+			t.Errorf("%q: got errors\n%q\nwant\n%q", tc.in, got, tc.errors)
+		}
+		if n == nil {
+			if tc.value != "" {
+				t.Errorf("%q: no value", tc.in)
+			}
+			continue
+		}
+		if value := lenientValue(n); value != tc.value {
+			t.Errorf("%q: got value %s, want %s", tc.in, value, tc.value)
+		}
+	}
+
+	// Valid input gives the same tree as ParseTree.
+	const in = "{\"a\": [1, {\"b\": null}],\n \"c\": \"\\u00e9\"}"
+	want, err := ParseTree([]byte(in))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, errs := ParseTreeLenient([]byte(in))
+	if errs != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, %v, want %+v", got, errs, want)
+	}
+}
+
+// lenientValue returns the compact text of the recovered node n.
+func lenientValue(n *Node) string {
+	switch n.Kind {
+	case NodeObject, NodeArray:
+		b := []byte{'['}
+		if n.Kind == NodeObject {
+			b[0] = '{'
+		}
+		for i, c := range n.Children {
+			if i > 0 {
+				b = append(b, ',')
+			}
+			if c.Key != nil {
+				b = append(append(b, c.Key.Raw...), ':')
+			}
+			b = append(b, lenientValue(c)...)
+		}
+		if n.Kind == NodeObject {
+			return string(append(b, '}'))
+		}
+		return string(append(b, ']'))
+	}
+	return string(n.Raw)
+}
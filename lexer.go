@@ -0,0 +1,399 @@
+package json
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"strconv"
+	"unicode/utf8"
+	"unsafe"
+)
+
+// A Lexer reads a JSON document from a byte slice value by value without
+// reflection, it's the decoding counterpart of AppendString and
+// AppendFloat for UnmarshalJSON methods generated by cmd/jsongen or
+// written by hand. Values are read like Unmarshal decodes them into
+// variables of basic types. The first error stops the Lexer: the calls
+// after it do nothing and return zero values, End returns the error. The
+// zero Lexer reads empty input.
+type Lexer struct {
+	data []byte
+	off  int
+	open bool // the opening brace of an object has just been read
+	err  error
+}
+
+// Reset makes l read data from the beginning.
+func (l *Lexer) Reset(data []byte) {
+	*l = Lexer{data: data}
+}
+
+// End checks that there is nothing but spaces after the values read and
+// returns the first error l has found.
+func (l *Lexer) End() error {
+	if l.peek() != 0 {
+		l.syntaxError("after top-level value")
+	}
+	return l.err
+}
+
+// fail records err if it's the first error.
+func (l *Lexer) fail(err error) {
+	if l.err == nil {
+		l.err = err
+	}
+}
+
+// syntaxError records the syntax error for the byte at the current offset
+// (or the end of input) found when looking for what.
+func (l *Lexer) syntaxError(what string) {
+	if l.err != nil {
+		return
+	}
+	if l.off >= len(l.data) {
+		l.fail(&SyntaxError{msg: "unexpected end of JSON input", Offset: int64(len(l.data))})
+		return
+	}
+	err := &SyntaxError{msg: "invalid character " + quoteChar(l.data[l.off]) + " " + what, Offset: int64(l.off) + 1}
+	locate(err, l.data, l.off, 1, 1)
+	l.fail(err)
+}
+
+// typeError skips the next value and records an UnmarshalTypeError for
+// it and the type t.
+func (l *Lexer) typeError(t reflect.Type) {
+	var what string
+	switch c := l.peek(); {
+	case c == 0:
+		l.syntaxError("looking for beginning of value")
+		return
+	case c == '{':
+		what = "object"
+	case c == '[':
+		what = "array"
+	case c == '"':
+		what = "string"
+	case c == 't' || c == 'f':
+		what = "bool"
+	case c == 'n':
+		what = "null"
+	default:
+		what = "number"
+	}
+	l.Skip()
+	l.fail(&UnmarshalTypeError{Value: what, Type: t, Offset: int64(l.off)})
+}
+
+// peek returns the first byte of the next value or token, it's 0 at the
+// end of input and after an error.
+func (l *Lexer) peek() byte {
+	if l.err != nil {
+		return 0
+	}
+	l.off = skipSpace(l.data, l.off)
+	if l.off == len(l.data) {
+		return 0
+	}
+	return l.data[l.off]
+}
+
+// literal reads the literal s expected at the current offset.
+func (l *Lexer) literal(s string) {
+	for i := range len(s) {
+		if l.off+i == len(l.data) || l.data[l.off+i] != s[i] {
+			l.off += i
+			l.syntaxError("in literal " + s + " (expecting " + quoteChar(s[i]) + ")")
+			return
+		}
+	}
+	l.off += len(s)
+}
+
+// Null reports whether the next value is null and reads it if so. Null
+// values are usually ignored: Unmarshal leaves values of basic types
+// unchanged for them.
+func (l *Lexer) Null() bool {
+	if l.peek() != 'n' {
+		return false
+	}
+	l.literal("null")
+	return l.err == nil
+}
+
+// Object reads the opening brace of the next value and reports whether
+// it's an object. For other values l fails with an UnmarshalTypeError for
+// the type t being decoded.
+func (l *Lexer) Object(t reflect.Type) bool {
+	if l.peek() != '{' {
+		l.typeError(t)
+		return false
+	}
+	l.off++
+	l.open = true
+	return true
+}
+
+// More reports whether there is one more member in the object being read,
+// it reads the comma before it or the closing brace after the last one.
+func (l *Lexer) More() bool {
+	c := l.peek()
+	switch {
+	case c == '}':
+		l.off++
+		l.open = false
+		return false
+	case l.open:
+		l.open = false
+		return true
+	case c == ',':
+		l.off++
+		return true
+	}
+	l.syntaxError("after object key:value pair")
+	return false
+}
+
+// Key reads the name of the next object member and the colon after it.
+// The result is only valid until the next call of l.
+func (l *Lexer) Key() []byte {
+	if l.peek() != '"' {
+		l.syntaxError("looking for beginning of object key string")
+		return nil
+	}
+	key := l.str()
+	if l.peek() != ':' {
+		l.syntaxError("after object key")
+		return nil
+	}
+	l.off++
+	return key
+}
+
+// str reads the string at the current offset and returns its contents,
+// which may share the memory of the input.
+func (l *Lexer) str() []byte {
+	start := l.off
+	if !l.skipString() {
+		return nil
+	}
+	s := l.data[start:l.off]
+	if bytes.IndexByte(s, '\\') >= 0 || !utf8.Valid(s) {
+		b, _ := unquoteBytes(s)
+		return b
+	}
+	return s[1 : len(s)-1]
+}
+
+// skipString moves past the string at the current offset checking it, it
+// reports whether the string is valid.
+func (l *Lexer) skipString() bool {
+	data := l.data
+	for i := l.off + 1; i < len(data); i++ {
+		switch c := data[i]; {
+		case c == '"':
+			l.off = i + 1
+			return true
+		case c == '\\':
+			if i+1 < len(data) {
+				switch data[i+1] {
+				case '"', '\\', '/', 'b', 'f', 'n', 'r', 't':
+					i++
+					continue
+				case 'u':
+					if getu4(data[i:]) >= 0 {
+						i += 5
+						continue
+					}
+				}
+			}
+			l.off = min(i+1, len(data))
+			l.syntaxError("in string escape code")
+			return false
+		case c < ' ':
+			l.off = i
+			l.syntaxError("in string literal")
+			return false
+		}
+	}
+	l.off = len(data)
+	l.syntaxError("")
+	return false
+}
+
+// number reads the number at the current offset, the result shares the
+// memory of the input, so it must not be kept.
+func (l *Lexer) number() string {
+	start := l.off
+	for l.off < len(l.data) && isNumberByte(l.data[l.off]) {
+		l.off++
+	}
+	s := unsafe.String(unsafe.SliceData(l.data[start:]), l.off-start)
+	if !isValidNumber(s) {
+		l.off = start
+		l.syntaxError("looking for beginning of value")
+		return ""
+	}
+	return s
+}
+
+// isNumberStart reports whether c starts a number.
+func isNumberStart(c byte) bool {
+	return c == '-' || '0' <= c && c <= '9'
+}
+
+var (
+	stringType = reflect.TypeFor[string]()
+	boolType   = reflect.TypeFor[bool]()
+	intTypes   = map[int]reflect.Type{0: reflect.TypeFor[int](), 8: reflect.TypeFor[int8](), 16: reflect.TypeFor[int16](), 32: reflect.TypeFor[int32](), 64: reflect.TypeFor[int64]()}
+	uintTypes  = map[int]reflect.Type{0: reflect.TypeFor[uint](), 8: reflect.TypeFor[uint8](), 16: reflect.TypeFor[uint16](), 32: reflect.TypeFor[uint32](), 64: reflect.TypeFor[uint64]()}
+	floatTypes = map[int]reflect.Type{32: reflect.TypeFor[float32](), 64: reflect.TypeFor[float64]()}
+)
+
+// ReadString reads the next value, which must be a string.
+func (l *Lexer) ReadString() string {
+	if l.peek() != '"' {
+		l.typeError(stringType)
+		return ""
+	}
+	return string(l.str())
+}
+
+// ReadBool reads the next value, which must be true or false.
+func (l *Lexer) ReadBool() bool {
+	switch l.peek() {
+	case 't':
+		l.literal("true")
+		return l.err == nil
+	case 'f':
+		l.literal("false")
+		return false
+	}
+	l.typeError(boolType)
+	return false
+}
+
+// ReadInt reads the next value, which must be an integer fitting into
+// bits (8, 16, 32 or 64) bits or into int if bits is 0. Errors name the
+// type of that size.
+func (l *Lexer) ReadInt(bits int) int64 {
+	if !isNumberStart(l.peek()) {
+		l.typeError(intTypes[bits])
+		return 0
+	}
+	s := l.number()
+	n, err := strconv.ParseInt(s, 10, bits)
+	if err != nil && l.err == nil {
+		l.fail(&UnmarshalTypeError{Value: "number " + s, Type: intTypes[bits], Offset: int64(l.off)})
+	}
+	return n
+}
+
+// ReadUint is like ReadInt, but for unsigned integers.
+func (l *Lexer) ReadUint(bits int) uint64 {
+	if !isNumberStart(l.peek()) {
+		l.typeError(uintTypes[bits])
+		return 0
+	}
+	s := l.number()
+	n, err := strconv.ParseUint(s, 10, bits)
+	if err != nil && l.err == nil {
+		l.fail(&UnmarshalTypeError{Value: "number " + s, Type: uintTypes[bits], Offset: int64(l.off)})
+	}
+	return n
+}
+
+// ReadFloat reads the next value, which must be a number in the range of
+// floats of the given size (32 or 64 bits).
+func (l *Lexer) ReadFloat(bits int) float64 {
+	if !isNumberStart(l.peek()) {
+		l.typeError(floatTypes[bits])
+		return 0
+	}
+	s := l.number()
+	f, err := strconv.ParseFloat(s, bits)
+	if err != nil && l.err == nil {
+		l.fail(&UnmarshalTypeError{Value: "number " + s, Type: floatTypes[bits], Offset: int64(l.off)})
+	}
+	return f
+}
+
+// Quoted reads the next value, which must be a string or null, and returns
+// its contents for fields with the "string" option, they're usually read
+// with another Lexer. It returns nil for null.
+func (l *Lexer) Quoted() []byte {
+	if c := l.peek(); c != '"' {
+		if c == 'n' {
+			l.literal("null")
+		} else if c != 0 {
+			l.Skip()
+			l.fail(errors.New("json: invalid use of ,string struct tag, trying to unmarshal unquoted value"))
+		} else {
+			l.syntaxError("looking for beginning of value")
+		}
+		return nil
+	}
+	return l.str()
+}
+
+// Raw returns the next value as is, it's checked in part only, so it's
+// meant to be passed to Unmarshal.
+func (l *Lexer) Raw() []byte {
+	start := l.skip()
+	if l.err != nil {
+		return nil
+	}
+	return l.data[start:l.off]
+}
+
+// Skip moves past the next value checking it.
+func (l *Lexer) Skip() {
+	start := l.skip()
+	if l.err != nil {
+		return
+	}
+	var scan scanner
+	if err := checkValid(l.data[start:l.off], &scan); err != nil {
+		if se, ok := err.(*SyntaxError); ok { //nolint:errorlint // checkValid returns it as is.
+			locate(se, l.data, start+max(int(se.Offset)-1, 0), 1, 1)
+			se.Offset += int64(start)
+		}
+		l.fail(err)
+	}
+}
+
+// skip moves past the next value following the brackets and strings and
+// returns the offset of its start.
+func (l *Lexer) skip() int {
+	if c := l.peek(); c == 0 || c == '}' || c == ']' || c == ',' || c == ':' {
+		l.syntaxError("looking for beginning of value")
+		return l.off
+	}
+	start, depth := l.off, 0
+	for l.err == nil && l.off < len(l.data) {
+		switch c := l.data[l.off]; {
+		case c == '"':
+			l.skipString()
+		case c == '{' || c == '[':
+			depth++
+			l.off++
+		case c == '}' || c == ']':
+			depth--
+			l.off++
+		case isSpace(c) || c == ',' || c == ':':
+			l.off++
+		case isWordByte(c) || isNumberByte(c):
+			for l.off < len(l.data) && (isWordByte(l.data[l.off]) || isNumberByte(l.data[l.off])) {
+				l.off++
+			}
+		default:
+			l.syntaxError("looking for beginning of value")
+		}
+		if depth == 0 {
+			return start
+		}
+	}
+	if depth > 0 {
+		l.syntaxError("")
+	}
+	return start
+}
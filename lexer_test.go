@@ -0,0 +1,189 @@
+package json
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// lexValue reads data as a single value of the type of ptr with a Lexer.
+func lexValue(data []byte, ptr any) error {
+	var l Lexer
+	l.Reset(data)
+	if !l.Null() {
+		switch p := ptr.(type) {
+		case *string:
+			*p = l.ReadString()
+		case *bool:
+			*p = l.ReadBool()
+		case *int8:
+			*p = int8(l.ReadInt(8))
+		case *int:
+			*p = int(l.ReadInt(0))
+		case *int64:
+			*p = l.ReadInt(64)
+		case *uint16:
+			*p = uint16(l.ReadUint(16))
+		case *float32:
+			*p = float32(l.ReadFloat(32))
+		case *float64:
+			*p = l.ReadFloat(64)
+		}
+	}
+	return l.End()
+}
+
+func TestLexerValues(t *testing.T) {
+	for _, tc := range []struct {
+		in  string
+		new func() any
+	}{
+		{`"aé\n😀"`, func() any { return new(string) }},
+		{` "plain" `, func() any { return new(string) }},
+		{`"\xff"`, func() any { return new(string) }},
+		{`"a\x"`, func() any { return new(string) }},
+		{"\"a\x01\"", func() any { return new(string) }},
+		{`"abc`, func() any { return new(string) }},
+		{`1`, func() any { return new(string) }},
+		{`null`, func() any { return new(string) }},
+		{`nul`, func() any { return new(string) }},
+		{`true`, func() any { return new(bool) }},
+		{`false`, func() any { return new(bool) }},
+		{`tru`, func() any { return new(bool) }},
+		{`"true"`, func() any { return new(bool) }},
+		{`-128`, func() any { return new(int8) }},
+		{`128`, func() any { return new(int8) }},
+		{`1.0`, func() any { return new(int8) }},
+		{`-9223372036854775808`, func() any { return new(int64) }},
+		{`9223372036854775807`, func() any { return new(int) }},
+		{`9223372036854775808`, func() any { return new(int) }},
+		{`4294967296`, func() any { return new(int) }},
+		{`01`, func() any { return new(int64) }},
+		{`-`, func() any { return new(int64) }},
+		{`[1]`, func() any { return new(int64) }},
+		{`65535`, func() any { return new(uint16) }},
+		{`-1`, func() any { return new(uint16) }},
+		{`1e40`, func() any { return new(float32) }},
+		{`-1.5e-3`, func() any { return new(float64) }},
+		{`1e999`, func() any { return new(float64) }},
+		{`{}`, func() any { return new(float64) }},
+		{`1 2`, func() any { return new(float64) }},
+		{``, func() any { return new(float64) }},
+	} {
+		want, got := tc.new(), tc.new()
+		wantErr := Unmarshal([]byte(tc.in), want)
+		err := lexValue([]byte(tc.in), got)
+		if (err != nil) != (wantErr != nil) {
+			t.Errorf("%s: got error %v, want %v", tc.in, err, wantErr)
+			continue
+		}
+		if err == nil && !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: got %v, want %v", tc.in, reflect.ValueOf(got).Elem(), reflect.ValueOf(want).Elem())
+		}
+		var (
+			se     *SyntaxError
+			te, ge *UnmarshalTypeError
+		)
+		if errors.As(wantErr, &se) && !errors.As(err, &se) ||
+			errors.As(wantErr, &te) && (!errors.As(err, &ge) || ge.Type != te.Type) {
+			t.Errorf("%s: got error %v, want %v", tc.in, err, wantErr)
+		}
+	}
+}
+
+func TestLexerObject(t *testing.T) {
+	type pair struct {
+		A string
+		B []int
+	}
+	// read decodes an object into a pair like generated UnmarshalJSON
+	// methods do it (without case-insensitive matching).
+	read := func(data string) (pair, error) {
+		var (
+			l Lexer
+			p pair
+		)
+		l.Reset([]byte(data))
+		if !l.Null() && l.Object(reflect.TypeFor[pair]()) {
+			for l.More() {
+				switch string(l.Key()) {
+				case "A":
+					if !l.Null() {
+						p.A = l.ReadString()
+					}
+				case "B":
+					if raw := l.Raw(); raw != nil {
+						if err := Unmarshal(raw, &p.B); err != nil {
+							return p, err
+						}
+					}
+				default:
+					l.Skip()
+				}
+			}
+		}
+		return p, l.End()
+	}
+	for _, in := range []string{
+		`{"A": "a", "B": [1, 2], "C": {"x": [true, null, "]"]}}`,
+		` { } `,
+		`null`,
+		`{"B": null, "A": null}`,
+		`{"A": "a",}`,
+		`{"A": "a" "B": []}`,
+		`{"A" "a"}`,
+		`{A: "a"}`,
+		`{"C": [1, 2}`,
+		`{"C": [1, 2]`,
+		`{"C": -}`,
+		`{"A": "a"}}`,
+		`[]`,
+		`"a"`,
+	} {
+		var want pair
+		wantErr := Unmarshal([]byte(in), &want)
+		got, err := read(in)
+		if (err != nil) != (wantErr != nil) {
+			t.Errorf("%s: got error %v, want %v", in, err, wantErr)
+			continue
+		}
+		if err == nil && !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: got %+v, want %+v", in, got, want)
+		}
+		var te *UnmarshalTypeError
+		if errors.As(wantErr, &te) && !errors.As(err, &te) {
+			t.Errorf("%s: got error %v, want %v", in, err, wantErr)
+		}
+	}
+}
+
+func TestLexerQuoted(t *testing.T) {
+	for _, tc := range []struct {
+		in   string
+		want int64
+		ok   bool
+	}{
+		{`"12"`, 12, true},
+		{`null`, 0, true},
+		{`12`, 0, false},
+		{`"1 2"`, 0, false},
+		{`"x"`, 0, false},
+	} {
+		var (
+			l Lexer
+			n int64
+		)
+		l.Reset([]byte(tc.in))
+		if s := l.Quoted(); s != nil {
+			var q Lexer
+			q.Reset(s)
+			n = q.ReadInt(64)
+			if err := q.End(); err != nil {
+				l.fail(err)
+			}
+		}
+		if err := l.End(); (err == nil) != tc.ok || tc.ok && n != tc.want {
+			t.Errorf("%s: got %d, error %v", tc.in, n, err)
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package json
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"strconv"
+)
+
+// A LinesDecoder reads and decodes newline-delimited JSON (also known as
+// JSON Lines or NDJSON) where every line of the input holds exactly one
+// JSON value. Empty (or whitespace-only) lines are skipped. A malformed
+// line doesn't affect subsequent ones, so decoding can continue after an
+// error.
+type LinesDecoder struct {
+	r    *bufio.Reader
+	d    decodeState
+	buf  []byte
+	line int
+	err  error
+}
+
+// A LineError describes an error found while decoding a particular line
+// of the LinesDecoder input.
+type LineError struct {
+	Line int   // line number starting from 1
+	Err  error // decoding error
+}
+
+func (e *LineError) Error() string {
+	return "line " + strconv.Itoa(e.Line) + ": " + e.Err.Error()
+}
+
+func (e *LineError) Unwrap() error { return e.Err }
+
+// NewLinesDecoder returns a new decoder that reads JSON lines from r.
+func NewLinesDecoder(r io.Reader) *LinesDecoder {
+	return &LinesDecoder{r: bufio.NewReader(r)}
+}
+
+// UseNumber causes the LinesDecoder to unmarshal a number into an any as a
+// Number instead of as a float64.
+func (dec *LinesDecoder) UseNumber() { dec.d.useNumber = true }
+
+// UseOrderedObject causes the LinesDecoder to unmarshal an object into an
+// any as a OrderedObject instead of as a map[string]any.
+func (dec *LinesDecoder) UseOrderedObject() { dec.d.useOrderedObject = true }
+
+// SetDuplicateKeyPolicy specifies how objects with repeated keys are
+// decoded, see Decoder.SetDuplicateKeyPolicy.
+func (dec *LinesDecoder) SetDuplicateKeyPolicy(p DuplicateKeyPolicy) {
+	dec.d.duplicateKeys = p
+}
+
+// SetMaxDepth limits the nesting of arrays and objects, see
+// Decoder.SetMaxDepth.
+func (dec *LinesDecoder) SetMaxDepth(n int) {
+	dec.d.scan.maxDepth = n
+}
+
+// SetLimits restricts the size of the input, see Decoder.SetLimits.
+// MaxBytes applies to every line excluding the line terminator.
+func (dec *LinesDecoder) SetLimits(l Limits) {
+	dec.d.scan.limits = l
+}
+
+// Line returns the number of the line holding the value returned by the
+// last Decode call.
+func (dec *LinesDecoder) Line() int {
+	return dec.line
+}
+
+// Decode reads the next line from its input and stores the value it holds
+// in the value pointed to by v. It returns io.EOF when there are no more
+// values. Errors specific to the line are returned as a *LineError, after
+// them Decode can be called again to proceed to the next line.
+//
+// See the documentation for Unmarshal for details about
+// the conversion of JSON into a Go value.
+func (dec *LinesDecoder) Decode(v any) error {
+	if dec.err != nil {
+		return dec.err
+	}
+	for {
+		line, err := dec.readLine()
+		if err != nil {
+			if !errors.Is(err, io.EOF) || len(line) == 0 {
+				return err
+			}
+		}
+		if !nonSpace(line) {
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		if err := checkValid(line, &dec.d.scan); err != nil {
+			return &LineError{Line: dec.line, Err: err}
+		}
+		dec.d.init(line)
+		if err := dec.d.unmarshal(v); err != nil {
+			return &LineError{Line: dec.line, Err: err}
+		}
+		return nil
+	}
+}
+
+// readLine returns the next line without its terminator. An error
+// is returned along with the last line (if it's not terminated).
+func (dec *LinesDecoder) readLine() ([]byte, error) {
+	dec.buf = dec.buf[:0]
+	dec.line++
+	limit := dec.d.scan.limits.MaxBytes
+	for {
+		chunk, err := dec.r.ReadSlice('\n')
+		if len(dec.buf) > 0 || errors.Is(err, bufio.ErrBufferFull) {
+			dec.buf = append(dec.buf, chunk...)
+			chunk = dec.buf
+		}
+		if !errors.Is(err, bufio.ErrBufferFull) {
+			line := bytes.TrimSuffix(bytes.TrimSuffix(chunk, []byte{'\n'}), []byte{'\r'})
+			if err != nil {
+				dec.err = err
+			}
+			if limit > 0 && int64(len(line)) > limit {
+				return nil, &LineError{Line: dec.line, Err: &LimitError{Limit: "MaxBytes", Max: limit, Offset: limit}}
+			}
+			return line, err
+		}
+		if limit > 0 && int64(len(dec.buf)) > limit+2 {
+			// Skip the rest of the line so that the next one can be read.
+			for errors.Is(err, bufio.ErrBufferFull) {
+				_, err = dec.r.ReadSlice('\n')
+			}
+			if err != nil {
+				dec.err = err
+			}
+			return nil, &LineError{Line: dec.line, Err: &LimitError{Limit: "MaxBytes", Max: limit, Offset: limit}}
+		}
+	}
+}
+
+// NewLinesEncoder returns a new encoder that writes newline-delimited JSON
+// to w: every value is written in compact form followed by a newline.
+// Indentation set with SetIndent is ignored.
+func NewLinesEncoder(w io.Writer) *Encoder {
+	enc := NewEncoder(w)
+	enc.lines = true
+	return enc
+}
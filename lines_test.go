@@ -0,0 +1,69 @@
+package json
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLinesDecoder(t *testing.T) {
+	const in = "{\"a\": 1}\r\n\n  \n[1,\n\"x\"\n" + `{"b": true}` + "\n1 2\n" + `"last"`
+	dec := NewLinesDecoder(strings.NewReader(in))
+	dec.UseOrderedObject()
+	for _, tc := range []struct {
+		line int
+		want any
+		err  bool
+	}{
+		{1, OrderedObject{{"a", float64(1)}}, false},
+		{4, nil, true},
+		{5, "x", false},
+		{6, OrderedObject{{"b", true}}, false},
+		{7, nil, true},
+		{8, "last", false},
+	} {
+		var v any
+		err := dec.Decode(&v)
+		var le *LineError
+		switch {
+		case tc.err && (!errors.As(err, &le) || le.Line != tc.line):
+			t.Errorf("line %d: got error %v, want LineError", tc.line, err)
+		case !tc.err && err != nil:
+			t.Errorf("line %d: unexpected error %v", tc.line, err)
+		case !tc.err && (dec.Line() != tc.line || !reflect.DeepEqual(v, tc.want)):
+			t.Errorf("line %d: got %#v at line %d, want %#v", tc.line, v, dec.Line(), tc.want)
+		}
+	}
+	var v any
+	if err := dec.Decode(&v); !errors.Is(err, io.EOF) {
+		t.Errorf("Decode at the end: got %v, want EOF", err)
+	}
+
+	dec = NewLinesDecoder(strings.NewReader(`"` + strings.Repeat("x", 5000) + "\"\n42\n"))
+	dec.SetLimits(Limits{MaxBytes: 100})
+	var le *LimitError
+	if err := dec.Decode(&v); !errors.As(err, &le) {
+		t.Errorf("Decode of a long line: got %v, want LimitError", err)
+	}
+	if err := dec.Decode(&v); err != nil || v != float64(42) || dec.Line() != 2 {
+		t.Errorf("Decode after a long line: got %v, %v at line %d", v, err, dec.Line())
+	}
+}
+
+func TestLinesEncoder(t *testing.T) {
+	var buf bytes.Buffer
+	enc := NewLinesEncoder(&buf)
+	enc.SetIndent("", "  ")
+	for _, v := range []any{map[string]any{"a": []int{1, 2}}, "multi\nline", 3} {
+		if err := enc.Encode(v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	const want = "{\"a\":[1,2]}\n\"multi\\nline\"\n3\n"
+	if got := buf.String(); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,87 @@
+package json
+
+import "reflect"
+
+// MergePatch applies the JSON Merge Patch (RFC 7386) patch to target and
+// returns the result: null members of the patch remove the corresponding
+// members of the target, object members are merged recursively and other
+// values replace the target ones. Existing members keep their positions,
+// new ones are added after them in the patch order. Nested objects are
+// expected to be OrderedObject values like the ones decoded with
+// UseOrderedObject, everything else is treated as opaque values. Neither
+// target nor patch is modified, but the result shares unchanged values
+// with them.
+func MergePatch(target, patch OrderedObject) OrderedObject {
+	res := make(OrderedObject, 0, len(target)+len(patch))
+	res = append(res, target...)
+	for _, m := range patch {
+		i := memberIndex(res, m.Key)
+		if m.Value == nil {
+			if i >= 0 {
+				res = append(res[:i:i], res[i+1:]...)
+			}
+			continue
+		}
+		value := m.Value
+		if p, ok := value.(OrderedObject); ok {
+			var t OrderedObject
+			if i >= 0 {
+				t, _ = res[i].Value.(OrderedObject)
+			}
+			value = MergePatch(t, p)
+		}
+		if i >= 0 {
+			res[i].Value = value
+		} else {
+			res = append(res, Member{m.Key, value})
+		}
+	}
+	return res
+}
+
+// CreateMergePatch returns the JSON Merge Patch (RFC 7386) turning original
+// into modified when applied with MergePatch. Changed object members are
+// described recursively and other changed values are replaced as a whole,
+// members are compared regardless of their order since merge patches can't
+// reorder them. Merge patches can't set values to null either, so null
+// members of modified are removed by the patch or not added at all.
+func CreateMergePatch(original, modified OrderedObject) OrderedObject {
+	patch := OrderedObject{}
+	for _, m := range original {
+		if memberIndex(modified, m.Key) < 0 {
+			patch = append(patch, Member{m.Key, nil})
+		}
+	}
+	for _, m := range modified {
+		i := memberIndex(original, m.Key)
+		if i < 0 {
+			if m.Value != nil {
+				patch = append(patch, Member{m.Key, m.Value})
+			}
+			continue
+		}
+		old := original[i].Value
+		o, ok1 := old.(OrderedObject)
+		n, ok2 := m.Value.(OrderedObject)
+		switch {
+		case ok1 && ok2:
+			if p := CreateMergePatch(o, n); len(p) > 0 {
+				patch = append(patch, Member{m.Key, p})
+			}
+		case !reflect.DeepEqual(old, m.Value):
+			patch = append(patch, Member{m.Key, m.Value})
+		}
+	}
+	return patch
+}
+
+// memberIndex returns the position of the first member of o with the key or
+// -1.
+func memberIndex(o OrderedObject, key string) int {
+	for i := range o {
+		if o[i].Key == key {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,64 @@
+package json
+
+import "testing"
+
+func TestMergePatch(t *testing.T) {
+	// Test cases of RFC 7386 Appendix A with objects at the top level.
+	for _, tc := range []struct {
+		target, patch, want string
+	}{
+		{`{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"b"}`, `{"b":"c"}`, `{"a":"b","b":"c"}`},
+		{`{"a":"b"}`, `{"a":null}`, `{}`},
+		{`{"a":"b","b":"c"}`, `{"a":null}`, `{"b":"c"}`},
+		{`{"a":["b"]}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"c"}`, `{"a":["b"]}`, `{"a":["b"]}`},
+		{`{"a":{"b":"c"}}`, `{"a":{"b":"d","c":null}}`, `{"a":{"b":"d"}}`},
+		{`{"a":[{"b":"c"}]}`, `{"a":[1]}`, `{"a":[1]}`},
+		{`{"e":null}`, `{"a":1}`, `{"e":null,"a":1}`},
+		{`{}`, `{"a":{"bb":{"ccc":null}}}`, `{"a":{"bb":{}}}`},
+		{`{"a":"x"}`, `{"a":{"b":null,"c":1}}`, `{"a":{"c":1}}`},
+		// Order of members.
+		{`{"z":1,"y":{"b":1,"a":2},"x":3}`, `{"w":0,"y":{"c":3,"a":null},"z":null}`, `{"y":{"b":1,"c":3},"x":3,"w":0}`},
+	} {
+		target := decodeOrdered(t, tc.target).(OrderedObject)
+		orig, _ := Marshal(target)
+		patch := decodeOrdered(t, tc.patch).(OrderedObject)
+		got, err := Marshal(MergePatch(target, patch))
+		if err != nil || string(got) != tc.want {
+			t.Errorf("%s + %s: got %s, %v, want %s", tc.target, tc.patch, got, err, tc.want)
+		}
+		if after, _ := Marshal(target); string(after) != string(orig) {
+			t.Errorf("%s + %s: target modified to %s", tc.target, tc.patch, after)
+		}
+	}
+}
+
+func TestCreateMergePatch(t *testing.T) {
+	for _, tc := range []struct {
+		original, modified, want string
+	}{
+		{`{}`, `{}`, `{}`},
+		{`{"a":1,"b":[1]}`, `{"b":[1],"a":1}`, `{}`},
+		{`{"a":1}`, `{"a":2}`, `{"a":2}`},
+		{`{"a":1,"b":2}`, `{"b":2,"c":3}`, `{"a":null,"c":3}`},
+		{`{"a":{"b":1,"c":{"d":2}}}`, `{"a":{"c":{"d":3},"b":1}}`, `{"a":{"c":{"d":3}}}`},
+		{`{"a":{"b":1}}`, `{"a":[1]}`, `{"a":[1]}`},
+		{`{"a":[1,{"b":1}]}`, `{"a":[1,{"b":2}]}`, `{"a":[1,{"b":2}]}`},
+		{`{"a":1}`, `{"a":null,"b":null}`, `{"a":null}`},
+		{`{"a":null}`, `{"a":null}`, `{}`},
+	} {
+		original := decodeOrdered(t, tc.original).(OrderedObject)
+		modified := decodeOrdered(t, tc.modified).(OrderedObject)
+		patch := CreateMergePatch(original, modified)
+		got, err := Marshal(patch)
+		if err != nil || string(got) != tc.want {
+			t.Errorf("%s -> %s: got %s, %v, want %s", tc.original, tc.modified, got, err, tc.want)
+		}
+		res, _ := Marshal(MergePatch(original, patch))
+		want, _ := Marshal(CreateMergePatch(MergePatch(original, patch), modified))
+		if string(want) != "{}" {
+			t.Errorf("%s -> %s: patching results in %s", tc.original, tc.modified, res)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package json
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// NamingConvention defines how the names of struct fields without a name
+// given in their tag are converted to JSON object member names, see
+// Encoder.SetNaming and Decoder.SetNaming.
+type NamingConvention int
+
+const (
+	// KeepNames uses field names as they are.
+	KeepNames NamingConvention = iota
+	// SnakeCase converts names to lowercase words separated by
+	// underscores, UserID and HTTPServer become user_id and http_server.
+	SnakeCase
+	// CamelCase converts names so that the first word is lowercase and
+	// every other one is capitalized, UserID and HTTPServer become userID
+	// and httpServer.
+	CamelCase
+	// PascalCase is like CamelCase, but capitalizes the first word too,
+	// so it only changes names containing underscores (User_name becomes
+	// UserName).
+	PascalCase
+
+	numNamingConventions
+)
+
+// valid returns c if it's a known convention and KeepNames otherwise.
+func (c NamingConvention) valid() NamingConvention {
+	if c < KeepNames || c >= numNamingConventions {
+		return KeepNames
+	}
+	return c
+}
+
+// fieldName is a member name of a field in one of the naming conventions.
+type fieldName struct {
+	name      string
+	nameBytes []byte                 // []byte(name)
+	equalFold func(s, t []byte) bool // bytes.EqualFold or equivalent
+}
+
+// conventionalNames returns the names name is converted to by all the
+// NamingConventions except for KeepNames.
+func conventionalNames(name string) []fieldName {
+	words := splitWords(name)
+	names := make([]fieldName, numNamingConventions-1)
+	for c := SnakeCase; c < numNamingConventions; c++ {
+		n := convertName(words, c)
+		names[c-1] = fieldName{n, []byte(n), foldFunc([]byte(n))}
+	}
+	return names
+}
+
+// splitWords splits a Go identifier into words at underscores, at lowercase
+// to uppercase transitions and before the last capital letter of an
+// acronym followed by a lowercase letter (HTTPServer is HTTP and Server).
+// Digits belong to the word preceding them.
+func splitWords(name string) []string {
+	var (
+		words []string
+		runes = []rune(name)
+		start = 0
+	)
+	for i, r := range runes {
+		switch {
+		case r == '_':
+			if start < i {
+				words = append(words, string(runes[start:i]))
+			}
+			start = i + 1
+		case i > start && unicode.IsUpper(r) &&
+			(!unicode.IsUpper(runes[i-1]) || i+1 < len(runes) && unicode.IsLower(runes[i+1])):
+			words = append(words, string(runes[start:i]))
+			start = i
+		}
+	}
+	if start < len(runes) {
+		words = append(words, string(runes[start:]))
+	}
+	return words
+}
+
+// convertName joins words according to c.
+func convertName(words []string, c NamingConvention) string {
+	var b strings.Builder
+	for i, w := range words {
+		switch {
+		case c == SnakeCase:
+			if i > 0 {
+				b.WriteByte('_')
+			}
+			b.WriteString(strings.ToLower(w))
+		case c == CamelCase && i == 0:
+			b.WriteString(strings.ToLower(w))
+		default:
+			r, size := utf8.DecodeRuneInString(w)
+			b.WriteRune(unicode.ToUpper(r))
+			b.WriteString(w[size:])
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,82 @@
+package json
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestConventionalNames(t *testing.T) {
+	for _, tc := range []struct {
+		name                 string
+		snake, camel, pascal string
+	}{
+		{"Name", "name", "name", "Name"},
+		{"UserID", "user_id", "userID", "UserID"},
+		{"HTTPServer", "http_server", "httpServer", "HTTPServer"},
+		{"ID", "id", "id", "ID"},
+		{"Block2Hash", "block2_hash", "block2Hash", "Block2Hash"},
+		{"User_name", "user_name", "userName", "UserName"},
+		{"ÜberX", "über_x", "überX", "ÜberX"},
+	} {
+		names := conventionalNames(tc.name)
+		got := []string{names[SnakeCase-1].name, names[CamelCase-1].name, names[PascalCase-1].name}
+		if want := []string{tc.snake, tc.camel, tc.pascal}; !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: got %q, want %q", tc.name, got, want)
+		}
+	}
+}
+
+func TestNaming(t *testing.T) {
+	type Inner struct {
+		BlockHash string
+	}
+	type T struct {
+		UserID int
+		Tagged string `json:"Tagged_Name"`
+		TTL    int    `json:",omitempty"`
+		Inner
+	}
+	v := T{1, "t", 2, Inner{"h"}}
+	for _, tc := range []struct {
+		c    NamingConvention
+		want string
+	}{
+		{KeepNames, `{"UserID":1,"Tagged_Name":"t","TTL":2,"BlockHash":"h"}`},
+		{SnakeCase, `{"user_id":1,"Tagged_Name":"t","ttl":2,"block_hash":"h"}`},
+		{CamelCase, `{"userID":1,"Tagged_Name":"t","ttl":2,"blockHash":"h"}`},
+		{PascalCase, `{"UserID":1,"Tagged_Name":"t","TTL":2,"BlockHash":"h"}`},
+		{100, `{"UserID":1,"Tagged_Name":"t","TTL":2,"BlockHash":"h"}`},
+	} {
+		var buf bytes.Buffer
+		enc := NewEncoder(&buf)
+		enc.SetNaming(tc.c)
+		if err := enc.Encode(v); err != nil {
+			t.Fatal(err)
+		}
+		if got := strings.TrimSuffix(buf.String(), "\n"); got != tc.want {
+			t.Errorf("naming %d: got %s, want %s", tc.c, got, tc.want)
+		}
+
+		var got T
+		dec := NewDecoder(&buf)
+		dec.SetNaming(tc.c)
+		if err := dec.Decode(&got); err != nil {
+			t.Fatal(err)
+		}
+		if got != v {
+			t.Errorf("naming %d: decoded %+v, want %+v", tc.c, got, v)
+		}
+	}
+
+	var got T
+	dec := NewDecoder(strings.NewReader(`{"User_ID": 5, "UserID": 6}`))
+	dec.SetNaming(SnakeCase)
+	if err := dec.Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	if got.UserID != 5 {
+		t.Errorf("got UserID %d, want 5", got.UserID)
+	}
+}
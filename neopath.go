@@ -0,0 +1,414 @@
+package json
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Limits of the JSONPath dialect used by Neo oracle nodes to filter
+// responses: NeoPathMaxDepth is the maximum number of steps descending into
+// values, NeoPathMaxObjects is the maximum number of values selected by
+// any step and NeoFilterMaxSize is the maximum size of the filtered result.
+const (
+	NeoPathMaxDepth   = 6
+	NeoPathMaxObjects = 1024
+	NeoFilterMaxSize  = 0xFFFF
+)
+
+// A NeoPathError is returned by NeoPath and NeoFilter for a path that is
+// invalid or selects too many values.
+type NeoPathError struct {
+	Path string
+}
+
+func (e *NeoPathError) Error() string {
+	return "json: invalid or too broad JSONPath " + strconv.Quote(e.Path)
+}
+
+// NeoFilter applies the JSONPath path to the JSON document data and returns
+// the array of the selected values, exactly like Neo oracle nodes filter
+// responses, so that results are byte-compatible. data must be a single
+// valid UTF-8 JSON value, objects are kept in their original order and the
+// result is encoded like Marshal does it. A result larger than
+// NeoFilterMaxSize yields a *SizeError.
+func NeoFilter(data []byte, path string) ([]byte, error) {
+	var v any
+	if err := UnmarshalWithOptions(data, &v, Options{UseOrderedObject: true, DisallowInvalidUTF8: true}); err != nil {
+		return nil, err
+	}
+	res, err := NeoPath(v, path)
+	if err != nil {
+		return nil, err
+	}
+	b, err := Marshal(res)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) > NeoFilterMaxSize {
+		return nil, &SizeError{Limit: NeoFilterMaxSize}
+	}
+	return b, nil
+}
+
+// NeoPath returns the values selected by the JSONPath path in v which
+// consists of OrderedObject, []any and basic values like the ones decoded
+// with UseOrderedObject. The dialect is the restricted one of Neo:
+//
+//	$            the root value, every path starts with it
+//	.name        the member of an object
+//	['a','b']    the members of an object, names can't contain quotes
+//	.* or [*]    all members of an object or elements of an array
+//	..name       the members with the name at any depth, each level of the
+//	             values is counted as a step
+//	[0,-1]       the elements of an array, negative indices count from the end
+//	[1:3]        a slice of an array, omitted or non-positive end counts from the end
+//
+// Paths with more than NeoPathMaxDepth steps or steps selecting more than
+// NeoPathMaxObjects values yield a *NeoPathError. An empty path selects v
+// itself, no matches give an empty slice.
+func NeoPath(v any, path string) ([]any, error) {
+	if path == "" {
+		return []any{v}, nil
+	}
+	p := neoPathParser{s: path, depth: NeoPathMaxDepth}
+	if typ, _ := p.next(); typ != neoPathRoot {
+		return nil, &NeoPathError{path}
+	}
+	objs := []any{v}
+	for p.i < len(p.s) {
+		var ok bool
+		switch typ, _ := p.next(); typ {
+		case neoPathDot:
+			objs, ok = p.dot(objs)
+		case neoPathLeftBracket:
+			objs, ok = p.leftBracket(objs)
+		}
+		if !ok || len(objs) > NeoPathMaxObjects {
+			return nil, &NeoPathError{path}
+		}
+	}
+	if objs == nil {
+		objs = []any{}
+	}
+	return objs, nil
+}
+
+// neoPathToken is a type of NeoPath tokens.
+type neoPathToken byte
+
+const (
+	neoPathInvalid neoPathToken = iota
+	neoPathRoot
+	neoPathDot
+	neoPathLeftBracket
+	neoPathRightBracket
+	neoPathAsterisk
+	neoPathComma
+	neoPathColon
+	neoPathIdentifier
+	neoPathString
+	neoPathNumber
+)
+
+// neoPathParser evaluates a NeoPath path step by step.
+type neoPathParser struct {
+	s     string
+	i     int
+	depth int // descents left
+}
+
+// next returns the next token with its text for identifiers, strings
+// (including the quotes) and numbers.
+func (p *neoPathParser) next() (neoPathToken, string) {
+	if p.i >= len(p.s) {
+		return neoPathInvalid, ""
+	}
+	typ, n := neoPathInvalid, 1
+	switch c := p.s[p.i]; {
+	case c == '$':
+		typ = neoPathRoot
+	case c == '.':
+		typ = neoPathDot
+	case c == '[':
+		typ = neoPathLeftBracket
+	case c == ']':
+		typ = neoPathRightBracket
+	case c == '*':
+		typ = neoPathAsterisk
+	case c == ',':
+		typ = neoPathComma
+	case c == ':':
+		typ = neoPathColon
+	case c == '\'':
+		end := strings.IndexByte(p.s[p.i+1:], '\'')
+		if end < 0 {
+			return neoPathInvalid, ""
+		}
+		typ, n = neoPathString, end+2
+	case c == '_' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z':
+		typ = neoPathIdentifier
+		for n < len(p.s)-p.i && isNeoPathIdent(p.s[p.i+n]) {
+			n++
+		}
+	case c == '-' || '0' <= c && c <= '9':
+		typ = neoPathNumber
+		for n < len(p.s)-p.i && '0' <= p.s[p.i+n] && p.s[p.i+n] <= '9' {
+			n++
+		}
+	default:
+		return neoPathInvalid, ""
+	}
+	val := p.s[p.i : p.i+n]
+	p.i += n
+	return typ, val
+}
+
+// isNeoPathIdent reports whether c can be a part of an identifier.
+func isNeoPathIdent(c byte) bool {
+	return c == '_' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9'
+}
+
+// descend takes one step down if the depth allows it.
+func (p *neoPathParser) descend() bool {
+	if p.depth <= 0 {
+		return false
+	}
+	p.depth--
+	return true
+}
+
+// dot processes the step after a dot.
+func (p *neoPathParser) dot(objs []any) ([]any, bool) {
+	switch typ, val := p.next(); typ {
+	case neoPathAsterisk:
+		return p.children(objs)
+	case neoPathDot:
+		return p.recursive(objs)
+	case neoPathIdentifier:
+		return p.members(objs, true, val)
+	default:
+		return nil, false
+	}
+}
+
+// children returns the elements of arrays and the member values of
+// objects in objs.
+func (p *neoPathParser) children(objs []any) ([]any, bool) {
+	if !p.descend() {
+		return nil, false
+	}
+	var res []any
+	for _, obj := range objs {
+		switch obj := obj.(type) {
+		case []any:
+			if len(res)+len(obj) > NeoPathMaxObjects {
+				return nil, false
+			}
+			res = append(res, obj...)
+		case OrderedObject:
+			if len(res)+len(obj) > NeoPathMaxObjects {
+				return nil, false
+			}
+			for _, m := range obj {
+				res = append(res, m.Value)
+			}
+		}
+	}
+	return res, true
+}
+
+// recursive returns the members with the name following .. in objs and
+// all the values nested in them. Every level of the values is counted
+// against the depth.
+func (p *neoPathParser) recursive(objs []any) ([]any, bool) {
+	typ, name := p.next()
+	if typ != neoPathIdentifier {
+		return nil, false
+	}
+	var res []any
+	for len(objs) > 0 {
+		found, _ := p.members(objs, false, name)
+		if len(res)+len(found) > NeoPathMaxObjects {
+			return nil, false
+		}
+		res = append(res, found...)
+		var ok bool
+		if objs, ok = p.children(objs); !ok {
+			return nil, false
+		}
+	}
+	return res, true
+}
+
+// members returns the values of the first members with the given names
+// of the objects in objs, the step is counted against the depth if
+// counted is set.
+func (p *neoPathParser) members(objs []any, counted bool, names ...string) ([]any, bool) {
+	if counted && !p.descend() {
+		return nil, false
+	}
+	var res []any
+	for _, obj := range objs {
+		obj, ok := obj.(OrderedObject)
+		if !ok {
+			continue
+		}
+		for _, name := range names {
+			for _, m := range obj {
+				if m.Key == name {
+					if len(res)+1 > NeoPathMaxObjects {
+						return nil, false
+					}
+					res = append(res, m.Value)
+					break
+				}
+			}
+		}
+	}
+	return res, true
+}
+
+// elements returns the elements with the given indices of the arrays in
+// objs.
+func (p *neoPathParser) elements(objs []any, indices ...int) ([]any, bool) {
+	if !p.descend() {
+		return nil, false
+	}
+	var res []any
+	for _, obj := range objs {
+		arr, ok := obj.([]any)
+		if !ok {
+			continue
+		}
+		for _, i := range indices {
+			if i < 0 {
+				i += len(arr)
+			}
+			if i >= 0 && i < len(arr) {
+				if len(res)+1 > NeoPathMaxObjects {
+					return nil, false
+				}
+				res = append(res, arr[i])
+			}
+		}
+	}
+	return res, true
+}
+
+// leftBracket processes the step after [.
+func (p *neoPathParser) leftBracket(objs []any) ([]any, bool) {
+	typ, val := p.next()
+	switch typ {
+	case neoPathAsterisk:
+		if typ, _ := p.next(); typ != neoPathRightBracket {
+			return nil, false
+		}
+		return p.children(objs)
+	case neoPathColon:
+		return p.slice(objs, 0)
+	case neoPathNumber:
+		switch typ, _ := p.next(); typ {
+		case neoPathColon:
+			start, ok := neoPathIndex(val)
+			if !ok {
+				return nil, false
+			}
+			return p.slice(objs, start)
+		case neoPathComma:
+			return p.union(objs, neoPathNumber, val)
+		case neoPathRightBracket:
+			i, ok := neoPathIndex(val)
+			if !ok {
+				return nil, false
+			}
+			return p.elements(objs, i)
+		}
+	case neoPathString:
+		switch typ, _ := p.next(); typ {
+		case neoPathComma:
+			return p.union(objs, neoPathString, val)
+		case neoPathRightBracket:
+			return p.members(objs, true, val[1:len(val)-1])
+		}
+	}
+	return nil, false
+}
+
+// union processes a comma-separated list of indices or names starting
+// with first.
+func (p *neoPathParser) union(objs []any, typ neoPathToken, first string) ([]any, bool) {
+	items := []string{first}
+	for {
+		t, val := p.next()
+		if t != typ {
+			return nil, false
+		}
+		items = append(items, val)
+		t, _ = p.next()
+		if t == neoPathRightBracket {
+			break
+		}
+		if t != neoPathComma {
+			return nil, false
+		}
+	}
+	if typ == neoPathString {
+		for i, s := range items {
+			items[i] = s[1 : len(s)-1]
+		}
+		return p.members(objs, true, items...)
+	}
+	indices := make([]int, len(items))
+	for i, s := range items {
+		var ok bool
+		if indices[i], ok = neoPathIndex(s); !ok {
+			return nil, false
+		}
+	}
+	return p.elements(objs, indices...)
+}
+
+// slice processes the end of a slice starting at start.
+func (p *neoPathParser) slice(objs []any, start int) ([]any, bool) {
+	end := 0
+	typ, val := p.next()
+	if typ == neoPathNumber {
+		var ok bool
+		if end, ok = neoPathIndex(val); !ok {
+			return nil, false
+		}
+		typ, _ = p.next()
+	}
+	if typ != neoPathRightBracket || !p.descend() {
+		return nil, false
+	}
+	var res []any
+	for _, obj := range objs {
+		arr, ok := obj.([]any)
+		if !ok {
+			continue
+		}
+		i, j := start, end
+		if i < 0 {
+			i = max(i+len(arr), 0)
+		}
+		if j <= 0 {
+			j += len(arr)
+		}
+		j = min(j, len(arr))
+		if j <= i {
+			continue
+		}
+		if len(res)+j-i > NeoPathMaxObjects {
+			return nil, false
+		}
+		res = append(res, arr[i:j]...)
+	}
+	return res, true
+}
+
+// neoPathIndex parses the number token s as a 32-bit index.
+func neoPathIndex(s string) (int, bool) {
+	i, err := strconv.ParseInt(s, 10, 32)
+	return int(i), err == nil
+}
@@ -0,0 +1,98 @@
+package json
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestNeoFilter(t *testing.T) {
+	const in = `{"store": {"book": [
+		{"category": "reference", "author": "Nigel Rees", "price": 8.95},
+		{"category": "fiction", "author": "Evelyn Waugh", "price": 12.99},
+		{"category": "fiction", "author": "Herman Melville", "isbn": "0-553-21311-3", "price": 8.99}
+	], "bicycle": {"color": "red", "price": 19.95}}, "expensive": 10, "<tag>": null}`
+
+	for _, tc := range []struct {
+		path, want string
+	}{
+		{"", `[{"store":{"book":[{"category":"reference","author":"Nigel Rees","price":8.95},{"category":"fiction","author":"Evelyn Waugh","price":12.99},{"category":"fiction","author":"Herman Melville","isbn":"0-553-21311-3","price":8.99}],"bicycle":{"color":"red","price":19.95}},"expensive":10,"\u003Ctag\u003E":null}]`},
+		{"$", `[{"store":{"book":[{"category":"reference","author":"Nigel Rees","price":8.95},{"category":"fiction","author":"Evelyn Waugh","price":12.99},{"category":"fiction","author":"Herman Melville","isbn":"0-553-21311-3","price":8.99}],"bicycle":{"color":"red","price":19.95}},"expensive":10,"\u003Ctag\u003E":null}]`},
+		{"$.expensive", `[10]`},
+		{"$['<tag>']", `[null]`},
+		{"$.store.book[*].author", `["Nigel Rees","Evelyn Waugh","Herman Melville"]`},
+		{"$..author", `["Nigel Rees","Evelyn Waugh","Herman Melville"]`},
+		{"$.store.*", `[[{"category":"reference","author":"Nigel Rees","price":8.95},{"category":"fiction","author":"Evelyn Waugh","price":12.99},{"category":"fiction","author":"Herman Melville","isbn":"0-553-21311-3","price":8.99}],{"color":"red","price":19.95}]`},
+		{"$.store..price", `[19.95,8.95,12.99,8.99]`},
+		{"$.store.book[2].author", `["Herman Melville"]`},
+		{"$.store.book[-1].isbn", `["0-553-21311-3"]`},
+		{"$.store.book[0,1].price", `[8.95,12.99]`},
+		{"$.store.book[-1,5,0].price", `[8.99,8.95]`},
+		{"$.store.book[:2].price", `[8.95,12.99]`},
+		{"$.store.book[1:].price", `[12.99,8.99]`},
+		{"$.store.book[-2:].price", `[12.99,8.99]`},
+		{"$.store.book[0:-1].price", `[8.95,12.99]`},
+		{"$.store.book[2:1]", `[]`},
+		{"$.store.book[0]['author','price']", `["Nigel Rees",8.95]`},
+		{"$..isbn", `["0-553-21311-3"]`},
+		{"$..book[1]", `[{"category":"fiction","author":"Evelyn Waugh","price":12.99}]`},
+		{"$.missing", `[]`},
+		{"$.expensive.x", `[]`},
+		{"$.store.bicycle[0]", `[]`},
+	} {
+		got, err := NeoFilter([]byte(in), tc.path)
+		if err != nil || string(got) != tc.want {
+			t.Errorf("%q: got %s, %v, want %s", tc.path, got, err, tc.want)
+		}
+	}
+
+	for _, path := range []string{
+		"store",
+		"$.",
+		"$...",
+		"$..*",
+		"$[",
+		"$[0",
+		"$[*",
+		"$['a'",
+		"$['a",
+		"$['a',0]",
+		"$[0,'a']",
+		"$[-]",
+		"$[0:1",
+		"$[99999999999]",
+		"$.a b",
+		"$.a.b.c.d.e.f.g",
+		"$[0][0][0][0][0][0][0]",
+		"$..book[1].author",
+	} {
+		var pe *NeoPathError
+		if _, err := NeoFilter([]byte(in), path); !errors.As(err, &pe) {
+			t.Errorf("%q: got error %v, want *NeoPathError", path, err)
+		}
+	}
+
+	if _, err := NeoFilter([]byte(in), "$.a.b.c.d.e.f"); err != nil {
+		t.Errorf("maximum depth: %v", err)
+	}
+	if _, err := NeoFilter([]byte(`{"a": 1} {}`), "$"); err == nil {
+		t.Error("several values: no error")
+	}
+	if _, err := NeoFilter([]byte("[\"\xff\"]"), "$"); err == nil {
+		t.Error("invalid UTF-8: no error")
+	}
+
+	many := "[" + strings.Repeat("[0,0],", NeoPathMaxObjects/2) + "[0]]"
+	if _, err := NeoFilter([]byte(many), "$[*]"); err != nil {
+		t.Errorf("maximum objects: %v", err)
+	}
+	var pe *NeoPathError
+	if _, err := NeoFilter([]byte(many), "$[*][*]"); !errors.As(err, &pe) {
+		t.Errorf("too many objects: got error %v, want *NeoPathError", err)
+	}
+	big := `["` + strings.Repeat("x", NeoFilterMaxSize) + `"]`
+	var se *SizeError
+	if _, err := NeoFilter([]byte(big), "$[0]"); !errors.As(err, &se) {
+		t.Errorf("too big result: got error %v, want *SizeError", err)
+	}
+}
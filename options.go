@@ -0,0 +1,236 @@
+package json
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+)
+
+// Options is a set of encoding and decoding settings for a single call of
+// MarshalWithOptions or UnmarshalWithOptions, or for an Encoder or Decoder
+// created with its methods. Every field corresponds to an Encoder or Decoder
+// setter of a similar name which documents it, the zero value gives the
+// behavior of Marshal and Unmarshal. Options is an ordinary value, so
+// common settings can be kept in a variable and adjusted per call.
+type Options struct {
+	// Config provides extensions for both directions, see Config.
+	Config *Config
+	// Naming is used for both encoding and decoding.
+	Naming NamingConvention
+	// MaxDepth is used for both encoding and decoding.
+	MaxDepth int
+
+	// Encoding settings.
+
+	Prefix, Indent      string
+	Escaping            EscapeProfile
+	HexCase             HexCase // HexUpper or HexLower calls SetUppercaseHex
+	DisableHTMLEscaping bool
+	EscapeSlash         bool
+	UnescapedUnicode    bool
+	Escaper             Escaper
+	MapKeyOrder         func(a, b string) int
+	KeyTemplate         *KeyTemplate
+	KeyOrder            KeyOrderPolicy
+	FloatFormat         FloatFormat
+	FixedNotationMin    float64 // minAbs of SetFixedNotation
+	FixedNotationMax    float64 // maxAbs of SetFixedNotation
+	JSSafeIntegers      bool
+	FieldNames          map[string]string
+	FieldFilter         func(structType reflect.Type, field string) bool
+	Redactor            func(name string, v any) any
+	MaxSize             int
+	SizeHint            int
+	FlushSize           int
+	Parallelism         int
+
+	// Decoding settings.
+
+	Limits                 Limits
+	UseNumber              bool
+	UseOrderedObject       bool
+	Arena                  *Arena
+	UseBigNumbers          bool
+	UseInt64               bool
+	DisallowInexactNumbers bool
+	AllowComments          bool
+	AllowTrailingCommas    bool
+	AllowWeakTyping        bool
+	AllowQuotedIntegers    bool
+	DisallowInvalidUTF8    bool
+	DetectEncoding         bool
+	InternKeys             bool
+	InternStrings          int // maxLen of InternStrings if positive
+	Surrogates             SurrogatePolicy
+	Nulls                  NullPolicy
+	DuplicateKeys          DuplicateKeyPolicy
+	OnUnknownField         func(path, key string, raw RawMessage)
+}
+
+// MarshalWithOptions is like Marshal, but encodes v according to o. The
+// returned slice is allocated with the capacity of o.SizeHint.
+func MarshalWithOptions(v any, o Options) ([]byte, error) {
+	buf := bytes.NewBuffer(make([]byte, 0, max(o.SizeHint, 0)))
+	enc := o.NewEncoder(buf)
+	enc.SetTrailingNewline(false)
+	enc.sizeHint = 0 // Only the returned buffer is sized.
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// UnmarshalWithOptions is like Unmarshal, but decodes data according to o.
+// As with Unmarshal, the whole data is checked to be a single valid JSON
+// value before anything is stored in v.
+func UnmarshalWithOptions(data []byte, v any, o Options) error {
+	if o.DetectEncoding {
+		data, _ = convertCharset(data)
+		o.DetectEncoding = false
+	}
+	if l := o.Limits.MaxBytes; l > 0 && int64(len(data)) > l {
+		return &LimitError{Limit: "MaxBytes", Max: l, Offset: l}
+	}
+	dec := o.configure(NewBytesDecoder(data))
+	scan := dec.scan
+	if err := checkValid(data, &scan); err != nil {
+		return err
+	}
+	return dec.Decode(v)
+}
+
+// Valid reports whether data is a single valid JSON value accepted by
+// UnmarshalWithOptions with the decoding settings of o: the syntax
+// extensions, limits, nesting depth and UTF-8 and surrogate rules are
+// applied, but nothing is decoded. See ValidReader for the reason why data
+// is not valid.
+func (o Options) Valid(data []byte) bool {
+	return validate(o.configure(NewBytesDecoder(data))) == nil
+}
+
+// ValidReader is like Valid, but reads the value from r up to the end of
+// input without keeping more than the value in memory. It returns nil for
+// a valid value or the error Decoder.Decode would return for it (a
+// SyntaxError, LimitError, DepthError, io.ErrUnexpectedEOF for incomplete
+// input or the error of r), data following the value yields a SyntaxError.
+func (o Options) ValidReader(r io.Reader) error {
+	return validate(o.NewDecoder(r))
+}
+
+// validate checks the next value of dec and that it's the last one.
+func validate(dec *Decoder) error {
+	n, err := dec.readValue()
+	if err == io.EOF {
+		var scan scanner
+		return checkValid(nil, &scan) // The error of empty input.
+	}
+	if err != nil {
+		return err
+	}
+	dec.scanp += n
+	c, err := dec.peek()
+	switch {
+	case err == nil:
+		err := &SyntaxError{msg: "invalid character " + quoteChar(c) + " after top-level value", Offset: dec.InputOffset() + 1}
+		dec.locate(err, dec.scanp)
+		return err
+	case err != io.EOF:
+		return err
+	}
+	// Comments at the end of input are left unread by peek.
+	for rest := dec.buf[dec.scanp:]; nonSpace(rest); {
+		rest = bytes.TrimLeft(rest, " \t\r\n")
+		n := commentEnd(rest, true)
+		if n <= 0 {
+			return io.ErrUnexpectedEOF
+		}
+		rest = rest[n:]
+	}
+	return nil
+}
+
+// NewEncoder returns an Encoder writing to w with the settings of o.
+func (o Options) NewEncoder(w io.Writer) *Encoder {
+	enc := NewEncoder(w)
+	enc.SetIndent(o.Prefix, o.Indent)
+	enc.SetEscapeHTML(!o.DisableHTMLEscaping)
+	enc.SetKeyTemplate(o.KeyTemplate, o.KeyOrder)
+	enc.SetSizeHint(o.SizeHint)
+	enc.SetFlushSize(o.FlushSize)
+	enc.SetParallelism(o.Parallelism)
+	if o.hasExtras() {
+		enc.setExtras().config = o.Config
+		enc.SetEscaping(o.Escaping)
+		if o.HexCase == HexUpper || o.HexCase == HexLower {
+			enc.SetUppercaseHex(o.HexCase == HexUpper)
+		}
+		enc.SetEscapeSlash(o.EscapeSlash)
+		enc.SetUnescapedUnicode(o.UnescapedUnicode)
+		enc.SetNaming(o.Naming)
+		enc.SetMaxDepth(o.MaxDepth)
+		enc.SetEscaper(o.Escaper)
+		enc.SetMapKeyOrder(o.MapKeyOrder)
+		enc.SetFloatFormat(o.FloatFormat)
+		enc.SetFixedNotation(o.FixedNotationMin, o.FixedNotationMax)
+		enc.SetJSSafeIntegers(o.JSSafeIntegers)
+		enc.SetFieldNames(o.FieldNames)
+		enc.SetFieldFilter(o.FieldFilter)
+		enc.SetRedactor(o.Redactor)
+		enc.SetMaxSize(o.MaxSize)
+	}
+	return enc
+}
+
+// hasExtras reports whether any of the encoding settings of o kept in
+// extraOpts is set.
+func (o Options) hasExtras() bool {
+	return o.Config != nil || o.Escaping != NeoCompat || o.HexCase != HexDefault || o.EscapeSlash ||
+		o.UnescapedUnicode || o.Naming != KeepNames || o.MaxDepth != 0 || o.Escaper != nil ||
+		o.MapKeyOrder != nil || o.FloatFormat != FloatJS || o.FixedNotationMin != 0 ||
+		o.FixedNotationMax != 0 || o.JSSafeIntegers || o.FieldNames != nil || o.FieldFilter != nil ||
+		o.Redactor != nil || o.MaxSize != 0
+}
+
+// NewDecoder returns a Decoder reading from r with the settings of o.
+func (o Options) NewDecoder(r io.Reader) *Decoder {
+	return o.configure(NewDecoder(r))
+}
+
+// NewBytesDecoder returns a Decoder reading from data in place (see
+// NewBytesDecoder) with the settings of o.
+func (o Options) NewBytesDecoder(data []byte) *Decoder {
+	return o.configure(NewBytesDecoder(data))
+}
+
+// configure applies the decoding settings of o to dec.
+func (o Options) configure(dec *Decoder) *Decoder {
+	dec.d.config = o.Config
+	dec.SetNaming(o.Naming)
+	dec.SetMaxDepth(o.MaxDepth)
+	dec.SetLimits(o.Limits)
+	dec.d.useNumber = o.UseNumber
+	dec.d.useOrderedObject = o.UseOrderedObject
+	dec.d.arena = o.Arena
+	dec.d.useBigNumbers = o.UseBigNumbers
+	dec.d.useInt64 = o.UseInt64
+	dec.d.exactNumbers = o.DisallowInexactNumbers
+	dec.scan.comments = o.AllowComments
+	dec.scan.trailingCommas = o.AllowTrailingCommas
+	dec.d.weakTypes = o.AllowWeakTyping
+	dec.d.quotedInts = o.AllowQuotedIntegers
+	dec.d.strictUTF8 = o.DisallowInvalidUTF8
+	if o.DetectEncoding {
+		dec.DetectEncoding()
+	}
+	if o.InternKeys {
+		dec.InternKeys()
+	}
+	if o.InternStrings > 0 {
+		dec.InternStrings(o.InternStrings)
+	}
+	dec.SetSurrogatePolicy(o.Surrogates)
+	dec.SetNullPolicy(o.Nulls)
+	dec.SetDuplicateKeyPolicy(o.DuplicateKeys)
+	dec.OnUnknownField(o.OnUnknownField)
+	return dec
+}
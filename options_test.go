@@ -0,0 +1,188 @@
+package json
+
+import (
+	"bytes"
+	"cmp"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"unsafe"
+)
+
+func TestMarshalWithOptions(t *testing.T) {
+	v := struct {
+		UserName string
+		Tags     map[string]int
+	}{"<é>", map[string]int{"b": 1, "a": 2}}
+
+	for i, tc := range []struct {
+		opts Options
+		want string
+	}{
+		{Options{}, `{"UserName":"\u003C\u00E9\u003E","Tags":{"a":2,"b":1}}`},
+		{Options{Escaping: GoStd, DisableHTMLEscaping: true, Naming: SnakeCase}, `{"user_name":"<é>","tags":{"a":2,"b":1}}`},
+		{Options{HexCase: HexLower}, `{"UserName":"\u003c\u00e9\u003e","Tags":{"a":2,"b":1}}`},
+		{Options{Escaping: ASCIIOnly, HexCase: HexUpper}, `{"UserName":"\u003C\u00E9\u003E","Tags":{"a":2,"b":1}}`},
+		{Options{Escaping: GoStd, Indent: "\t"}, "{\n\t\"UserName\": \"\\u003cé\\u003e\",\n\t\"Tags\": {\n\t\t\"a\": 2,\n\t\t\"b\": 1\n\t}\n}"},
+	} {
+		b, err := MarshalWithOptions(v, tc.opts)
+		if err != nil {
+			t.Errorf("#%d: %v", i, err)
+			continue
+		}
+		if string(b) != tc.want {
+			t.Errorf("#%d: got %s, want %s", i, b, tc.want)
+		}
+	}
+	if want, _ := Marshal(v); !bytes.Equal(want, must(MarshalWithOptions(v, Options{}))) {
+		t.Errorf("zero options differ from Marshal")
+	}
+
+	var de *DepthError
+	if _, err := MarshalWithOptions([][]int{{1}}, Options{MaxDepth: 1}); !errors.As(err, &de) {
+		t.Errorf("depth: got %v", err)
+	}
+
+	fixed := Options{FixedNotationMin: 1e-6, FixedNotationMax: 1e22}
+	if got := must(MarshalWithOptions([]float64{1e21, 1e-6}, fixed)); string(got) != `[1000000000000000000000,0.000001]` {
+		t.Errorf("fixed notation: got %s", got)
+	}
+	var ue *UnsupportedValueError
+	if _, err := MarshalWithOptions(1e-7, fixed); !errors.As(err, &ue) {
+		t.Errorf("fixed notation: got %v", err)
+	}
+}
+
+func TestMarshalWithOptionsSizeHint(t *testing.T) {
+	v := make([]string, 1000)
+	for i := range v {
+		v[i] = strconv.Itoa(i)
+	}
+	want, _ := Marshal(v)
+	opts := Options{SizeHint: len(want)}
+	if got := must(MarshalWithOptions(v, opts)); !bytes.Equal(got, want) || cap(got) != len(want) {
+		t.Errorf("got %s (capacity %d), want %s", got, cap(got), want)
+	}
+	opts.SizeHint = -1
+	if got := must(MarshalWithOptions(v, opts)); !bytes.Equal(got, want) {
+		t.Errorf("negative hint: got %s, want %s", got, want)
+	}
+}
+
+func must(b []byte, err error) []byte {
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+func TestUnmarshalWithOptions(t *testing.T) {
+	var v struct {
+		UserName string
+		N        any
+	}
+	opts := Options{Naming: SnakeCase, UseNumber: true, AllowComments: true, AllowTrailingCommas: true}
+	if err := UnmarshalWithOptions([]byte(`{"user_name": "x", /* c */ "n": 1.5,}`), &v, opts); err != nil {
+		t.Fatal(err)
+	}
+	if v.UserName != "x" || v.N != Number("1.5") {
+		t.Errorf("got %+v", v)
+	}
+
+	for _, tc := range []struct {
+		in   string
+		opts Options
+		err  error
+	}{
+		{`{"n": 1} 2`, Options{}, &SyntaxError{}},
+		{`{"n": 1,}`, Options{}, &SyntaxError{}},
+		{`{"n": [[1]]}`, Options{MaxDepth: 2}, &DepthError{}},
+		{`{"n": "long"}`, Options{Limits: Limits{MaxBytes: 5}}, &LimitError{}},
+		{"{\"n\": \"\xff\"}", Options{DisallowInvalidUTF8: true}, nil},
+	} {
+		v.N = "untouched"
+		err := UnmarshalWithOptions([]byte(tc.in), &v, tc.opts)
+		if err == nil {
+			t.Errorf("%s: no error", tc.in)
+			continue
+		}
+		if tc.err != nil {
+			if target := reflect.New(reflect.TypeOf(tc.err)); !errors.As(err, target.Interface()) {
+				t.Errorf("%s: got %T %v, want %T", tc.in, err, err, tc.err)
+			}
+			if v.N != "untouched" {
+				t.Errorf("%s: value changed to %v", tc.in, v.N)
+			}
+		}
+	}
+
+	var ss []string
+	if err := UnmarshalWithOptions([]byte(`["short", "short", "longer one", "longer one"]`), &ss, Options{InternStrings: 5}); err != nil {
+		t.Fatal(err)
+	}
+	if unsafe.StringData(ss[0]) != unsafe.StringData(ss[1]) || unsafe.StringData(ss[2]) == unsafe.StringData(ss[3]) {
+		t.Errorf("InternStrings: got %q not interned up to 5 bytes", ss)
+	}
+
+	utf16 := []byte{0xFF, 0xFE, '"', 0, 'a', 0, '"', 0}
+	var s string
+	if err := UnmarshalWithOptions(utf16, &s, Options{DetectEncoding: true}); err != nil || s != "a" {
+		t.Errorf("UTF-16: got %q, %v", s, err)
+	}
+}
+
+func TestOptionsValid(t *testing.T) {
+	for _, tc := range []struct {
+		in   string
+		opts Options
+		err  string
+	}{
+		{` {"n": [1, "é"]} `, Options{}, ""},
+		{`{"n": 1} 2`, Options{}, `invalid character '2' after top-level value`},
+		{`{"n": 1,}`, Options{}, `invalid character '}' looking for beginning of object key string`},
+		{`{"n": 1,} /* c */ `, Options{AllowComments: true, AllowTrailingCommas: true}, ""},
+		{`1 // c`, Options{AllowComments: true}, ""},
+		{`1 /* c`, Options{AllowComments: true}, `unexpected EOF`},
+		{`{"n": [[1]]}`, Options{MaxDepth: 2}, `json: exceeded max nesting depth of 2`},
+		{`{"n": "long"}`, Options{Limits: Limits{MaxBytes: 5}}, `json: input exceeds MaxBytes of 5`},
+		{`[1, 2, 3]`, Options{Limits: Limits{MaxElements: 2}}, `json: input exceeds MaxElements of 2`},
+		{"{\"n\": \"\xff\"}", Options{}, ""},
+		{"{\"n\": \"\xff\"}", Options{DisallowInvalidUTF8: true}, `invalid UTF-8 in string`},
+		{`"\ud800"`, Options{Surrogates: SurrogateError}, `invalid escape of an unpaired surrogate in string`},
+		{"\xef\xbb\xbf[]", Options{DetectEncoding: true}, ""},
+		{` `, Options{}, `unexpected end of JSON input`},
+	} {
+		err := tc.opts.ValidReader(iotest.OneByteReader(strings.NewReader(tc.in)))
+		if got := fmt.Sprint(err); tc.err == "" && err != nil || tc.err != "" && got != tc.err {
+			t.Errorf("%q: got error %v, want %s", tc.in, err, cmp.Or(tc.err, "none"))
+		}
+		if ok := tc.opts.Valid([]byte(tc.in)); ok != (tc.err == "") {
+			t.Errorf("%q: Valid is %v", tc.in, ok)
+		}
+		if ok := UnmarshalWithOptions([]byte(tc.in), new(any), tc.opts) == nil; ok != (tc.err == "") {
+			t.Errorf("%q: UnmarshalWithOptions success is %v", tc.in, ok)
+		}
+	}
+
+	for _, tc := range []struct {
+		r   io.Reader
+		err error
+	}{
+		{strings.NewReader(`[1, 2`), io.ErrUnexpectedEOF},
+		{strings.NewReader("[] /* c */ // c\n// c"), nil},
+		{io.MultiReader(strings.NewReader(`[]`), iotest.ErrReader(errors.ErrUnsupported)), errors.ErrUnsupported},
+	} {
+		if err := (Options{AllowComments: true}).ValidReader(tc.r); err != tc.err {
+			t.Errorf("got error %v, want %v", err, tc.err)
+		}
+	}
+	var se *SyntaxError
+	if err := ValidReader(strings.NewReader("[]\n  x")); !errors.As(err, &se) || se.Offset != 6 || se.Line != 2 || se.Column != 3 {
+		t.Errorf("trailing data: got error %#v", err)
+	}
+}
@@ -0,0 +1,65 @@
+package json
+
+import (
+	"errors"
+	"io"
+)
+
+// Handler receives parsing events from Parse in document order. Parsing
+// stops at the first error returned by any of the methods, Parse then
+// returns this error.
+type Handler interface {
+	// ObjectStart is called at the beginning of an object.
+	ObjectStart() error
+	// Key is called for every object member name before its value.
+	Key(key string) error
+	// ObjectEnd is called at the end of an object.
+	ObjectEnd() error
+	// ArrayStart is called at the beginning of an array.
+	ArrayStart() error
+	// ArrayEnd is called at the end of an array.
+	ArrayEnd() error
+	// Value is called for every literal value, v is a string, Number,
+	// bool or nil for JSON strings, numbers, booleans and null respectively.
+	Value(v Token) error
+}
+
+// Parse reads JSON values from r until the end of input passing them to h as
+// a sequence of events without constructing any Go values for objects and
+// arrays. Syntax errors are returned as is, after the events for the valid
+// part of the input.
+func Parse(r io.Reader, h Handler) error {
+	dec := NewDecoder(r)
+	dec.UseNumber()
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			if errors.Is(err, io.EOF) && len(dec.tokenStack) == 0 {
+				return nil
+			}
+			if errors.Is(err, io.EOF) {
+				err = io.ErrUnexpectedEOF
+			}
+			return err
+		}
+		switch tok {
+		case Delim('{'):
+			err = h.ObjectStart()
+		case Delim('}'):
+			err = h.ObjectEnd()
+		case Delim('['):
+			err = h.ArrayStart()
+		case Delim(']'):
+			err = h.ArrayEnd()
+		default:
+			if key, ok := tok.(string); ok && dec.tokenState == tokenObjectColon {
+				err = h.Key(key)
+			} else {
+				err = h.Value(tok)
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package json
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type recordingHandler struct {
+	events []string
+	stop   string
+}
+
+func (h *recordingHandler) add(e string) error {
+	h.events = append(h.events, e)
+	if e == h.stop {
+		return errors.New("stop")
+	}
+	return nil
+}
+
+func (h *recordingHandler) ObjectStart() error   { return h.add("{") }
+func (h *recordingHandler) Key(key string) error { return h.add("key " + key) }
+func (h *recordingHandler) ObjectEnd() error     { return h.add("}") }
+func (h *recordingHandler) ArrayStart() error    { return h.add("[") }
+func (h *recordingHandler) ArrayEnd() error      { return h.add("]") }
+func (h *recordingHandler) Value(v Token) error  { return h.add(fmt.Sprintf("%T %v", v, v)) }
+
+func TestParse(t *testing.T) {
+	const in = `{"a": [1.50, "a", true, null, {}], "b": {"c": "d"}} 7`
+	h := new(recordingHandler)
+	if err := Parse(strings.NewReader(in), h); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"{", "key a", "[", "json.Number 1.50", "string a", "bool true", "<nil> <nil>",
+		"{", "}", "]", "key b", "{", "key c", "string d", "}", "}", "json.Number 7"}
+	if !reflect.DeepEqual(h.events, want) {
+		t.Errorf("got events %q, want %q", h.events, want)
+	}
+
+	h = &recordingHandler{stop: "key b"}
+	if err := Parse(strings.NewReader(in), h); err == nil || err.Error() != "stop" {
+		t.Errorf("handler error: got %v", err)
+	}
+	if len(h.events) != 11 {
+		t.Errorf("got %d events after the handler error", len(h.events))
+	}
+
+	if err := Parse(strings.NewReader(`[1, 2`), new(recordingHandler)); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("truncated input: got %v", err)
+	}
+	var se *SyntaxError
+	if err := Parse(strings.NewReader(`[1 2]`), new(recordingHandler)); !errors.As(err, &se) {
+		t.Errorf("invalid input: got %v", err)
+	}
+}
@@ -0,0 +1,161 @@
+package json
+
+import (
+	"bytes"
+	"strconv"
+)
+
+// A PathError is returned by DecodePath when there is no value at the
+// requested path.
+type PathError struct {
+	Path string // the path up to the first missing element
+}
+
+func (e *PathError) Error() string {
+	return "json: no value at " + e.Path
+}
+
+// DecodePath decodes the value found at the given path in data into the
+// value pointed to by v. Every path element is either an object member
+// name or a decimal array index depending on the value it is applied to,
+// an empty path addresses the whole document. Only the addressed value is
+// decoded, everything else is skipped without allocating anything (but the
+// whole input is still checked to be valid JSON, as with Unmarshal). If an
+// object has several members with the same name, the first one is used.
+//
+// Errors are reported as Unmarshal does with offsets and paths relative
+// to the whole document. A missing value yields a *PathError.
+func DecodePath(data []byte, v any, path ...string) error {
+	var d decodeState
+	err := checkValid(data, &d.scan)
+	if err != nil {
+		return err
+	}
+
+	d.init(data)
+	off := skipSpace(data, 0)
+	for _, name := range path {
+		off, err = d.pathStep(off, name)
+		if err != nil {
+			return err
+		}
+	}
+	// Decode only the value itself, with the rest of the data cut off.
+	d.data = data[:skipValue(data, off)]
+	d.off = off
+	return d.unmarshal(v)
+}
+
+// pathStep finds the element name in the object or array starting at
+// d.data[off] and returns the offset of its value, it also records the
+// element in the error context path.
+func (d *decodeState) pathStep(off int, name string) (int, error) {
+	_, off, err := d.pathMember(off, name)
+	return off, err
+}
+
+// pathMember is like pathStep, but also returns the offset of the member,
+// that is of the key for object members and of the value for array
+// elements.
+func (d *decodeState) pathMember(off int, name string) (int, int, error) {
+	data := d.data
+	switch data[off] {
+	case '{':
+		off = skipSpace(data, off+1)
+		for data[off] != '}' {
+			member := off
+			end := skipString(data, off)
+			key := data[off:end]
+			match := bytes.Equal(key[1:len(key)-1], []byte(name))
+			if !match && bytes.IndexByte(key, '\\') >= 0 {
+				s, _ := unquote(key)
+				match = s == name
+			}
+			off = skipSpace(data, skipSpace(data, end)+1) // Skip ':'.
+			if match {
+				d.pushPath(key, 0)
+				return member, off, nil
+			}
+			off = skipMember(data, off)
+		}
+		d.pushPath(quoteKey(name), 0)
+	case '[':
+		index, err := strconv.Atoi(name)
+		if err != nil || index < 0 || name[0] == '+' {
+			d.pushPath(quoteKey(name), 0)
+			break
+		}
+		off = skipSpace(data, off+1)
+		for i := 0; data[off] != ']'; i++ {
+			if i == index {
+				d.pushPath(nil, index)
+				return off, off, nil
+			}
+			off = skipMember(data, off)
+		}
+		d.pushPath(nil, index)
+	default:
+		d.pushPath(quoteKey(name), 0)
+	}
+	return 0, 0, &PathError{Path: formatPath(d.errorContext.Path, "")}
+}
+
+// skipMember skips the valid value starting at data[off] along with
+// the following comma and returns the offset of the next element or of
+// the closing bracket.
+func skipMember(data []byte, off int) int {
+	off = skipSpace(data, skipValue(data, off))
+	if data[off] == ',' {
+		off = skipSpace(data, off+1)
+	}
+	return off
+}
+
+// skipSpace returns the offset of the first non-space byte in data[off:].
+func skipSpace(data []byte, off int) int {
+	for off < len(data) && isSpace(data[off]) {
+		off++
+	}
+	return off
+}
+
+// skipString returns the offset right after the valid string starting at
+// data[off].
+func skipString(data []byte, off int) int {
+	for off++; data[off] != '"'; off++ {
+		if data[off] == '\\' {
+			off++
+		}
+	}
+	return off + 1
+}
+
+// skipValue returns the offset right after the valid value starting at
+// data[off].
+func skipValue(data []byte, off int) int {
+	depth := 0
+	for {
+		switch data[off] {
+		case '"':
+			off = skipString(data, off)
+		case '{', '[':
+			depth++
+			off++
+		case '}', ']':
+			depth--
+			off++
+		default:
+			if depth == 0 {
+				// Literal, it ends at a delimiter or the end of input.
+				for off < len(data) && !isSpace(data[off]) && bytes.IndexByte([]byte(",:]}"), data[off]) < 0 {
+					off++
+				}
+				return off
+			}
+			off++
+		}
+		if depth == 0 {
+			return off
+		}
+	}
+}
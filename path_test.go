@@ -0,0 +1,60 @@
+package json
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestDecodePath(t *testing.T) {
+	const in = ` {"a": [1, {"b": "x", "c\"": [true, null]}, "s\"]}"], "d": -1.5e3 , "a": 0} `
+	for _, tc := range []struct {
+		path []string
+		want any
+		err  string
+	}{
+		{nil, nil, ""},
+		{[]string{"d"}, float64(-1500), ""},
+		{[]string{"a", "0"}, float64(1), ""},
+		{[]string{"a", "1", "b"}, "x", ""},
+		{[]string{"a", "1", `c"`, "1"}, nil, ""},
+		{[]string{"a", "1", `c"`}, []any{true, nil}, ""},
+		{[]string{"a", "2"}, `s"]}`, ""},
+		{[]string{"a", "3"}, nil, "json: no value at a[3]"},
+		{[]string{"a", "x"}, nil, "json: no value at a.x"},
+		{[]string{"a", "1", "e", "f"}, nil, "json: no value at a[1].e"},
+		{[]string{"d", "0"}, nil, "json: no value at d[\"0\"]"},
+	} {
+		var v any
+		err := DecodePath([]byte(in), &v, tc.path...)
+		if tc.err != "" {
+			var pe *PathError
+			if !errors.As(err, &pe) || err.Error() != tc.err {
+				t.Errorf("%q: got error %v, want %s", tc.path, err, tc.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: unexpected error %v", tc.path, err)
+			continue
+		}
+		if tc.path == nil {
+			continue
+		}
+		if !reflect.DeepEqual(v, tc.want) {
+			t.Errorf("%q: got %#v, want %#v", tc.path, v, tc.want)
+		}
+	}
+
+	var s string
+	err := DecodePath([]byte(in), &s, "a", "0")
+	want := &UnmarshalTypeError{"number", reflect.TypeFor[string](), 9, "", "", "a[0]"}
+	if !reflect.DeepEqual(err, want) {
+		t.Errorf("got error %#v, want %#v", err, want)
+	}
+
+	var se *SyntaxError
+	if err := DecodePath([]byte(`{"a": 1}}`), &s, "b"); !errors.As(err, &se) {
+		t.Errorf("invalid input: got %v", err)
+	}
+}
@@ -0,0 +1,251 @@
+package json
+
+import (
+	"errors"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// A Pointer is a JSON Pointer (RFC 6901), the sequence of its reference
+// tokens without the ~0 and ~1 escaping. Tokens address object members by
+// name and array elements by decimal indices without leading zeros, "-"
+// addresses the element past the end of an array when setting values. An
+// empty Pointer addresses the whole document.
+//
+// Get, Set and Delete operate on trees of OrderedObject, map[string]any
+// and []any values like the ones decoded into an any, while GetRaw, SetRaw
+// and DeleteRaw operate on encoded documents. If an object has several
+// members with the same name, the first one is used.
+type Pointer []string
+
+// ParsePointer parses the string representation of a JSON Pointer, which is
+// either empty or a sequence of tokens each prefixed by '/', where '~' and
+// '/' are escaped as "~0" and "~1".
+func ParsePointer(s string) (Pointer, error) {
+	if s == "" {
+		return Pointer{}, nil
+	}
+	if s[0] != '/' {
+		return nil, errors.New("json: JSON Pointer " + strconv.Quote(s) + " doesn't start with '/'")
+	}
+	p := Pointer(strings.Split(s[1:], "/"))
+	for i, tok := range p {
+		for j := 0; j < len(tok); j++ {
+			if tok[j] == '~' && (j+1 == len(tok) || tok[j+1] != '0' && tok[j+1] != '1') {
+				return nil, errors.New("json: invalid escape in JSON Pointer " + strconv.Quote(s))
+			}
+		}
+		p[i] = strings.ReplaceAll(strings.ReplaceAll(tok, "~1", "/"), "~0", "~")
+	}
+	return p, nil
+}
+
+var pointerEscaper = strings.NewReplacer("~", "~0", "/", "~1")
+
+// String returns the string representation of p.
+func (p Pointer) String() string {
+	var sb strings.Builder
+	for _, tok := range p {
+		sb.WriteByte('/')
+		pointerEscaper.WriteString(&sb, tok)
+	}
+	return sb.String()
+}
+
+// Get returns the value addressed by p in the tree v. A missing value yields
+// a *PathError.
+func (p Pointer) Get(v any) (any, error) {
+	for i, tok := range p {
+		found := false
+		switch c := v.(type) {
+		case OrderedObject:
+			for _, m := range c {
+				if m.Key == tok {
+					v, found = m.Value, true
+					break
+				}
+			}
+		case map[string]any:
+			v, found = c[tok]
+		case []any:
+			var n int
+			if n, found = pointerIndex(tok, len(c)); found {
+				v = c[n]
+			}
+		}
+		if !found {
+			return nil, p.missing(i)
+		}
+	}
+	return v, nil
+}
+
+// Set sets the value addressed by p in the tree v to value and returns the
+// updated tree. Missing object members are added after the existing ones
+// and the index equal to the length of an array (or "-") appends value to
+// it, the parent of the value must exist. Like append, Set modifies v in
+// place, but the result must be used instead of v since slices can be
+// reallocated. An empty Pointer returns value itself.
+func (p Pointer) Set(v any, value any) (any, error) {
+	return p.update(v, 0, value, false)
+}
+
+// Delete removes the value addressed by p from the tree v and returns the
+// updated tree like Set does. Array elements after the removed one are
+// moved by one. A missing value yields a *PathError.
+func (p Pointer) Delete(v any) (any, error) {
+	if len(p) == 0 {
+		return nil, errors.New("json: Delete with empty pointer")
+	}
+	return p.update(v, 0, nil, true)
+}
+
+// update sets or deletes the value addressed by p[i:] in v.
+func (p Pointer) update(v any, i int, value any, del bool) (any, error) {
+	if i == len(p) {
+		return value, nil
+	}
+	tok, last := p[i], i == len(p)-1
+	switch c := v.(type) {
+	case OrderedObject:
+		for j := range c {
+			if c[j].Key != tok {
+				continue
+			}
+			if last && del {
+				return slices.Delete(c, j, j+1), nil
+			}
+			elem, err := p.update(c[j].Value, i+1, value, del)
+			if err != nil {
+				return nil, err
+			}
+			c[j].Value = elem
+			return c, nil
+		}
+		if last && !del {
+			return append(c, Member{tok, value}), nil
+		}
+	case map[string]any:
+		elem, ok := c[tok]
+		if last && del {
+			if !ok {
+				break
+			}
+			delete(c, tok)
+			return c, nil
+		}
+		if ok || last {
+			elem, err := p.update(elem, i+1, value, del)
+			if err != nil {
+				return nil, err
+			}
+			c[tok] = elem
+			return c, nil
+		}
+	case []any:
+		if n, ok := pointerIndex(tok, len(c)); ok {
+			if last && del {
+				return slices.Delete(c, n, n+1), nil
+			}
+			elem, err := p.update(c[n], i+1, value, del)
+			if err != nil {
+				return nil, err
+			}
+			c[n] = elem
+			return c, nil
+		}
+		if last && !del && (tok == "-" || tok == strconv.Itoa(len(c))) {
+			return append(c, value), nil
+		}
+	}
+	return nil, p.missing(i)
+}
+
+// GetRaw returns the raw value addressed by p in the JSON document data, the
+// result shares the memory of data. A missing value yields a *PathError.
+func (p Pointer) GetRaw(data []byte) (RawMessage, error) {
+	ix, path, err := p.indexPath(data, false)
+	if err != nil {
+		return nil, err
+	}
+	return p.check(ix.Get(path...))
+}
+
+// SetRaw returns a copy of the JSON document data with the value addressed
+// by p set to the JSON value like Set does.
+func (p Pointer) SetRaw(data []byte, value RawMessage) ([]byte, error) {
+	ix, path, err := p.indexPath(data, true)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := ix.Get(path...); err != nil && len(path) > 0 {
+		return p.check(ix.Insert(value, path...))
+	}
+	return p.check(ix.Replace(value, path...))
+}
+
+// DeleteRaw returns a copy of the JSON document data without the value
+// addressed by p like Delete does.
+func (p Pointer) DeleteRaw(data []byte) ([]byte, error) {
+	if len(p) == 0 {
+		return nil, errors.New("json: Delete with empty pointer")
+	}
+	ix, path, err := p.indexPath(data, false)
+	if err != nil {
+		return nil, err
+	}
+	return p.check(ix.Delete(path...))
+}
+
+// indexPath indexes the document data and returns p as a path for the
+// Index methods. Tokens applied to arrays are checked to be valid indices,
+// the last one can be "-" replaced with the length of the array if end is
+// set.
+func (p Pointer) indexPath(data []byte, end bool) (*Index, []string, error) {
+	ix, err := BuildIndexDepth(data, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+	path := []string(p)
+	for i, tok := range p {
+		_, start, _, err := ix.find(path[:i])
+		if err != nil {
+			return nil, nil, p.missing(i - 1)
+		}
+		if data[start] != '[' {
+			continue
+		}
+		if end && tok == "-" && i == len(p)-1 {
+			path = append(path[:i:i], strconv.Itoa(countElements(data, start)))
+		} else if _, ok := pointerIndex(tok, -1); !ok {
+			return nil, nil, p.missing(i)
+		}
+	}
+	return ix, path, nil
+}
+
+// check converts *PathError errors of Index methods applied to an existing
+// parent of the value to the ones for the whole pointer.
+func (p Pointer) check(data []byte, err error) ([]byte, error) {
+	var pe *PathError
+	if errors.As(err, &pe) {
+		return nil, p.missing(len(p) - 1)
+	}
+	return data, err
+}
+
+// missing returns a *PathError for the missing value at p[:i+1].
+func (p Pointer) missing(i int) error {
+	return &PathError{Path: p[:i+1].String()}
+}
+
+// pointerIndex parses the array index tok and checks it to be less than n
+// if n isn't negative.
+func pointerIndex(tok string, n int) (int, bool) {
+	if tok == "" || tok[0] < '0' || tok[0] > '9' || len(tok) > 1 && tok[0] == '0' {
+		return 0, false
+	}
+	i, err := strconv.Atoi(tok)
+	return i, err == nil && (n < 0 || i < n)
+}
@@ -0,0 +1,173 @@
+package json
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParsePointer(t *testing.T) {
+	for _, tc := range []struct {
+		in   string
+		want Pointer
+	}{
+		{"", Pointer{}},
+		{"/", Pointer{""}},
+		{"/a/0", Pointer{"a", "0"}},
+		{"/a~1b/m~0n/~01", Pointer{"a/b", "m~n", "~1"}},
+		{"//x/", Pointer{"", "x", ""}},
+	} {
+		p, err := ParsePointer(tc.in)
+		if err != nil || !reflect.DeepEqual(p, tc.want) {
+			t.Errorf("%q: got %q, %v, want %q", tc.in, p, err, tc.want)
+		}
+		if s := p.String(); s != tc.in {
+			t.Errorf("%q: String returned %q", tc.in, s)
+		}
+	}
+	for _, in := range []string{"a", "/~", "/~2", "/a~/b"} {
+		if _, err := ParsePointer(in); err == nil {
+			t.Errorf("%q: no error", in)
+		}
+	}
+}
+
+func TestPointer(t *testing.T) {
+	const in = `{"a": [1, {"b/c": "x", "~": [true]}], "d": {}, "e": null, "d": 0}`
+	for _, tc := range []struct {
+		ptr  string
+		want string // empty if missing
+	}{
+		{"", in},
+		{"/a", `[1, {"b/c": "x", "~": [true]}]`},
+		{"/a/0", `1`},
+		{"/a/1/b~1c", `"x"`},
+		{"/a/1/~0/0", `true`},
+		{"/d", `{}`},
+		{"/e", `null`},
+		{"/a/2", ``},
+		{"/a/-", ``},
+		{"/a/01", ``},
+		{"/a/+1", ``},
+		{"/a/x", ``},
+		{"/a/0/0", ``},
+		{"/e/0", ``},
+		{"/f", ``},
+		{"/f/g", ``},
+	} {
+		p, err := ParsePointer(tc.ptr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := p.GetRaw([]byte(in))
+		v, treeErr := p.Get(decodeOrdered(t, in))
+		if tc.want == "" {
+			var pe *PathError
+			if !errors.As(err, &pe) || !errors.As(treeErr, &pe) {
+				t.Errorf("%q: got errors %v, %v, want *PathError", tc.ptr, err, treeErr)
+			}
+			continue
+		}
+		if err != nil || string(got) != tc.want {
+			t.Errorf("%q: GetRaw returned %s, %v, want %s", tc.ptr, got, err, tc.want)
+		}
+		if want := decodeOrdered(t, tc.want); treeErr != nil || !reflect.DeepEqual(v, want) {
+			t.Errorf("%q: Get returned %#v, %v, want %#v", tc.ptr, v, treeErr, want)
+		}
+	}
+
+	var pe *PathError
+	if _, err := (Pointer{"a", "3", "x"}).GetRaw([]byte(in)); !errors.As(err, &pe) || pe.Path != "/a/3" {
+		t.Errorf("got error %v, want no value at /a/3", err)
+	}
+}
+
+func TestPointerEdits(t *testing.T) {
+	const in = `{"a": [1, {"b": "x"}], "c": {}, "d": [], "e": 2}`
+	for _, tc := range []struct {
+		op    string
+		ptr   string
+		value string
+		want  string // empty if failed
+	}{
+		{"set", "/e", `5`, `{"a":[1,{"b":"x"}],"c":{},"d":[],"e":5}`},
+		{"set", "/f", `true`, `{"a":[1,{"b":"x"}],"c":{},"d":[],"e":2,"f":true}`},
+		{"set", "/a/1/b", `"y"`, `{"a":[1,{"b":"y"}],"c":{},"d":[],"e":2}`},
+		{"set", "/a/0", `[]`, `{"a":[[],{"b":"x"}],"c":{},"d":[],"e":2}`},
+		{"set", "/a/2", `3`, `{"a":[1,{"b":"x"},3],"c":{},"d":[],"e":2}`},
+		{"set", "/a/-", `3`, `{"a":[1,{"b":"x"},3],"c":{},"d":[],"e":2}`},
+		{"set", "/d/-", `0`, `{"a":[1,{"b":"x"}],"c":{},"d":[0],"e":2}`},
+		{"set", "/c/~1", `0`, `{"a":[1,{"b":"x"}],"c":{"/":0},"d":[],"e":2}`},
+		{"set", "", `0`, `0`},
+		{"set", "/a/3", `0`, ``},
+		{"set", "/a/01", `0`, ``},
+		{"set", "/e/x", `0`, ``},
+		{"set", "/x/y", `0`, ``},
+		{"delete", "/e", ``, `{"a":[1,{"b":"x"}],"c":{},"d":[]}`},
+		{"delete", "/a/0", ``, `{"a":[{"b":"x"}],"c":{},"d":[],"e":2}`},
+		{"delete", "/a/1/b", ``, `{"a":[1,{}],"c":{},"d":[],"e":2}`},
+		{"delete", "/a/-", ``, ``},
+		{"delete", "/a/2", ``, ``},
+		{"delete", "/x", ``, ``},
+		{"delete", "", ``, ``},
+	} {
+		p, err := ParsePointer(tc.ptr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, ordered := range []bool{true, false} {
+			var tree any
+			opts := Options{UseOrderedObject: ordered}
+			if err := UnmarshalWithOptions([]byte(in), &tree, opts); err != nil {
+				t.Fatal(err)
+			}
+			var value any
+			if tc.value != "" {
+				if err := Unmarshal([]byte(tc.value), &value); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if tc.op == "set" {
+				tree, err = p.Set(tree, value)
+			} else {
+				tree, err = p.Delete(tree)
+			}
+			if tc.want == "" {
+				if err == nil {
+					t.Errorf("%s %q: no error", tc.op, tc.ptr)
+				}
+				continue
+			}
+			got, _ := Marshal(tree)
+			if err != nil || ordered && string(got) != tc.want {
+				t.Errorf("%s %q: got %s, %v, want %s", tc.op, tc.ptr, got, err, tc.want)
+			}
+		}
+
+		var got []byte
+		if tc.op == "set" {
+			got, err = p.SetRaw([]byte(in), RawMessage(tc.value))
+		} else {
+			got, err = p.DeleteRaw([]byte(in))
+		}
+		if tc.want == "" {
+			if err == nil {
+				t.Errorf("%s raw %q: no error", tc.op, tc.ptr)
+			}
+			continue
+		}
+		if err != nil || !reflect.DeepEqual(decodeOrdered(t, string(got)), decodeOrdered(t, tc.want)) {
+			t.Errorf("%s raw %q: got %s, %v, want %s", tc.op, tc.ptr, got, err, tc.want)
+		}
+	}
+}
+
+// decodeOrdered decodes the JSON document s with UseOrderedObject.
+func decodeOrdered(t *testing.T, s string) any {
+	t.Helper()
+	var v any
+	if err := UnmarshalWithOptions([]byte(s), &v, Options{UseOrderedObject: true}); err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
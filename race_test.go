@@ -0,0 +1,7 @@
+//go:build race
+
+package json
+
+// raceEnabled reports whether the tests run with the race detector, which
+// makes sync.Pool drop items at random and so breaks allocation counts.
+const raceEnabled = true
@@ -0,0 +1,86 @@
+package json
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"reflect"
+	"sync"
+	"sync/atomic"
+)
+
+var (
+	typeEncoders    sync.Map    // map[reflect.Type]func(*Encoder, reflect.Value) error
+	typeDecoders    sync.Map    // map[reflect.Type]func(*Decoder, reflect.Value) error
+	hasTypeDecoders atomic.Bool // whether typeDecoders may be non-empty
+)
+
+// RegisterEncoder makes Marshal and Encoder use fn to encode values of type
+// t instead of any methods t has and the default reflection-based encoding,
+// it allows to customize the encoding of types from other packages. fn is
+// called with an Encoder positioned where the value of t is expected (just
+// like MarshalJSONTo) and the value itself, it must write exactly one JSON
+// value using WriteToken, Encode, EncodeRaw or the BeginObject/BeginArray
+// interface. Errors are returned from Marshal wrapped in a MarshalerError.
+// Pointers to t are encoded as the values they point to (or null), a nil fn
+// removes the registration.
+//
+// RegisterEncoder must be called before values of t (or of types containing
+// it) are marshaled for the first time, usually in an init function, since
+// the encoders are cached. It's safe for concurrent use.
+func RegisterEncoder(t reflect.Type, fn func(*Encoder, reflect.Value) error) {
+	if fn == nil {
+		typeEncoders.Delete(t)
+	} else {
+		typeEncoders.Store(t, fn)
+	}
+	encoderCache.Delete(t)
+	encoderCache.Delete(reflect.PointerTo(t))
+}
+
+func newRegisteredEncoder(fn func(*Encoder, reflect.Value) error) encoderFunc {
+	return func(e *encodeState, v reflect.Value, opts encOpts) {
+		e.marshalTo(func(enc *Encoder) error { return fn(enc, v) }, v.Type(), opts)
+	}
+}
+
+// RegisterDecoder makes Unmarshal and Decoder use fn to decode values of
+// type t instead of any methods t has and the default reflection-based
+// decoding, it allows to customize the decoding of types from other
+// packages. fn is called with a Decoder reading just the JSON value to be
+// decoded (null included) and the addressable value of t to set, it must
+// read the whole value using Token, Decode or DecodeRaw. The Decoder has
+// the same options as the one decoding the enclosing value (except for the
+// size and depth limits). Errors are returned from Unmarshal as they are.
+// A nil fn removes the registration.
+//
+// RegisterDecoder is safe for concurrent use, but it's not synchronized
+// with decoding, so it's better called in an init function.
+func RegisterDecoder(t reflect.Type, fn func(*Decoder, reflect.Value) error) {
+	if fn == nil {
+		typeDecoders.Delete(t)
+		return
+	}
+	typeDecoders.Store(t, fn)
+	hasTypeDecoders.Store(true)
+}
+
+// registeredUnmarshaler adapts a function registered with RegisterDecoder
+// to Unmarshaler.
+type registeredUnmarshaler struct {
+	fn func(*Decoder, reflect.Value) error
+	v  reflect.Value
+	s  *decodeSettings // the settings of the decoder calling it
+}
+
+func (u registeredUnmarshaler) UnmarshalJSON(data []byte) error {
+	dec := NewDecoder(bytes.NewReader(data))
+	dec.d.decodeSettings = *u.s
+	if err := u.fn(dec, u.v); err != nil {
+		return err
+	}
+	if _, err := dec.Token(); len(dec.tokenStack) > 0 || !errors.Is(err, io.EOF) {
+		return errors.New("json: incomplete value read for " + u.v.Type().String())
+	}
+	return nil
+}
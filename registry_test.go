@@ -0,0 +1,120 @@
+package json
+
+import (
+	"errors"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+type regAmount struct {
+	units uint64
+}
+
+func (regAmount) MarshalJSON() ([]byte, error) { return []byte(`"method"`), nil }
+
+type regPair struct {
+	A, B int
+}
+
+func TestRegisterEncoder(t *testing.T) {
+	RegisterEncoder(reflect.TypeFor[regAmount](), func(enc *Encoder, v reflect.Value) error {
+		a := v.Interface().(regAmount)
+		if a.units == 13 {
+			return errors.New("unlucky")
+		}
+		return enc.WriteToken(strconv.FormatUint(a.units, 10))
+	})
+	RegisterEncoder(reflect.TypeFor[regPair](), func(enc *Encoder, v reflect.Value) error {
+		p := v.Interface().(regPair)
+		return enc.Encode([]int{p.A, p.B})
+	})
+	defer RegisterEncoder(reflect.TypeFor[regAmount](), nil)
+	defer RegisterEncoder(reflect.TypeFor[regPair](), nil)
+
+	v := struct {
+		A  regAmount
+		P  *regAmount
+		N  *regAmount
+		L  []regPair
+		AS regAmount `json:",string"`
+	}{regAmount{1}, &regAmount{2}, nil, []regPair{{1, 2}}, regAmount{3}}
+	b, err := Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"A":"1","P":"2","N":null,"L":[[1,2]],"AS":"3"}`; string(b) != want {
+		t.Errorf("got %s, want %s", b, want)
+	}
+
+	var me *MarshalerError
+	if _, err := Marshal(regAmount{13}); !errors.As(err, &me) || me.Err.Error() != "unlucky" {
+		t.Errorf("encoder error: got %v", err)
+	}
+
+	RegisterEncoder(reflect.TypeFor[regAmount](), nil)
+	if b, err := Marshal(&regAmount{1}); err != nil || string(b) != `"method"` {
+		t.Errorf("after removal: got %s, %v", b, err)
+	}
+}
+
+func TestRegisterDecoder(t *testing.T) {
+	RegisterDecoder(reflect.TypeFor[regAmount](), func(dec *Decoder, v reflect.Value) error {
+		var s *string
+		if err := dec.Decode(&s); err != nil {
+			return err
+		}
+		if s == nil {
+			v.Set(reflect.ValueOf(regAmount{42}))
+			return nil
+		}
+		n, err := strconv.ParseUint(*s, 10, 64)
+		if err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(regAmount{n}))
+		return nil
+	})
+	RegisterDecoder(reflect.TypeFor[regPair](), func(dec *Decoder, v reflect.Value) error {
+		if _, err := dec.Token(); err != nil {
+			return err
+		}
+		p := v.Addr().Interface().(*regPair)
+		if err := dec.Decode(&p.A); err != nil {
+			return err
+		}
+		if p.A == 0 {
+			return nil // Leave the array unfinished.
+		}
+		if err := dec.Decode(&p.B); err != nil {
+			return err
+		}
+		_, err := dec.Token()
+		return err
+	})
+	defer RegisterDecoder(reflect.TypeFor[regAmount](), nil)
+	defer RegisterDecoder(reflect.TypeFor[regPair](), nil)
+
+	var v struct {
+		A regAmount
+		P *regAmount
+		N regAmount
+		L []regPair
+	}
+	err := Unmarshal([]byte(`{"A": "1", "P": "2", "N": null, "L": [[1, 2], [3, 4]]}`), &v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.A.units != 1 || v.P == nil || v.P.units != 2 || v.N.units != 42 || !reflect.DeepEqual(v.L, []regPair{{1, 2}, {3, 4}}) {
+		t.Errorf("got %+v", v)
+	}
+
+	var a regAmount
+	if err := Unmarshal([]byte(`"x"`), &a); err == nil {
+		t.Error("decoder error: no error")
+	}
+	var p regPair
+	if err := Unmarshal([]byte(`[0, 1]`), &p); err == nil {
+		t.Error("incomplete read: no error")
+	}
+}
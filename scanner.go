@@ -13,29 +13,145 @@ package json
 // This file starts with two simple examples using the scanner
 // before diving into the scanner itself.
 
-import "strconv"
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"strconv"
+	"unicode/utf8"
+)
 
-// Valid reports whether data is a valid JSON encoding.
+// Valid reports whether data is a valid JSON encoding, that is whether
+// Unmarshal accepts it. Options.Valid checks data against other decoding
+// settings.
 func Valid(data []byte) bool {
 	return checkValid(data, &scanner{}) == nil
 }
 
+// ValidReader is like Valid, but reads the value from r, see
+// Options.ValidReader.
+func ValidReader(r io.Reader) error {
+	return Options{}.ValidReader(r)
+}
+
 // checkValid verifies that data is valid JSON-encoded data.
 // scan is passed in for use by checkValid to avoid an allocation.
 func checkValid(data []byte, scan *scanner) error {
 	scan.reset()
-	for _, c := range data {
+	for i, c := range data {
 		scan.bytes++
-		if scan.step(scan, c) == scanError {
+		// Errors after the top-level value are reported with scanEnd.
+		if v := scan.step(scan, c); v == scanError || v == scanEnd && scan.err != nil {
+			locate(scan.err, data, i, 1, 1)
 			return scan.err
 		}
 	}
 	if scan.eof() == scanError {
+		locate(scan.err, data, len(data), 1, 1)
 		return scan.err
 	}
 	return nil
 }
 
+// invalidUTF8 returns the position of the first byte of an invalid UTF-8
+// sequence in b or -1 if b is valid UTF-8.
+func invalidUTF8(b []byte) int {
+	if utf8.Valid(b) {
+		return -1
+	}
+	for i := 0; i < len(b); {
+		if b[i] < utf8.RuneSelf {
+			i++
+			continue
+		}
+		r, size := utf8.DecodeRune(b[i:])
+		if r == utf8.RuneError && size == 1 {
+			return i
+		}
+		i += size
+	}
+	return -1
+}
+
+// errInvalidUTF8 is the description of the SyntaxError returned for
+// invalid UTF-8 when it's not allowed.
+const errInvalidUTF8 = "invalid UTF-8 in string"
+
+// errLoneSurrogate is the description of the SyntaxError returned for
+// unpaired surrogates with SurrogateError policy.
+const errLoneSurrogate = "invalid escape of an unpaired surrogate in string"
+
+// ctxChunk is the number of bytes checkValidContext scans between context
+// checks.
+const ctxChunk = 64 << 10
+
+// checkValidContext is like checkValid, but returns the ctx error once
+// ctx is done.
+func checkValidContext(ctx context.Context, data []byte, scan *scanner) error {
+	scan.reset()
+	for off := 0; off < len(data); off += ctxChunk {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		for i, c := range data[off:min(off+ctxChunk, len(data))] {
+			scan.bytes++
+			if v := scan.step(scan, c); v == scanError || v == scanEnd && scan.err != nil {
+				locate(scan.err, data, off+i, 1, 1)
+				return scan.err
+			}
+		}
+	}
+	if scan.eof() == scanError {
+		locate(scan.err, data, len(data), 1, 1)
+		return scan.err
+	}
+	return nil
+}
+
+// checkValidFields is like checkValid, but when data is an object it stops
+// after the values of all the given members are scanned returning the
+// offset of the end of the last one of them (or len(data) if some of them
+// are missing).
+func checkValidFields(data []byte, scan *scanner, fields []string) (int, error) {
+	left := make(map[string]bool, len(fields))
+	for _, f := range fields {
+		left[f] = true
+	}
+	var (
+		keyStart int
+		wanted   bool // whether the current top-level member is in left
+	)
+	scan.reset()
+	for i, c := range data {
+		scan.bytes++
+		v := scan.step(scan, c)
+		if v == scanError || v == scanEnd && scan.err != nil {
+			locate(scan.err, data, i, 1, 1)
+			return 0, scan.err
+		}
+		depth := len(scan.parseState)
+		switch {
+		case v == scanBeginLiteral && depth == 1 && scan.parseState[0] == parseObjectKey:
+			keyStart = i
+		case v == scanObjectKey && depth == 1:
+			key, _ := unquote(bytes.TrimRight(data[keyStart:i], " \t\r\n"))
+			wanted = left[key]
+			delete(left, key)
+		case wanted && (v == scanObjectValue && depth == 1 || v == scanEndObject && depth == 0):
+			wanted = false
+			if len(left) == 0 {
+				return len(bytes.TrimRight(data[:i], " \t\r\n")), nil
+			}
+		}
+	}
+	if scan.eof() == scanError {
+		locate(scan.err, data, len(data), 1, 1)
+		return 0, scan.err
+	}
+	return len(data), nil
+}
+
 // nextValue splits data after the next whole JSON value,
 // returning that value and the bytes that follow it as separate slices.
 // scan is passed in for use by nextValue to avoid an allocation.
@@ -66,13 +182,98 @@ func nextValue(data []byte, scan *scanner) (value, rest []byte, err error) {
 }
 
 // A SyntaxError is a description of a JSON syntax error.
+// Line and Column (both starting at 1, columns are counted in bytes) point
+// to the offending byte, Context is an excerpt of the input line around it
+// (a Decoder only includes the input it has read so far). They're zero when
+// the position is not known.
 type SyntaxError struct {
-	msg    string // description of error
-	Offset int64  // error occurred after reading Offset bytes
+	msg     string // description of error
+	Offset  int64  // error occurred after reading Offset bytes
+	Line    int    // line of the offending byte
+	Column  int    // column of the offending byte
+	Context string // part of the input around the offending byte
 }
 
 func (e *SyntaxError) Error() string { return e.msg }
 
+// errorContext is the maximum number of bytes taken from either side of
+// the offending byte for SyntaxError.Context.
+const errorContext = 16
+
+// locate fills in the position of err if it's a SyntaxError that occurred
+// at data[pos], line and col being the position of data[0].
+func locate(err error, data []byte, pos int, line, col int) {
+	var e *SyntaxError
+	if !errors.As(err, &e) {
+		return
+	}
+	start := bytes.LastIndexByte(data[:pos], '\n') + 1
+	e.Line = line + bytes.Count(data[:start], []byte{'\n'})
+	if start > 0 {
+		col = 1
+	}
+	e.Column = col + pos - start
+	end := len(data)
+	if i := bytes.IndexByte(data[pos:], '\n'); i >= 0 {
+		end = pos + i
+	}
+	start = max(start, pos-errorContext)
+	end = min(end, pos+errorContext)
+	e.Context = string(data[start:end])
+}
+
+// maxNestingDepth is the default limit of nested arrays and objects
+// the scanner accepts.
+const maxNestingDepth = 10000
+
+// A DepthError is returned when arrays and objects are nested deeper than
+// allowed, see Decoder.SetMaxDepth and Encoder.SetMaxDepth.
+type DepthError struct {
+	Limit  int   // maximum allowed depth
+	Offset int64 // error occurred after reading Offset bytes, zero when encoding
+}
+
+func (e *DepthError) Error() string {
+	return "json: exceeded max nesting depth of " + strconv.Itoa(e.Limit)
+}
+
+// A SizeError is returned when the encoded value is larger than allowed,
+// see Encoder.SetMaxSize.
+type SizeError struct {
+	Limit int // maximum allowed size in bytes
+}
+
+func (e *SizeError) Error() string {
+	return "json: encoded value exceeds max size of " + strconv.Itoa(e.Limit) + " bytes"
+}
+
+// Limits restricts the size of the input accepted by a Decoder or
+// UnmarshalLimited. Zero values mean no limit.
+type Limits struct {
+	// MaxBytes is the maximum size of a single top-level JSON value
+	// including any whitespace preceding it.
+	MaxBytes int64
+	// MaxStringLen is the maximum length of a string literal (keys
+	// included) in bytes as it appears in the input, that is before
+	// escape sequences are processed.
+	MaxStringLen int
+	// MaxMembers is the maximum number of members in an object.
+	MaxMembers int
+	// MaxElements is the maximum number of elements in an array.
+	MaxElements int
+}
+
+// A LimitError is returned when the input exceeds one of the Limits.
+type LimitError struct {
+	Limit  string // name of the Limits field exceeded, like "MaxBytes"
+	Max    int64  // value of that field
+	Offset int64  // error occurred after reading Offset bytes
+}
+
+func (e *LimitError) Error() string {
+	return "json: input exceeds " + e.Limit + " of " + strconv.FormatInt(e.Max, 10)
+}
+
 // A scanner is a JSON scanning state machine.
 // Callers call scan.reset() and then pass bytes in one at a time
 // by calling scan.step(&scan, c) for each byte.
@@ -108,6 +309,25 @@ type scanner struct {
 
 	// total bytes consumed, updated by decoder.Decode
 	bytes int64
+
+	// maximum nesting depth, maxNestingDepth if not positive
+	maxDepth int
+
+	// Size limits and the counters needed to enforce them: number of
+	// members/elements for every parseState entry (only maintained when
+	// MaxMembers or MaxElements is set) and the length of the current string.
+	limits Limits
+	counts []int
+	strLen int
+
+	// Accept // and /* */ comments wherever whitespace is allowed,
+	// afterComment is the state to return to at the end of a comment,
+	// it's nil outside of comments.
+	comments     bool
+	afterComment func(*scanner, byte) int
+
+	// Accept a comma after the last member or element.
+	trailingCommas bool
 }
 
 // These values are returned by the state transition functions
@@ -153,6 +373,8 @@ func (s *scanner) reset() {
 	s.err = nil
 	s.redo = false
 	s.endTop = false
+	s.counts = s.counts[0:0]
+	s.afterComment = nil
 }
 
 // eof tells the scanner that the end of input has been reached.
@@ -161,22 +383,37 @@ func (s *scanner) eof() int {
 	if s.err != nil {
 		return scanError
 	}
-	if s.endTop {
-		return scanEnd
+	if !s.endTop {
+		s.step(s, ' ')
+	} else if s.afterComment != nil {
+		s.step(s, '\n') // A line comment ends here.
 	}
-	s.step(s, ' ')
-	if s.endTop {
+	if s.endTop && s.afterComment == nil {
 		return scanEnd
 	}
 	if s.err == nil {
-		s.err = &SyntaxError{"unexpected end of JSON input", s.bytes}
+		s.err = &SyntaxError{msg: "unexpected end of JSON input", Offset: s.bytes}
 	}
 	return scanError
 }
 
 // pushParseState pushes a new parse state p onto the parse stack.
-func (s *scanner) pushParseState(p int) {
+// It returns successState or scanError if the stack gets too deep.
+func (s *scanner) pushParseState(p int, successState int) int {
 	s.parseState = append(s.parseState, p)
+	if s.limits.MaxMembers > 0 || s.limits.MaxElements > 0 {
+		s.counts = append(s.counts, 0)
+	}
+	limit := s.maxDepth
+	if limit <= 0 {
+		limit = maxNestingDepth
+	}
+	if len(s.parseState) <= limit {
+		return successState
+	}
+	s.step = stateError
+	s.err = &DepthError{Limit: limit, Offset: s.bytes}
+	return scanError
 }
 
 // popParseState pops a parse state (already obtained) off the stack
@@ -184,6 +421,9 @@ func (s *scanner) pushParseState(p int) {
 func (s *scanner) popParseState() {
 	n := len(s.parseState) - 1
 	s.parseState = s.parseState[0:n]
+	if len(s.counts) > n {
+		s.counts = s.counts[0:n]
+	}
 	s.redo = false
 	if n == 0 {
 		s.step = stateEndTop
@@ -202,6 +442,9 @@ func stateBeginValueOrEmpty(s *scanner, c byte) int {
 	if c <= ' ' && isSpace(c) {
 		return scanSkipSpace
 	}
+	if c == '/' && s.comments {
+		return s.beginComment(stateBeginValueOrEmpty)
+	}
 	if c == ']' {
 		return stateEndValue(s, c)
 	}
@@ -213,17 +456,22 @@ func stateBeginValue(s *scanner, c byte) int {
 	if c <= ' ' && isSpace(c) {
 		return scanSkipSpace
 	}
+	if c == '/' && s.comments {
+		return s.beginComment(stateBeginValue)
+	}
+	if len(s.counts) > 0 && s.tooMany() {
+		return scanError
+	}
 	switch c {
 	case '{':
 		s.step = stateBeginStringOrEmpty
-		s.pushParseState(parseObjectKey)
-		return scanBeginObject
+		return s.pushParseState(parseObjectKey, scanBeginObject)
 	case '[':
 		s.step = stateBeginValueOrEmpty
-		s.pushParseState(parseArrayValue)
-		return scanBeginArray
+		return s.pushParseState(parseArrayValue, scanBeginArray)
 	case '"':
 		s.step = stateInString
+		s.strLen = 0
 		return scanBeginLiteral
 	case '-':
 		s.step = stateNeg
@@ -253,6 +501,9 @@ func stateBeginStringOrEmpty(s *scanner, c byte) int {
 	if c <= ' ' && isSpace(c) {
 		return scanSkipSpace
 	}
+	if c == '/' && s.comments {
+		return s.beginComment(stateBeginStringOrEmpty)
+	}
 	if c == '}' {
 		n := len(s.parseState)
 		s.parseState[n-1] = parseObjectValue
@@ -266,8 +517,12 @@ func stateBeginString(s *scanner, c byte) int {
 	if c <= ' ' && isSpace(c) {
 		return scanSkipSpace
 	}
+	if c == '/' && s.comments {
+		return s.beginComment(stateBeginString)
+	}
 	if c == '"' {
 		s.step = stateInString
+		s.strLen = 0
 		return scanBeginLiteral
 	}
 	return s.error(c, "looking for beginning of object key string")
@@ -287,6 +542,9 @@ func stateEndValue(s *scanner, c byte) int {
 		s.step = stateEndValue
 		return scanSkipSpace
 	}
+	if c == '/' && s.comments {
+		return s.beginComment(stateEndValue)
+	}
 	ps := s.parseState[n-1]
 	switch ps {
 	case parseObjectKey:
@@ -300,6 +558,9 @@ func stateEndValue(s *scanner, c byte) int {
 		if c == ',' {
 			s.parseState[n-1] = parseObjectKey
 			s.step = stateBeginString
+			if s.trailingCommas {
+				s.step = stateBeginStringOrEmpty
+			}
 			return scanObjectValue
 		}
 		if c == '}' {
@@ -310,6 +571,9 @@ func stateEndValue(s *scanner, c byte) int {
 	case parseArrayValue:
 		if c == ',' {
 			s.step = stateBeginValue
+			if s.trailingCommas {
+				s.step = stateBeginValueOrEmpty
+			}
 			return scanArrayValue
 		}
 		if c == ']' {
@@ -323,8 +587,12 @@ func stateEndValue(s *scanner, c byte) int {
 
 // stateEndTop is the state after finishing the top-level value,
 // such as after reading `{}` or `[1,2,3]`.
-// Only space characters should be seen now.
+// Only space characters (and comments if allowed) should be seen now.
 func stateEndTop(s *scanner, c byte) int {
+	if c == '/' && s.comments {
+		s.beginComment(stateEndTop)
+		return scanEnd
+	}
 	if c != ' ' && c != '\t' && c != '\r' && c != '\n' {
 		// Complain about non-space byte on next call.
 		s.error(c, "after top-level value")
@@ -334,6 +602,9 @@ func stateEndTop(s *scanner, c byte) int {
 
 // stateInString is the state after reading `"`.
 func stateInString(s *scanner, c byte) int {
+	if s.limits.MaxStringLen > 0 && s.stringTooLong() {
+		return scanError
+	}
 	if c == '"' {
 		s.step = stateEndValue
 		return scanContinue
@@ -350,6 +621,9 @@ func stateInString(s *scanner, c byte) int {
 
 // stateInStringEsc is the state after reading `"\` during a quoted string.
 func stateInStringEsc(s *scanner, c byte) int {
+	if s.limits.MaxStringLen > 0 && s.stringTooLong() {
+		return scanError
+	}
 	switch c {
 	case 'b', 'f', 'n', 'r', 't', '\\', '/', '"':
 		s.step = stateInString
@@ -363,6 +637,9 @@ func stateInStringEsc(s *scanner, c byte) int {
 
 // stateInStringEscU is the state after reading `"\u` during a quoted string.
 func stateInStringEscU(s *scanner, c byte) int {
+	if s.limits.MaxStringLen > 0 && s.stringTooLong() {
+		return scanError
+	}
 	if '0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F' {
 		s.step = stateInStringEscU1
 		return scanContinue
@@ -373,6 +650,9 @@ func stateInStringEscU(s *scanner, c byte) int {
 
 // stateInStringEscU1 is the state after reading `"\u1` during a quoted string.
 func stateInStringEscU1(s *scanner, c byte) int {
+	if s.limits.MaxStringLen > 0 && s.stringTooLong() {
+		return scanError
+	}
 	if '0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F' {
 		s.step = stateInStringEscU12
 		return scanContinue
@@ -383,6 +663,9 @@ func stateInStringEscU1(s *scanner, c byte) int {
 
 // stateInStringEscU12 is the state after reading `"\u12` during a quoted string.
 func stateInStringEscU12(s *scanner, c byte) int {
+	if s.limits.MaxStringLen > 0 && s.stringTooLong() {
+		return scanError
+	}
 	if '0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F' {
 		s.step = stateInStringEscU123
 		return scanContinue
@@ -393,6 +676,9 @@ func stateInStringEscU12(s *scanner, c byte) int {
 
 // stateInStringEscU123 is the state after reading `"\u123` during a quoted string.
 func stateInStringEscU123(s *scanner, c byte) int {
+	if s.limits.MaxStringLen > 0 && s.stringTooLong() {
+		return scanError
+	}
 	if '0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F' {
 		s.step = stateInString
 		return scanContinue
@@ -580,16 +866,183 @@ func stateNul(s *scanner, c byte) int {
 	return s.error(c, "in literal null (expecting 'l')")
 }
 
+// beginComment switches to the comment states after reading `/`,
+// returning to the state next once the comment is over.
+func (s *scanner) beginComment(next func(*scanner, byte) int) int {
+	s.afterComment = next
+	s.step = stateComment
+	return scanSkipSpace
+}
+
+// stateComment is the state after reading `/` outside of a value.
+func stateComment(s *scanner, c byte) int {
+	switch c {
+	case '/':
+		s.step = stateLineComment
+		return scanSkipSpace
+	case '*':
+		s.step = stateBlockComment
+		return scanSkipSpace
+	}
+	return s.error(c, "in comment")
+}
+
+// stateLineComment is the state after reading `//`.
+func stateLineComment(s *scanner, c byte) int {
+	if c == '\n' {
+		s.step, s.afterComment = s.afterComment, nil
+	}
+	return scanSkipSpace
+}
+
+// stateBlockComment is the state after reading `/*`.
+func stateBlockComment(s *scanner, c byte) int {
+	if c == '*' {
+		s.step = stateBlockCommentStar
+	}
+	return scanSkipSpace
+}
+
+// stateBlockCommentStar is the state after reading `*` in a block comment.
+func stateBlockCommentStar(s *scanner, c byte) int {
+	switch c {
+	case '/':
+		s.step, s.afterComment = s.afterComment, nil
+	case '*':
+	default:
+		s.step = stateBlockComment
+	}
+	return scanSkipSpace
+}
+
+// commentEnd returns the length of the comment at the beginning of b,
+// not including the newline terminating a // comment. It's 0 if b doesn't
+// start with a comment and -1 if the comment is incomplete, atEOF tells
+// whether the end of b is the end of input.
+func commentEnd(b []byte, atEOF bool) int {
+	if len(b) < 2 {
+		if atEOF || len(b) == 0 || b[0] != '/' {
+			return 0
+		}
+		return -1
+	}
+	switch {
+	case b[0] != '/':
+		return 0
+	case b[1] == '/':
+		if i := bytes.IndexByte(b, '\n'); i >= 0 {
+			return i
+		}
+		if atEOF {
+			return len(b)
+		}
+		return -1
+	case b[1] == '*':
+		if i := bytes.Index(b[2:], []byte("*/")); i >= 0 {
+			return i + 4
+		}
+		return -1
+	}
+	return 0
+}
+
+// stripComments replaces comments in b (that's known to be valid JSON with
+// comments, possibly followed by incomplete data at the end of input) with
+// spaces retaining newlines.
+func stripComments(b []byte, atEOF bool) {
+	for i := 0; i < len(b); i++ {
+		switch b[i] {
+		case '"':
+			for i++; i < len(b) && b[i] != '"'; i++ {
+				if b[i] == '\\' {
+					i++
+				}
+			}
+		case '/':
+			n := commentEnd(b[i:], atEOF)
+			if n <= 0 {
+				return
+			}
+			for j := i; j < i+n; j++ {
+				if b[j] != '\n' {
+					b[j] = ' '
+				}
+			}
+			i += n - 1
+		}
+	}
+}
+
+// stripTrailingCommas replaces commas after the last member or element with
+// spaces in b, that's known to be valid JSON with trailing commas and no
+// comments.
+func stripTrailingCommas(b []byte) {
+	comma := -1
+	for i := 0; i < len(b); i++ {
+		switch c := b[i]; c {
+		case '"':
+			for i++; i < len(b) && b[i] != '"'; i++ {
+				if b[i] == '\\' {
+					i++
+				}
+			}
+			comma = -1
+		case ',':
+			comma = i
+		case ']', '}':
+			if comma >= 0 {
+				b[comma] = ' '
+			}
+			comma = -1
+		default:
+			if !isSpace(c) {
+				comma = -1
+			}
+		}
+	}
+}
+
 // stateError is the state after reaching a syntax error,
 // such as after reading `[1}` or `5.1.2`.
 func stateError(s *scanner, c byte) int {
 	return scanError
 }
 
+// tooMany counts the value being started as a member or element of the
+// innermost object or array and reports whether that exceeds the limits.
+// The error is recorded in s.
+func (s *scanner) tooMany() bool {
+	n := len(s.counts) - 1
+	s.counts[n]++
+	limit, name := s.limits.MaxElements, "MaxElements"
+	if s.parseState[n] != parseArrayValue {
+		limit, name = s.limits.MaxMembers, "MaxMembers"
+	}
+	if limit <= 0 || s.counts[n] <= limit {
+		return false
+	}
+	s.step = stateError
+	s.err = &LimitError{Limit: name, Max: int64(limit), Offset: s.bytes}
+	return true
+}
+
+// stringTooLong counts one more byte of the current string (the closing
+// quote included) and reports whether it's longer than allowed. The error
+// is recorded in s.
+func (s *scanner) stringTooLong() bool {
+	s.strLen++
+	if s.strLen <= s.limits.MaxStringLen+1 {
+		return false
+	}
+	s.step = stateError
+	s.err = &LimitError{Limit: "MaxStringLen", Max: int64(s.limits.MaxStringLen), Offset: s.bytes}
+	return true
+}
+
 // error records an error and switches to the error state.
 func (s *scanner) error(c byte, context string) int {
 	s.step = stateError
-	s.err = &SyntaxError{"invalid character " + quoteChar(c) + " " + context, s.bytes}
+	s.err = &SyntaxError{msg: "invalid character " + quoteChar(c) + " " + context, Offset: s.bytes}
 	return scanError
 }
 
@@ -6,10 +6,13 @@ package json
 
 import (
 	"bytes"
+	"errors"
 	"math"
 	"math/rand/v2"
 	"reflect"
+	"strings"
 	"testing"
+	"testing/iotest"
 )
 
 var validTests = []struct {
@@ -102,6 +105,22 @@ func TestCompactSeparators(t *testing.T) {
 	}
 }
 
+func TestCompactEscaping(t *testing.T) {
+	in := `{"b" : "<'+\u00e9\/\"\t", "a": ["` + "\u2028\xff" + `", 1.50, null]}`
+	want := `{"b":"\u003C\u0027\u002B\u00E9/\u0022\t","a":["\u2028\uFFFD",1.50,null]}`
+	var buf bytes.Buffer
+	if err := Compact(&buf, []byte(in)); err != nil {
+		t.Fatalf("Compact: %v", err)
+	}
+	if s := buf.String(); s != want {
+		t.Errorf("Compact(%#q) = %#q, want %#q", in, s, want)
+	}
+	buf.Reset()
+	if err := Compact(&buf, []byte(`{"a": "b"`)); err == nil || buf.Len() != 0 {
+		t.Errorf("Compact of invalid input: got %#q, %v", buf.String(), err)
+	}
+}
+
 func TestIndent(t *testing.T) {
 	var buf bytes.Buffer
 	for _, tt := range examples {
@@ -122,6 +141,18 @@ func TestIndent(t *testing.T) {
 	}
 }
 
+func TestIndentEscaping(t *testing.T) {
+	in := `{"a":["<\/", {"b\"":"\u00e9"}],"c":[]}`
+	want := "{\n\t\"a\": [\n\t\t\"\\u003C/\",\n\t\t{\n\t\t\t\"b\\u0022\": \"\\u00E9\"\n\t\t}\n\t],\n\t\"c\": []\n}"
+	var buf bytes.Buffer
+	if err := Indent(&buf, []byte(in), "", "\t"); err != nil {
+		t.Fatalf("Indent: %v", err)
+	}
+	if s := buf.String(); s != want {
+		t.Errorf("Indent(%#q) = %#q, want %#q", in, s, want)
+	}
+}
+
 // Tests of a large random structure.
 
 func TestCompactBig(t *testing.T) {
@@ -183,8 +214,8 @@ type indentErrorTest struct {
 }
 
 var indentErrorTests = []indentErrorTest{
-	{`{"X": "foo", "Y"}`, &SyntaxError{"invalid character '}' after object key", 17}},
-	{`{"X": "foo" "Y": "bar"}`, &SyntaxError{"invalid character '\"' after object key:value pair", 13}},
+	{`{"X": "foo", "Y"}`, &SyntaxError{"invalid character '}' after object key", 17, 1, 17, `{"X": "foo", "Y"}`}},
+	{`{"X": "foo" "Y": "bar"}`, &SyntaxError{"invalid character '\"' after object key:value pair", 13, 1, 13, `{"X": "foo" "Y": "bar"}`}},
 }
 
 func TestIndentErrors(t *testing.T) {
@@ -325,3 +356,33 @@ func genMap(n int) map[string]any {
 	}
 	return x
 }
+
+func TestSyntaxErrorPosition(t *testing.T) {
+	const in = "{\n  \"name\": \"neo\",\n  \"list\": [1, 2 3],\n  \"x\": 0\n}"
+	check := func(name string, err error, context string) {
+		t.Helper()
+		var se *SyntaxError
+		if !errors.As(err, &se) {
+			t.Fatalf("%s: got %v, want SyntaxError", name, err)
+		}
+		if se.Line != 3 || se.Column != 17 || se.Context != context {
+			t.Errorf("%s: got line %d, column %d, context %q", name, se.Line, se.Column, se.Context)
+		}
+	}
+	var v any
+	check("Unmarshal", Unmarshal([]byte(in), &v), `  "list": [1, 2 3],`)
+	check("Indent", Indent(new(bytes.Buffer), []byte(in), "", "  "), `  "list": [1, 2 3],`)
+	// Decoder doesn't read past the offending byte.
+	check("Decode", NewDecoder(iotest.OneByteReader(strings.NewReader(in))).Decode(&v), `  "list": [1, 2 3`)
+
+	dec := NewDecoder(iotest.OneByteReader(strings.NewReader("1\n2\n[3 4]")))
+	for range 2 {
+		if err := dec.Decode(&v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var se *SyntaxError
+	if err := dec.Decode(&v); !errors.As(err, &se) || se.Line != 3 || se.Column != 4 || se.Context != "[3 4" {
+		t.Errorf("Decode of the third value: got %#v", err)
+	}
+}
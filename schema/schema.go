@@ -0,0 +1,559 @@
+// Package schema validates JSON values against schemas written in a
+// practical subset of JSON Schema. The supported keywords are:
+//
+//	type                   a type name or an array of them
+//	enum, const            allowed values, compared like JSON values
+//	properties             schemas of object members
+//	required               names of mandatory object members
+//	additionalProperties   a schema of members not in properties
+//	items                  a schema of all array elements
+//	minItems, maxItems     array length bounds
+//	minLength, maxLength   string length bounds in code points
+//	pattern                a regular expression strings must match
+//	minimum, maximum, exclusiveMinimum, exclusiveMaximum
+//	                       number bounds
+//
+// Boolean schemas are supported too, other keywords (like $schema, title
+// or description) are ignored. Patterns use the RE2 syntax of the regexp
+// package, which is a subset of ECMA 262 regular expressions used by JSON
+// Schema for anything but backreferences and lookarounds.
+//
+// Values are validated either as trees of json.OrderedObject (or
+// map[string]any), []any and basic values like the ones decoded into an
+// any, or as a stream of tokens read from a json.Decoder without building
+// the whole value. Both ways report all violations found along with the
+// JSON Pointers to the invalid values in document order.
+package schema
+
+import (
+	"errors"
+	"io"
+	"maps"
+	"math"
+	"reflect"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	json "github.com/nspcc-dev/go-ordered-json"
+)
+
+// A Schema is a compiled schema, it's safe for concurrent use.
+type Schema struct {
+	reject     bool // the false schema
+	types      []string
+	enum       []any
+	constant   bool // enum comes from const
+	properties map[string]*Schema
+	required   []string
+	additional *Schema
+	items      *Schema
+	pattern    *regexp.Regexp
+
+	minimum, maximum                   *float64
+	exclusiveMinimum, exclusiveMaximum *float64
+	minLength, maxLength               int // -1 if not set
+	minItems, maxItems                 int // -1 if not set
+}
+
+// A Violation is a value not matching the schema.
+type Violation struct {
+	Path    json.Pointer // the invalid value
+	Keyword string       // the schema keyword it violates
+	Message string
+}
+
+func (v Violation) Error() string {
+	path := v.Path.String()
+	if path == "" {
+		path = "/"
+	}
+	return "schema: " + path + ": " + v.Message
+}
+
+var typeNames = []string{"null", "boolean", "object", "array", "number", "integer", "string"}
+
+// Compile parses the JSON schema data.
+func Compile(data []byte) (*Schema, error) {
+	var v any
+	if err := json.UnmarshalWithOptions(data, &v, json.Options{UseOrderedObject: true}); err != nil {
+		return nil, err
+	}
+	return compile(v, nil)
+}
+
+// MustCompile is like Compile but panics if the schema can't be parsed.
+func MustCompile(data []byte) *Schema {
+	s, err := Compile(data)
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
+
+// compile compiles the decoded schema v found at path in the document.
+func compile(v any, path json.Pointer) (*Schema, error) {
+	s := &Schema{minLength: -1, maxLength: -1, minItems: -1, maxItems: -1}
+	obj, ok := v.(json.OrderedObject)
+	if !ok {
+		b, ok := v.(bool)
+		if !ok {
+			return nil, errors.New("schema: " + describe(path) + " is not an object or boolean")
+		}
+		s.reject = !b
+		return s, nil
+	}
+	for _, m := range obj {
+		p := appendPath(path, m.Key)
+		invalid := errors.New("schema: invalid " + describe(p))
+		var err error
+		switch m.Key {
+		case "type":
+			switch t := m.Value.(type) {
+			case string:
+				s.types = []string{t}
+			case []any:
+				for _, t := range t {
+					t, ok := t.(string)
+					if !ok {
+						return nil, invalid
+					}
+					s.types = append(s.types, t)
+				}
+			default:
+				return nil, invalid
+			}
+			for _, t := range s.types {
+				if !slices.Contains(typeNames, t) {
+					return nil, errors.New("schema: unknown type " + strconv.Quote(t) + " in " + describe(p))
+				}
+			}
+		case "enum":
+			enum, ok := m.Value.([]any)
+			if !ok {
+				return nil, invalid
+			}
+			s.enum, s.constant = enum, false
+		case "const":
+			s.enum, s.constant = []any{m.Value}, true
+		case "properties":
+			props, ok := m.Value.(json.OrderedObject)
+			if !ok {
+				return nil, invalid
+			}
+			s.properties = make(map[string]*Schema, len(props))
+			for _, prop := range props {
+				if s.properties[prop.Key], err = compile(prop.Value, appendPath(p, prop.Key)); err != nil {
+					return nil, err
+				}
+			}
+		case "required":
+			names, ok := m.Value.([]any)
+			if !ok {
+				return nil, invalid
+			}
+			for _, name := range names {
+				name, ok := name.(string)
+				if !ok {
+					return nil, invalid
+				}
+				s.required = append(s.required, name)
+			}
+		case "additionalProperties":
+			s.additional, err = compile(m.Value, p)
+		case "items":
+			s.items, err = compile(m.Value, p)
+		case "pattern":
+			pattern, ok := m.Value.(string)
+			if !ok {
+				return nil, invalid
+			}
+			if s.pattern, err = regexp.Compile(pattern); err != nil {
+				return nil, errors.New("schema: invalid " + describe(p) + ": " + err.Error())
+			}
+		case "minimum":
+			s.minimum, err = bound(m.Value, p)
+		case "maximum":
+			s.maximum, err = bound(m.Value, p)
+		case "exclusiveMinimum":
+			s.exclusiveMinimum, err = bound(m.Value, p)
+		case "exclusiveMaximum":
+			s.exclusiveMaximum, err = bound(m.Value, p)
+		case "minLength":
+			s.minLength, err = count(m.Value, p)
+		case "maxLength":
+			s.maxLength, err = count(m.Value, p)
+		case "minItems":
+			s.minItems, err = count(m.Value, p)
+		case "maxItems":
+			s.maxItems, err = count(m.Value, p)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// bound returns the number v of the keyword at path.
+func bound(v any, path json.Pointer) (*float64, error) {
+	f, ok := v.(float64)
+	if !ok {
+		return nil, errors.New("schema: invalid " + describe(path))
+	}
+	return &f, nil
+}
+
+// count returns the non-negative integer v of the keyword at path.
+func count(v any, path json.Pointer) (int, error) {
+	f, ok := v.(float64)
+	if !ok || f < 0 || f != math.Trunc(f) || f > math.MaxInt32 {
+		return 0, errors.New("schema: invalid " + describe(path))
+	}
+	return int(f), nil
+}
+
+// describe returns the description of the schema part at path for errors.
+func describe(path json.Pointer) string {
+	if len(path) == 0 {
+		return "schema"
+	}
+	return path[len(path)-1] + " at " + path.String()
+}
+
+// Validate checks the value v against the schema and returns all the
+// violations found, nil if v is valid.
+func (s *Schema) Validate(v any) []Violation {
+	var c checker
+	c.value(s, nil, v)
+	return c.violations
+}
+
+// ValidateStream reads the next JSON value from dec and checks it against
+// the schema like Validate does, it returns the first error of dec if
+// there is any (io.EOF if there are no more values). Only the values
+// checked against enum or const are decoded as a whole (with the settings
+// of dec), everything else is checked token by token.
+func (s *Schema) ValidateStream(dec *json.Decoder) ([]Violation, error) {
+	var c checker
+	if err := c.stream(dec, s, nil); err != nil {
+		return nil, err
+	}
+	return c.violations, nil
+}
+
+// checker collects violations.
+type checker struct {
+	violations []Violation
+}
+
+func (c *checker) report(path json.Pointer, keyword, msg string) {
+	c.violations = append(c.violations, Violation{path, keyword, msg})
+}
+
+// value checks the value v at path against s.
+func (c *checker) value(s *Schema, path json.Pointer, v any) {
+	if !c.start(s, path, jsonType(v)) {
+		return
+	}
+	c.enum(s, path, v)
+	switch v := v.(type) {
+	case json.OrderedObject:
+		seen := make(map[string]bool, len(v))
+		for _, m := range v {
+			if !seen[m.Key] {
+				seen[m.Key] = true
+				c.value(s.member(m.Key), appendPath(path, m.Key), m.Value)
+			}
+		}
+		c.object(s, path, seen)
+	case map[string]any:
+		seen := make(map[string]bool, len(v))
+		for _, k := range slices.Sorted(maps.Keys(v)) {
+			seen[k] = true
+			c.value(s.member(k), appendPath(path, k), v[k])
+		}
+		c.object(s, path, seen)
+	case []any:
+		for i, elem := range v {
+			c.value(s.items, appendPath(path, strconv.Itoa(i)), elem)
+		}
+		c.array(s, path, len(v))
+	default:
+		c.scalar(s, path, v)
+	}
+}
+
+// stream checks the next value from dec at path against s.
+func (c *checker) stream(dec *json.Decoder, s *Schema, path json.Pointer) error {
+	if s != nil && !s.reject && s.enum != nil {
+		var v any
+		if err := dec.Decode(&v); err != nil {
+			return err
+		}
+		c.value(s, path, v)
+		return nil
+	}
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	switch tok {
+	case json.Delim('{'):
+		if !c.start(s, path, "object") {
+			s = nil
+		}
+		seen := make(map[string]bool)
+		for dec.More() {
+			tok, err := dec.Token()
+			if err != nil {
+				return unexpected(err)
+			}
+			key := tok.(string)
+			var sub *Schema
+			if !seen[key] {
+				seen[key] = true
+				sub = s.member(key)
+			}
+			if err := c.stream(dec, sub, appendPath(path, key)); err != nil {
+				return unexpected(err)
+			}
+		}
+		c.object(s, path, seen)
+	case json.Delim('['):
+		if !c.start(s, path, "array") {
+			s = nil
+		}
+		n := 0
+		for ; dec.More(); n++ {
+			var sub *Schema
+			if s != nil {
+				sub = s.items
+			}
+			if err := c.stream(dec, sub, appendPath(path, strconv.Itoa(n))); err != nil {
+				return unexpected(err)
+			}
+		}
+		c.array(s, path, n)
+	default:
+		if c.start(s, path, jsonType(tok)) {
+			c.scalar(s, path, tok)
+		}
+		return nil
+	}
+	_, err = dec.Token() // The closing delimiter.
+	return unexpected(err)
+}
+
+// unexpected converts io.EOF in the middle of a value to
+// io.ErrUnexpectedEOF.
+func unexpected(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// start checks the value of the given type at path against the false
+// schema and the types of s and reports whether the rest of s is to be
+// checked.
+func (c *checker) start(s *Schema, path json.Pointer, typ string) bool {
+	if s == nil {
+		return false
+	}
+	if s.reject {
+		c.report(path, "false", "no value is allowed")
+		return false
+	}
+	if s.types != nil && !slices.ContainsFunc(s.types, func(t string) bool {
+		return t == typ || t == "number" && typ == "integer"
+	}) {
+		if typ == "integer" {
+			typ = "number"
+		}
+		c.report(path, "type", "got "+typ+", want "+strings.Join(s.types, " or "))
+	}
+	return true
+}
+
+// enum checks the value v at path against the enum or const of s.
+func (c *checker) enum(s *Schema, path json.Pointer, v any) {
+	if s.enum == nil || slices.ContainsFunc(s.enum, func(e any) bool { return equal(e, v) }) {
+		return
+	}
+	if s.constant {
+		c.report(path, "const", "value "+encode(v)+" is not "+encode(s.enum[0]))
+	} else {
+		c.report(path, "enum", "value "+encode(v)+" is not one of "+encode(s.enum))
+	}
+}
+
+// object checks the object at path with the given member names against s.
+func (c *checker) object(s *Schema, path json.Pointer, seen map[string]bool) {
+	if s == nil {
+		return
+	}
+	for _, name := range s.required {
+		if !seen[name] {
+			c.report(path, "required", "missing member "+strconv.Quote(name))
+		}
+	}
+}
+
+// array checks the array at path with n elements against s.
+func (c *checker) array(s *Schema, path json.Pointer, n int) {
+	if s == nil {
+		return
+	}
+	if s.minItems >= 0 && n < s.minItems {
+		c.report(path, "minItems", strconv.Itoa(n)+" elements, want at least "+strconv.Itoa(s.minItems))
+	}
+	if s.maxItems >= 0 && n > s.maxItems {
+		c.report(path, "maxItems", strconv.Itoa(n)+" elements, want at most "+strconv.Itoa(s.maxItems))
+	}
+}
+
+// scalar checks the string or number v at path against s.
+func (c *checker) scalar(s *Schema, path json.Pointer, v any) {
+	if str, ok := v.(string); ok {
+		n := utf8.RuneCountInString(str)
+		if s.minLength >= 0 && n < s.minLength {
+			c.report(path, "minLength", "length "+strconv.Itoa(n)+", want at least "+strconv.Itoa(s.minLength))
+		}
+		if s.maxLength >= 0 && n > s.maxLength {
+			c.report(path, "maxLength", "length "+strconv.Itoa(n)+", want at most "+strconv.Itoa(s.maxLength))
+		}
+		if s.pattern != nil && !s.pattern.MatchString(str) {
+			c.report(path, "pattern", "value "+encode(str)+" doesn't match "+strconv.Quote(s.pattern.String()))
+		}
+		return
+	}
+	f, ok := number(v)
+	if !ok {
+		return
+	}
+	for _, b := range []struct {
+		keyword string
+		limit   *float64
+		fails   func(f, limit float64) bool
+		want    string
+	}{
+		{"minimum", s.minimum, func(f, l float64) bool { return f < l }, "at least"},
+		{"maximum", s.maximum, func(f, l float64) bool { return f > l }, "at most"},
+		{"exclusiveMinimum", s.exclusiveMinimum, func(f, l float64) bool { return f <= l }, "greater than"},
+		{"exclusiveMaximum", s.exclusiveMaximum, func(f, l float64) bool { return f >= l }, "less than"},
+	} {
+		if b.limit != nil && b.fails(f, *b.limit) {
+			c.report(path, b.keyword, "value "+encode(v)+", want "+b.want+" "+encode(*b.limit))
+		}
+	}
+}
+
+// member returns the schema of the object member with the given name, nil
+// if it's not checked.
+func (s *Schema) member(name string) *Schema {
+	if s == nil {
+		return nil
+	}
+	if sub, ok := s.properties[name]; ok {
+		return sub
+	}
+	return s.additional
+}
+
+// jsonType returns the JSON Schema type name of the decoded value v.
+func jsonType(v any) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case json.OrderedObject, map[string]any:
+		return "object"
+	case []any:
+		return "array"
+	case string:
+		return "string"
+	}
+	if f, ok := number(v); ok {
+		if f == math.Trunc(f) {
+			return "integer"
+		}
+		return "number"
+	}
+	return reflect.TypeOf(v).String()
+}
+
+// number returns the value of the decoded number v.
+func number(v any) (float64, bool) {
+	switch v := v.(type) {
+	case float64:
+		return v, true
+	case int64:
+		return float64(v), true
+	case json.Number:
+		f, err := v.Float64()
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// equal reports whether the decoded values a and b are equal as JSON
+// values: numbers are compared by value and objects regardless of the
+// order of members.
+func equal(a, b any) bool {
+	if fa, ok := number(a); ok {
+		fb, ok := number(b)
+		return ok && fa == fb
+	}
+	if ma, ok := members(a); ok {
+		mb, ok := members(b)
+		if !ok || len(ma) != len(mb) {
+			return false
+		}
+		for k, va := range ma {
+			if vb, ok := mb[k]; !ok || !equal(va, vb) {
+				return false
+			}
+		}
+		return true
+	}
+	if aa, ok := a.([]any); ok {
+		ab, ok := b.([]any)
+		return ok && slices.EqualFunc(aa, ab, equal)
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// members returns the first members of the decoded object v.
+func members(v any) (map[string]any, bool) {
+	switch v := v.(type) {
+	case map[string]any:
+		return v, true
+	case json.OrderedObject:
+		m := make(map[string]any, len(v))
+		for _, member := range v {
+			if _, dup := m[member.Key]; !dup {
+				m[member.Key] = member.Value
+			}
+		}
+		return m, true
+	}
+	return nil, false
+}
+
+// encode returns v encoded as JSON for messages.
+func encode(v any) string {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return "?"
+	}
+	return string(b)
+}
+
+// appendPath returns a copy of path with the token added.
+func appendPath(path json.Pointer, tok string) json.Pointer {
+	return append(path[:len(path):len(path)], tok)
+}
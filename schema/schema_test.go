@@ -0,0 +1,164 @@
+package schema
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+
+	json "github.com/nspcc-dev/go-ordered-json"
+)
+
+const manifest = `{
+	"type": "object",
+	"required": ["name", "abi"],
+	"properties": {
+		"name": {"type": "string", "minLength": 1, "maxLength": 8},
+		"groups": {"type": "array", "maxItems": 2, "items": {
+			"type": "object",
+			"properties": {"pubkey": {"type": "string", "pattern": "^0[23][0-9a-f]{64}$"}},
+			"required": ["pubkey"]
+		}},
+		"abi": {"type": "object", "properties": {
+			"methods": {"type": "array", "minItems": 1, "items": {"type": "object",
+				"properties": {
+					"offset": {"type": "integer", "minimum": 0},
+					"safe": {"type": "boolean"},
+					"returntype": {"enum": ["Void", "Integer", "String"]}
+				}
+			}}
+		}},
+		"extra": {"type": ["object", "null"], "additionalProperties": {"type": "number", "exclusiveMaximum": 10}},
+		"version": {"const": {"major": 3, "minor": 0}},
+		"trusts": false
+	}
+}`
+
+func TestValidate(t *testing.T) {
+	s := MustCompile([]byte(manifest))
+	key := "02" + strings.Repeat("ab", 32)
+	for _, tc := range []struct {
+		doc  string
+		want []string
+	}{
+		{`{"name": "Token", "abi": {"methods": [{"offset": 0, "safe": true, "returntype": "Void"}]}}`, nil},
+		{`{"name": "Token", "groups": [{"pubkey": "` + key + `"}], "abi": {"methods": [{"offset": 1.0}]}, "extra": {"a": 9.5}, "version": {"minor": 0, "major": 3}}`, nil},
+		{`{"abi": {"methods": []}, "name": ""}`, []string{
+			`schema: /abi/methods: 0 elements, want at least 1`,
+			`schema: /name: length 0, want at least 1`,
+		}},
+		{`{"name": "x"}`, []string{`schema: /: missing member "abi"`}},
+		{`[]`, []string{`schema: /: got array, want object`}},
+		{`{"name": "Привет!", "abi": {"methods": [{"offset": -1, "safe": 1, "returntype": "Array"}, {"offset": 0.5}]}}`, []string{
+			`schema: /abi/methods/0/offset: value -1, want at least 0`,
+			`schema: /abi/methods/0/safe: got number, want boolean`,
+			`schema: /abi/methods/0/returntype: value "Array" is not one of ["Void","Integer","String"]`,
+			`schema: /abi/methods/1/offset: got number, want integer`,
+		}},
+		{`{"name": "LongerName", "abi": {"methods": [{}]}, "groups": [{"pubkey": "03"}, {}, {"pubkey": null}], "extra": {"x": 10, "y": "1"}}`, []string{
+			`schema: /name: length 10, want at most 8`,
+			`schema: /groups/0/pubkey: value "03" doesn't match "^0[23][0-9a-f]{64}$"`,
+			`schema: /groups/1: missing member "pubkey"`,
+			`schema: /groups/2/pubkey: got null, want string`,
+			`schema: /groups: 3 elements, want at most 2`,
+			`schema: /extra/x: value 10, want less than 10`,
+			`schema: /extra/y: got string, want number`,
+		}},
+		{`{"name": "a", "abi": {"methods": [{}]}, "extra": 1, "version": {"major": 3}, "trusts": []}`, []string{
+			`schema: /extra: got number, want object or null`,
+			`schema: /version: value {"major":3} is not {"major":3,"minor":0}`,
+			`schema: /trusts: no value is allowed`,
+		}},
+	} {
+		var v any
+		if err := json.UnmarshalWithOptions([]byte(tc.doc), &v, json.Options{UseOrderedObject: true}); err != nil {
+			t.Fatal(err)
+		}
+		if got := messages(s.Validate(v)); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s:\ngot  %q\nwant %q", tc.doc, got, tc.want)
+		}
+
+		dec := json.NewDecoder(strings.NewReader(tc.doc))
+		vs, err := s.ValidateStream(dec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := messages(vs); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("stream %s:\ngot  %q\nwant %q", tc.doc, got, tc.want)
+		}
+	}
+}
+
+func TestValidateMap(t *testing.T) {
+	s := MustCompile([]byte(`{"properties": {"b": {"type": "string"}, "a": {"minimum": 1}}, "required": ["c"]}`))
+	var v any
+	if err := json.Unmarshal([]byte(`{"b": 1, "a": 0}`), &v); err != nil {
+		t.Fatal(err)
+	}
+	want := []Violation{
+		{json.Pointer{"a"}, "minimum", "value 0, want at least 1"},
+		{json.Pointer{"b"}, "type", "got number, want string"},
+		{nil, "required", `missing member "c"`},
+	}
+	if got := s.Validate(v); !reflect.DeepEqual(got, want) {
+		t.Errorf("got  %q\nwant %q", got, want)
+	}
+}
+
+func TestValidateStream(t *testing.T) {
+	s := MustCompile([]byte(`{"items": {"type": "integer"}}`))
+	dec := json.NewDecoder(strings.NewReader(`[1, 2] [3, "x"] [4,`))
+	for _, want := range [][]string{nil, {`schema: /1: got string, want integer`}} {
+		vs, err := s.ValidateStream(dec)
+		if got := messages(vs); err != nil || !reflect.DeepEqual(got, want) {
+			t.Errorf("got %q, %v, want %q", got, err, want)
+		}
+	}
+	if _, err := s.ValidateStream(dec); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("truncated value: got error %v", err)
+	}
+	if _, err := s.ValidateStream(json.NewDecoder(bytes.NewReader(nil))); err != io.EOF {
+		t.Errorf("no values: got error %v", err)
+	}
+}
+
+func TestCompile(t *testing.T) {
+	for _, in := range []string{
+		`1`,
+		`{"type": "int"}`,
+		`{"type": 1}`,
+		`{"properties": {"a": 1}}`,
+		`{"required": "a"}`,
+		`{"items": []}`,
+		`{"pattern": "("}`,
+		`{"minimum": "1"}`,
+		`{"maxLength": -1}`,
+		`{"minItems": 1.5}`,
+		`{"enum": 1}`,
+	} {
+		if _, err := Compile([]byte(in)); err == nil {
+			t.Errorf("%s: no error", in)
+		}
+	}
+	for _, in := range []string{`true`, `{}`, `{"title": "x", "$schema": "y"}`} {
+		s, err := Compile([]byte(in))
+		if err != nil {
+			t.Errorf("%s: %v", in, err)
+			continue
+		}
+		if vs := s.Validate([]any{1.0}); vs != nil {
+			t.Errorf("%s: got %v", in, vs)
+		}
+	}
+}
+
+// messages returns the errors of violations.
+func messages(vs []Violation) []string {
+	var res []string
+	for _, v := range vs {
+		res = append(res, v.Error())
+	}
+	return res
+}
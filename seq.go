@@ -0,0 +1,177 @@
+package json
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"strconv"
+)
+
+// recordSeparator starts every JSON text in a sequence (RFC 7464).
+const recordSeparator = 0x1E
+
+// A SeqDecoder reads and decodes JSON text sequences (RFC 7464, also known
+// as application/json-seq) where every JSON value is preceded by an ASCII
+// record separator (RS) character. A corrupt record is reported and skipped,
+// decoding resynchronizes at the next RS. Empty records are ignored.
+type SeqDecoder struct {
+	r      *bufio.Reader
+	d      decodeState
+	buf    []byte
+	record int
+	err    error
+}
+
+// A RecordError describes an error found while decoding a particular
+// record of the SeqDecoder input.
+type RecordError struct {
+	Record int   // number of the record starting from 1, 0 for data before the first RS
+	Err    error // decoding error
+}
+
+func (e *RecordError) Error() string {
+	return "record " + strconv.Itoa(e.Record) + ": " + e.Err.Error()
+}
+
+func (e *RecordError) Unwrap() error { return e.Err }
+
+var (
+	errSeqStart     = errors.New("json: text sequence doesn't start with a record separator")
+	errSeqTruncated = errors.New("json: possibly truncated number at the end of the record")
+)
+
+// NewSeqDecoder returns a new decoder that reads a JSON text sequence from r.
+func NewSeqDecoder(r io.Reader) *SeqDecoder {
+	return &SeqDecoder{r: bufio.NewReader(r), record: -1}
+}
+
+// UseNumber causes the SeqDecoder to unmarshal a number into an any as a
+// Number instead of as a float64.
+func (dec *SeqDecoder) UseNumber() { dec.d.useNumber = true }
+
+// UseOrderedObject causes the SeqDecoder to unmarshal an object into an
+// any as a OrderedObject instead of as a map[string]any.
+func (dec *SeqDecoder) UseOrderedObject() { dec.d.useOrderedObject = true }
+
+// SetDuplicateKeyPolicy specifies how objects with repeated keys are
+// decoded, see Decoder.SetDuplicateKeyPolicy.
+func (dec *SeqDecoder) SetDuplicateKeyPolicy(p DuplicateKeyPolicy) {
+	dec.d.duplicateKeys = p
+}
+
+// SetMaxDepth limits the nesting of arrays and objects, see
+// Decoder.SetMaxDepth.
+func (dec *SeqDecoder) SetMaxDepth(n int) {
+	dec.d.scan.maxDepth = n
+}
+
+// SetLimits restricts the size of the input, see Decoder.SetLimits.
+// MaxBytes applies to every record excluding the separator.
+func (dec *SeqDecoder) SetLimits(l Limits) {
+	dec.d.scan.limits = l
+}
+
+// Record returns the number of the record holding the value returned by the
+// last Decode call.
+func (dec *SeqDecoder) Record() int {
+	return dec.record
+}
+
+// Decode reads the next record from its input and stores the value it
+// holds in the value pointed to by v. It returns io.EOF when there are no
+// more values. Errors specific to the record are returned as a
+// *RecordError, after them Decode can be called again to proceed to the
+// next record.
+//
+// See the documentation for Unmarshal for details about
+// the conversion of JSON into a Go value.
+func (dec *SeqDecoder) Decode(v any) error {
+	for {
+		if dec.err != nil {
+			return dec.err
+		}
+		data, err := dec.readRecord()
+		if err != nil {
+			return err
+		}
+		if dec.record == 0 {
+			if nonSpace(data) {
+				return &RecordError{Record: 0, Err: errSeqStart}
+			}
+			continue
+		}
+		if !nonSpace(data) {
+			continue
+		}
+		if err := checkValid(data, &dec.d.scan); err != nil {
+			return &RecordError{Record: dec.record, Err: err}
+		}
+		// A number that's not followed by whitespace could have been cut.
+		if c := data[len(data)-1]; '0' <= c && c <= '9' && isNumberRecord(data) {
+			return &RecordError{Record: dec.record, Err: errSeqTruncated}
+		}
+		dec.d.init(data)
+		if err := dec.d.unmarshal(v); err != nil {
+			return &RecordError{Record: dec.record, Err: err}
+		}
+		return nil
+	}
+}
+
+// isNumberRecord reports whether the (valid) record holds a number.
+func isNumberRecord(data []byte) bool {
+	for _, c := range data {
+		if !isSpace(c) {
+			return c == '-' || '0' <= c && c <= '9'
+		}
+	}
+	return false
+}
+
+// readRecord returns the data up to the next record separator (which
+// is consumed) or the end of input.
+func (dec *SeqDecoder) readRecord() ([]byte, error) {
+	dec.buf = dec.buf[:0]
+	dec.record++
+	limit := dec.d.scan.limits.MaxBytes
+	for {
+		chunk, err := dec.r.ReadSlice(recordSeparator)
+		if len(dec.buf) > 0 || errors.Is(err, bufio.ErrBufferFull) {
+			dec.buf = append(dec.buf, chunk...)
+			chunk = dec.buf
+		}
+		if !errors.Is(err, bufio.ErrBufferFull) {
+			if err == nil {
+				chunk = chunk[:len(chunk)-1]
+			} else {
+				dec.err = err
+				if len(chunk) == 0 {
+					return nil, err
+				}
+			}
+			if limit > 0 && int64(len(chunk)) > limit {
+				return nil, &RecordError{Record: dec.record, Err: &LimitError{Limit: "MaxBytes", Max: limit, Offset: limit}}
+			}
+			return chunk, nil
+		}
+		if limit > 0 && int64(len(dec.buf)) > limit {
+			// Skip the rest of the record.
+			for errors.Is(err, bufio.ErrBufferFull) {
+				_, err = dec.r.ReadSlice(recordSeparator)
+			}
+			if err != nil {
+				dec.err = err
+			}
+			return nil, &RecordError{Record: dec.record, Err: &LimitError{Limit: "MaxBytes", Max: limit, Offset: limit}}
+		}
+	}
+}
+
+// NewSeqEncoder returns a new encoder that writes a JSON text sequence to
+// w: every value is preceded by a record separator and followed by a
+// newline, as RFC 7464 requires.
+func NewSeqEncoder(w io.Writer) *Encoder {
+	enc := NewEncoder(w)
+	enc.seq = true
+	return enc
+}
@@ -0,0 +1,72 @@
+package json
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSeqDecoder(t *testing.T) {
+	const in = "junk\x1e{\"a\": 1}\n\x1e\x1e[1, 2\n\x1e\"ok\"\n\x1e123\x1e-5\n\x1e{\"b\":\n  null}\n"
+	dec := NewSeqDecoder(strings.NewReader(in))
+	dec.UseOrderedObject()
+	for _, tc := range []struct {
+		record int
+		want   any
+		err    error
+	}{
+		{0, nil, errSeqStart},
+		{1, OrderedObject{{"a", float64(1)}}, nil},
+		{3, nil, &SyntaxError{}},
+		{4, "ok", nil},
+		{5, nil, errSeqTruncated},
+		{6, float64(-5), nil},
+		{7, OrderedObject{{"b", nil}}, nil},
+	} {
+		var v any
+		err := dec.Decode(&v)
+		var re *RecordError
+		switch {
+		case tc.err != nil && (!errors.As(err, &re) || re.Record != tc.record || reflect.TypeOf(re.Err) != reflect.TypeOf(tc.err)):
+			t.Errorf("record %d: got error %v, want %v", tc.record, err, tc.err)
+		case tc.err == nil && err != nil:
+			t.Errorf("record %d: unexpected error %v", tc.record, err)
+		case tc.err == nil && (dec.Record() != tc.record || !reflect.DeepEqual(v, tc.want)):
+			t.Errorf("record %d: got %#v in record %d, want %#v", tc.record, v, dec.Record(), tc.want)
+		}
+	}
+	var v any
+	if err := dec.Decode(&v); !errors.Is(err, io.EOF) {
+		t.Errorf("Decode at the end: got %v, want EOF", err)
+	}
+}
+
+func TestSeqEncoder(t *testing.T) {
+	var buf bytes.Buffer
+	enc := NewSeqEncoder(&buf)
+	if err := enc.Encode([]int{1}); err != nil {
+		t.Fatal(err)
+	}
+	enc.SetIndent("", " ")
+	if err := enc.Encode(map[string]int{"a": 1}); err != nil {
+		t.Fatal(err)
+	}
+	const want = "\x1e[1]\n\x1e{\n \"a\": 1\n}\n"
+	if got := buf.String(); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	dec := NewSeqDecoder(&buf)
+	var v any
+	for range 2 {
+		if err := dec.Decode(&v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := dec.Decode(&v); !errors.Is(err, io.EOF) {
+		t.Errorf("Decode at the end: got %v, want EOF", err)
+	}
+}
@@ -0,0 +1,136 @@
+package json
+
+import (
+	"bytes"
+	"errors"
+	"strconv"
+)
+
+// Replace returns a copy of the indexed document with the value at path
+// replaced by the JSON value. Only the bytes of the old value are swapped,
+// the rest of the document is copied as is, so it's much cheaper than
+// decoding, modifying and encoding the document again. The Index still
+// refers to the original document, it must be rebuilt to edit the result.
+// A missing value yields a *PathError.
+func (ix *Index) Replace(value RawMessage, path ...string) ([]byte, error) {
+	value, err := spliceValue(value)
+	if err != nil {
+		return nil, err
+	}
+	_, start, end, err := ix.find(path)
+	if err != nil {
+		return nil, err
+	}
+	return splice(ix.data, start, end, value), nil
+}
+
+// Insert returns a copy of the indexed document with the JSON value added
+// at path like Replace does. The last element of the path is the name of the
+// new object member written after the existing ones or the index of the new
+// array element, the elements from this index on are moved by one, and the
+// index equal to the length of the array appends the value. An existing
+// object member is an error, Replace is to be used for it.
+func (ix *Index) Insert(value RawMessage, path ...string) ([]byte, error) {
+	if len(path) == 0 {
+		return nil, errors.New("json: Insert with empty path")
+	}
+	value, err := spliceValue(value)
+	if err != nil {
+		return nil, err
+	}
+	name := path[len(path)-1]
+	_, start, end, err := ix.find(path[:len(path)-1])
+	if err != nil {
+		return nil, err
+	}
+	data := ix.data
+	var b []byte
+	switch data[start] {
+	case '{':
+		if _, _, _, err := ix.find(path); err == nil {
+			return nil, errors.New("json: Insert of existing member " + strconv.Quote(name))
+		}
+		b = appendString(b, name, encOpts{escapeHTML: true})
+		b = append(append(b, ':'), value...)
+	case '[':
+		index, err := strconv.Atoi(name)
+		if err == nil && index >= 0 && name[0] != '+' {
+			if member, _, _, err := ix.find(path); err == nil {
+				b = append(append(b, value...), ',')
+				return splice(data, member, member, b), nil
+			}
+			if index == countElements(data, start) {
+				b = value
+				break
+			}
+		}
+		fallthrough
+	default:
+		_, _, _, err := ix.find(path)
+		return nil, err
+	}
+	last := end - 2 // The last byte before the closing bracket.
+	for isSpace(data[last]) {
+		last--
+	}
+	if data[last] != data[start] {
+		b = append([]byte{','}, b...)
+	}
+	return splice(data, last+1, last+1, b), nil
+}
+
+// Delete returns a copy of the indexed document without the object member
+// or the array element at path like Replace does. A missing value yields a
+// *PathError.
+func (ix *Index) Delete(path ...string) ([]byte, error) {
+	if len(path) == 0 {
+		return nil, errors.New("json: Delete with empty path")
+	}
+	member, _, end, err := ix.find(path)
+	if err != nil {
+		return nil, err
+	}
+	data := ix.data
+	// Remove the comma following the member or, for the last one, the
+	// comma preceding it.
+	if next := skipSpace(data, end); data[next] == ',' {
+		end = skipSpace(data, next+1)
+	} else {
+		prev := member - 1
+		for isSpace(data[prev]) {
+			prev--
+		}
+		if data[prev] == ',' {
+			member = prev
+		}
+	}
+	return splice(data, member, end, nil), nil
+}
+
+// spliceValue checks value to be valid JSON and returns it without
+// surrounding spaces.
+func spliceValue(value RawMessage) (RawMessage, error) {
+	var scan scanner
+	if err := checkValid(value, &scan); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSpace(value), nil
+}
+
+// splice returns a copy of data with data[i:j] replaced by b.
+func splice(data []byte, i, j int, b []byte) []byte {
+	res := make([]byte, 0, len(data)-(j-i)+len(b))
+	res = append(res, data[:i]...)
+	res = append(res, b...)
+	return append(res, data[j:]...)
+}
+
+// countElements returns the number of elements of the valid array starting
+// at data[off].
+func countElements(data []byte, off int) int {
+	n := 0
+	for off = skipSpace(data, off+1); data[off] != ']'; off = skipMember(data, off) {
+		n++
+	}
+	return n
+}
@@ -0,0 +1,77 @@
+package json
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestIndexEdits(t *testing.T) {
+	const in = `{"a": [1, {"b": "x"}], "c": { }, "d": [ ], "e": 2}`
+	for _, tc := range []struct {
+		op    string
+		value string
+		path  []string
+		want  string
+	}{
+		{"replace", ` 5 `, []string{"e"}, `{"a": [1, {"b": "x"}], "c": { }, "d": [ ], "e": 5}`},
+		{"replace", `{"z": null}`, []string{"a", "1"}, `{"a": [1, {"z": null}], "c": { }, "d": [ ], "e": 2}`},
+		{"replace", `"y"`, []string{"a", "1", "b"}, `{"a": [1, {"b": "y"}], "c": { }, "d": [ ], "e": 2}`},
+		{"replace", `[]`, nil, `[]`},
+		{"insert", `true`, []string{"f"}, `{"a": [1, {"b": "x"}], "c": { }, "d": [ ], "e": 2,"f":true}`},
+		{"insert", `1`, []string{"c", "<"}, `{"a": [1, {"b": "x"}], "c": {"\u003C":1 }, "d": [ ], "e": 2}`},
+		{"insert", `0`, []string{"a", "0"}, `{"a": [0,1, {"b": "x"}], "c": { }, "d": [ ], "e": 2}`},
+		{"insert", `2`, []string{"a", "2"}, `{"a": [1, {"b": "x"},2], "c": { }, "d": [ ], "e": 2}`},
+		{"insert", `0`, []string{"d", "0"}, `{"a": [1, {"b": "x"}], "c": { }, "d": [0 ], "e": 2}`},
+		{"insert", `1`, []string{"a", "1", "c"}, `{"a": [1, {"b": "x","c":1}], "c": { }, "d": [ ], "e": 2}`},
+		{"delete", ``, []string{"e"}, `{"a": [1, {"b": "x"}], "c": { }, "d": [ ]}`},
+		{"delete", ``, []string{"a"}, `{"c": { }, "d": [ ], "e": 2}`},
+		{"delete", ``, []string{"a", "1"}, `{"a": [1], "c": { }, "d": [ ], "e": 2}`},
+		{"delete", ``, []string{"a", "1", "b"}, `{"a": [1, {}], "c": { }, "d": [ ], "e": 2}`},
+	} {
+		ix, err := BuildIndex([]byte(in))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []byte
+		switch tc.op {
+		case "replace":
+			got, err = ix.Replace(RawMessage(tc.value), tc.path...)
+		case "insert":
+			got, err = ix.Insert(RawMessage(tc.value), tc.path...)
+		case "delete":
+			got, err = ix.Delete(tc.path...)
+		}
+		if err != nil || string(got) != tc.want {
+			t.Errorf("%s %q: got %s, %v, want %s", tc.op, tc.path, got, err, tc.want)
+		}
+		if err == nil && !Valid(got) {
+			t.Errorf("%s %q: invalid result", tc.op, tc.path)
+		}
+	}
+
+	ix, _ := BuildIndex([]byte(in))
+	var pe *PathError
+	for _, err := range []error{
+		must2(ix.Replace(RawMessage(`1`), "x")),
+		must2(ix.Insert(RawMessage(`1`), "x", "y")),
+		must2(ix.Insert(RawMessage(`1`), "a", "3")),
+		must2(ix.Insert(RawMessage(`1`), "a", "-1")),
+		must2(ix.Insert(RawMessage(`1`), "e", "y")),
+		must2(ix.Delete("a", "2")),
+	} {
+		if !errors.As(err, &pe) {
+			t.Errorf("got %v, want PathError", err)
+		}
+	}
+	if _, err := ix.Insert(RawMessage(`1`), "e"); err == nil {
+		t.Error("existing member: no error")
+	}
+	if _, err := ix.Replace(RawMessage(`{`), "e"); err == nil {
+		t.Error("invalid value: no error")
+	}
+	if _, err := ix.Delete(); err == nil {
+		t.Error("empty path: no error")
+	}
+}
+
+func must2(_ []byte, err error) error { return err }
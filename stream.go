@@ -6,21 +6,30 @@ package json
 
 import (
 	"bytes"
+	"context"
 	"errors"
+	"fmt"
 	"io"
+	"iter"
+	"reflect"
 )
 
 // A Decoder reads and decodes JSON values from an input stream.
 type Decoder struct {
-	r     io.Reader
-	buf   []byte
-	d     decodeState
-	scanp int // start of unread data in buf
-	scan  scanner
-	err   error
+	r       io.Reader
+	buf     []byte
+	d       decodeState
+	scanp   int   // start of unread data in buf
+	scanned int64 // amount of data already scanned
+	lines   int   // newlines in the data already scanned
+	column  int   // bytes after the last newline in the data already scanned
+	scan    scanner
+	err     error
+	shared  bool // buf is the caller's data, see NewBytesDecoder
 
-	tokenState int
-	tokenStack []int
+	tokenState  int
+	tokenStack  []int
+	tokenOffset int64 // input offset of the last token returned
 }
 
 // NewDecoder returns a new decoder that reads from r.
@@ -31,14 +40,163 @@ func NewDecoder(r io.Reader) *Decoder {
 	return &Decoder{r: r}
 }
 
+// NewBytesDecoder returns a new decoder that reads the values from data
+// in place, without copying it into a buffer, which is useful for large
+// memory-mapped files. data is not modified (unless AllowComments or
+// AllowTrailingCommas is used, then the decoder works on a copy of it),
+// but it must not be modified while the Decoder is used either. There is
+// no io.ReaderAt variant: a file that is not memory-mapped can be decoded
+// with NewDecoder and an io.SectionReader, which copies it through the
+// buffer of the Decoder. DecodePath and BuildIndex take byte slices and
+// work on them in place too, they need the whole document in memory.
+func NewBytesDecoder(data []byte) *Decoder {
+	return &Decoder{r: bytes.NewReader(nil), buf: data[:len(data):len(data)], shared: true}
+}
+
 // UseNumber causes the Decoder to unmarshal a number into an any as a
 // Number instead of as a float64.
 func (dec *Decoder) UseNumber() { dec.d.useNumber = true }
 
+// SetNaming makes the Decoder match object members to struct fields
+// without names given in their tags using the field names converted
+// according to c (see Encoder.SetNaming), so that the output of an
+// Encoder with the same convention can be decoded. Members still match
+// names case-insensitively if there is no exact match.
+func (dec *Decoder) SetNaming(c NamingConvention) { dec.d.naming = c.valid() }
+
 // UseOrderedObject causes the Decoder to unmarshal an object into an any
 // as a OrderedObject instead of as a map[string]any.
 func (dec *Decoder) UseOrderedObject() { dec.d.useOrderedObject = true }
 
+// UseArena causes the Decoder to allocate the values it unmarshals into
+// interfaces and OrderedObject from a, see Arena for their lifetime. Passing
+// nil returns to the regular allocation.
+func (dec *Decoder) UseArena(a *Arena) { dec.d.arena = a }
+
+// UseBigNumbers causes the Decoder to unmarshal an integer that doesn't fit
+// into int64 or uint64 into an any as a *big.Int and a number that's out of
+// float64 range as a *big.Float instead of losing precision (or failing).
+// Other numbers are not affected and follow UseNumber setting.
+func (dec *Decoder) UseBigNumbers() { dec.d.useBigNumbers = true }
+
+// UseInt64 causes the Decoder to unmarshal a number without fraction and
+// exponent into an any as an int64, integers that don't fit into it are
+// unmarshaled as a Number (or *big.Int with UseBigNumbers). Other numbers
+// are not affected and follow UseNumber setting.
+func (dec *Decoder) UseInt64() { dec.d.useInt64 = true }
+
+// DisallowInexactNumbers causes the Decoder to return an UnmarshalTypeError
+// when a number would be rounded to be stored into a floating-point value
+// (including an any holding a float64), like 12345678901234567 or 1e-400
+// for float64. A number is considered to be exact when the shortest decimal
+// representation of the result has the same value, so 0.1 is fine. Integer
+// values out of range are always rejected.
+func (dec *Decoder) DisallowInexactNumbers() { dec.d.exactNumbers = true }
+
+// SetMaxDepth limits the nesting of arrays and objects accepted by the
+// Decoder to n levels, deeper values make Decode (and Token) fail with
+// a DepthError. A non-positive n restores the default limit of 10000.
+func (dec *Decoder) SetMaxDepth(n int) {
+	dec.scan.maxDepth = n
+}
+
+// SetLimits restricts the size of the input accepted by the Decoder,
+// values exceeding any of the limits make Decode fail with a LimitError.
+// MaxBytes applies to every value read by Decode, the Token API only
+// enforces it along with MaxStringLen for strings and numbers.
+func (dec *Decoder) SetLimits(l Limits) {
+	dec.scan.limits = l
+}
+
+// AllowComments causes the Decoder to accept // and /* */ comments wherever
+// whitespace is allowed in the input, they're treated as whitespace. Values
+// returned by DecodeRaw and RawToken have comments replaced with spaces.
+func (dec *Decoder) AllowComments() { dec.scan.comments = true }
+
+// AllowTrailingCommas causes the Decoder to accept a comma after the last
+// member of an object or element of an array, like in `[1, 2,]`. Values
+// returned by DecodeRaw and RawToken have such commas replaced with spaces.
+func (dec *Decoder) AllowTrailingCommas() { dec.scan.trailingCommas = true }
+
+// AllowWeakTyping causes the Decoder to convert scalar values of mismatching
+// types instead of returning an UnmarshalTypeError: a string holding a JSON
+// number is decoded into numeric types, a string accepted by
+// strconv.ParseBool into a bool (an empty string means 0 or false for
+// both), a number into a string as is and into a bool as whether it's
+// non-zero.
+func (dec *Decoder) AllowWeakTyping() { dec.d.weakTypes = true }
+
+// AllowQuotedIntegers causes the Decoder to accept JSON strings holding
+// integers (without fractions and exponents) for values of integer types
+// in addition to numbers, as written by an Encoder with SetJSSafeIntegers.
+func (dec *Decoder) AllowQuotedIntegers() { dec.d.quotedInts = true }
+
+// DisallowInvalidUTF8 causes the Decoder to return a SyntaxError for strings
+// containing invalid UTF-8 byte sequences, by default they're replaced with
+// U+FFFD.
+func (dec *Decoder) DisallowInvalidUTF8() { dec.d.strictUTF8 = true }
+
+// DetectEncoding causes the Decoder to skip a byte order mark at the
+// beginning of input and to convert UTF-16 and UTF-32 (big or little endian)
+// input to UTF-8. The encoding is detected by the byte order mark or, if
+// there is none, using RFC 4627 heuristics (zero bytes among the first four
+// ones). It has no effect after the first call to Decode or Token. A
+// Decoder returned by NewBytesDecoder converts the whole data at once, it
+// still works on data in place if it's UTF-8.
+func (dec *Decoder) DetectEncoding() {
+	if _, ok := dec.r.(*charsetReader); ok || dec.scanned != 0 || dec.scanp != 0 || len(dec.buf) != 0 && !dec.shared {
+		return
+	}
+	if dec.shared {
+		var converted bool
+		dec.buf, converted = convertCharset(dec.buf)
+		dec.shared = !converted
+	}
+	dec.r = &charsetReader{r: dec.r}
+}
+
+// SetSurrogatePolicy specifies how \u escapes of unpaired UTF-16 surrogates
+// in strings are decoded.
+func (dec *Decoder) SetSurrogatePolicy(p SurrogatePolicy) {
+	dec.d.surrogates = p
+}
+
+// OnUnknownField sets a function the Decoder calls for every object member
+// that is skipped because it doesn't correspond to any field of the target
+// struct, decoding proceeds normally. path is the path of the object (empty
+// for the top-level value) in the form used by UnmarshalTypeError, raw is
+// the member's value which is only valid during the call. Passing nil
+// removes the hook.
+func (dec *Decoder) OnUnknownField(f func(path, key string, raw RawMessage)) {
+	dec.d.unknownField = f
+}
+
+// InternKeys causes the Decoder to reuse a single string for all the
+// occurrences of an object key instead of allocating a new one each time,
+// which saves memory when decoding many objects of the same shape. Keys
+// are remembered for the lifetime of the Decoder (up to an internal limit
+// on their number), so it's not worth it for inputs with ever new keys.
+func (dec *Decoder) InternKeys() {
+	if dec.d.intern == nil {
+		dec.d.intern = make(map[string]string)
+	}
+}
+
+// InternStrings is like InternKeys, but applies to string values not longer
+// than maxLen bytes too.
+func (dec *Decoder) InternStrings(maxLen int) {
+	dec.InternKeys()
+	dec.d.internMaxLen = maxLen
+}
+
+// SetNullPolicy specifies how JSON null is decoded into values that can't
+// be nil. The default is NullIgnore, see NullPolicy for details.
+func (dec *Decoder) SetNullPolicy(p NullPolicy) { dec.d.nulls = p }
+
+// SetDuplicateKeyPolicy specifies how objects with repeated keys are decoded.
+// The default is KeepAll, see DuplicateKeyPolicy for details.
+func (dec *Decoder) SetDuplicateKeyPolicy(p DuplicateKeyPolicy) { dec.d.duplicateKeys = p }
+
 // Decode reads the next JSON-encoded value from its
 // input and stores it in the value pointed to by v.
 //
@@ -54,7 +212,7 @@ func (dec *Decoder) Decode(v any) error {
 	}
 
 	if !dec.tokenValueAllowed() {
-		return &SyntaxError{msg: "not at beginning of value"}
+		return dec.syntaxError("not at beginning of value")
 	}
 
 	// Read whole value into buffer.
@@ -76,6 +234,50 @@ func (dec *Decoder) Decode(v any) error {
 	return err
 }
 
+// DecodeContext is like Decode, but it aborts with the ctx error once ctx
+// is done. ctx is checked before every read from the underlying reader
+// (a blocked read is not interrupted though) and periodically while the
+// value is decoded. If that happens while the value is being read, the next
+// call to the Decoder starts reading it from the beginning, otherwise the
+// value is consumed and v may be partially filled. ctx is also passed to
+// the UnmarshalJSONContext methods of UnmarshalerContext values.
+func (dec *Decoder) DecodeContext(ctx context.Context, v any) error {
+	dec.d.ctx = ctx
+	defer func() { dec.d.ctx = nil }()
+	return dec.Decode(v)
+}
+
+// DecodeRaw reads the next JSON-encoded value from its input and returns
+// a copy of its exact bytes. The value is checked to be valid JSON, but
+// nothing is unescaped or converted, so it can be routed elsewhere or
+// decoded later at a fraction of the cost of a full Decode.
+func (dec *Decoder) DecodeRaw() (RawMessage, error) {
+	if dec.err != nil {
+		return nil, dec.err
+	}
+
+	if err := dec.tokenPrepareForDecode(); err != nil {
+		return nil, err
+	}
+
+	if !dec.tokenValueAllowed() {
+		return nil, dec.syntaxError("not at beginning of value")
+	}
+
+	n, err := dec.readValue()
+	if err != nil {
+		return nil, err
+	}
+	raw := bytes.TrimLeft(dec.buf[dec.scanp:dec.scanp+n], " \t\r\n")
+	m := make(RawMessage, len(raw))
+	copy(m, raw)
+	dec.scanp += n
+
+	dec.tokenValueEnd()
+
+	return m, nil
+}
+
 // Buffered returns a reader of the data remaining in the Decoder's
 // buffer. The reader is valid until the next call to Decode.
 func (dec *Decoder) Buffered() io.Reader {
@@ -107,11 +309,15 @@ Input:
 				break Input
 			}
 			if v == scanError {
+				dec.locate(dec.scan.err, scanp+i)
 				dec.err = dec.scan.err
 				return 0, dec.scan.err
 			}
 		}
 		scanp = len(dec.buf)
+		if err := dec.checkSize(scanp); err != nil {
+			return 0, err
+		}
 
 		// Did the last read have an error?
 		// Delayed until now to allow buffer scan.
@@ -120,6 +326,10 @@ Input:
 				if dec.scan.step(&dec.scan, ' ') == scanEnd {
 					break Input
 				}
+				if dec.scan.comments {
+					dec.own()
+					stripComments(dec.buf[dec.scanp:], true)
+				}
 				if nonSpace(dec.buf) {
 					err = io.ErrUnexpectedEOF
 				}
@@ -128,17 +338,84 @@ Input:
 			return 0, err
 		}
 
+		if dec.d.ctx != nil {
+			if err := dec.d.ctx.Err(); err != nil {
+				return 0, err
+			}
+		}
 		n := scanp - dec.scanp
 		err = dec.refill()
 		scanp = dec.scanp + n
 	}
+	if err := dec.checkSize(scanp); err != nil {
+		return 0, err
+	}
+	// Decoding is done by a scanner that doesn't know about comments
+	// and trailing commas.
+	if dec.scan.comments {
+		dec.own()
+		stripComments(dec.buf[dec.scanp:scanp], false)
+	}
+	if dec.scan.trailingCommas {
+		dec.own()
+		stripTrailingCommas(dec.buf[dec.scanp:scanp])
+	}
+	if dec.d.strictUTF8 {
+		if i := invalidUTF8(dec.buf[dec.scanp:scanp]); i >= 0 {
+			pos := dec.scanp + i
+			err := &SyntaxError{msg: errInvalidUTF8, Offset: dec.scanned + int64(pos) + 1}
+			dec.locate(err, pos)
+			dec.err = err
+			return 0, err
+		}
+	}
+	if dec.d.surrogates == SurrogateError {
+		if i := loneSurrogate(dec.buf[dec.scanp:scanp]); i >= 0 {
+			pos := dec.scanp + i
+			err := &SyntaxError{msg: errLoneSurrogate, Offset: dec.scanned + int64(pos) + 1}
+			dec.locate(err, pos)
+			dec.err = err
+			return 0, err
+		}
+	}
 	return scanp - dec.scanp, nil
 }
 
+// own makes dec.buf a private copy of the caller's data before it's
+// modified in place.
+func (dec *Decoder) own() {
+	if dec.shared {
+		dec.buf = bytes.Clone(dec.buf)
+		dec.shared = false
+	}
+}
+
+// checkSize returns a LimitError if the value being read that ends at
+// (or is still incomplete at) scanp exceeds MaxBytes.
+func (dec *Decoder) checkSize(scanp int) error {
+	limit := dec.scan.limits.MaxBytes
+	if limit <= 0 || int64(scanp-dec.scanp) <= limit {
+		return nil
+	}
+	dec.err = &LimitError{Limit: "MaxBytes", Max: limit, Offset: dec.scanned + int64(dec.scanp) + limit}
+	return dec.err
+}
+
 func (dec *Decoder) refill() error {
+	if dec.shared {
+		return io.EOF // All the data is in the buffer.
+	}
 	// Make room to read more into the buffer.
 	// First slide down data already consumed.
 	if dec.scanp > 0 {
+		dec.scanned += int64(dec.scanp)
+		done := dec.buf[:dec.scanp]
+		if i := bytes.LastIndexByte(done, '\n'); i >= 0 {
+			dec.lines += bytes.Count(done, []byte{'\n'})
+			dec.column = len(done) - i - 1
+		} else {
+			dec.column += len(done)
+		}
 		n := copy(dec.buf, dec.buf[dec.scanp:])
 		dec.buf = dec.buf[:n]
 		dec.scanp = 0
@@ -170,32 +447,84 @@ func nonSpace(b []byte) bool {
 
 // An Encoder writes JSON values to an output stream.
 type Encoder struct {
-	w          io.Writer
-	err        error
-	escapeHTML bool
+	w    io.Writer
+	err  error
+	opts encOpts
 
 	indentBuf    *bytes.Buffer
 	indentPrefix string
 	indentValue  string
+	lines        bool // newline-delimited output, see NewLinesEncoder
+	noNewline    bool // don't terminate values with a newline, see SetTrailingNewline
+	seq          bool // JSON text sequence output, see NewSeqEncoder
+	rawTrusted   bool // EncodeRaw doesn't validate its input, see SetValidateRaw
+	sizeHint     int  // expected size of values, see SetSizeHint
+	flushSize    int  // output of values buffered before writing, see SetFlushSize
+	workers      int  // goroutines encoding top-level arrays, see SetParallelism
+	ownExtras    bool // opts.extra is not shared with other Encoders
+
+	tokenStack []encToken      // arrays and objects opened by WriteToken
+	nested     bool            // writes a single value for MarshalJSONTo
+	depth      int             // nesting depth of the MarshalJSONTo value
+	ctx        context.Context // passed to MarshalerContext values, see EncodeContext
+	values     int             // number of values written for MarshalJSONTo
+}
+
+// encToken is an array or object opened by Encoder.WriteToken.
+type encToken struct {
+	object bool
+	n      int  // elements or members written
+	key    bool // the key is written, a value is expected
 }
 
 // NewEncoder returns a new encoder that writes to w.
 func NewEncoder(w io.Writer) *Encoder {
-	return &Encoder{w: w, escapeHTML: true}
+	return &Encoder{w: w, opts: encOpts{escapeHTML: true}}
 }
 
 // Encode writes the JSON encoding of v to the stream,
 // followed by a newline character.
 //
 // See the documentation for Marshal for details about the
-// conversion of Go values to JSON.
+// conversion of Go values to JSON. Iterators and channels are written
+// as they're read unless indentation or size limit is set, if encoding
+// fails after a part of the value is written, all subsequent calls
+// return the same error.
 func (enc *Encoder) Encode(v any) error {
 	if enc.err != nil {
 		return enc.err
 	}
+	if enc.nested || len(enc.tokenStack) > 0 {
+		return enc.encodeToken(v)
+	}
 	e := newEncodeState()
-	err := e.marshal(v, encOpts{escapeHTML: enc.escapeHTML})
+	e.ctx = enc.ctx
+	e.workers = enc.workers
+	indent := !enc.lines && (enc.indentPrefix != "" || enc.indentValue != "")
+	if enc.seq && !indent {
+		e.WriteByte(recordSeparator)
+	}
+	maxSize := enc.opts.extras().maxSize
+	if !indent && maxSize <= 0 {
+		e.sink = enc.w
+		if enc.flushSize > 0 {
+			e.streams = 1 // The whole value is streamed.
+			e.flushSize = enc.flushSize
+		}
+	}
+	if e.streams == 0 {
+		e.Grow(enc.sizeHint) // Streamed output is not buffered whole.
+	}
+	start := e.Len()
+	err := e.marshal(v, enc.opts)
+	if err == nil && maxSize > 0 && e.Len()-start > maxSize {
+		err = &SizeError{Limit: maxSize}
+	}
 	if err != nil {
+		if e.flushed {
+			// The output is broken already.
+			enc.err = err
+		}
 		return err
 	}
 
@@ -205,15 +534,20 @@ func (enc *Encoder) Encode(v any) error {
 	// is required if the encoded value was a number,
 	// so that the reader knows there aren't more
 	// digits coming.
-	e.WriteByte('\n')
+	if !enc.noNewline || enc.lines || enc.seq {
+		e.WriteByte('\n')
+	}
 
 	b := e.Bytes()
-	if enc.indentPrefix != "" || enc.indentValue != "" {
+	if indent {
 		if enc.indentBuf == nil {
 			enc.indentBuf = new(bytes.Buffer)
 		}
 		enc.indentBuf.Reset()
-		err = Indent(enc.indentBuf, b, enc.indentPrefix, enc.indentValue)
+		if enc.seq {
+			enc.indentBuf.WriteByte(recordSeparator)
+		}
+		err = indentEscaped(enc.indentBuf, b, enc.indentPrefix, enc.indentValue, false)
 		if err != nil {
 			return err
 		}
@@ -222,10 +556,19 @@ func (enc *Encoder) Encode(v any) error {
 	if _, err = enc.w.Write(b); err != nil {
 		enc.err = err
 	}
-	encodeStatePool.Put(e)
+	putEncodeState(e)
 	return err
 }
 
+// EncodeContext is like Encode, but passes ctx to MarshalJSONContext
+// methods of MarshalerContext values.
+func (enc *Encoder) EncodeContext(ctx context.Context, v any) error {
+	prev := enc.ctx
+	enc.ctx = ctx
+	defer func() { enc.ctx = prev }()
+	return enc.Encode(v)
+}
+
 // SetIndent instructs the encoder to format each subsequent encoded
 // value as if indented by the package-level function Indent(dst, src, prefix, indent).
 // Calling SetIndent("", "") disables indentation.
@@ -234,6 +577,26 @@ func (enc *Encoder) SetIndent(prefix, indent string) {
 	enc.indentValue = indent
 }
 
+// SetTrailingNewline specifies whether Encode terminates every value with
+// a newline, which it does by default. Without it the caller is
+// responsible for separating consecutive numbers, they're indistinguishable
+// otherwise. Encoders returned by NewLinesEncoder and NewSeqEncoder always
+// write the newline since their formats require it.
+func (enc *Encoder) SetTrailingNewline(on bool) {
+	enc.noNewline = !on
+}
+
+// setExtras returns the rarely set options of enc for a setter to change,
+// they're allocated or copied first unless enc has its own ones already.
+func (enc *Encoder) setExtras() *extraOpts {
+	if !enc.ownExtras {
+		x := *enc.opts.extras()
+		enc.opts.extra = &x
+		enc.ownExtras = true
+	}
+	return enc.opts.extra
+}
+
 // SetEscapeHTML specifies whether problematic HTML characters
 // should be escaped inside JSON quoted strings.
 // The default behavior is to escape &, <, and > to \u0026, \u003c, and \u003e
@@ -242,7 +605,422 @@ func (enc *Encoder) SetIndent(prefix, indent string) {
 // In non-HTML settings where the escaping interferes with the readability
 // of the output, SetEscapeHTML(false) disables this behavior.
 func (enc *Encoder) SetEscapeHTML(on bool) {
-	enc.escapeHTML = on
+	enc.opts.escapeHTML = on
+}
+
+// SetEscaping specifies the set of characters escaped in JSON strings, the
+// default is NeoCompat (unknown profiles mean it too). HTML characters
+// are still controlled by SetEscapeHTML.
+func (enc *Encoder) SetEscaping(p EscapeProfile) {
+	enc.setExtras().escaping = p.valid()
+}
+
+// SetUppercaseHex specifies whether hex digits in \uXXXX escapes are
+// uppercase (like "\u00E9") or lowercase (like "\u00e9"), overriding the
+// default of the escaping profile (uppercase for NeoCompat only).
+func (enc *Encoder) SetUppercaseHex(on bool) {
+	if on {
+		enc.setExtras().hexCase = HexUpper
+	} else {
+		enc.setExtras().hexCase = HexLower
+	}
+}
+
+// SetUnescapedUnicode specifies whether non-ASCII characters that are
+// printable (as defined by unicode.IsPrint) are written as UTF-8 whatever
+// the escaping profile is. Other non-ASCII characters (like U+0085 or
+// U+200B) are escaped then, U+2028 and U+2029 are always escaped.
+func (enc *Encoder) SetUnescapedUnicode(on bool) {
+	enc.setExtras().printable = on
+}
+
+// SetEscapeSlash specifies whether '/' is escaped as "\/" in JSON strings,
+// like Newtonsoft.Json can be configured to do. It's not escaped by
+// default.
+func (enc *Encoder) SetEscapeSlash(on bool) {
+	enc.setExtras().escapeSlash = on
+}
+
+// SetEscaper makes the Encoder escape JSON strings with esc instead of the
+// built-in rules, all the other escaping settings are ignored then (but
+// the characters JSON doesn't allow in strings are still escaped if esc
+// doesn't). Passing nil restores the built-in rules.
+func (enc *Encoder) SetEscaper(esc Escaper) {
+	enc.setExtras().escaper = esc
+}
+
+// SetMapKeyOrder specifies the order of map keys in JSON objects, cmp must
+// return a negative number if a goes before b, a positive one if b goes
+// before a and zero otherwise (like strings.Compare which is the default
+// order). CompareUTF16 can be used to match C# ordinal sorting. It doesn't
+// affect OrderedObject and structs. Passing nil restores the default.
+func (enc *Encoder) SetMapKeyOrder(cmp func(a, b string) int) {
+	enc.setExtras().keyCmp = cmp
+}
+
+// SetKeyTemplate makes the Encoder write the members of objects (including
+// the ones of structs, maps, OrderedObject values and the output of
+// Marshaler values) in the order given by t for their paths or, with the
+// KeyOrderCheck policy, fail if they're not in that order. Objects at paths
+// without a template are written as usual, so are values written between
+// tokens with WriteToken since their paths are unknown. Objects with a
+// template are kept in memory until they're complete. Passing nil (the
+// default) disables the template.
+func (enc *Encoder) SetKeyTemplate(t *KeyTemplate, p KeyOrderPolicy) {
+	enc.opts.keyTemplate = t
+	if check := p == KeyOrderCheck; check || enc.opts.extras().keyCheck {
+		enc.setExtras().keyCheck = check
+	}
+}
+
+// SetFloatFormat makes the Encoder write floating point numbers in the
+// given format instead of FloatJS.
+func (enc *Encoder) SetFloatFormat(f FloatFormat) {
+	enc.setExtras().floatFormat = f
+}
+
+// SetJSSafeIntegers makes the Encoder write values of integer types with
+// absolute values above 2^53-1 as JSON strings, so JavaScript clients
+// parsing numbers as float64 don't lose precision. Smaller integers are
+// written as numbers. See Decoder.AllowQuotedIntegers for decoding them.
+func (enc *Encoder) SetJSSafeIntegers(on bool) {
+	enc.setExtras().jsSafeInts = on
+}
+
+// SetFixedNotation makes the Encoder write all floating point numbers in
+// plain decimal notation without exponents whatever the float format is,
+// using the shortest representation that round-trips. Non-zero numbers
+// with absolute values below minAbs or not below maxAbs make Encode fail
+// with an UnsupportedValueError then. A non-positive maxAbs (the default)
+// disables fixed notation.
+func (enc *Encoder) SetFixedNotation(minAbs, maxAbs float64) {
+	x := enc.setExtras()
+	x.fixedMin, x.fixedMax = minAbs, maxAbs
+}
+
+// SetNaming makes the Encoder convert the names of struct fields without
+// names given in their tags according to c, KeepNames (the default) uses
+// the field names as they are.
+func (enc *Encoder) SetNaming(c NamingConvention) {
+	enc.setExtras().naming = c.valid()
+}
+
+// SetFieldNames makes the Encoder write the struct fields which member
+// names are keys of m (after applying tags and SetNaming) with the names
+// given by the corresponding values instead. It allows to produce variants
+// of the same payload without duplicating types. m is used as is, so it
+// must not be changed while the Encoder is in use. A nil m (the default)
+// disables renaming.
+func (enc *Encoder) SetFieldNames(m map[string]string) {
+	enc.setExtras().renames = m
+}
+
+// SetFieldFilter makes the Encoder consult f for every struct field before
+// writing it, the field is omitted if f returns false. f is called with
+// the struct type and the member name of the field as it would be
+// written (after applying the tag, SetNaming and SetFieldNames), inline
+// fields are always written. It allows to select different
+// projections of the same types at runtime. A nil f (the default) disables
+// filtering.
+func (enc *Encoder) SetFieldFilter(f func(structType reflect.Type, field string) bool) {
+	enc.setExtras().fieldFilter = f
+}
+
+// SetRedactor enables the redacted mode in which the values of struct
+// fields with the "redact" option are replaced with the result of r called
+// with the member name and the field value (nil if it can't be obtained
+// because of an unexported embedded struct), the result is encoded as
+// usual. Redact can be used to write a placeholder string. A nil r (the
+// default) disables the mode.
+func (enc *Encoder) SetRedactor(r func(name string, v any) any) {
+	enc.setExtras().redactor = r
+}
+
+// RedactedPlaceholder is the string written by Redact.
+const RedactedPlaceholder = "[REDACTED]"
+
+// Redact is a redactor for Encoder.SetRedactor replacing values with
+// RedactedPlaceholder.
+func Redact(string, any) any {
+	return RedactedPlaceholder
+}
+
+// SetMaxDepth limits the nesting of arrays and objects produced by the
+// Encoder to n levels, deeper values make Encode fail with a DepthError.
+// A non-positive n (the default) means no limit.
+func (enc *Encoder) SetMaxDepth(n int) {
+	enc.setExtras().maxDepth = n
+}
+
+// SetMaxSize limits the size of every value written by Encode to n bytes
+// (not counting the newline and indentation), larger values make Encode
+// fail with a SizeError without writing anything. Encoding stops as soon
+// as the limit is exceeded, so huge values don't consume memory needlessly.
+// A non-positive n (the default) means no limit. Together with SetMaxDepth
+// it allows to enforce protocol limits like the ones of NeoVM
+// serialization before the data is sent.
+func (enc *Encoder) SetMaxSize(n int) {
+	enc.setExtras().maxSize = n
+}
+
+// SetSizeHint tells the Encoder that values are expected to take about n
+// bytes, so that the buffer for every one of them is allocated with this
+// capacity at once instead of growing through several reallocations. It
+// has no effect on values streamed with SetFlushSize and doesn't limit the
+// size in any way, see SetMaxSize for that.
+func (enc *Encoder) SetSizeHint(n int) {
+	enc.sizeHint = max(n, 0)
+}
+
+// SetFlushSize causes Encode to pass the output to the writer in chunks of
+// about n bytes as they're produced instead of buffering every value
+// completely, so values of any size can be written with bounded memory.
+// The output is flushed between the elements of arrays and the members of
+// objects and inside large byte slices, but a single huge string is still
+// buffered whole. The price is that if encoding fails in the middle, the
+// part written can't be taken back, the Encoder then returns the error
+// from all subsequent calls. It has no effect with indentation (SetIndent)
+// or SetMaxSize which need the complete value. A non-positive n (the
+// default) disables flushing, except for iterators and channels which are
+// always streamed.
+func (enc *Encoder) SetFlushSize(n int) {
+	enc.flushSize = max(n, 0)
+}
+
+// SetParallelism causes Encode to split the elements of large top-level
+// arrays and slices into chunks that n goroutines encode concurrently, the
+// output is the same as without it. It makes sense for bulk exports of
+// many independent values as long as the MarshalJSON methods, the field
+// filter and the redactor used for them are safe for concurrent use. An n
+// of 1 or less (the default) means sequential encoding.
+func (enc *Encoder) SetParallelism(n int) {
+	enc.workers = n
+}
+
+// WriteToken writes the next JSON token to the stream, it's the
+// counterpart of Decoder.Token. t is a Delim for the beginning or the end
+// of an array or object, a string, a Number, a float64, a bool or nil.
+// Commas and colons are inserted as needed, strings following the
+// beginning of an object or its values are written as member names.
+// Values inside an array or object started with WriteToken can also be
+// written with Encode, no newline is added after them then. The newline
+// is written after the whole top-level value as Encode does; indentation
+// set with SetIndent doesn't apply to such values.
+func (enc *Encoder) WriteToken(t Token) error {
+	if enc.err != nil {
+		return enc.err
+	}
+	switch t := t.(type) {
+	case Delim:
+		switch t {
+		case '{', '[':
+			b, err := enc.separator(nil, false)
+			if err != nil {
+				return err
+			}
+			if limit := enc.opts.extras().maxDepth; limit > 0 && enc.depth+len(enc.tokenStack) >= limit {
+				return &DepthError{Limit: limit}
+			}
+			enc.tokenStack = append(enc.tokenStack, encToken{object: t == '{'})
+			return enc.write(append(b, byte(t)))
+		case '}', ']':
+			n := len(enc.tokenStack)
+			if n == 0 || enc.tokenStack[n-1].object != (t == '}') || enc.tokenStack[n-1].key {
+				return errors.New("json: unexpected " + t.String())
+			}
+			enc.tokenStack = enc.tokenStack[:n-1]
+			return enc.write(enc.finish([]byte{byte(t)}))
+		}
+		return errors.New("json: invalid delimiter " + t.String())
+	case string:
+		n := len(enc.tokenStack)
+		if n > 0 && enc.tokenStack[n-1].object && !enc.tokenStack[n-1].key {
+			b, err := enc.separator(nil, true)
+			if err != nil {
+				return err
+			}
+			b = appendString(b, t, enc.opts)
+			return enc.write(append(b, ':'))
+		}
+	case Number, float64, bool, nil:
+	default:
+		return fmt.Errorf("json: invalid token type %T", t)
+	}
+	return enc.encodeToken(t)
+}
+
+// BeginObject starts a new object, its members are written with WriteKey
+// followed by the value (written with WriteValue or EncodeRaw or started
+// with BeginObject or BeginArray), End finishes it. It's the same as
+// WriteToken(Delim('{')).
+func (enc *Encoder) BeginObject() error {
+	return enc.WriteToken(Delim('{'))
+}
+
+// BeginArray starts a new array, its elements are written with WriteValue
+// or EncodeRaw or started with BeginObject or BeginArray, End finishes it.
+// It's the same as WriteToken(Delim('[')).
+func (enc *Encoder) BeginArray() error {
+	return enc.WriteToken(Delim('['))
+}
+
+// WriteKey writes the name of the next member of the object started with
+// BeginObject, it fails if there is no such object or if the value of the
+// previous member is not written yet.
+func (enc *Encoder) WriteKey(key string) error {
+	if enc.err != nil {
+		return enc.err
+	}
+	n := len(enc.tokenStack)
+	if n == 0 || !enc.tokenStack[n-1].object || enc.tokenStack[n-1].key {
+		return errors.New("json: unexpected object member name")
+	}
+	return enc.WriteToken(key)
+}
+
+// WriteValue writes the JSON encoding of v as the next array element or
+// object member value, it fails if a member name is expected instead.
+// Outside of arrays and objects it's the same as Encode.
+func (enc *Encoder) WriteValue(v any) error {
+	return enc.Encode(v)
+}
+
+// EncodeRaw writes the already encoded value raw at the current position
+// of the stream, that is as the next top-level value like Encode does it,
+// as an array element or as an object member value (using WriteToken or
+// the BeginObject and BeginArray interface). By default raw is checked to
+// be valid and compacted like RawMessage values are when they're encoded,
+// see SetValidateRaw. Empty raw is written as null. Indentation set with
+// SetIndent isn't applied to raw.
+func (enc *Encoder) EncodeRaw(raw RawMessage) error {
+	if enc.err != nil {
+		return enc.err
+	}
+	if len(raw) == 0 {
+		raw = RawMessage("null")
+	}
+	if !enc.rawTrusted {
+		e := newEncodeState()
+		defer putEncodeState(e)
+		if err := compact(&e.Buffer, raw, enc.opts.escapeHTML); err != nil {
+			return err
+		}
+		raw = e.Bytes()
+	}
+	b, err := enc.separator(nil, false)
+	if err != nil {
+		return err
+	}
+	b = append(b, raw...)
+	return enc.write(enc.finish(b))
+}
+
+// SetValidateRaw controls whether EncodeRaw checks its input, the
+// default is to validate and compact it. SetValidateRaw(false) makes
+// EncodeRaw write the data as is without any checks, the caller then
+// is responsible for it being a valid JSON value (a cached Encoder output
+// for example).
+func (enc *Encoder) SetValidateRaw(on bool) {
+	enc.rawTrusted = !on
+}
+
+// End finishes the innermost object or array started with BeginObject,
+// BeginArray or WriteToken.
+func (enc *Encoder) End() error {
+	if enc.err != nil {
+		return enc.err
+	}
+	n := len(enc.tokenStack)
+	if n == 0 {
+		return errors.New("json: no object or array to end")
+	}
+	if enc.tokenStack[n-1].object {
+		return enc.WriteToken(Delim('}'))
+	}
+	return enc.WriteToken(Delim(']'))
+}
+
+// encodeToken writes v as a value inside of the arrays and objects opened
+// by WriteToken or as the only value of MarshalJSONTo.
+func (enc *Encoder) encodeToken(v any) error {
+	rv := reflect.ValueOf(v)
+	return enc.encodeWith(valueEncoder(enc.opts.extras().config, rv), rv)
+}
+
+// encodeWith is like encodeToken, but uses the encoder f for v.
+func (enc *Encoder) encodeWith(f encoderFunc, v reflect.Value) error {
+	e := newEncodeState()
+	defer putEncodeState(e)
+	b, err := enc.separator(e.scratch[:0], false)
+	if err != nil {
+		return err
+	}
+	e.Write(b)
+	e.depth = enc.depth + len(enc.tokenStack)
+	e.ctx = enc.ctx
+	opts := enc.opts
+	if len(enc.tokenStack) > 0 {
+		opts.keyTemplate = nil
+	}
+	if err := e.marshalWith(f, v, opts); err != nil {
+		return err
+	}
+	e.Write(enc.finish(e.scratch[:0]))
+	return enc.write(e.Bytes())
+}
+
+// separator appends the comma or the record separator needed before the
+// next value (or member name if key is set) to b.
+func (enc *Encoder) separator(b []byte, key bool) ([]byte, error) {
+	n := len(enc.tokenStack)
+	if n == 0 {
+		if enc.nested && enc.values > 0 {
+			return nil, errors.New("json: more than one value written by MarshalJSONTo")
+		}
+		if enc.seq && !enc.nested {
+			b = append(b, recordSeparator)
+		}
+		return b, nil
+	}
+	c := &enc.tokenStack[n-1]
+	switch {
+	case !c.object:
+		if c.n > 0 {
+			b = append(b, ',')
+		}
+		c.n++
+	case c.key:
+		c.key = false
+	case !key:
+		return nil, errors.New("json: object member name expected")
+	default:
+		if c.n > 0 {
+			b = append(b, ',')
+		}
+		c.n++
+		c.key = true
+	}
+	return b, nil
+}
+
+// finish appends the newline to b if a top-level value is complete.
+func (enc *Encoder) finish(b []byte) []byte {
+	if len(enc.tokenStack) > 0 {
+		return b
+	}
+	enc.values++
+	if !enc.nested && (!enc.noNewline || enc.lines || enc.seq) {
+		b = append(b, '\n')
+	}
+	return b
+}
+
+func (enc *Encoder) write(b []byte) error {
+	if _, err := enc.w.Write(b); err != nil {
+		enc.err = err
+		return err
+	}
+	return nil
 }
 
 // RawMessage is a raw encoded JSON value.
@@ -353,7 +1131,7 @@ func (dec *Decoder) tokenPrepareForDecode() error {
 			return err
 		}
 		if c != ',' {
-			return &SyntaxError{"expected comma after array element", 0}
+			return dec.syntaxError("expected comma after array element")
 		}
 		dec.scanp++
 		dec.tokenState = tokenArrayValue
@@ -363,7 +1141,7 @@ func (dec *Decoder) tokenPrepareForDecode() error {
 			return err
 		}
 		if c != ':' {
-			return &SyntaxError{"expected colon after object key", 0}
+			return dec.syntaxError("expected colon after object key")
 		}
 		dec.scanp++
 		dec.tokenState = tokenObjectValue
@@ -412,18 +1190,23 @@ func (dec *Decoder) Token() (Token, error) {
 		if err != nil {
 			return nil, err
 		}
+		dec.tokenOffset = dec.InputOffset()
 		switch c {
 		case '[':
 			if !dec.tokenValueAllowed() {
 				return dec.tokenError(c)
 			}
+			if err := dec.tokenCheckDepth(); err != nil {
+				return nil, err
+			}
 			dec.scanp++
 			dec.tokenStack = append(dec.tokenStack, dec.tokenState)
 			dec.tokenState = tokenArrayStart
 			return Delim('['), nil
 
 		case ']':
-			if dec.tokenState != tokenArrayStart && dec.tokenState != tokenArrayComma {
+			if dec.tokenState != tokenArrayStart && dec.tokenState != tokenArrayComma &&
+				(dec.tokenState != tokenArrayValue || !dec.scan.trailingCommas) {
 				return dec.tokenError(c)
 			}
 			dec.scanp++
@@ -436,13 +1219,17 @@ func (dec *Decoder) Token() (Token, error) {
 			if !dec.tokenValueAllowed() {
 				return dec.tokenError(c)
 			}
+			if err := dec.tokenCheckDepth(); err != nil {
+				return nil, err
+			}
 			dec.scanp++
 			dec.tokenStack = append(dec.tokenStack, dec.tokenState)
 			dec.tokenState = tokenObjectStart
 			return Delim('{'), nil
 
 		case '}':
-			if dec.tokenState != tokenObjectStart && dec.tokenState != tokenObjectComma {
+			if dec.tokenState != tokenObjectStart && dec.tokenState != tokenObjectComma &&
+				(dec.tokenState != tokenObjectKey || !dec.scan.trailingCommas) {
 				return dec.tokenError(c)
 			}
 			dec.scanp++
@@ -502,6 +1289,40 @@ func (dec *Decoder) Token() (Token, error) {
 	}
 }
 
+// A RawToken is a Token along with its location in the input stream.
+type RawToken struct {
+	Token Token
+	// Raw holds the exact input bytes of the token, string literals
+	// are not unescaped and numbers are not converted. It refers to the
+	// Decoder's buffer and is only valid until the next call to the Decoder.
+	Raw []byte
+	// Offset is the input offset of the first byte of Raw.
+	Offset int64
+}
+
+// RawToken is like Token, but also returns the raw bytes of the token and
+// its offset in the input stream. Unchanged tokens can be written back
+// byte-for-byte using Raw.
+func (dec *Decoder) RawToken() (RawToken, error) {
+	t, err := dec.Token()
+	if err != nil {
+		return RawToken{}, err
+	}
+	n := int(dec.InputOffset() - dec.tokenOffset)
+	return RawToken{
+		Token:  t,
+		Raw:    dec.buf[dec.scanp-n : dec.scanp : dec.scanp],
+		Offset: dec.tokenOffset,
+	}, nil
+}
+
+// InputOffset returns the input stream byte offset of the current decoder position.
+// The offset gives the location of the end of the most recently returned token
+// and the beginning of the next token.
+func (dec *Decoder) InputOffset() int64 {
+	return dec.scanned + int64(dec.scanp)
+}
+
 func clearOffset(err error) {
 	var s *SyntaxError
 	if errors.As(err, &s) {
@@ -509,6 +1330,19 @@ func clearOffset(err error) {
 	}
 }
 
+// tokenCheckDepth returns an error if opening one more array or object
+// would exceed the nesting limit.
+func (dec *Decoder) tokenCheckDepth() error {
+	limit := dec.scan.maxDepth
+	if limit <= 0 {
+		limit = maxNestingDepth
+	}
+	if len(dec.tokenStack) >= limit {
+		return &DepthError{Limit: limit, Offset: dec.InputOffset()}
+	}
+	return nil
+}
+
 func (dec *Decoder) tokenError(c byte) (Token, error) {
 	var context string
 	switch dec.tokenState {
@@ -525,7 +1359,20 @@ func (dec *Decoder) tokenError(c byte) (Token, error) {
 	case tokenObjectComma:
 		context = " after object key:value pair"
 	}
-	return nil, &SyntaxError{"invalid character " + quoteChar(c) + " " + context, 0}
+	return nil, dec.syntaxError("invalid character " + quoteChar(c) + " " + context)
+}
+
+// syntaxError returns a SyntaxError positioned at the unread data.
+func (dec *Decoder) syntaxError(msg string) error {
+	err := &SyntaxError{msg: msg}
+	dec.locate(err, dec.scanp)
+	return err
+}
+
+// locate fills in the position of err if it's a SyntaxError that occurred
+// at dec.buf[pos].
+func (dec *Decoder) locate(err error, pos int) {
+	locate(err, dec.buf, pos, dec.lines+1, dec.column+1)
 }
 
 // More reports whether there is another element in the
@@ -535,14 +1382,77 @@ func (dec *Decoder) More() bool {
 	return err == nil && c != ']' && c != '}'
 }
 
+// DecodeArray returns an iterator over the elements of the JSON array that
+// is the next value in the dec input, every element is decoded into a new T
+// when the iteration reaches it, so the array as a whole is never kept in
+// memory. Elements that can't be stored in T are yielded along with an
+// UnmarshalTypeError and the iteration can continue, other errors (including
+// the next value not being an array) stop it. The Decoder can be used to read
+// the following values once the iteration is over.
+//
+// See the documentation for Unmarshal for details about
+// the conversion of JSON into a Go value.
+func DecodeArray[T any](dec *Decoder) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		var zero T
+		tok, err := dec.Token()
+		if err != nil {
+			yield(zero, err)
+			return
+		}
+		if tok != Delim('[') {
+			var value string
+			switch tok.(type) {
+			case Delim:
+				value = "object"
+			case string:
+				value = "string"
+			case bool:
+				value = "bool"
+			case nil:
+				value = "null"
+			default:
+				value = "number"
+			}
+			yield(zero, &UnmarshalTypeError{Value: value, Type: reflect.TypeFor[[]T](), Offset: dec.tokenOffset})
+			return
+		}
+		for dec.More() {
+			var v T
+			err := dec.Decode(&v)
+			if err != nil && dec.err != nil {
+				yield(zero, err)
+				return
+			}
+			if !yield(v, err) {
+				return
+			}
+		}
+		if _, err := dec.Token(); err != nil {
+			yield(zero, err)
+		}
+	}
+}
+
 func (dec *Decoder) peek() (byte, error) {
 	var err error
 	for {
+	Buffer:
 		for i := dec.scanp; i < len(dec.buf); i++ {
 			c := dec.buf[i]
 			if isSpace(c) {
 				continue
 			}
+			if c == '/' && dec.scan.comments {
+				switch n := commentEnd(dec.buf[i:], err != nil); {
+				case n < 0:
+					dec.scanp = i
+					break Buffer
+				case n > 0:
+					i += n - 1
+					continue
+				}
+			}
 			dec.scanp = i
 			return c, nil
 		}
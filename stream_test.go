@@ -13,8 +13,14 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"reflect"
+	"runtime"
+	"strconv"
 	"strings"
+	"sync/atomic"
 	"testing"
+	"testing/iotest"
+	"time"
+	"unsafe"
 )
 
 // Test values for the stream test.
@@ -350,11 +356,11 @@ var tokenStreamCases = []tokenStreamCase{
 	{json: ` [{"a": 1} {"a": 2}] `, expTokens: []any{
 		Delim('['),
 		decodeThis{map[string]any{"a": float64(1)}},
-		decodeThis{&SyntaxError{"expected comma after array element", 0}},
+		decodeThis{&SyntaxError{msg: "expected comma after array element"}},
 	}},
 	{json: `{ "a" 1 }`, expTokens: []any{
 		Delim('{'), "a",
-		decodeThis{&SyntaxError{"expected colon after object key", 0}},
+		decodeThis{&SyntaxError{msg: "expected colon after object key"}},
 	}},
 }
 
@@ -427,3 +433,929 @@ func TestHTTPDecoding(t *testing.T) {
 		t.Errorf("err = %v; want io.EOF", err)
 	}
 }
+
+func TestRawToken(t *testing.T) {
+	const in = ` {"aA": [1.50, true, null], "b" : "x"} `
+	var exp = []struct {
+		tok Token
+		raw string
+	}{
+		{Delim('{'), `{`},
+		{"aA", `"aA"`},
+		{Delim('['), `[`},
+		{float64(1.5), `1.50`},
+		{true, `true`},
+		{nil, `null`},
+		{Delim(']'), `]`},
+		{"b", `"b"`},
+		{"x", `"x"`},
+		{Delim('}'), `}`},
+	}
+	// One byte at a time to make the Decoder move its buffer around.
+	dec := NewDecoder(iotest.OneByteReader(strings.NewReader(in)))
+	for i, e := range exp {
+		rt, err := dec.RawToken()
+		if err != nil {
+			t.Fatalf("#%d: unexpected error: %v", i, err)
+		}
+		if !reflect.DeepEqual(rt.Token, e.tok) {
+			t.Errorf("#%d: token %v, want %v", i, rt.Token, e.tok)
+		}
+		if string(rt.Raw) != e.raw {
+			t.Errorf("#%d: raw %q, want %q", i, rt.Raw, e.raw)
+		}
+		if got := in[rt.Offset : rt.Offset+int64(len(rt.Raw))]; got != e.raw {
+			t.Errorf("#%d: offset %d points to %q, want %q", i, rt.Offset, got, e.raw)
+		}
+		if end := dec.InputOffset(); end != rt.Offset+int64(len(rt.Raw)) {
+			t.Errorf("#%d: InputOffset %d, want %d", i, end, rt.Offset+int64(len(rt.Raw)))
+		}
+	}
+	if _, err := dec.RawToken(); !errors.Is(err, io.EOF) {
+		t.Errorf("expected EOF, got %v", err)
+	}
+}
+
+func TestDecodeRaw(t *testing.T) {
+	const in = ` {"a": "A"}  [1, 2]
+	"str\n" 1.50 {"b": ["c"]}`
+	dec := NewDecoder(strings.NewReader(in))
+	for i, exp := range []string{`{"a": "A"}`, `[1, 2]`, `"str\n"`, `1.50`} {
+		raw, err := dec.DecodeRaw()
+		if err != nil {
+			t.Fatalf("#%d: unexpected error: %v", i, err)
+		}
+		if string(raw) != exp {
+			t.Errorf("#%d: got %q, want %q", i, raw, exp)
+		}
+	}
+
+	// DecodeRaw can be mixed with the Token API.
+	if tok, err := dec.Token(); err != nil || tok != Delim('{') {
+		t.Fatalf("Token: %v, %v", tok, err)
+	}
+	if tok, err := dec.Token(); err != nil || tok != "b" {
+		t.Fatalf("Token: %v, %v", tok, err)
+	}
+	raw, err := dec.DecodeRaw()
+	if err != nil || string(raw) != `["c"]` {
+		t.Fatalf("DecodeRaw: %q, %v", raw, err)
+	}
+	if tok, err := dec.Token(); err != nil || tok != Delim('}') {
+		t.Fatalf("Token: %v, %v", tok, err)
+	}
+	if _, err := dec.DecodeRaw(); !errors.Is(err, io.EOF) {
+		t.Errorf("expected EOF, got %v", err)
+	}
+
+	dec = NewDecoder(strings.NewReader(`[1, 2}`))
+	if _, err := dec.DecodeRaw(); err == nil {
+		t.Error("expected syntax error")
+	}
+}
+
+func TestMaxDepth(t *testing.T) {
+	const in = `{"a": [[1], {"b": [2]}]}`
+	for _, tc := range []struct {
+		limit int
+		fail  bool
+	}{{0, false}, {4, false}, {3, true}, {1, true}} {
+		var v any
+		dec := NewDecoder(strings.NewReader(in))
+		dec.SetMaxDepth(tc.limit)
+		err := dec.Decode(&v)
+		var de *DepthError
+		if tc.fail != errors.As(err, &de) {
+			t.Errorf("Decode with limit %d: unexpected error %v", tc.limit, err)
+		}
+
+		dec = NewDecoder(strings.NewReader(in))
+		dec.SetMaxDepth(tc.limit)
+		for err == nil {
+			_, err = dec.Token()
+		}
+		if tc.fail != errors.As(err, &de) {
+			t.Errorf("Token with limit %d: unexpected error %v", tc.limit, err)
+		}
+
+		var buf bytes.Buffer
+		enc := NewEncoder(&buf)
+		enc.SetMaxDepth(tc.limit)
+		err = enc.Encode(OrderedObject{{"a", []any{[]int{1}, map[string][]int{"b": {2}}}}})
+		if tc.fail != errors.As(err, &de) {
+			t.Errorf("Encode with limit %d: unexpected error %v", tc.limit, err)
+		}
+	}
+
+	var v any
+	deep := strings.Repeat("[", maxNestingDepth+1) + strings.Repeat("]", maxNestingDepth+1)
+	var de *DepthError
+	if err := Unmarshal([]byte(deep), &v); !errors.As(err, &de) || de.Limit != maxNestingDepth {
+		t.Errorf("Unmarshal beyond the default limit: unexpected error %v", err)
+	}
+	if err := Unmarshal([]byte(deep[1:len(deep)-1]), &v); err != nil {
+		t.Errorf("Unmarshal at the default limit: unexpected error %v", err)
+	}
+}
+
+func TestEncoderMaxSize(t *testing.T) {
+	v := OrderedObject{{"a", []any{[]int{1}, map[string][]int{"b": {2}}}}}
+	const out = `{"a":[[1],{"b":[2]}]}`
+	for _, tc := range []struct {
+		limit int
+		fail  bool
+	}{{0, false}, {len(out), false}, {len(out) - 1, true}, {5, true}} {
+		var buf bytes.Buffer
+		enc := NewEncoder(&buf)
+		enc.SetMaxSize(tc.limit)
+		enc.SetIndent("", " ")
+		err := enc.Encode(v)
+		var se *SizeError
+		if tc.fail != errors.As(err, &se) {
+			t.Errorf("Encode with limit %d: unexpected error %v", tc.limit, err)
+		}
+		if tc.fail && (se.Limit != tc.limit || buf.Len() != 0) {
+			t.Errorf("Encode with limit %d: got %v and %q written", tc.limit, err, buf.String())
+		}
+	}
+}
+
+func TestEncoderFlushSize(t *testing.T) {
+	v := make([]OrderedObject, 1000)
+	for i := range v {
+		v[i] = OrderedObject{{"i", i}, {"s", strings.Repeat("x", 20)}}
+	}
+	want, _ := Marshal(v)
+	w := new(countingWriter)
+	enc := NewEncoder(w)
+	enc.SetFlushSize(1024)
+	if err := enc.Encode(v); err != nil {
+		t.Fatal(err)
+	}
+	if w.String() != string(want)+"\n" {
+		t.Errorf("got %d bytes, want %d", w.Len(), len(want)+1)
+	}
+	if w.writes < len(want)/1024 {
+		t.Errorf("%d bytes written in %d chunks", w.Len(), w.writes)
+	}
+
+	// Nothing is flushed by default.
+	w = new(countingWriter)
+	if err := NewEncoder(w).Encode(v); err != nil || w.writes != 1 {
+		t.Errorf("got %d writes, %v", w.writes, err)
+	}
+
+	w = &countingWriter{err: errors.New("broken")}
+	enc = NewEncoder(w)
+	enc.SetFlushSize(1024)
+	if err := enc.Encode(v); err == nil || w.writes != 1 {
+		t.Errorf("write error: got %v after %d writes", err, w.writes)
+	}
+	if err := enc.Encode(1); err == nil {
+		t.Error("broken encoder: no error")
+	}
+}
+
+func TestBytesDecoder(t *testing.T) {
+	data := []byte("{\"a\": 1} [2, /* c */ 3,]\n\"x\"")
+	orig := string(data)
+	dec := NewBytesDecoder(data)
+	var v any
+	if err := dec.Decode(&v); err != nil || !reflect.DeepEqual(v, map[string]any{"a": 1.0}) {
+		t.Fatalf("got %v, %v", v, err)
+	}
+	if err := dec.Decode(&v); err == nil {
+		t.Error("comment: no error")
+	}
+
+	for _, opts := range []Options{{AllowComments: true, AllowTrailingCommas: true}, {AllowComments: true, AllowTrailingCommas: true, UseNumber: true}} {
+		dec := opts.NewBytesDecoder(data)
+		var got []any
+		for {
+			var v any
+			if err := dec.Decode(&v); err == io.EOF {
+				break
+			} else if err != nil {
+				t.Fatal(err)
+			}
+			got = append(got, v)
+		}
+		if len(got) != 3 || got[2] != "x" {
+			t.Errorf("got %v", got)
+		}
+	}
+	if string(data) != orig {
+		t.Errorf("data modified to %q", data)
+	}
+
+	var se *SyntaxError
+	dec = NewBytesDecoder([]byte("1\n[2,"))
+	if err := dec.Decode(&v); err != nil {
+		t.Fatal(err)
+	}
+	if err := dec.Decode(&v); err != io.ErrUnexpectedEOF && !errors.As(err, &se) {
+		t.Errorf("truncated: got %v", err)
+	}
+}
+
+// failingMarshaler fails to marshal non-zero values.
+type failingMarshaler int
+
+func (m failingMarshaler) MarshalJSON() ([]byte, error) {
+	if m != 0 {
+		return nil, errors.New("failed")
+	}
+	return []byte(`0`), nil
+}
+
+func TestEncoderParallelism(t *testing.T) {
+	type item struct {
+		ID   int
+		Name string
+		Tags []string
+		Meta OrderedObject
+	}
+	v := make([]item, 5000)
+	for i := range v {
+		v[i] = item{i, strconv.Itoa(i), []string{"a", "b"}, OrderedObject{{"z", i}, {"a", nil}}}
+	}
+	want, _ := Marshal(v)
+	for _, n := range []int{1, 4, 32} {
+		var buf bytes.Buffer
+		enc := NewEncoder(&buf)
+		enc.SetParallelism(n)
+		enc.SetTrailingNewline(false)
+		if err := enc.Encode(v); err != nil {
+			t.Fatalf("%d: %v", n, err)
+		}
+		if !bytes.Equal(buf.Bytes(), want) {
+			t.Errorf("%d: output differs", n)
+		}
+	}
+	if b, err := MarshalWithOptions(v[:10], Options{Parallelism: 4}); err != nil || string(b) != string(must(Marshal(v[:10]))) {
+		t.Errorf("short array: got %s, %v", b, err)
+	}
+
+	fail := make([]failingMarshaler, 1000)
+	fail[700] = 1
+	var me *MarshalerError
+	if _, err := MarshalWithOptions(fail, Options{Parallelism: 4}); !errors.As(err, &me) {
+		t.Errorf("got %v, want MarshalerError", err)
+	}
+
+	// A panic in a worker is raised by the caller.
+	func() {
+		defer func() {
+			if r := recover(); r != "boom" {
+				t.Errorf("got panic %v, want boom", r)
+			}
+		}()
+		MarshalWithOptions(make([]panickingMarshaler, 1000), Options{Parallelism: 4})
+	}()
+
+	// The other chunks stop after an error.
+	goroutines := runtime.NumGoroutine()
+	blocking := make([]blockingMarshaler, 1000)
+	release := make(chan struct{})
+	var calls atomic.Int32
+	for i := range blocking {
+		blocking[i] = blockingMarshaler{release: release, calls: &calls, fail: i == 0, block: i > 0 && i%16 == 0}
+	}
+	if _, err := MarshalWithOptions(blocking, Options{Parallelism: 4}); !errors.As(err, &me) {
+		t.Errorf("got %v, want MarshalerError", err)
+	}
+	close(release)
+	for deadline := time.Now().Add(5 * time.Second); runtime.NumGoroutine() > goroutines && time.Now().Before(deadline); {
+		runtime.Gosched()
+	}
+	if n := calls.Load(); n > 4 {
+		t.Errorf("%d elements encoded after an error", n)
+	}
+}
+
+type panickingMarshaler struct{}
+
+func (panickingMarshaler) MarshalJSON() ([]byte, error) {
+	panic("boom")
+}
+
+// blockingMarshaler fails or waits for release and counts the calls
+// made after the failure.
+type blockingMarshaler struct {
+	release     chan struct{}
+	calls       *atomic.Int32
+	fail, block bool
+}
+
+func (m blockingMarshaler) MarshalJSON() ([]byte, error) {
+	if m.fail {
+		return nil, errors.New("failed")
+	}
+	if m.block {
+		<-m.release
+	}
+	select {
+	case <-m.release:
+		m.calls.Add(1)
+	default:
+	}
+	return []byte(`0`), nil
+}
+
+func TestLimits(t *testing.T) {
+	const in = `{"a": [1, 2, 3], "bb": "Abcd\u0041", "c": {}}`
+	for _, tc := range []struct {
+		limits Limits
+		name   string
+	}{
+		{Limits{}, ""},
+		{Limits{MaxBytes: int64(len(in)), MaxStringLen: 10, MaxMembers: 3, MaxElements: 3}, ""},
+		{Limits{MaxBytes: int64(len(in)) - 1}, "MaxBytes"},
+		{Limits{MaxStringLen: 9}, "MaxStringLen"},
+		{Limits{MaxMembers: 2}, "MaxMembers"},
+		{Limits{MaxElements: 2}, "MaxElements"},
+	} {
+		var v any
+		dec := NewDecoder(iotest.OneByteReader(strings.NewReader(in)))
+		dec.SetLimits(tc.limits)
+		errs := map[string]error{
+			"Decode":           dec.Decode(&v),
+			"UnmarshalLimited": UnmarshalLimited([]byte(in), &v, tc.limits),
+		}
+		for f, err := range errs {
+			var le *LimitError
+			switch {
+			case tc.name == "" && err != nil:
+				t.Errorf("%s with %+v: unexpected error %v", f, tc.limits, err)
+			case tc.name != "" && (!errors.As(err, &le) || le.Limit != tc.name):
+				t.Errorf("%s with %+v: got error %v, want %s LimitError", f, tc.limits, err, tc.name)
+			}
+		}
+	}
+}
+
+func TestDecoderComments(t *testing.T) {
+	const in = "// config\n{ /* block\n * comment */ \"a\": [1 /**/, 2// two\n], \"b\"/**/:/***/\"/* not a comment */\" // end\n}\n/* tail */ 3 // last"
+	for _, r := range []func(string) io.Reader{
+		func(s string) io.Reader { return strings.NewReader(s) },
+		func(s string) io.Reader { return iotest.OneByteReader(strings.NewReader(s)) },
+	} {
+		dec := NewDecoder(r(in))
+		dec.AllowComments()
+		dec.UseOrderedObject()
+		var v any
+		if err := dec.Decode(&v); err != nil {
+			t.Fatal(err)
+		}
+		want := OrderedObject{{"a", []any{float64(1), float64(2)}}, {"b", "/* not a comment */"}}
+		if !reflect.DeepEqual(v, want) {
+			t.Errorf("got %#v, want %#v", v, want)
+		}
+		if err := dec.Decode(&v); err != nil || v != float64(3) {
+			t.Errorf("second value: got %v, %v", v, err)
+		}
+		if err := dec.Decode(&v); !errors.Is(err, io.EOF) {
+			t.Errorf("at the end: got %v, want EOF", err)
+		}
+
+		dec = NewDecoder(r(in))
+		dec.AllowComments()
+		var toks []Token
+		for {
+			tok, err := dec.Token()
+			if err != nil {
+				if !errors.Is(err, io.EOF) {
+					t.Errorf("Token: %v", err)
+				}
+				break
+			}
+			toks = append(toks, tok)
+		}
+		wantToks := []Token{Delim('{'), "a", Delim('['), float64(1), float64(2), Delim(']'), "b", "/* not a comment */", Delim('}'), float64(3)}
+		if !reflect.DeepEqual(toks, wantToks) {
+			t.Errorf("got tokens %v, want %v", toks, wantToks)
+		}
+	}
+
+	for _, in := range []string{"[1, /* x ]", "[1 / 2]"} {
+		dec := NewDecoder(strings.NewReader(in))
+		dec.AllowComments()
+		var v any
+		if err := dec.Decode(&v); err == nil {
+			t.Errorf("%q: no error", in)
+		}
+	}
+	var v any
+	if err := NewDecoder(strings.NewReader("/**/1")).Decode(&v); err == nil {
+		t.Error("comments are accepted by default")
+	}
+}
+
+func TestDecoderTrailingCommas(t *testing.T) {
+	const in = `{"a": [1, 2, ], "b": {"c": ",]",}, "d": [[],], }`
+	dec := NewDecoder(strings.NewReader(in + in))
+	dec.AllowTrailingCommas()
+	dec.UseOrderedObject()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		t.Fatal(err)
+	}
+	want := OrderedObject{
+		{"a", []any{float64(1), float64(2)}},
+		{"b", OrderedObject{{"c", ",]"}}},
+		{"d", []any{[]any{}}},
+	}
+	if !reflect.DeepEqual(v, want) {
+		t.Errorf("got %#v, want %#v", v, want)
+	}
+	var toks []Token
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			if !errors.Is(err, io.EOF) {
+				t.Errorf("Token: %v", err)
+			}
+			break
+		}
+		toks = append(toks, tok)
+	}
+	wantToks := []Token{Delim('{'), "a", Delim('['), float64(1), float64(2), Delim(']'),
+		"b", Delim('{'), "c", ",]", Delim('}'), "d", Delim('['), Delim('['), Delim(']'), Delim(']'), Delim('}')}
+	if !reflect.DeepEqual(toks, wantToks) {
+		t.Errorf("got tokens %v, want %v", toks, wantToks)
+	}
+
+	for _, in := range []string{`[1,,]`, `[,]`, `{,}`, `{"a":1,,}`} {
+		dec := NewDecoder(strings.NewReader(in))
+		dec.AllowTrailingCommas()
+		if err := dec.Decode(&v); err == nil {
+			t.Errorf("%s: no error", in)
+		}
+	}
+	if err := NewDecoder(strings.NewReader(`[1,]`)).Decode(&v); err == nil {
+		t.Error("trailing commas are accepted by default")
+	}
+}
+
+func TestDecodeArray(t *testing.T) {
+	type transfer struct {
+		Amount int `json:"amount"`
+	}
+	dec := NewDecoder(iotest.OneByteReader(strings.NewReader(`[{"amount": 1}, {"amount": "x"}, {"amount": 3}] "next"`)))
+	var (
+		got  []int
+		errs int
+	)
+	for tr, err := range DecodeArray[transfer](dec) {
+		var ute *UnmarshalTypeError
+		if err != nil {
+			if !errors.As(err, &ute) {
+				t.Fatalf("unexpected error %v", err)
+			}
+			errs++
+			continue
+		}
+		got = append(got, tr.Amount)
+	}
+	if !reflect.DeepEqual(got, []int{1, 3}) || errs != 1 {
+		t.Errorf("got %v and %d errors", got, errs)
+	}
+	var s string
+	if err := dec.Decode(&s); err != nil || s != "next" {
+		t.Errorf("value after the array: got %q, %v", s, err)
+	}
+
+	for _, in := range []string{`{"a": 1}`, `[1, 2`, `[1, x]`} {
+		var last error
+		for _, err := range DecodeArray[int](NewDecoder(strings.NewReader(in))) {
+			last = err
+		}
+		if last == nil {
+			t.Errorf("%s: no error", in)
+		}
+	}
+
+	dec = NewDecoder(strings.NewReader(`[1, 2, 3]`))
+	for v := range DecodeArray[int](dec) {
+		if v == 2 {
+			break
+		}
+	}
+	var rest []int
+	for v := range DecodeArray[int](NewDecoder(strings.NewReader(`[]`))) {
+		rest = append(rest, v)
+	}
+	if len(rest) != 0 {
+		t.Errorf("empty array: got %v", rest)
+	}
+}
+
+func TestDisallowInvalidUTF8(t *testing.T) {
+	const in = "{\"ok\": \"é\", \"bad\": \"a\xffb\"}"
+	var v map[string]string
+	if err := NewDecoder(strings.NewReader(in)).Decode(&v); err != nil || v["bad"] != "a�b" {
+		t.Errorf("default: got %q, %v", v, err)
+	}
+	dec := NewDecoder(strings.NewReader(in))
+	dec.DisallowInvalidUTF8()
+	var se *SyntaxError
+	if err := dec.Decode(&v); !errors.As(err, &se) || se.Offset != 23 || se.Column != 23 {
+		t.Errorf("strict: got %#v", err)
+	}
+}
+
+func TestDecoderOnUnknownField(t *testing.T) {
+	const in = `{"A": 1, "x": {"y": [1, 2]}, "B": [{"b": true, "z": "s"}, {"c": null}], "w" : 7 }`
+	var v struct {
+		A int
+		B []struct{ B bool }
+	}
+	var got []string
+	dec := NewDecoder(strings.NewReader(in))
+	dec.OnUnknownField(func(path, key string, raw RawMessage) {
+		got = append(got, path+" "+key+" "+string(raw))
+	})
+	if err := dec.Decode(&v); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{` x {"y": [1, 2]}`, `B[0] z "s"`, `B[1] c null`, ` w 7`}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if v.A != 1 || len(v.B) != 2 || !v.B[0].B {
+		t.Errorf("got %+v", v)
+	}
+}
+
+func TestDecoderInternStrings(t *testing.T) {
+	const in = `[{"key": "short", "s": "longer one"}, {"key": "short", "s": "longer one"}] {"key": "short"}`
+	dec := NewDecoder(strings.NewReader(in))
+	dec.InternStrings(5)
+	var (
+		v []map[string]string
+		o OrderedObject
+	)
+	if err := dec.Decode(&v); err != nil {
+		t.Fatal(err)
+	}
+	if err := dec.Decode(&o); err != nil {
+		t.Fatal(err)
+	}
+	keys := make(map[*byte]bool)
+	vals := make(map[*byte]bool)
+	var long []*byte
+	for _, m := range v {
+		for k, s := range m {
+			keys[unsafe.StringData(k)] = true
+			if s == "short" {
+				vals[unsafe.StringData(s)] = true
+			} else {
+				long = append(long, unsafe.StringData(s))
+			}
+		}
+	}
+	keys[unsafe.StringData(o[0].Key)] = true
+	vals[unsafe.StringData(o[0].Value.(string))] = true
+	if len(keys) != 2 {
+		t.Error("keys are not interned")
+	}
+	if len(vals) != 1 {
+		t.Error("short values are not interned")
+	}
+	if len(long) != 2 || long[0] == long[1] {
+		t.Error("long values are interned")
+	}
+}
+
+func TestEncoderTrailingNewline(t *testing.T) {
+	var buf bytes.Buffer
+	enc := NewEncoder(&buf)
+	enc.SetTrailingNewline(false)
+	for _, v := range []any{[]int{1}, "a"} {
+		if err := enc.Encode(v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	enc.SetIndent(">", "  ")
+	if err := enc.Encode(map[string]int{"a": 1}); err != nil {
+		t.Fatal(err)
+	}
+	enc.SetTrailingNewline(true)
+	if err := enc.Encode(2); err != nil {
+		t.Fatal(err)
+	}
+	const want = "[1]\"a\"{\n>  \"a\": 1\n>}2\n"
+	if got := buf.String(); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	buf.Reset()
+	enc = NewLinesEncoder(&buf)
+	enc.SetTrailingNewline(false)
+	if err := enc.Encode(1); err != nil || buf.String() != "1\n" {
+		t.Errorf("lines encoder: got %q, %v", buf.String(), err)
+	}
+}
+
+func TestEncoderWriteToken(t *testing.T) {
+	var buf bytes.Buffer
+	enc := NewEncoder(&buf)
+	for _, tok := range []Token{Delim('{'), "a", 1.5, "b", Delim('['), true, nil, Number("2")} {
+		if err := enc.WriteToken(tok); err != nil {
+			t.Fatalf("%v: %v", tok, err)
+		}
+	}
+	if err := enc.Encode(OrderedObject{{"z", 1}, {"y", "s"}}); err != nil {
+		t.Fatal(err)
+	}
+	enc.WriteToken(Delim(']'))
+	enc.WriteToken(Delim('}'))
+	enc.WriteToken("s")
+	const want = `{"a":1.5,"b":[true,null,2,{"z":1,"y":"s"}]}` + "\n" + `"s"` + "\n"
+	if buf.String() != want {
+		t.Errorf("got %q, want %q", buf.String(), want)
+	}
+
+	for _, toks := range [][]Token{
+		{Delim('}')},
+		{Delim('['), Delim('}')},
+		{Delim('{'), 1.0},
+		{Delim('{'), "k", Delim('}')},
+		{Delim('(')},
+		{1},
+	} {
+		enc := NewEncoder(io.Discard)
+		var err error
+		for _, tok := range toks {
+			if err = enc.WriteToken(tok); err != nil {
+				break
+			}
+		}
+		if err == nil {
+			t.Errorf("%v: no error", toks)
+		}
+	}
+}
+
+func TestEncoderBuilders(t *testing.T) {
+	var buf bytes.Buffer
+	enc := NewEncoder(&buf)
+	steps := []func() error{
+		enc.BeginObject,
+		func() error { return enc.WriteKey("a<") },
+		func() error { return enc.WriteValue("x\"y") },
+		func() error { return enc.WriteKey("b") },
+		enc.BeginArray,
+		func() error { return enc.WriteValue(1) },
+		enc.BeginObject,
+		enc.End,
+		func() error { return enc.WriteValue([]int{2}) },
+		enc.End,
+		enc.End,
+	}
+	for i, step := range steps {
+		if err := step(); err != nil {
+			t.Fatalf("step %d: %v", i, err)
+		}
+	}
+	const want = `{"a\u003C":"x\u0022y","b":[1,{},[2]]}` + "\n"
+	if buf.String() != want {
+		t.Errorf("got %q, want %q", buf.String(), want)
+	}
+
+	for i, steps := range [][]func(enc *Encoder) error{
+		{(*Encoder).End},
+		{func(enc *Encoder) error { return enc.WriteKey("a") }},
+		{(*Encoder).BeginArray, func(enc *Encoder) error { return enc.WriteKey("a") }},
+		{(*Encoder).BeginObject, func(enc *Encoder) error { return enc.WriteValue(1) }},
+		{(*Encoder).BeginObject, func(enc *Encoder) error { return enc.WriteKey("a") },
+			func(enc *Encoder) error { return enc.WriteKey("b") }},
+		{(*Encoder).BeginObject, func(enc *Encoder) error { return enc.WriteKey("a") }, (*Encoder).End},
+	} {
+		enc := NewEncoder(io.Discard)
+		var err error
+		for _, step := range steps {
+			if err = step(enc); err != nil {
+				break
+			}
+		}
+		if err == nil {
+			t.Errorf("#%d: no error", i)
+		}
+	}
+}
+
+func TestEncoderEncodeRaw(t *testing.T) {
+	for _, validate := range []bool{true, false} {
+		var buf bytes.Buffer
+		enc := NewEncoder(&buf)
+		enc.SetValidateRaw(validate)
+		if err := enc.BeginObject(); err != nil {
+			t.Fatal(err)
+		}
+		if err := enc.WriteKey("a"); err != nil {
+			t.Fatal(err)
+		}
+		if err := enc.EncodeRaw(RawMessage(`[1, "<"]`)); err != nil {
+			t.Fatal(err)
+		}
+		if err := enc.EncodeRaw(RawMessage(`2`)); err == nil {
+			t.Error("member value without name: no error")
+		}
+		if err := enc.WriteKey("b"); err != nil {
+			t.Fatal(err)
+		}
+		if err := enc.EncodeRaw(nil); err != nil {
+			t.Fatal(err)
+		}
+		if err := enc.End(); err != nil {
+			t.Fatal(err)
+		}
+		if err := enc.EncodeRaw(RawMessage(`{}`)); err != nil {
+			t.Fatal(err)
+		}
+		want := `{"a":[1,"\u003C"],"b":null}` + "\n{}\n"
+		if !validate {
+			want = `{"a":[1, "<"],"b":null}` + "\n{}\n"
+		}
+		if buf.String() != want {
+			t.Errorf("validate %t: got %q, want %q", validate, buf.String(), want)
+		}
+	}
+
+	enc := NewEncoder(io.Discard)
+	var se *SyntaxError
+	if err := enc.EncodeRaw(RawMessage(`{"a"}`)); !errors.As(err, &se) {
+		t.Errorf("invalid input: got %v", err)
+	}
+}
+
+func TestEncoderRedactor(t *testing.T) {
+	type Creds struct {
+		User     string
+		Password string `json:"pass,redact"`
+		Key      []byte `json:",omitempty,redact"`
+		Token    *int   `json:",string,redact"`
+	}
+	token := 5
+	v := []Creds{{"u", "secret", []byte{1}, &token}, {"v", "", nil, nil}}
+	for _, tc := range []struct {
+		redactor func(string, any) any
+		want     string
+	}{
+		{nil, `[{"User":"u","pass":"secret","Key":"AQ==","Token":"5"},{"User":"v","pass":"","Token":null}]`},
+		{Redact, `[{"User":"u","pass":"[REDACTED]","Key":"[REDACTED]","Token":"[REDACTED]"},` +
+			`{"User":"v","pass":"[REDACTED]","Token":"[REDACTED]"}]`},
+		{func(name string, v any) any {
+			if s, ok := v.(string); ok {
+				return len(s)
+			}
+			return name
+		}, `[{"User":"u","pass":6,"Key":"Key","Token":"Token"},{"User":"v","pass":0,"Token":"Token"}]`},
+	} {
+		var buf bytes.Buffer
+		enc := NewEncoder(&buf)
+		enc.SetRedactor(tc.redactor)
+		if err := enc.Encode(v); err != nil {
+			t.Fatal(err)
+		}
+		if got := strings.TrimSuffix(buf.String(), "\n"); got != tc.want {
+			t.Errorf("got %s, want %s", got, tc.want)
+		}
+	}
+}
+
+func TestEncoderFieldFilter(t *testing.T) {
+	type Item struct {
+		ID      int    `json:"id"`
+		Details string `json:"details,omitempty"`
+	}
+	type Response struct {
+		Items []Item
+		Debug string
+		Extra OrderedObject `json:",inline"`
+	}
+	v := Response{[]Item{{1, "d"}}, "x", OrderedObject{{"e", 1}}}
+	var calls []string
+	summary := func(st reflect.Type, field string) bool {
+		calls = append(calls, st.Name()+"."+field)
+		return field != "details" && field != "Debug"
+	}
+	var buf bytes.Buffer
+	enc := NewEncoder(&buf)
+	enc.SetFieldFilter(summary)
+	if err := enc.Encode(v); err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"Items":[{"id":1}],"e":1}` + "\n"; buf.String() != want {
+		t.Errorf("got %s, want %s", buf.String(), want)
+	}
+	if want := []string{"Response.Items", "Item.id", "Item.details", "Response.Debug"}; !reflect.DeepEqual(calls, want) {
+		t.Errorf("filter calls: got %q, want %q", calls, want)
+	}
+}
+
+func TestEncoderFieldNames(t *testing.T) {
+	type Tx struct {
+		Hash   string `json:"hash"`
+		Sender string
+		Fee    int `json:"fee,redact"`
+		Meta   map[string]int
+	}
+	var (
+		buf     bytes.Buffer
+		names   []string
+		enc     = NewEncoder(&buf)
+		renames = map[string]string{"hash": "txid", "sender": "from", "fee": "<fee>", "a": "b"}
+	)
+	enc.SetNaming(SnakeCase)
+	enc.SetFieldNames(renames)
+	enc.SetRedactor(func(name string, _ any) any {
+		names = append(names, name)
+		return 0
+	})
+	if err := enc.Encode(Tx{"h", "s", 1, map[string]int{"a": 1}}); err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"txid":"h","from":"s","\u003Cfee\u003E":0,"meta":{"a":1}}` + "\n"; buf.String() != want {
+		t.Errorf("got %s, want %s", buf.String(), want)
+	}
+	if want := []string{"<fee>"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("redactor names: got %q, want %q", names, want)
+	}
+}
+
+func TestEncoderFixedNotation(t *testing.T) {
+	for _, tc := range []struct {
+		v    any
+		want string
+	}{
+		{[]float64{0, 1e20, 1e-8, -123.456, 5e-7}, "[0,100000000000000000000,0.00000001,-123.456,0.0000005]\n"},
+		{float32(1e-7), "0.0000001\n"},
+		{struct {
+			F float64 `json:",string"`
+		}{1e21 - 1e6}, "{\"F\":\"999999999999999000000\"}\n"},
+		{1e22, ""},
+		{[]float64{1, 1e-9}, ""},
+	} {
+		var buf bytes.Buffer
+		enc := NewEncoder(&buf)
+		enc.SetFloatFormat(FloatCSharp)
+		enc.SetFixedNotation(1e-8, 1e22)
+		err := enc.Encode(tc.v)
+		if tc.want == "" {
+			var uve *UnsupportedValueError
+			if !errors.As(err, &uve) {
+				t.Errorf("%v: got error %v", tc.v, err)
+			}
+			continue
+		}
+		if err != nil || buf.String() != tc.want {
+			t.Errorf("%v: got %q, %v, want %q", tc.v, buf.String(), err, tc.want)
+		}
+	}
+}
+
+func TestJSSafeIntegers(t *testing.T) {
+	type T struct {
+		I  int64
+		N  int64
+		U  uint64
+		S  uint64
+		Q  int64 `json:",string"`
+		F  float64
+		Is []int
+	}
+	v := T{1<<53 - 1, -1 << 53, 1 << 63, 42, 1 << 60, 1 << 60, []int{1, 1 << 62}}
+	var buf bytes.Buffer
+	enc := NewEncoder(&buf)
+	enc.SetJSSafeIntegers(true)
+	if err := enc.Encode(v); err != nil {
+		t.Fatal(err)
+	}
+	const want = `{"I":9007199254740991,"N":"-9007199254740992","U":"9223372036854775808","S":42,` +
+		`"Q":"1152921504606846976","F":1152921504606847000,"Is":[1,"4611686018427387904"]}` + "\n"
+	if buf.String() != want {
+		t.Errorf("got %s, want %s", buf.String(), want)
+	}
+
+	var w T
+	if err := Unmarshal(buf.Bytes(), &w); err == nil {
+		t.Error("Unmarshal accepted quoted integers")
+	}
+	dec := NewDecoder(&buf)
+	dec.AllowQuotedIntegers()
+	if err := dec.Decode(&w); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(w, v) {
+		t.Errorf("got %+v, want %+v", w, v)
+	}
+	for _, in := range []string{`{"I":"1.5"}`, `{"I":"1e3"}`, `{"I":"x"}`, `{"F":"1"}`, `{"S":"-1"}`} {
+		dec := NewDecoder(strings.NewReader(in))
+		dec.AllowQuotedIntegers()
+		if err := dec.Decode(new(T)); err == nil {
+			t.Errorf("%s: no error", in)
+		}
+	}
+}
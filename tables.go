@@ -111,6 +111,17 @@ var safeSet = [utf8.RuneSelf]bool{
 	'\u007f': false,
 }
 
+// stdSafeSet and stdHTMLSafeSet are like safeSet and htmlSafeSet, but
+// follow the standard library: only the control characters, the double
+// quote and the backslash (and <, >, & for HTML) need escaping.
+var stdSafeSet, stdHTMLSafeSet = func() (set, html [utf8.RuneSelf]bool) {
+	for b := range set {
+		set[b] = b >= 0x20 && b != '"' && b != '\\'
+		html[b] = set[b] && b != '<' && b != '>' && b != '&'
+	}
+	return
+}()
+
 // htmlSafeSet holds the value true if the ASCII character with the given
 // array position can be safely represented inside a JSON string, embedded
 // inside of HTML <script> tags, without any additional escaping.
@@ -216,3 +227,61 @@ var htmlSafeSet = [utf8.RuneSelf]bool{
 	'~':      true,
 	'\u007f': false,
 }
+
+// escapeTable tells how every byte is written in a string: 0 means as is,
+// escU means as a \u00XX escape, escNonASCII marks the bytes of multi-byte
+// characters that are handled separately, anything else is the character
+// following a backslash in a two-character escape like \n.
+type escapeTable [256]byte
+
+const (
+	escU        = 'u'
+	escNonASCII = 1
+)
+
+// escapeTables holds the escapeTable of every escaping profile, with and
+// without HTML escaping, with and without escaping of '/'.
+var escapeTables = func() (t [ASCIIOnly + 1][2][2]escapeTable) {
+	for p := range t {
+		for html := range t[p] {
+			for slash := range t[p][html] {
+				o := encOpts{escapeHTML: html == 1, extra: &extraOpts{escaping: EscapeProfile(p), escapeSlash: slash == 1}}
+				tab := &t[p][html][slash]
+				for b := range tab {
+					tab[b] = escapeCode(byte(b), o)
+				}
+			}
+		}
+	}
+	return
+}()
+
+// escapeCode returns the escapeTable entry for b with options o.
+func escapeCode(b byte, o encOpts) byte {
+	if b >= utf8.RuneSelf {
+		return escNonASCII
+	}
+	if o.safe(b) {
+		return 0
+	}
+	switch b {
+	case '\\', '/':
+		return b
+	case '"':
+		if o.extras().escaping == NeoCompat {
+			return escU
+		}
+		return b
+	case 0x08:
+		return 'b'
+	case '\n':
+		return 'n'
+	case 0x0c:
+		return 'f'
+	case '\r':
+		return 'r'
+	case '\t':
+		return 't'
+	}
+	return escU
+}
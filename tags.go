@@ -35,3 +35,30 @@ func (o tagOptions) Contains(optionName string) bool {
 	}
 	return false
 }
+
+// Value returns the value of the optionName=value option and whether it's
+// present. The value extends to the end of the tag, so it can contain
+// commas, but such an option must be the last one.
+func (o tagOptions) Value(optionName string) (string, bool) {
+	s := string(o)
+	for {
+		if v, ok := strings.CutPrefix(s, optionName+"="); ok {
+			return v, true
+		}
+		var ok bool
+		if _, s, ok = strings.Cut(s, ","); !ok {
+			return "", false
+		}
+	}
+}
+
+// After returns the rest of the first option starting with prefix and
+// whether there is such an option.
+func (o tagOptions) After(prefix string) (string, bool) {
+	for s := range strings.FieldsFuncSeq(string(o), func(c rune) bool { return c == ',' }) {
+		if v, ok := strings.CutPrefix(s, prefix); ok {
+			return v, true
+		}
+	}
+	return "", false
+}
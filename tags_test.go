@@ -26,3 +26,20 @@ func TestTagParsing(t *testing.T) {
 		}
 	}
 }
+
+func TestTagOptionValue(t *testing.T) {
+	_, opts := parseTag("field,omitempty,default=a,b")
+	for _, tt := range []struct {
+		opt  string
+		want string
+		ok   bool
+	}{
+		{"default", "a,b", true},
+		{"omitempty", "", false},
+		{"fault", "", false},
+	} {
+		if v, ok := opts.Value(tt.opt); v != tt.want || ok != tt.ok {
+			t.Errorf("Value(%q) = %q, %v", tt.opt, v, ok)
+		}
+	}
+}
@@ -0,0 +1,315 @@
+package json
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	timeType     = reflect.TypeFor[time.Time]()
+	durationType = reflect.TypeFor[time.Duration]()
+)
+
+// timeLayouts maps the layout names accepted by the "format" option of
+// time.Time fields to the layouts.
+var timeLayouts = map[string]string{
+	"ANSIC":       time.ANSIC,
+	"UnixDate":    time.UnixDate,
+	"RubyDate":    time.RubyDate,
+	"RFC822":      time.RFC822,
+	"RFC822Z":     time.RFC822Z,
+	"RFC850":      time.RFC850,
+	"RFC1123":     time.RFC1123,
+	"RFC1123Z":    time.RFC1123Z,
+	"RFC3339":     time.RFC3339,
+	"RFC3339Nano": time.RFC3339Nano,
+	"Kitchen":     time.Kitchen,
+	"Stamp":       time.Stamp,
+	"StampMilli":  time.StampMilli,
+	"StampMicro":  time.StampMicro,
+	"StampNano":   time.StampNano,
+	"DateTime":    time.DateTime,
+	"DateOnly":    time.DateOnly,
+	"TimeOnly":    time.TimeOnly,
+}
+
+// timeFormatOf returns the layout or the unix format name given with the
+// "format" option for a field of time.Time or *time.Time type t or the
+// format name for a time.Duration or *time.Duration field, it returns an
+// empty string for other fields.
+func timeFormatOf(opts tagOptions, t reflect.Type) string {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	f, _ := opts.After("format:")
+	switch t {
+	case timeType:
+		if l, ok := timeLayouts[f]; ok {
+			return l
+		}
+		return f
+	case durationType:
+		if durationUnit(f) != 0 || f == "string" {
+			return f
+		}
+	}
+	return ""
+}
+
+// durationUnit returns the number of nanoseconds in the unit of the
+// duration format f or 0 if f is not a numeric format.
+func durationUnit(f string) int64 {
+	switch f {
+	case "sec":
+		return int64(time.Second)
+	case "milli":
+		return int64(time.Millisecond)
+	case "micro":
+		return int64(time.Microsecond)
+	case "nano":
+		return 1
+	}
+	return 0
+}
+
+// unixUnit returns the number of nanoseconds in the unit of the unix time
+// format f or 0 if f is a layout.
+func unixUnit(f string) int64 {
+	switch f {
+	case "unix":
+		return int64(time.Second)
+	case "unixmilli":
+		return int64(time.Millisecond)
+	case "unixmicro":
+		return int64(time.Microsecond)
+	case "unixnano":
+		return 1
+	}
+	return 0
+}
+
+// newTimeEncoder returns an encoder of time.Time, time.Duration and
+// pointers to them (t is the field type) using the given format.
+func newTimeEncoder(t reflect.Type, format string) encoderFunc {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	isTime := t == timeType
+	unit := unixUnit(format)
+	if !isTime {
+		unit = durationUnit(format)
+	}
+	return func(e *encodeState, v reflect.Value, opts encOpts) {
+		if v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				e.WriteString("null")
+				return
+			}
+			v = v.Elem()
+		}
+		switch {
+		case !isTime && unit != 0:
+			e.Write(appendUnits(e.AvailableBuffer(), v.Int(), unit))
+		case !isTime:
+			e.string(time.Duration(v.Int()).String(), opts)
+		case unit != 0:
+			t, _ := reflect.TypeAssert[time.Time](v)
+			e.Write(appendUnixTime(e.AvailableBuffer(), t, unit))
+		default:
+			t, _ := reflect.TypeAssert[time.Time](v)
+			e.string(t.Format(format), opts)
+		}
+	}
+}
+
+// appendUnixTime appends t as a decimal number of units since the Unix
+// epoch, the fractional part is only written if it's not zero.
+func appendUnixTime(b []byte, t time.Time, unit int64) []byte {
+	whole := t.Unix()*(int64(time.Second)/unit) + int64(t.Nanosecond())/unit
+	rem := int64(t.Nanosecond()) % unit
+	if whole < 0 && rem > 0 {
+		whole, rem = -(whole + 1), unit-rem
+		b = append(b, '-')
+	}
+	b = strconv.AppendInt(b, whole, 10)
+	return appendFraction(b, rem, unit)
+}
+
+// appendUnits appends n nanoseconds as a decimal number of units, the
+// fractional part is only written if it's not zero.
+func appendUnits(b []byte, n, unit int64) []byte {
+	u := uint64(n)
+	if n < 0 {
+		b = append(b, '-')
+		u = -u
+	}
+	b = strconv.AppendUint(b, u/uint64(unit), 10)
+	return appendFraction(b, int64(u%uint64(unit)), unit)
+}
+
+// appendFraction appends the fractional part rem/unit without trailing
+// zeros if rem is not zero.
+func appendFraction(b []byte, rem, unit int64) []byte {
+	if rem == 0 {
+		return b
+	}
+	frac := strconv.AppendInt(nil, unit+rem, 10)[1:]
+	b = append(b, '.')
+	return append(b, bytes.TrimRight(frac, "0")...)
+}
+
+// parseUnits parses a decimal number without an exponent into the whole
+// number of units and the remaining nanoseconds, both negated for
+// negative numbers.
+func parseUnits(s string, unit int64) (int64, int64, bool) {
+	neg := strings.HasPrefix(s, "-")
+	s = strings.TrimPrefix(s, "-")
+	ip, fp, _ := strings.Cut(s, ".")
+	width := len(strconv.FormatInt(unit, 10)) - 1
+	if ip == "" || len(fp) > width || !isDigits(ip) || !isDigits(fp) {
+		return 0, 0, false
+	}
+	whole, err := strconv.ParseInt(ip, 10, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+	var frac int64
+	if fp != "" {
+		frac, _ = strconv.ParseInt(fp+strings.Repeat("0", width-len(fp)), 10, 64)
+	}
+	if neg {
+		whole, frac = -whole, -frac
+	}
+	return whole, frac, true
+}
+
+// parseUnixTime parses a decimal number of units since the Unix epoch
+// without an exponent, the result is in UTC.
+func parseUnixTime(s string, unit int64) (time.Time, bool) {
+	whole, frac, ok := parseUnits(s, unit)
+	if !ok {
+		return time.Time{}, false
+	}
+	perSecond := int64(time.Second) / unit
+	return time.Unix(whole/perSecond, whole%perSecond*unit+frac).UTC(), true
+}
+
+// parseDuration parses a decimal number of units without an exponent.
+func parseDuration(s string, unit int64) (time.Duration, bool) {
+	whole, frac, ok := parseUnits(s, unit)
+	if !ok || whole > math.MaxInt64/unit || whole < math.MinInt64/unit {
+		return 0, false
+	}
+	n := whole * unit
+	if frac > 0 && n > math.MaxInt64-frac || frac < 0 && n < math.MinInt64-frac {
+		return 0, false
+	}
+	return time.Duration(n + frac), true
+}
+
+func isDigits(s string) bool {
+	for i := range len(s) {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// timeValue decodes the next value into the time.Time, time.Duration or
+// a pointer to them v using the given format.
+func (d *decodeState) timeValue(v reflect.Value, format string) {
+	start := d.off
+	d.value(reflect.Value{})
+	item := bytes.TrimLeft(d.data[start:d.off], " \t\r\n")
+	if item[0] == 'n' {
+		d.literalStore(nullLiteral, v, false)
+		return
+	}
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		v = v.Elem()
+	}
+	if v.Type() != timeType {
+		d.durationValue(item, v, format)
+		return
+	}
+	var (
+		t    time.Time
+		err  error
+		unit = unixUnit(format)
+	)
+	switch {
+	case unit != 0 && isNumber(item):
+		var ok bool
+		if t, ok = parseUnixTime(string(item), unit); !ok {
+			err = fmt.Errorf("json: invalid %s time %s", format, item)
+		}
+	case unit == 0 && item[0] == '"':
+		s, _ := d.unquoteBytes(item)
+		t, err = time.Parse(format, string(s))
+	default:
+		err = &UnmarshalTypeError{Value: valueKind(item), Type: v.Type(), Offset: int64(d.off)}
+	}
+	if err != nil {
+		d.saveError(err)
+		return
+	}
+	v.Set(reflect.ValueOf(t))
+}
+
+// durationValue stores the duration value item into v.
+func (d *decodeState) durationValue(item []byte, v reflect.Value, format string) {
+	var (
+		dur  time.Duration
+		err  error
+		unit = durationUnit(format)
+	)
+	switch {
+	case unit != 0 && isNumber(item):
+		var ok bool
+		if dur, ok = parseDuration(string(item), unit); !ok {
+			err = fmt.Errorf("json: invalid %s duration %s", format, item)
+		}
+	case unit == 0 && item[0] == '"':
+		s, _ := d.unquoteBytes(item)
+		dur, err = time.ParseDuration(string(s))
+	default:
+		err = &UnmarshalTypeError{Value: valueKind(item), Type: v.Type(), Offset: int64(d.off)}
+	}
+	if err != nil {
+		d.saveError(err)
+		return
+	}
+	v.SetInt(int64(dur))
+}
+
+// isNumber reports whether the JSON value item is a number.
+func isNumber(item []byte) bool {
+	return item[0] == '-' || '0' <= item[0] && item[0] <= '9'
+}
+
+// valueKind returns the name of the kind of the JSON value item for
+// UnmarshalTypeError.
+func valueKind(item []byte) string {
+	switch item[0] {
+	case '{':
+		return "object"
+	case '[':
+		return "array"
+	case '"':
+		return "string"
+	case 't', 'f':
+		return "bool"
+	case 'n':
+		return "null"
+	}
+	return "number"
+}
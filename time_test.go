@@ -0,0 +1,128 @@
+package json
+
+import (
+	"errors"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestTimeFormats(t *testing.T) {
+	type T struct {
+		R   time.Time  `json:"r,format:RFC3339"`
+		U   time.Time  `json:"u,format:unix"`
+		M   *time.Time `json:"m,format:unixmilli"`
+		D   time.Time  `json:"d,format:2006-01-02"`
+		N   *time.Time `json:"n,format:unixnano"`
+		Def time.Time  `json:"def"`
+	}
+	tm := time.Date(2024, 3, 1, 12, 30, 15, 250_000_000, time.UTC)
+	v := T{R: tm, U: tm, M: &tm, D: tm, Def: tm}
+	b, err := Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const want = `{"r":"2024-03-01T12:30:15Z","u":1709296215.25,"m":1709296215250,` +
+		`"d":"2024-03-01","n":null,"def":"2024-03-01T12:30:15.25Z"}`
+	if string(b) != want {
+		t.Errorf("got %s, want %s", b, want)
+	}
+
+	var w T
+	if err := Unmarshal([]byte(want), &w); err != nil {
+		t.Fatal(err)
+	}
+	if !w.R.Equal(tm.Truncate(time.Second)) || !w.U.Equal(tm) || w.M == nil || !w.M.Equal(tm) ||
+		!w.D.Equal(time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)) || w.N != nil || !w.Def.Equal(tm) {
+		t.Errorf("got %+v", w)
+	}
+
+	for _, tc := range []struct {
+		in   string
+		want any
+	}{
+		{`{"u":"1"}`, &UnmarshalTypeError{}},
+		{`{"u":1e3}`, nil},
+		{`{"m":1.5e3}`, nil},
+		{`{"d":20240301}`, &UnmarshalTypeError{}},
+		{`{"d":"2024/03/01"}`, &time.ParseError{}},
+	} {
+		err := Unmarshal([]byte(tc.in), new(T))
+		if err == nil {
+			t.Errorf("%s: no error", tc.in)
+			continue
+		}
+		if tc.want != nil && !errors.As(err, reflect.New(reflect.TypeOf(tc.want)).Interface()) {
+			t.Errorf("%s: got error %T %v", tc.in, err, err)
+		}
+	}
+}
+
+func TestUnixTime(t *testing.T) {
+	for _, tc := range []struct {
+		t    time.Time
+		unit int64
+		want string
+	}{
+		{time.Unix(0, 0), int64(time.Second), "0"},
+		{time.Unix(-1, 500_000_000), int64(time.Second), "-0.5"},
+		{time.Unix(-3, 250_000_000), int64(time.Second), "-2.75"},
+		{time.Unix(-3, 0), int64(time.Millisecond), "-3000"},
+		{time.Unix(1, 1), int64(time.Microsecond), "1000000.001"},
+		{time.Unix(1, 1), 1, "1000000001"},
+	} {
+		got := string(appendUnixTime(nil, tc.t, tc.unit))
+		if got != tc.want {
+			t.Errorf("%v/%d: got %s, want %s", tc.t, tc.unit, got, tc.want)
+		}
+		back, ok := parseUnixTime(got, tc.unit)
+		if !ok || !back.Equal(tc.t) {
+			t.Errorf("%s/%d: parsed %v, %v", got, tc.unit, back, ok)
+		}
+	}
+	for _, s := range []string{"", "-", ".5", "1.0000000001", "+1", "1e3", "--1"} {
+		if _, ok := parseUnixTime(s, int64(time.Second)); ok {
+			t.Errorf("%q: parsed", s)
+		}
+	}
+}
+
+func TestDurationFormats(t *testing.T) {
+	type T struct {
+		N   time.Duration  `json:"n,format:nano"`
+		S   time.Duration  `json:"s,format:sec"`
+		M   *time.Duration `json:"m,format:milli"`
+		Str time.Duration  `json:"str,format:string"`
+		Def time.Duration  `json:"def,format:weeks"`
+	}
+	d := -90*time.Minute - 1500*time.Microsecond
+	v := T{N: d, S: d, M: &d, Str: d, Def: d}
+	b, err := Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const want = `{"n":-5400001500000,"s":-5400.0015,"m":-5400001.5,"str":"-1h30m0.0015s","def":-5400001500000}`
+	if string(b) != want {
+		t.Errorf("got %s, want %s", b, want)
+	}
+	var w T
+	if err := Unmarshal([]byte(want), &w); err != nil {
+		t.Fatal(err)
+	}
+	if w.M == nil || *w.M != d || !reflect.DeepEqual(w, T{d, d, w.M, d, d}) {
+		t.Errorf("got %+v", w)
+	}
+
+	for _, in := range []string{
+		`{"s":"1s"}`, `{"str":1}`, `{"str":"1 hour"}`, `{"n":1.5}`,
+		`{"s":1e3}`, `{"s":9223372037}`, `{"m":0.0000001}`,
+	} {
+		if err := Unmarshal([]byte(in), new(T)); err == nil {
+			t.Errorf("%s: no error", in)
+		}
+	}
+	if err := Unmarshal([]byte(`{"s":9223372036.854775807,"m":null}`), &w); err != nil || w.S != math.MaxInt64 || w.M != nil {
+		t.Errorf("max: got %v, %v, %v", w.S, w.M, err)
+	}
+}
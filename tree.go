@@ -0,0 +1,163 @@
+package json
+
+import (
+	"slices"
+	"strconv"
+)
+
+// NodeKind is a kind of JSON value represented by a Node.
+type NodeKind int
+
+// Kinds of nodes, one per type of JSON values.
+const (
+	NodeNull NodeKind = iota
+	NodeBool
+	NodeNumber
+	NodeString
+	NodeObject
+	NodeArray
+)
+
+var nodeKindNames = [...]string{"null", "bool", "number", "string", "object", "array"}
+
+func (k NodeKind) String() string {
+	if k >= 0 && int(k) < len(nodeKindNames) {
+		return nodeKindNames[k]
+	}
+	return "NodeKind(" + strconv.Itoa(int(k)) + ")"
+}
+
+// A Position is a location in the source document.
+type Position struct {
+	Offset int // byte offset starting from 0
+	Line   int // line number starting from 1
+	Column int // column in bytes starting from 1
+}
+
+func (p Position) String() string {
+	return strconv.Itoa(p.Line) + ":" + strconv.Itoa(p.Column)
+}
+
+// A Node is a JSON value of a document parsed by ParseTree along with its
+// location in it.
+type Node struct {
+	Kind       NodeKind
+	Start, End Position   // the range of the value, End is right after it
+	Raw        RawMessage // the value as parsed, it shares the memory of the document
+	Key        *Node      // the string node of the name of an object member
+	Children   []*Node    // object members or array elements in document order
+
+	src    []byte     // the parsed value
+	orig   []*Node    // the parsed children
+	spans  []nodeSpan // the ranges of the parsed children in src
+	edited bool       // the value is set by SetValue
+}
+
+// nodeSpan is the range of an object member or an array element in the
+// text of its parent.
+type nodeSpan struct {
+	member int // the offset of the key or of the value of an array element
+	keyEnd int // the offset right after the key
+	value  int // the offset of the value
+	end    int // the offset right after the value
+}
+
+// Decode decodes the value of the node (with all the edits made) into the
+// value pointed to by v like Unmarshal does.
+func (n *Node) Decode(v any) error {
+	return Unmarshal(n.Bytes(), v)
+}
+
+// ParseTree checks data to be valid JSON and returns the tree of nodes
+// describing it. Unlike values decoded by Unmarshal, nodes keep the
+// position of every value and key in the document (which is what linters
+// and editors need) and all the members of objects, including the ones with
+// duplicate names, in document order. Invalid input yields a *SyntaxError.
+func ParseTree(data []byte) (*Node, error) {
+	var scan scanner
+	if err := checkValid(data, &scan); err != nil {
+		return nil, err
+	}
+	p := treeParser{data: data, line: 1}
+	n, _ := p.value(skipSpace(data, 0))
+	return n, nil
+}
+
+// treeParser builds nodes of a valid document, positions are cheap to get
+// in increasing order of offsets.
+type treeParser struct {
+	data      []byte
+	off       int // the offset of the last position
+	line      int
+	lineStart int // the offset of the beginning of the line
+}
+
+// position returns the position of data[off].
+func (p *treeParser) position(off int) Position {
+	if off < p.off {
+		p.off, p.line, p.lineStart = 0, 1, 0
+	}
+	for ; p.off < off; p.off++ {
+		if p.data[p.off] == '\n' {
+			p.line++
+			p.lineStart = p.off + 1
+		}
+	}
+	return Position{off, p.line, off - p.lineStart + 1}
+}
+
+// value returns the node of the value starting at data[off] and the offset
+// right after it.
+func (p *treeParser) value(off int) (*Node, int) {
+	data := p.data
+	start := off
+	n := &Node{Start: p.position(off)}
+	switch c := data[off]; c {
+	case '{', '[':
+		n.Kind = NodeArray
+		if c == '{' {
+			n.Kind = NodeObject
+		}
+		n.Children = make([]*Node, 0)
+		off = skipSpace(data, off+1)
+		for data[off] != '}' && data[off] != ']' {
+			var key *Node
+			span := nodeSpan{member: off - start, keyEnd: off - start}
+			if n.Kind == NodeObject {
+				key, off = p.value(off)
+				span.keyEnd = off - start
+				off = skipSpace(data, skipSpace(data, off)+1) // Skip ':'.
+			}
+			var elem *Node
+			span.value = off - start
+			elem, off = p.value(off)
+			span.end = off - start
+			elem.Key = key
+			n.Children = append(n.Children, elem)
+			n.spans = append(n.spans, span)
+			off = skipSpace(data, off)
+			if data[off] == ',' {
+				off = skipSpace(data, off+1)
+			}
+		}
+		off++
+		n.orig = slices.Clone(n.Children)
+	case '"':
+		n.Kind = NodeString
+		off = skipString(data, off)
+	default:
+		switch c {
+		case 'n':
+			n.Kind = NodeNull
+		case 't', 'f':
+			n.Kind = NodeBool
+		default:
+			n.Kind = NodeNumber
+		}
+		off = skipValue(data, off)
+	}
+	n.End = p.position(off)
+	n.Raw = data[start:off:off]
+	n.src = n.Raw
+	return n, off
+}
@@ -0,0 +1,72 @@
+package json
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseTree(t *testing.T) {
+	const in = "{\n  \"a\": [1, true],\n\t\"é\" : {\"b\": null, \"b\": \"x\\n\"},\n  \"c\": []\n}\n"
+	root, err := ParseTree([]byte(in))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var lines []string
+	var walk func(n *Node, indent string)
+	walk = func(n *Node, indent string) {
+		s := fmt.Sprintf("%s%v %v-%v %s", indent, n.Kind, n.Start, n.End, n.Raw)
+		if n.Key != nil {
+			s = fmt.Sprintf("%s%s@%v: %v %v-%v", indent, n.Key.Raw, n.Key.Start, n.Kind, n.Start, n.End)
+			if n.Kind != NodeObject && n.Kind != NodeArray {
+				s += " " + string(n.Raw)
+			}
+		} else if n.Kind == NodeObject || n.Kind == NodeArray {
+			s = fmt.Sprintf("%s%v %v-%v", indent, n.Kind, n.Start, n.End)
+		}
+		lines = append(lines, s)
+		for _, c := range n.Children {
+			walk(c, indent+"  ")
+		}
+	}
+	walk(root, "")
+	want := []string{
+		`object 1:1-5:2`,
+		`  "a"@2:3: array 2:8-2:17`,
+		`    number 2:9-2:10 1`,
+		`    bool 2:12-2:16 true`,
+		`  "é"@3:2: object 3:9-3:32`,
+		`    "b"@3:10: null 3:15-3:19 null`,
+		`    "b"@3:21: string 3:26-3:31 "x\n"`,
+		`  "c"@4:3: array 4:8-4:10`,
+	}
+	if !reflect.DeepEqual(lines, want) {
+		t.Errorf("got\n%s\nwant\n%s", strings.Join(lines, "\n"), strings.Join(want, "\n"))
+	}
+
+	if n := root.Children[1]; n.Start.Offset != strings.Index(in, `{"b"`) || n.End.Offset != strings.Index(in, `},`)+1 {
+		t.Errorf("got offsets %d-%d", n.Start.Offset, n.End.Offset)
+	}
+	var s string
+	if err := root.Children[1].Children[1].Decode(&s); err != nil || s != "x\n" {
+		t.Errorf("Decode returned %q, %v", s, err)
+	}
+	var key string
+	if err := root.Children[1].Key.Decode(&key); err != nil || key != "é" {
+		t.Errorf("Decode of key returned %q, %v", key, err)
+	}
+
+	for _, in := range []string{` -1.5e3 `, `"s"`, `null`} {
+		n, err := ParseTree([]byte(in))
+		if err != nil || string(n.Raw) != strings.TrimSpace(in) || n.Children != nil {
+			t.Errorf("%q: got %+v, %v", in, n, err)
+		}
+	}
+
+	var se *SyntaxError
+	if _, err := ParseTree([]byte(`{"a": [1,]}`)); !errors.As(err, &se) {
+		t.Errorf("invalid input: got error %v", err)
+	}
+}